@@ -0,0 +1,380 @@
+// Package client Отправка метрик сервису из приложения без агента.
+// Значения накапливаются в процессе и периодически отправляются одним запросом /updates/add/
+// в формате агента: JSON, gzip, подпись HashSHA256 и, при наличии ключа, шифрование RSA.
+//
+// Запрос /updates/add/ складывается с сохраненными метриками, как /update/: counter и гистограммы
+// отправляются приращениями с прошлой отправки, gauge - последним значением, если оно изменилось.
+// Поэтому метрики одного арендатора могут отправлять несколько клиентов и агентов.
+//
+//	c := client.New("http://localhost:8080", client.WithHashKey("secret", ""))
+//	go c.Run(ctx)
+//	c.Counter("Requests").Inc()
+//	c.Gauge("QueueLength").Set(12)
+//	c.Histogram("RequestDuration", nil).Observe(0.12)
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/s-turchinskiy/metrics/internal/utils/hashutil"
+	"github.com/s-turchinskiy/metrics/internal/utils/netutil"
+	"github.com/s-turchinskiy/metrics/internal/utils/rsautil"
+	"github.com/s-turchinskiy/metrics/models"
+)
+
+// DefaultFlushInterval Период отправки накопленных значений по умолчанию
+const DefaultFlushInterval = 10 * time.Second
+
+// DefaultBuckets Границы корзин гистограммы по умолчанию, как на сервере
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+const histogramType = "histogram"
+
+// Client Накопление метрик и их отправка на сервер
+type Client struct {
+	url           string
+	httpClient    *http.Client
+	flushInterval time.Duration
+	hashKey       string
+	hashKeyID     string
+	rsaPublicKey  *rsa.PublicKey
+	cryptoKeyID   string
+	apiKey        string
+	errorHandler  func(error)
+	realIP        string
+
+	mutex      sync.Mutex
+	counters   map[string]*Counter
+	gauges     map[string]*Gauge
+	histograms map[string]*Histogram
+
+	flushMutex sync.Mutex // отправки не пересекаются, чтобы значения неудачной отправки вернулись до следующей
+}
+
+// Option Настройка клиента
+type Option func(*Client)
+
+// WithHashKey Ключ подписи HashSHA256 и его идентификатор на сервере, пустой keyID - без идентификатора
+func WithHashKey(key, keyID string) Option {
+	return func(c *Client) {
+		c.hashKey = key
+		c.hashKeyID = keyID
+	}
+}
+
+// WithRSAPublicKey Публичный ключ шифрования тела запроса и его идентификатор на сервере
+func WithRSAPublicKey(key *rsa.PublicKey, keyID string) Option {
+	return func(c *Client) {
+		c.rsaPublicKey = key
+		c.cryptoKeyID = keyID
+	}
+}
+
+// WithAPIKey Ключ API в заголовке Authorization, если на сервере включена проверка ключей
+func WithAPIKey(apiKey string) Option {
+	return func(c *Client) {
+		c.apiKey = apiKey
+	}
+}
+
+// WithFlushInterval Период отправки накопленных значений в Run
+func WithFlushInterval(interval time.Duration) Option {
+	return func(c *Client) {
+		if interval > 0 {
+			c.flushInterval = interval
+		}
+	}
+}
+
+// WithHTTPClient HTTP-клиент, например с настройками TLS
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		if httpClient != nil {
+			c.httpClient = httpClient
+		}
+	}
+}
+
+// WithErrorHandler Обработка ошибок отправки в Run, по умолчанию ошибки отбрасываются.
+// Неотправленные значения остаются в клиенте до следующей отправки
+func WithErrorHandler(handler func(error)) Option {
+	return func(c *Client) {
+		if handler != nil {
+			c.errorHandler = handler
+		}
+	}
+}
+
+// New Создание клиента сервера serverAddress, например http://localhost:8080
+func New(serverAddress string, opts ...Option) *Client {
+
+	c := &Client{
+		url:           strings.TrimRight(serverAddress, "/") + "/updates/add/",
+		httpClient:    &http.Client{Timeout: 10 * time.Second},
+		flushInterval: DefaultFlushInterval,
+		errorHandler:  func(error) {},
+		counters:      make(map[string]*Counter),
+		gauges:        make(map[string]*Gauge),
+		histograms:    make(map[string]*Histogram),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	// адрес для X-Real-IP, по которому сервер проверяет доверенную подсеть
+	if ip, err := netutil.OutboundIP(c.url); err == nil {
+		c.realIP = ip.String()
+	}
+
+	return c
+}
+
+// Counter Счетчик name, при повторном вызове - тот же счетчик
+func (c *Client) Counter(name string) *Counter {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	counter, exist := c.counters[name]
+	if !exist {
+		counter = &Counter{}
+		c.counters[name] = counter
+	}
+
+	return counter
+}
+
+// Gauge Значение name, при повторном вызове - то же значение
+func (c *Client) Gauge(name string) *Gauge {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	gauge, exist := c.gauges[name]
+	if !exist {
+		gauge = &Gauge{}
+		c.gauges[name] = gauge
+	}
+
+	return gauge
+}
+
+// Histogram Гистограмма name с границами корзин bounds, nil - DefaultBuckets.
+// Границы задаются при первом вызове, повторный вызов возвращает ту же гистограмму.
+// Границы не по возрастанию - ошибка программы, вызывает панику
+func (c *Client) Histogram(name string, bounds []float64) *Histogram {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	histogram, exist := c.histograms[name]
+	if exist {
+		return histogram
+	}
+
+	if bounds == nil {
+		bounds = DefaultBuckets
+	}
+	if !sort.SliceIsSorted(bounds, func(i, j int) bool { return bounds[i] < bounds[j] }) {
+		panic("client: histogram bounds must increase for " + name)
+	}
+	for i := 1; i < len(bounds); i++ {
+		if bounds[i] == bounds[i-1] {
+			panic("client: histogram bounds must increase for " + name)
+		}
+	}
+
+	histogram = &Histogram{bounds: append([]float64(nil), bounds...), counts: make([]uint64, len(bounds)+1)}
+	c.histograms[name] = histogram
+
+	return histogram
+}
+
+// Run Отправка накопленных значений каждые flushInterval до отмены ctx, после отмены - последняя отправка
+func (c *Client) Run(ctx context.Context) {
+
+	ticker := time.NewTicker(c.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), c.httpClient.Timeout+time.Second)
+			if err := c.Flush(shutdownCtx); err != nil {
+				c.errorHandler(err)
+			}
+			cancel()
+			return
+		case <-ticker.C:
+			if err := c.Flush(ctx); err != nil {
+				c.errorHandler(err)
+			}
+		}
+	}
+}
+
+// Flush Отправка приращений counter и гистограмм и изменившихся gauge.
+// При ошибке значения возвращаются в клиент и сложатся с новыми при следующей отправке
+func (c *Client) Flush(ctx context.Context) error {
+
+	c.flushMutex.Lock()
+	defer c.flushMutex.Unlock()
+
+	batch := c.collect()
+	if len(batch.metrics) == 0 {
+		return nil
+	}
+
+	if err := c.send(ctx, batch.metrics); err != nil {
+		batch.restore()
+		return err
+	}
+
+	return nil
+}
+
+type batch struct {
+	metrics []metric
+	restore func()
+}
+
+// collect Снятие значений для отправки
+func (c *Client) collect() batch {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var metrics []metric
+	var restores []func()
+	for name, counter := range c.counters {
+		if delta, ok := counter.take(); ok {
+			metrics = append(metrics, metric{ID: name, MType: models.Counter, Delta: &delta})
+			restores = append(restores, func() { counter.Add(delta) })
+		}
+	}
+
+	for name, gauge := range c.gauges {
+		if value, ok := gauge.take(); ok {
+			metrics = append(metrics, metric{ID: name, MType: models.Gauge, Value: &value})
+			restores = append(restores, gauge.restore)
+		}
+	}
+
+	for name, histogram := range c.histograms {
+		if value, ok := histogram.take(); ok {
+			metrics = append(metrics, metric{ID: name, MType: histogramType, Histogram: &value})
+			restores = append(restores, func() { histogram.merge(value) })
+		}
+	}
+
+	return batch{metrics: metrics, restore: func() {
+		for _, restore := range restores {
+			restore()
+		}
+	}}
+}
+
+// send Запрос /updates/add/: JSON сжимается gzip, затем шифруется, подписывается итоговое тело
+func (c *Client) send(ctx context.Context, metrics []metric) error {
+
+	data, err := json.Marshal(metrics)
+	if err != nil {
+		return fmt.Errorf("marshal metrics: %w", err)
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err = zw.Write(data); err != nil {
+		return fmt.Errorf("compress metrics: %w", err)
+	}
+	if err = zw.Close(); err != nil {
+		return fmt.Errorf("compress metrics: %w", err)
+	}
+	body := buf.Bytes()
+
+	var wrappedKey string
+	if c.rsaPublicKey != nil {
+		body, wrappedKey, err = rsautil.EncryptHybrid(c.rsaPublicKey, body)
+		if err != nil {
+			return fmt.Errorf("encrypt metrics: %w", err)
+		}
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Content-Encoding", "gzip")
+
+	if wrappedKey != "" {
+		request.Header.Set(rsautil.EncryptedKeyHeader, wrappedKey)
+		if c.cryptoKeyID != "" {
+			request.Header.Set(rsautil.KeyIDHeader, c.cryptoKeyID)
+		}
+	}
+
+	if c.hashKey != "" {
+		// метка времени, nonce, метод и URI подписываются вместе с телом, повтор запроса сервер отклонит
+		timestamp, nonce, err := hashutil.NewReplayValues(time.Now())
+		if err != nil {
+			return err
+		}
+		request.Header.Set(hashutil.TimestampHeader, timestamp)
+		request.Header.Set(hashutil.NonceHeader, nonce)
+		request.Header.Set("HashSHA256", hashutil.СomputeHexadecimalSha256Hash(c.hashKey, hashutil.SignedData(request.Method, request.URL.RequestURI(), timestamp, nonce, body)))
+		if c.hashKeyID != "" {
+			request.Header.Set(hashutil.KeyIDHeader, c.hashKeyID)
+		}
+	}
+
+	if c.apiKey != "" {
+		request.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	if c.realIP != "" {
+		request.Header.Set("X-Real-IP", c.realIP)
+	}
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("send metrics to %s: %w", c.url, err)
+	}
+	defer response.Body.Close()
+
+	responseBody, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("send metrics to %s: status %d: %s", c.url, response.StatusCode, strings.TrimSpace(string(responseBody)))
+	}
+
+	return nil
+}
+
+// metric Метрика в формате запроса /updates/add/
+type metric struct {
+	ID        string          `json:"id"`
+	MType     string          `json:"type"`
+	Delta     *int64          `json:"delta,omitempty"`
+	Value     *float64        `json:"value,omitempty"`
+	Histogram *histogramValue `json:"histogram,omitempty"`
+}
+
+// histogramValue Гистограмма в формате сервера: Counts по корзинам, не накопленные, последняя - до +Inf
+type histogramValue struct {
+	Bounds []float64 `json:"bounds"`
+	Counts []uint64  `json:"counts"`
+	Sum    float64   `json:"sum"`
+	Count  uint64    `json:"count"`
+}
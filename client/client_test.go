@@ -0,0 +1,190 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/s-turchinskiy/metrics/internal/utils/hashutil"
+	"github.com/s-turchinskiy/metrics/internal/utils/rsautil"
+)
+
+// testServer Прием /updates/add/ с проверкой подписи, расшифровкой и распаковкой как на сервере
+type testServer struct {
+	t          *testing.T
+	hashKey    string
+	privateKey *rsa.PrivateKey
+	status     int
+	requests   [][]metric
+	realIPs    []string
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	assert.Equal(s.t, "/updates/add/", r.URL.Path)
+	s.realIPs = append(s.realIPs, r.Header.Get("X-Real-IP"))
+
+	body, err := io.ReadAll(r.Body)
+	require.NoError(s.t, err)
+
+	if s.hashKey != "" {
+		data := hashutil.SignedData(r.Method, r.URL.RequestURI(), r.Header.Get(hashutil.TimestampHeader), r.Header.Get(hashutil.NonceHeader), body)
+		assert.Equal(s.t, hashutil.СomputeHexadecimalSha256Hash(s.hashKey, data), r.Header.Get("HashSHA256"))
+	}
+
+	if s.privateKey != nil {
+		body, err = rsautil.DecryptHybrid(s.privateKey, r.Header.Get(rsautil.EncryptedKeyHeader), body)
+		require.NoError(s.t, err)
+	}
+
+	assert.Equal(s.t, "gzip", r.Header.Get("Content-Encoding"))
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	require.NoError(s.t, err)
+	data, err := io.ReadAll(zr)
+	require.NoError(s.t, err)
+
+	var metrics []metric
+	require.NoError(s.t, json.Unmarshal(data, &metrics))
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].ID < metrics[j].ID })
+	s.requests = append(s.requests, metrics)
+
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+	}
+}
+
+func TestClient_Flush(t *testing.T) {
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		hashKey    string
+		privateKey *rsa.PrivateKey
+	}{
+		{name: "без подписи и шифрования"},
+		{name: "подпись", hashKey: "secret"},
+		{name: "подпись и шифрование", hashKey: "secret", privateKey: privateKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			handler := &testServer{t: t, hashKey: tt.hashKey, privateKey: tt.privateKey}
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			opts := []Option{WithHashKey(tt.hashKey, "")}
+			if tt.privateKey != nil {
+				opts = append(opts, WithRSAPublicKey(&tt.privateKey.PublicKey, ""))
+			}
+			c := New(server.URL, opts...)
+
+			c.Counter("Requests").Inc()
+			c.Counter("Requests").Add(2)
+			c.Gauge("QueueLength").Set(5)
+			c.Gauge("QueueLength").Set(3)
+			c.Histogram("Duration", []float64{0.1, 1}).Observe(0.05)
+			c.Histogram("Duration", nil).Observe(2)
+
+			require.NoError(t, c.Flush(context.Background()))
+			require.NoError(t, c.Flush(context.Background()), "пустая отправка без запроса")
+
+			require.Len(t, handler.requests, 1)
+			assert.Equal(t, "127.0.0.1", handler.realIPs[0], "адрес для проверки доверенной подсети")
+			metrics := handler.requests[0]
+			require.Len(t, metrics, 3)
+
+			assert.Equal(t, "histogram", metrics[0].MType)
+			require.NotNil(t, metrics[0].Histogram)
+			assert.Equal(t, []float64{0.1, 1}, metrics[0].Histogram.Bounds)
+			assert.Equal(t, []uint64{1, 0, 1}, metrics[0].Histogram.Counts)
+			assert.InDelta(t, 2.05, metrics[0].Histogram.Sum, 1e-9)
+			assert.Equal(t, uint64(2), metrics[0].Histogram.Count)
+			assert.Equal(t, "gauge", metrics[1].MType)
+			assert.Equal(t, 3.0, *metrics[1].Value)
+			assert.Equal(t, "counter", metrics[2].MType)
+			assert.Equal(t, int64(3), *metrics[2].Delta)
+
+			c.Counter("Requests").Inc()
+			require.NoError(t, c.Flush(context.Background()))
+
+			require.Len(t, handler.requests, 2)
+			metrics = handler.requests[1]
+			require.Len(t, metrics, 1, "неизмененные gauge и гистограммы не отправляются")
+			assert.Equal(t, int64(1), *metrics[0].Delta, "counter отправляется приращением")
+		})
+	}
+}
+
+func TestClient_FlushHistogramOnly(t *testing.T) {
+
+	handler := &testServer{t: t}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	c := New(server.URL)
+	c.Counter("Requests").Inc()
+	require.NoError(t, c.Flush(context.Background()))
+
+	c.Histogram("Duration", nil).Observe(0.3)
+	require.NoError(t, c.Flush(context.Background()))
+
+	require.Len(t, handler.requests, 2)
+	require.Len(t, handler.requests[1], 1, "неизмененные gauge и counter не отправляются")
+	assert.Equal(t, "histogram", handler.requests[1][0].MType)
+}
+
+func TestClient_FlushError(t *testing.T) {
+
+	handler := &testServer{t: t, status: http.StatusInternalServerError}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	c := New(server.URL)
+	c.Counter("Requests").Add(2)
+	c.Gauge("QueueLength").Set(5)
+	c.Gauge("Workers").Set(4)
+	c.Histogram("Duration", []float64{1}).Observe(0.5)
+
+	require.Error(t, c.Flush(context.Background()))
+	require.Error(t, c.Flush(context.Background()), "значения неудачной отправки повторяются")
+
+	c.Counter("Requests").Add(3)
+	c.Gauge("QueueLength").Set(7)
+	c.Histogram("Duration", nil).Observe(2)
+
+	handler.status = 0
+	require.NoError(t, c.Flush(context.Background()))
+
+	require.Len(t, handler.requests, 3)
+	metrics := handler.requests[2]
+	require.Len(t, metrics, 4)
+	assert.Equal(t, &histogramValue{Bounds: []float64{1}, Counts: []uint64{1, 1}, Sum: 2.5, Count: 2}, metrics[0].Histogram, "значения неудачной отправки сложены с новыми")
+	assert.Equal(t, 7.0, *metrics[1].Value, "новое значение gauge не затерто")
+	assert.Equal(t, int64(5), *metrics[2].Delta, "приращения неудачной отправки сложены с новыми")
+	assert.Equal(t, 4.0, *metrics[3].Value, "gauge неудачной отправки повторен")
+
+	require.NoError(t, c.Flush(context.Background()))
+	assert.Len(t, handler.requests, 3, "отправленные значения не повторяются")
+}
+
+func TestClient_HistogramBounds(t *testing.T) {
+
+	c := New("http://localhost:8080")
+	assert.Panics(t, func() { c.Histogram("Duration", []float64{1, 0.5}) })
+	assert.Panics(t, func() { c.Histogram("Duration", []float64{1, 1}) })
+	assert.NotPanics(t, func() { c.Histogram("Duration", nil) })
+}
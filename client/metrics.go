@@ -0,0 +1,130 @@
+package client
+
+import (
+	"sort"
+	"sync"
+)
+
+// Counter Счетчик, на сервер отправляется приращение с прошлой отправки
+type Counter struct {
+	mutex sync.Mutex
+	delta int64
+	dirty bool
+}
+
+// Add Увеличение счетчика на delta
+func (c *Counter) Add(delta int64) {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.delta += delta
+	c.dirty = true
+}
+
+// Inc Увеличение счетчика на 1
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// take Приращение с прошлой отправки, false - счетчик не изменялся
+func (c *Counter) take() (int64, bool) {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.dirty {
+		return 0, false
+	}
+
+	delta := c.delta
+	c.delta, c.dirty = 0, false
+
+	return delta, true
+}
+
+// Gauge Значение, на сервер отправляется последнее установленное, если оно изменилось с прошлой отправки
+type Gauge struct {
+	mutex sync.Mutex
+	value float64
+	dirty bool
+}
+
+// Set Установка значения
+func (g *Gauge) Set(value float64) {
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.value = value
+	g.dirty = true
+}
+
+// take Значение для отправки, false - не изменялось с прошлой отправки
+func (g *Gauge) take() (float64, bool) {
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	dirty := g.dirty
+	g.dirty = false
+
+	return g.value, dirty
+}
+
+// restore Повтор значения при следующей отправке после ошибки. Значение, установленное после take, новее
+func (g *Gauge) restore() {
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.dirty = true
+}
+
+// Histogram Распределение значений по корзинам, на сервер отправляются значения с прошлой отправки
+type Histogram struct {
+	mutex  sync.Mutex
+	bounds []float64
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// Observe Добавление значения
+func (h *Histogram) Observe(value float64) {
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.counts[sort.SearchFloat64s(h.bounds, value)]++
+	h.sum += value
+	h.count++
+}
+
+func (h *Histogram) take() (histogramValue, bool) {
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.count == 0 {
+		return histogramValue{}, false
+	}
+
+	value := histogramValue{Bounds: h.bounds, Counts: h.counts, Sum: h.sum, Count: h.count}
+	h.counts = make([]uint64, len(h.bounds)+1)
+	h.sum, h.count = 0, 0
+
+	return value, true
+}
+
+func (h *Histogram) merge(value histogramValue) {
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for i, count := range value.Counts {
+		h.counts[i] += count
+	}
+	h.sum += value.Sum
+	h.count += value.Count
+}
@@ -3,11 +3,14 @@ package config
 
 import (
 	"crypto/rsa"
+	"crypto/tls"
 	"errors"
 	"flag"
 	"fmt"
+	"github.com/s-turchinskiy/metrics/internal/agent/collector"
 	"github.com/s-turchinskiy/metrics/internal/utils/configutil"
 	"github.com/s-turchinskiy/metrics/internal/utils/rsautil"
+	"github.com/s-turchinskiy/metrics/internal/utils/tlsutil"
 	"os"
 	"runtime"
 	"strconv"
@@ -19,14 +22,48 @@ type NetAddress struct {
 	Port int
 }
 
+const (
+	TransportHTTP = "http"
+	TransportGRPC = "grpc"
+)
+
+// Режимы отправки метрик
+const (
+	ReportModeSingle = "single" // по одной метрике
+	ReportModeBatch  = "batch"  // пачками, по HTTP - сжатыми gzip
+)
+
 type ProgramConfig struct {
 	Addr             *NetAddress
+	GRPCAddr         *NetAddress
+	Transport        string //Способ отправки метрик на сервер: http или grpc
+	ReportMode       string //Режим отправки метрик: single или batch
+	BatchMaxSize     int    //Максимальный размер пачки метрик в байтах JSON, 0 - все метрики одной пачкой
 	PollInterval     int
 	ReportInterval   int
+	collectors       string
+	Collectors       map[string]collector.Setting //Настройки коллекторов метрик: включен ли и интервал сбора
+	processNames     string
+	CollectorOptions collector.Options //Параметры встроенных коллекторов
+	execConfigPath   string
+	ExecCommands     []collector.ExecCommand //Команды, вывод которых собирается как метрики
+	LocalHTTPAddr    string                  //Адрес приема метрик от приложений хоста по HTTP, пустая строка отключает прием
+	LocalUDPAddr     string                  //Адрес приема метрик от приложений хоста по UDP, пустая строка отключает прием
+	LocalGaugeTTL    int                     //Время жизни gauge приложений хоста без обновления в секундах, 0 - без ограничения
 	HashKey          string
-	RateLimit        int //Количество одновременно исходящих запросов на сервер
+	HashKeyID        string //Идентификатор ключа HashSHA256 для ротации ключей на сервере
+	APIKey           string //Ключ API для заголовка Authorization и метаданных gRPC authorization
+	RateLimit        int    //Количество одновременно исходящих запросов на сервер
 	rsaPublicKeyPath string
 	RSAPublicKey     *rsa.PublicKey
+	CryptoKeyID      string //Идентификатор ключа RSA для ротации ключей на сервере
+	tlsCAPath        string
+	tlsCertPath      string
+	tlsKeyPath       string
+	TLSConfig        *tls.Config //mTLS: сертификат агента и CA, которым проверяется сервер
+	SpoolDir         string      //Каталог очереди неотправленных метрик, пустая строка отключает очередь
+	SpoolMaxSize     int64       //Максимальный размер очереди в байтах
+	SpoolMaxAge      int         //Максимальный возраст метрики в очереди в секундах
 }
 
 func ParseFlags() (*ProgramConfig, error) {
@@ -34,6 +71,12 @@ func ParseFlags() (*ProgramConfig, error) {
 	cfg := ProgramConfig{}
 
 	cfg.Addr = &NetAddress{Host: "localhost", Port: 8080}
+	cfg.GRPCAddr = &NetAddress{Host: "localhost", Port: 3200}
+	cfg.Transport = TransportHTTP
+	cfg.ReportMode = ReportModeSingle
+	cfg.SpoolMaxSize = 10 * 1024 * 1024
+	cfg.SpoolMaxAge = 3600
+	cfg.LocalGaugeTTL = 300
 
 	configFilePath := configutil.GetConfigFilePath()
 	if configFilePath != "" {
@@ -43,11 +86,30 @@ func ParseFlags() (*ProgramConfig, error) {
 	}
 
 	flag.Var(cfg.Addr, "a", "Net address host:port")
+	flag.Var(cfg.GRPCAddr, "grpc-address", "gRPC server net address host:port")
+	flag.StringVar(&cfg.Transport, "transport", cfg.Transport, "transport to send metrics: http or grpc")
+	flag.StringVar(&cfg.ReportMode, "report-mode", cfg.ReportMode, "Режим отправки метрик: single - по одной, batch - пачками")
+	flag.IntVar(&cfg.BatchMaxSize, "batch-max-size", cfg.BatchMaxSize, "Максимальный размер пачки метрик в байтах JSON, 0 - все метрики одной пачкой")
 	flag.IntVar(&cfg.PollInterval, "p", 2, "poll interval")
 	flag.IntVar(&cfg.ReportInterval, "r", 10, "report interval")
+	flag.StringVar(&cfg.collectors, "collectors", cfg.collectors, "Настройки коллекторов метрик: <имя>:<on|off|интервал в секундах>,...")
 	flag.StringVar(&cfg.HashKey, "k", "", "HashSHA256 key")
+	flag.StringVar(&cfg.HashKeyID, "key-id", cfg.HashKeyID, "HashSHA256 key id")
+	flag.StringVar(&cfg.APIKey, "api-key", cfg.APIKey, "API key for Authorization header and gRPC authorization metadata")
+	flag.StringVar(&cfg.processNames, "process-names", cfg.processNames, "Имена процессов через запятую для коллектора process")
+	flag.StringVar(&cfg.execConfigPath, "exec-config", cfg.execConfigPath, "Путь к JSON-файлу с командами, вывод которых собирается как метрики")
+	flag.StringVar(&cfg.LocalHTTPAddr, "local-http", cfg.LocalHTTPAddr, "Адрес приема метрик от приложений хоста по HTTP, например 127.0.0.1:8125")
+	flag.StringVar(&cfg.LocalUDPAddr, "local-udp", cfg.LocalUDPAddr, "Адрес приема метрик от приложений хоста по UDP, например 127.0.0.1:8125")
+	flag.IntVar(&cfg.LocalGaugeTTL, "local-gauge-ttl", cfg.LocalGaugeTTL, "Время жизни gauge приложений хоста без обновления в секундах, 0 - без ограничения")
 	flag.IntVar(&cfg.RateLimit, "l", runtime.NumCPU(), "number of concurrently outgoing requests to server")
 	flag.StringVar(&cfg.rsaPublicKeyPath, "crypto-key", "", "Путь до файла с публичным ключом")
+	flag.StringVar(&cfg.CryptoKeyID, "crypto-key-id", cfg.CryptoKeyID, "Идентификатор ключа RSA на сервере")
+	flag.StringVar(&cfg.tlsCAPath, "tls-ca", cfg.tlsCAPath, "Путь к сертификату CA сервера, включает mTLS")
+	flag.StringVar(&cfg.tlsCertPath, "tls-cert", cfg.tlsCertPath, "Путь к сертификату агента для mTLS")
+	flag.StringVar(&cfg.tlsKeyPath, "tls-key", cfg.tlsKeyPath, "Путь к приватному ключу сертификата агента для mTLS")
+	flag.StringVar(&cfg.SpoolDir, "spool-dir", cfg.SpoolDir, "Каталог очереди неотправленных метрик, пустая строка отключает очередь")
+	flag.Int64Var(&cfg.SpoolMaxSize, "spool-max-size", cfg.SpoolMaxSize, "Максимальный размер очереди неотправленных метрик в байтах")
+	flag.IntVar(&cfg.SpoolMaxAge, "spool-max-age", cfg.SpoolMaxAge, "Максимальный возраст метрики в очереди в секундах")
 	flag.Parse()
 
 	if envAddr := os.Getenv("ADDRESS"); envAddr != "" {
@@ -57,6 +119,38 @@ func ParseFlags() (*ProgramConfig, error) {
 		}
 	}
 
+	if envAddr := os.Getenv("GRPC_ADDRESS"); envAddr != "" {
+		err := cfg.GRPCAddr.Set(envAddr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if value := os.Getenv("TRANSPORT"); value != "" {
+		cfg.Transport = value
+	}
+
+	if cfg.Transport != TransportHTTP && cfg.Transport != TransportGRPC {
+		return nil, fmt.Errorf("unknown transport %s, need %s or %s", cfg.Transport, TransportHTTP, TransportGRPC)
+	}
+
+	if value := os.Getenv("REPORT_MODE"); value != "" {
+		cfg.ReportMode = value
+	}
+
+	if cfg.ReportMode != ReportModeSingle && cfg.ReportMode != ReportModeBatch {
+		return nil, fmt.Errorf("unknown report mode %s, need %s or %s", cfg.ReportMode, ReportModeSingle, ReportModeBatch)
+	}
+
+	if valueStr := os.Getenv("BATCH_MAX_SIZE"); valueStr != "" {
+		value, err := strconv.Atoi(valueStr)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.BatchMaxSize = value
+	}
+
 	if envPollInterval := os.Getenv("POLL_INTERVAL"); envPollInterval != "" {
 		value, err := strconv.Atoi(envPollInterval)
 		if err != nil {
@@ -75,6 +169,54 @@ func ParseFlags() (*ProgramConfig, error) {
 		cfg.ReportInterval = value
 	}
 
+	if value := os.Getenv("COLLECTORS"); value != "" {
+		cfg.collectors = value
+	}
+
+	var err error
+	cfg.Collectors, err = collector.ParseSettings(cfg.collectors)
+	if err != nil {
+		return nil, fmt.Errorf("collectors: %w", err)
+	}
+
+	if value := os.Getenv("PROCESS_NAMES"); value != "" {
+		cfg.processNames = value
+	}
+
+	for _, name := range strings.Split(cfg.processNames, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			cfg.CollectorOptions.ProcessNames = append(cfg.CollectorOptions.ProcessNames, name)
+		}
+	}
+
+	if value := os.Getenv("LOCAL_HTTP_ADDRESS"); value != "" {
+		cfg.LocalHTTPAddr = value
+	}
+
+	if value := os.Getenv("LOCAL_UDP_ADDRESS"); value != "" {
+		cfg.LocalUDPAddr = value
+	}
+
+	if valueStr := os.Getenv("LOCAL_GAUGE_TTL"); valueStr != "" {
+		value, err := strconv.Atoi(valueStr)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.LocalGaugeTTL = value
+	}
+
+	if value := os.Getenv("EXEC_CONFIG"); value != "" {
+		cfg.execConfigPath = value
+	}
+
+	if cfg.execConfigPath != "" {
+		cfg.ExecCommands, err = collector.LoadExecCommands(cfg.execConfigPath)
+		if err != nil {
+			return nil, fmt.Errorf("exec config: %w", err)
+		}
+	}
+
 	if valueStr := os.Getenv("RATE_LIMIT"); valueStr != "" {
 		value, err := strconv.Atoi(valueStr)
 		if err != nil {
@@ -84,16 +226,68 @@ func ParseFlags() (*ProgramConfig, error) {
 		cfg.RateLimit = value
 	}
 
+	if value := os.Getenv("SPOOL_DIR"); value != "" {
+		cfg.SpoolDir = value
+	}
+
+	if valueStr := os.Getenv("SPOOL_MAX_SIZE"); valueStr != "" {
+		value, err := strconv.ParseInt(valueStr, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.SpoolMaxSize = value
+	}
+
+	if valueStr := os.Getenv("SPOOL_MAX_AGE"); valueStr != "" {
+		value, err := strconv.Atoi(valueStr)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.SpoolMaxAge = value
+	}
+
 	if value := os.Getenv("KEY"); value != "" {
 		cfg.HashKey = value
 	}
 
+	if value := os.Getenv("KEY_ID"); value != "" {
+		cfg.HashKeyID = value
+	}
+
+	if value := os.Getenv("API_KEY"); value != "" {
+		cfg.APIKey = value
+	}
+
 	if value := os.Getenv("CRYPTO_KEY"); value != "" {
 		cfg.rsaPublicKeyPath = value
 	}
 
+	if value := os.Getenv("CRYPTO_KEY_ID"); value != "" {
+		cfg.CryptoKeyID = value
+	}
+
+	if value := os.Getenv("TLS_CA_CERT"); value != "" {
+		cfg.tlsCAPath = value
+	}
+
+	if value := os.Getenv("TLS_CERT"); value != "" {
+		cfg.tlsCertPath = value
+	}
+
+	if value := os.Getenv("TLS_KEY"); value != "" {
+		cfg.tlsKeyPath = value
+	}
+
+	if cfg.tlsCAPath != "" {
+		cfg.TLSConfig, err = tlsutil.ClientConfig(cfg.tlsCAPath, cfg.tlsCertPath, cfg.tlsKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("mTLS: %w", err)
+		}
+	}
+
 	if cfg.rsaPublicKeyPath != "" {
-		var err error
 		cfg.RSAPublicKey, err = rsautil.ReadPublicKey(cfg.rsaPublicKeyPath)
 		if err != nil {
 			err = fmt.Errorf("path: %s, error: %w", cfg.rsaPublicKeyPath, err)
@@ -7,9 +7,28 @@ import (
 
 type JSONConfig struct {
 	Address        string `json:"address,omitempty"`
+	GRPCAddress    string `json:"grpc_address,omitempty"`
+	Transport      string `json:"transport,omitempty"`
+	ReportMode     string `json:"report_mode,omitempty"`
+	BatchMaxSize   int    `json:"batch_max_size,omitempty"`
 	ReportInterval string `json:"report_interval,omitempty"`
 	PollInterval   string `json:"poll_interval,omitempty"`
+	Collectors     string `json:"collectors,omitempty"`
+	ProcessNames   string `json:"process_names,omitempty"`
+	ExecConfig     string `json:"exec_config,omitempty"`
+	LocalHTTP      string `json:"local_http_address,omitempty"`
+	LocalUDP       string `json:"local_udp_address,omitempty"`
+	LocalGaugeTTL  string `json:"local_gauge_ttl,omitempty"`
 	CryptoKey      string `json:"crypto_key,omitempty"`
+	KeyID          string `json:"key_id,omitempty"`
+	CryptoKeyID    string `json:"crypto_key_id,omitempty"`
+	APIKey         string `json:"api_key,omitempty"`
+	TLSCACert      string `json:"tls_ca_cert,omitempty"`
+	TLSCert        string `json:"tls_cert,omitempty"`
+	TLSKey         string `json:"tls_key,omitempty"`
+	SpoolDir       string `json:"spool_dir,omitempty"`
+	SpoolMaxSize   int64  `json:"spool_max_size,omitempty"`
+	SpoolMaxAge    string `json:"spool_max_age,omitempty"`
 }
 
 func loadConfigFromJSON(config *ProgramConfig, filePath string) error {
@@ -25,10 +44,97 @@ func loadConfigFromJSON(config *ProgramConfig, filePath string) error {
 		}
 	}
 
+	if jsonConfig.GRPCAddress != "" {
+		err := config.GRPCAddr.Set(jsonConfig.GRPCAddress)
+		if err != nil {
+			return err
+		}
+	}
+
+	if jsonConfig.Transport != "" {
+		config.Transport = jsonConfig.Transport
+	}
+
+	if jsonConfig.ReportMode != "" {
+		config.ReportMode = jsonConfig.ReportMode
+	}
+
+	if jsonConfig.BatchMaxSize != 0 {
+		config.BatchMaxSize = jsonConfig.BatchMaxSize
+	}
+
 	if jsonConfig.CryptoKey != "" {
 		config.rsaPublicKeyPath = jsonConfig.CryptoKey
 	}
 
+	if jsonConfig.KeyID != "" {
+		config.HashKeyID = jsonConfig.KeyID
+	}
+
+	if jsonConfig.CryptoKeyID != "" {
+		config.CryptoKeyID = jsonConfig.CryptoKeyID
+	}
+
+	if jsonConfig.TLSCACert != "" {
+		config.tlsCAPath = jsonConfig.TLSCACert
+	}
+
+	if jsonConfig.TLSCert != "" {
+		config.tlsCertPath = jsonConfig.TLSCert
+	}
+
+	if jsonConfig.TLSKey != "" {
+		config.tlsKeyPath = jsonConfig.TLSKey
+	}
+
+	if jsonConfig.APIKey != "" {
+		config.APIKey = jsonConfig.APIKey
+	}
+
+	if jsonConfig.Collectors != "" {
+		config.collectors = jsonConfig.Collectors
+	}
+
+	if jsonConfig.ProcessNames != "" {
+		config.processNames = jsonConfig.ProcessNames
+	}
+
+	if jsonConfig.ExecConfig != "" {
+		config.execConfigPath = jsonConfig.ExecConfig
+	}
+
+	if jsonConfig.LocalHTTP != "" {
+		config.LocalHTTPAddr = jsonConfig.LocalHTTP
+	}
+
+	if jsonConfig.LocalUDP != "" {
+		config.LocalUDPAddr = jsonConfig.LocalUDP
+	}
+
+	if jsonConfig.LocalGaugeTTL != "" {
+		seconds, err := timeutils.ParseDurationFromString(jsonConfig.LocalGaugeTTL)
+		if err != nil {
+			return err
+		}
+		config.LocalGaugeTTL = seconds
+	}
+
+	if jsonConfig.SpoolDir != "" {
+		config.SpoolDir = jsonConfig.SpoolDir
+	}
+
+	if jsonConfig.SpoolMaxSize != 0 {
+		config.SpoolMaxSize = jsonConfig.SpoolMaxSize
+	}
+
+	if jsonConfig.SpoolMaxAge != "" {
+		seconds, err := timeutils.ParseDurationFromString(jsonConfig.SpoolMaxAge)
+		if err != nil {
+			return err
+		}
+		config.SpoolMaxAge = seconds
+	}
+
 	if jsonConfig.ReportInterval != "" {
 		seconds, err := timeutils.ParseDurationFromString(jsonConfig.ReportInterval)
 		if err != nil {
@@ -3,10 +3,13 @@ package main
 import (
 	"context"
 	"fmt"
+	"github.com/s-turchinskiy/metrics/internal/agent/services/sendmetric"
+	"github.com/s-turchinskiy/metrics/internal/agent/services/sendmetric/grpcsender"
 	"github.com/s-turchinskiy/metrics/internal/agent/services/sendmetric/httpresty"
 	"github.com/s-turchinskiy/metrics/internal/utils/closerutil"
 	"github.com/s-turchinskiy/metrics/internal/utils/hashutil"
 	"log"
+	"net"
 	"os/signal"
 	"sync"
 	"syscall"
@@ -15,10 +18,13 @@ import (
 	"github.com/joho/godotenv"
 
 	"github.com/s-turchinskiy/metrics/cmd/agent/config"
+	"github.com/s-turchinskiy/metrics/internal/agent/collector"
+	"github.com/s-turchinskiy/metrics/internal/agent/ingest"
 	"github.com/s-turchinskiy/metrics/internal/agent/logger"
 	"github.com/s-turchinskiy/metrics/internal/agent/reporter"
 	"github.com/s-turchinskiy/metrics/internal/agent/repositories"
 	"github.com/s-turchinskiy/metrics/internal/agent/services"
+	"github.com/s-turchinskiy/metrics/internal/agent/spool"
 )
 
 // go run -ldflags "-X main.buildVersion=v1.0.1 -X main.buildDate=20.10.2025 -X main.buildCommit=Comment"
@@ -50,44 +56,122 @@ func main() {
 	defer stop()
 	closer := closerutil.New(20 * time.Second)
 
+	scheme := "http://"
+	if cfg.TLSConfig != nil {
+		scheme = "https://"
+	}
+
 	metricsHandler := &services.MetricsHandler{
 		Storage: &repositories.MetricsStorage{
-			Gauge:   make(map[string]float64),
-			Counter: make(map[string]int64),
+			Gauge:     make(map[string]float64),
+			Counter:   make(map[string]int64),
+			IngestTTL: time.Duration(cfg.LocalGaugeTTL) * time.Second,
 		},
-		ServerAddress: "http://" + cfg.Addr.String(),
+		ServerAddress: scheme + cfg.Addr.String(),
 	}
 
 	errorsCh := make(chan error)
 	go closer.ProcessingErrorsChannel(errorsCh)
 
+	if cfg.LocalHTTPAddr != "" {
+		listener, err := net.Listen("tcp", cfg.LocalHTTPAddr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go func() {
+			if err := ingest.ServeHTTP(ctx, listener, metricsHandler.Storage); err != nil {
+				errorsCh <- err
+			}
+		}()
+	}
+
+	if cfg.LocalUDPAddr != "" {
+		conn, err := net.ListenPacket("udp", cfg.LocalUDPAddr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go func() {
+			if err := ingest.ServeUDP(ctx, conn, metricsHandler.Storage); err != nil {
+				errorsCh <- err
+			}
+		}()
+	}
+
 	var wg sync.WaitGroup
 	wg.Add(2)
 
 	go func() {
 		defer wg.Done()
-		go services.UpdateMetrics(ctx, metricsHandler, cfg.PollInterval, errorsCh)
+		pollInterval := time.Duration(cfg.PollInterval) * time.Second
+		collectors := append(collector.Enabled(cfg.Collectors, pollInterval, cfg.CollectorOptions),
+			collector.ExecCollectors(cfg.ExecCommands, pollInterval)...)
+		go services.UpdateMetrics(ctx, metricsHandler, collectors, errorsCh)
 	}()
 
-	sender := httpresty.New(
-		fmt.Sprintf("%s/update/", metricsHandler.ServerAddress),
-		httpresty.WithHash(cfg.HashKey, hashutil.СomputeHexadecimalSha256Hash),
-		httpresty.WithRsaPublicKey(cfg.RSAPublicKey),
-	)
+	var sender sendmetric.BatchSender
+	switch cfg.Transport {
+	case config.TransportGRPC:
+
+		grpcSender, err := grpcsender.New(
+			cfg.GRPCAddr.String(),
+			grpcsender.WithHash(cfg.HashKey),
+			grpcsender.WithKeyID(cfg.HashKeyID),
+			grpcsender.WithAPIKey(cfg.APIKey),
+			grpcsender.WithTLSConfig(cfg.TLSConfig),
+		)
+		if err != nil {
+			log.Fatal(err)
+		}
+		closer.Add(grpcSender.Close)
+		sender = grpcSender
+
+	default:
+
+		sender = httpresty.New(
+			fmt.Sprintf("%s/update/", metricsHandler.ServerAddress),
+			httpresty.WithHash(cfg.HashKey, hashutil.СomputeHexadecimalSha256Hash),
+			httpresty.WithRsaPublicKey(cfg.RSAPublicKey),
+			httpresty.WithKeyIDs(cfg.HashKeyID, cfg.CryptoKeyID),
+			httpresty.WithAPIKey(cfg.APIKey),
+			httpresty.WithTLSConfig(cfg.TLSConfig),
+			httpresty.WithBatchURL(fmt.Sprintf("%s/updates/add/", metricsHandler.ServerAddress)),
+		)
+	}
+
+	var metricsSpool *spool.Spool
+	if cfg.SpoolDir != "" {
+		metricsSpool, err = spool.Open(cfg.SpoolDir, cfg.SpoolMaxSize, time.Duration(cfg.SpoolMaxAge)*time.Second)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	go func() {
 		defer wg.Done()
 
+		if cfg.ReportMode == config.ReportModeBatch {
+			reporter.ReportMetricsBatch(
+				ctx,
+				metricsHandler,
+				sender,
+				metricsSpool,
+				cfg.ReportInterval,
+				cfg.RateLimit,
+				cfg.BatchMaxSize,
+				errorsCh)
+			return
+		}
+
 		reporter.ReportMetrics(
 			ctx,
 			metricsHandler,
 			sender,
+			metricsSpool,
 			cfg.ReportInterval,
 			cfg.RateLimit,
 			errorsCh)
 	}()
 
-	//go reporter.ReportMetricsBatch(metricsHandler, cfg.ReportInterval, errors)
-
 	<-ctx.Done()
 	err = closer.Shutdown()
 
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
 
+	"github.com/s-turchinskiy/metrics/internal/agent/collector"
 	"github.com/s-turchinskiy/metrics/internal/agent/repositories"
 	"github.com/s-turchinskiy/metrics/internal/agent/services"
 	"github.com/s-turchinskiy/metrics/internal/agent/services/sendmetric/httpresty"
@@ -24,17 +26,26 @@ func BenchmarkAll(b *testing.B) {
 		fmt.Sprintf("%s/update/", h.ServerAddress),
 	)
 
+	settings, err := collector.ParseSettings("")
+	if err != nil {
+		b.Fatal(err)
+	}
+	collector.CPUSampleTime = time.Nanosecond
+	collectors := collector.Enabled(settings, time.Second, collector.Options{})
+
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
 
-		metrics, err := services.GetMetrics(time.Nanosecond)
-		if err != nil {
-			b.Fatal(err)
-		}
-		err = h.Storage.UpdateMetrics(metrics)
-		if err != nil {
-			b.Fatal(err)
+		for _, c := range collectors {
+			sample, err := c.Collector.Collect(context.Background())
+			if err != nil {
+				b.Fatal(err)
+			}
+			err = h.Storage.UpdateSource(c.Name, sample.Gauges, sample.Counters)
+			if err != nil {
+				b.Fatal(err)
+			}
 		}
 
 		metricsStorage, err := h.Storage.GetMetrics()
@@ -2,9 +2,13 @@ package main
 
 import (
 	"context"
+	"github.com/s-turchinskiy/metrics/internal/server/grpcserver"
 	"github.com/s-turchinskiy/metrics/internal/server/repository"
+	"github.com/s-turchinskiy/metrics/internal/server/repository/file"
 	"github.com/s-turchinskiy/metrics/internal/server/repository/memcashed"
 	"github.com/s-turchinskiy/metrics/internal/server/repository/postgresql"
+	"github.com/s-turchinskiy/metrics/internal/server/retention"
+	"github.com/s-turchinskiy/metrics/internal/server/statsd"
 	closerutil "github.com/s-turchinskiy/metrics/internal/utils/closerutil"
 	"log"
 	_ "net/http/pprof"
@@ -16,6 +20,7 @@ import (
 	"github.com/s-turchinskiy/metrics/internal/server/handlers"
 	"github.com/s-turchinskiy/metrics/internal/server/middleware/logger"
 	"github.com/s-turchinskiy/metrics/internal/server/settings"
+	"github.com/s-turchinskiy/metrics/internal/server/staleness"
 )
 
 func init() {
@@ -45,27 +50,34 @@ func main() {
 		log.Fatal(err)
 	}
 
+	errorsCh := make(chan error)
+	go closer.ProcessingErrorsChannel(errorsCh)
+
 	var rep repository.Repository
-	if settings.Settings.Store == settings.Database {
+	switch settings.Settings.Store {
+	case settings.Database:
 
 		rep, err = postgresql.Initialize(ctx, settings.Settings.Database.String(), settings.Settings.Database.DBName)
 		if err != nil {
 			logger.Log.Debugw("Connect to database error", "error", err.Error())
 			log.Fatal(err)
 		}
-		closer.Add(rep.Close)
 
-	} else {
+	case settings.File:
 
-		rep = &memcashed.MemCashed{
-			Gauge:   make(map[string]float64),
-			Counter: make(map[string]int64),
+		fileRep, err := file.New(ctx, settings.Settings.FileStoragePath, settings.Settings.Restore, !settings.Settings.AsynchronousWritingDataToFile)
+		if err != nil {
+			logger.Log.Debugw("Open file repository error", "error", err.Error())
+			log.Fatal(err)
 		}
+		go compactFilePeriodically(ctx, fileRep, errorsCh)
+		rep = fileRep
 
-	}
+	default:
 
-	errorsCh := make(chan error)
-	go closer.ProcessingErrorsChannel(errorsCh)
+		rep = memcashed.New()
+
+	}
 
 	metricsHandler := handlers.NewHandler(ctx, rep, settings.Settings.FileStoragePath, settings.Settings.AsynchronousWritingDataToFile)
 	httpServer := handlers.NewHTTPServer(
@@ -73,9 +85,10 @@ func main() {
 		settings.Settings.Address.String(),
 		10*time.Second,
 		10*time.Second,
-		settings.Settings.RSAPrivateKey,
-		settings.Settings.HashKey,
+		settings.Settings.Keyring,
 	)
+	httpServer.TLSConfig = settings.Settings.TLSConfig
+	go settings.Settings.Keyring.Run(ctx, time.Duration(settings.Settings.KeysReloadInterval)*time.Second)
 	closer.Add(httpServer.FuncShutdown(logger.Log))
 	go func() {
 		err = httpServer.Run(settings.Settings.EnableHTTPS, pathCert, pathRSAPrivateKey)
@@ -87,8 +100,50 @@ func main() {
 		}
 	}()
 
-	go saveMetricsToFilePeriodically(ctx, metricsHandler, errorsCh)
-	closer.Add(metricsHandler.Service.SaveMetricsToFile)
+	if settings.Settings.GRPCAddress != "" {
+		grpcServer := grpcserver.New(metricsHandler.Service, settings.Settings.GRPCAddress,
+			grpcserver.WithKeys(settings.Settings.Keyring),
+			grpcserver.WithReplayGuard(metricsHandler.ReplayGuard()),
+			grpcserver.WithKeyStore(metricsHandler.KeyStore()),
+			grpcserver.WithTenants(settings.Settings.TenantKeyMap, settings.Settings.TenantHeader),
+			grpcserver.WithTrustedSubnet(settings.Settings.TrustedNet),
+			grpcserver.WithTLS(settings.Settings.TLSConfig, settings.Settings.TLSClients),
+		)
+		closer.Add(grpcServer.FuncShutdown(logger.Log))
+		go func() {
+			if err := grpcServer.Run(); err != nil {
+				logger.Log.Errorw("gRPC server startup error", "error", err.Error())
+				errorsCh <- err
+			}
+		}()
+	}
+
+	if settings.Settings.StatsdUDPAddress != "" || settings.Settings.StatsdTCPAddress != "" {
+		statsdServer := statsd.New(metricsHandler.Service, settings.Settings.StatsdUDPAddress, settings.Settings.StatsdTCPAddress)
+		if err = statsdServer.Run(ctx); err != nil {
+			logger.Log.Errorw("StatsD server startup error", "error", err.Error())
+			log.Fatal(err)
+		}
+		closer.Add(statsdServer.Shutdown)
+	}
+
+	// file.Repository сам ведет журнал в FileStoragePath, снимки в этот же файл не пишем
+	if settings.Settings.Store != settings.File {
+		go saveMetricsToFilePeriodically(ctx, metricsHandler, errorsCh)
+		closer.Add(metricsHandler.Service.SaveMetricsToFile)
+	}
+
+	compactor := retention.New(rep, settings.Settings.RetentionPolicy, time.Duration(settings.Settings.RetentionInterval)*time.Second)
+	go compactor.Run(ctx)
+	closer.Add(compactor.Stop)
+
+	// устаревшие gauge удаляются не позже чем через половину TTL после его истечения
+	staleRemover := staleness.New(metricsHandler.Service, settings.Settings.StalePolicy, settings.Settings.StalePolicy.TTL/2)
+	go staleRemover.Run(ctx)
+	closer.Add(staleRemover.Stop)
+
+	// хранилище закрывается последним, после остановки всех, кто в него пишет
+	closer.Add(rep.Close)
 
 	<-ctx.Done()
 	err = closer.Shutdown()
@@ -116,3 +171,27 @@ func saveMetricsToFilePeriodically(ctx context.Context, h *handlers.MetricsHandl
 		}
 	}
 }
+
+func compactFilePeriodically(ctx context.Context, rep *file.Repository, errors chan error) {
+
+	if !settings.Settings.AsynchronousWritingDataToFile {
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(settings.Settings.StoreInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := rep.Compact(ctx)
+			if err != nil {
+				logger.Log.Infoln("error", err.Error())
+				errors <- err
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/cpu"
+	"github.com/shirou/gopsutil/v4/mem"
+)
+
+// Имена встроенных коллекторов
+const (
+	Runtime = "runtime"
+	Memory  = "memory"
+	CPU     = "cpu"
+	Random  = "random"
+)
+
+var (
+	memStatsNames = []string{"Alloc", "BuckHashSys", "Frees", "GCCPUFraction", "GCSys", "HeapAlloc", "HeapIdle", "HeapInuse",
+		"HeapObjects", "HeapReleased", "HeapSys", "LastGC", "Lookups", "MCacheInuse", "MCacheSys", "MSpanInuse", "MSpanSys",
+		"Mallocs", "NextGC", "NumForcedGC", "NumGC", "OtherSys", "PauseTotalNs", "StackInuse", "StackSys", "Sys", "TotalAlloc"}
+)
+
+// CPUSampleTime Время замера загрузки процессора коллектором cpu
+var CPUSampleTime = time.Second
+
+func init() {
+	Register(Runtime, true, func(Options) Collector { return CollectorFunc(collectRuntime) })
+	Register(Memory, true, func(Options) Collector { return CollectorFunc(collectMemory) })
+	Register(CPU, true, func(Options) Collector { return CollectorFunc(collectCPU) })
+	Register(Random, true, func(Options) Collector { return CollectorFunc(collectRandom) })
+}
+
+// collectRuntime Метрики runtime.MemStats, каждый сбор увеличивает PollCount
+func collectRuntime(_ context.Context) (Sample, error) {
+
+	result := make(map[string]float64, len(memStatsNames))
+
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
+	v := reflect.ValueOf(memStats)
+	typeOfS := v.Type()
+
+	for i := 0; i < v.NumField(); i++ {
+
+		for _, metricsName := range memStatsNames {
+
+			if metricsName != typeOfS.Field(i).Name {
+				continue
+			}
+
+			switch typeName := typeOfS.Field(i).Type.Name(); typeName {
+			case "uint64":
+				{
+					result[metricsName] = float64(v.Field(i).Interface().(uint64))
+				}
+			case "uint32":
+				{
+					result[metricsName] = float64(v.Field(i).Interface().(uint32))
+				}
+			case "float64":
+				{
+					result[metricsName] = v.Field(i).Interface().(float64)
+				}
+			default:
+				return Sample{}, fmt.Errorf("unexpected type %s for metric %s", typeName, metricsName)
+			}
+		}
+	}
+
+	return Sample{Gauges: result, Counters: map[string]int64{"PollCount": 1}}, nil
+}
+
+func collectMemory(_ context.Context) (Sample, error) {
+
+	vm, err := mem.VirtualMemory()
+	if err != nil {
+		return Sample{}, err
+	}
+
+	return Sample{Gauges: map[string]float64{
+		"TotalMemory": float64(vm.Total),
+		"FreeMemory":  float64(vm.Free),
+	}}, nil
+}
+
+func collectCPU(ctx context.Context) (Sample, error) {
+
+	cpuPercent, err := cpu.PercentWithContext(ctx, CPUSampleTime, true)
+	if err != nil {
+		return Sample{}, err
+	}
+
+	result := make(map[string]float64, len(cpuPercent))
+	for i, percent := range cpuPercent {
+		result[fmt.Sprintf("CPUutilization%d", i)] = percent
+	}
+
+	return Sample{Gauges: result}, nil
+}
+
+func collectRandom(_ context.Context) (Sample, error) {
+	return Sample{Gauges: map[string]float64{"RandomValue": rand.Float64()}}, nil
+}
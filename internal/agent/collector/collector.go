@@ -0,0 +1,230 @@
+// Package collector Источники метрик агента: интерфейс Collector, реестр и запуск с собственным интервалом.
+// Свой источник регистрируется вызовом Register в init пакета, который импортируется в main агента
+package collector
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/s-turchinskiy/metrics/internal/agent/logger"
+)
+
+// Sample Результат одного сбора: значения gauge и приращения counter
+type Sample struct {
+	Gauges   map[string]float64
+	Counters map[string]int64
+}
+
+// Collector Источник метрик
+type Collector interface {
+	Collect(ctx context.Context) (Sample, error)
+}
+
+// CollectorFunc Функция как Collector
+type CollectorFunc func(ctx context.Context) (Sample, error)
+
+func (f CollectorFunc) Collect(ctx context.Context) (Sample, error) {
+	return f(ctx)
+}
+
+// Options Параметры встроенных коллекторов из настроек агента
+type Options struct {
+	ProcessNames []string // имена процессов для коллектора process
+}
+
+// Factory Создание коллектора при запуске агента
+type Factory func(opts Options) Collector
+
+type registration struct {
+	factory        Factory
+	enabledDefault bool
+}
+
+var (
+	registryMutex sync.RWMutex
+	registry      = make(map[string]registration)
+)
+
+// Register Регистрация коллектора под именем name. enabledDefault - включен ли коллектор без настройки.
+// Повторная регистрация имени - ошибка программы, вызывает панику
+func Register(name string, enabledDefault bool, factory Factory) {
+
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	if factory == nil {
+		panic("collector: Register factory is nil for " + name)
+	}
+	if _, exist := registry[name]; exist {
+		panic("collector: Register called twice for " + name)
+	}
+
+	registry[name] = registration{factory: factory, enabledDefault: enabledDefault}
+}
+
+// Names Имена зарегистрированных коллекторов по алфавиту
+func Names() []string {
+
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Setting Настройка коллектора. Interval 0 - интервал опроса агента
+type Setting struct {
+	Enabled  bool
+	Interval time.Duration
+}
+
+// ParseSettings Разбор настроек коллекторов: <имя>:<on|off|интервал в секундах>,...
+// Интервал включает коллектор. Не указанные коллекторы работают с настройками по умолчанию
+func ParseSettings(spec string) (map[string]Setting, error) {
+
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	result := make(map[string]Setting, len(registry))
+	for name, r := range registry {
+		result[name] = Setting{Enabled: r.enabledDefault}
+	}
+
+	for _, item := range strings.Split(spec, ",") {
+
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		name, value, found := strings.Cut(item, ":")
+		name = strings.TrimSpace(name)
+		value = strings.TrimSpace(value)
+
+		setting, exist := result[name]
+		if !exist {
+			return nil, fmt.Errorf("unknown collector %q, registered: %s", name, strings.Join(sortedNames(result), ","))
+		}
+
+		switch {
+		case !found || value == "on":
+			setting.Enabled = true
+		case value == "off":
+			setting.Enabled = false
+		default:
+			seconds, err := strconv.Atoi(value)
+			if err != nil || seconds <= 0 {
+				return nil, fmt.Errorf("collector %s: expected on, off or interval in seconds, got %q", name, value)
+			}
+			setting.Enabled = true
+			setting.Interval = time.Duration(seconds) * time.Second
+		}
+
+		result[name] = setting
+	}
+
+	return result, nil
+}
+
+func sortedNames(m map[string]Setting) []string {
+
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Storage Хранилище результатов: значения источника source заменяют его прежние значения
+type Storage interface {
+	UpdateSource(source string, gauges map[string]float64, counters map[string]int64) error
+}
+
+// Scheduled Включенный коллектор с интервалом сбора
+type Scheduled struct {
+	Name      string
+	Collector Collector
+	Interval  time.Duration
+}
+
+// Enabled Создание включенных коллекторов, без своего интервала - с pollInterval
+func Enabled(settings map[string]Setting, pollInterval time.Duration, opts Options) []Scheduled {
+
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	var result []Scheduled
+	for _, name := range sortedNames(settings) {
+
+		setting := settings[name]
+		r, exist := registry[name]
+		if !setting.Enabled || !exist {
+			continue
+		}
+
+		interval := setting.Interval
+		if interval <= 0 {
+			interval = pollInterval
+		}
+		result = append(result, Scheduled{Name: name, Collector: r.factory(opts), Interval: interval})
+	}
+
+	return result
+}
+
+// Run Сбор метрик каждым коллектором со своим интервалом до отмены ctx.
+// Ошибка сбора пропускает результат одного сбора, ошибка хранилища останавливает коллектор
+func Run(ctx context.Context, collectors []Scheduled, storage Storage, errors chan error) {
+
+	var wg sync.WaitGroup
+	for _, c := range collectors {
+		wg.Add(1)
+		go func(c Scheduled) {
+			defer wg.Done()
+			run(ctx, c, storage, errors)
+		}(c)
+	}
+
+	wg.Wait()
+}
+
+func run(ctx context.Context, c Scheduled, storage Storage, errors chan error) {
+
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+
+			sample, err := c.Collector.Collect(ctx)
+			if err != nil {
+				logger.Log.Infow("collect metrics error", "collector", c.Name, "error", err.Error())
+				continue
+			}
+
+			if err = storage.UpdateSource(c.Name, sample.Gauges, sample.Counters); err != nil {
+				logger.Log.Infow("storage update metrics error", "collector", c.Name, "error", err.Error())
+				select {
+				case errors <- err:
+				case <-ctx.Done():
+				}
+				return
+			}
+		}
+	}
+}
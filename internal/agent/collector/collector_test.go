@@ -0,0 +1,118 @@
+package collector
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCollector = "test"
+
+func init() {
+	Register(testCollector, false, func(Options) Collector {
+		return CollectorFunc(func(ctx context.Context) (Sample, error) {
+			return Sample{Gauges: map[string]float64{"TestValue": 1}, Counters: map[string]int64{"TestCount": 2}}, nil
+		})
+	})
+}
+
+func TestParseSettings(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		spec    string
+		want    map[string]Setting
+		wantErr bool
+	}{
+		{
+			name: "по умолчанию",
+			spec: "",
+			want: map[string]Setting{CPU: {Enabled: true}, Random: {Enabled: true}, testCollector: {Enabled: false}},
+		},
+		{
+			name: "включение, выключение и интервал",
+			spec: "test, random:off, cpu:10",
+			want: map[string]Setting{CPU: {Enabled: true, Interval: 10 * time.Second}, Random: {Enabled: false}, testCollector: {Enabled: true}},
+		},
+		{name: "неизвестный коллектор", spec: "gpu", wantErr: true},
+		{name: "неверный интервал", spec: "cpu:0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			got, err := ParseSettings(tt.spec)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			for name, setting := range tt.want {
+				assert.Equal(t, setting, got[name], name)
+			}
+		})
+	}
+}
+
+func TestRegister_Twice(t *testing.T) {
+	assert.Panics(t, func() { Register(testCollector, true, func(Options) Collector { return nil }) })
+}
+
+type storageFunc func(source string, gauges map[string]float64, counters map[string]int64) error
+
+func (f storageFunc) UpdateSource(source string, gauges map[string]float64, counters map[string]int64) error {
+	return f(source, gauges, counters)
+}
+
+func TestRun(t *testing.T) {
+
+	settings := map[string]Setting{testCollector: {Enabled: true}, Random: {Enabled: false}}
+
+	collectors := Enabled(settings, 10*time.Millisecond, Options{})
+	require.Len(t, collectors, 1)
+	assert.Equal(t, testCollector, collectors[0].Name)
+
+	var mutex sync.Mutex
+	updates := 0
+	storage := storageFunc(func(source string, gauges map[string]float64, counters map[string]int64) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		assert.Equal(t, testCollector, source)
+		assert.Equal(t, 1.0, gauges["TestValue"])
+		assert.Equal(t, int64(2), counters["TestCount"])
+		updates++
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		Run(ctx, collectors, storage, make(chan error, 1))
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return updates >= 2
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+}
+
+func TestBuiltin(t *testing.T) {
+
+	sample, err := collectRuntime(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, sample.Gauges, len(memStatsNames))
+	assert.Equal(t, int64(1), sample.Counters["PollCount"])
+
+	sample, err = collectRandom(context.Background())
+	require.NoError(t, err)
+	assert.Contains(t, sample.Gauges, "RandomValue")
+}
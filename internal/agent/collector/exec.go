@@ -0,0 +1,363 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/s-turchinskiy/metrics/internal/agent/logger"
+	"github.com/s-turchinskiy/metrics/internal/utils/configutil"
+)
+
+// Форматы вывода команды
+const (
+	FormatPlain  = "plain"  // строки <имя> <gauge|counter> <значение>
+	FormatNagios = "nagios" // perfdata после | в выводе проверки Nagios
+)
+
+// ExecSourcePrefix Префикс источника метрик команды в хранилище
+const ExecSourcePrefix = "exec:"
+
+const (
+	defaultExecTimeout = 10 * time.Second
+	maxExecOutput      = 1024 * 1024
+)
+
+// ExecCommand Команда, вывод которой разбирается в метрики
+type ExecCommand struct {
+	Name     string   `json:"name"`               // имя для метрик самоконтроля и источника в хранилище
+	Command  string   `json:"command"`            // исполняемый файл, запускается без оболочки
+	Args     []string `json:"args,omitempty"`     // аргументы
+	Format   string   `json:"format,omitempty"`   // plain (по умолчанию) или nagios
+	Interval string   `json:"interval,omitempty"` // период запуска, например 30s, по умолчанию интервал опроса агента
+	Timeout  string   `json:"timeout,omitempty"`  // время выполнения, после которого команда завершается, по умолчанию 10s
+
+	interval time.Duration
+	timeout  time.Duration
+}
+
+// LoadExecCommands Чтение команд из JSON-файла с массивом ExecCommand
+func LoadExecCommands(path string) ([]ExecCommand, error) {
+
+	var commands []ExecCommand
+	if err := configutil.LoadJSONConfig(path, &commands); err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool, len(commands))
+	for i := range commands {
+
+		c := &commands[i]
+		if c.Name == "" || c.Command == "" {
+			return nil, fmt.Errorf("exec command %d: name and command are required", i+1)
+		}
+		if names[c.Name] {
+			return nil, fmt.Errorf("exec command %s: duplicate name", c.Name)
+		}
+		names[c.Name] = true
+
+		switch c.Format {
+		case "":
+			c.Format = FormatPlain
+		case FormatPlain, FormatNagios:
+		default:
+			return nil, fmt.Errorf("exec command %s: unknown format %q, need %s or %s", c.Name, c.Format, FormatPlain, FormatNagios)
+		}
+
+		var err error
+		if c.interval, err = parseOptionalDuration(c.Interval); err != nil {
+			return nil, fmt.Errorf("exec command %s: interval: %w", c.Name, err)
+		}
+		if c.timeout, err = parseOptionalDuration(c.Timeout); err != nil {
+			return nil, fmt.Errorf("exec command %s: timeout: %w", c.Name, err)
+		}
+	}
+
+	return commands, nil
+}
+
+func parseOptionalDuration(value string) (time.Duration, error) {
+
+	if value == "" {
+		return 0, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("must be positive, got %s", value)
+	}
+
+	return duration, nil
+}
+
+// ExecCollectors Коллекторы команд, каждый со своим интервалом
+func ExecCollectors(commands []ExecCommand, pollInterval time.Duration) []Scheduled {
+
+	result := make([]Scheduled, 0, len(commands))
+	for _, command := range commands {
+
+		interval := command.interval
+		if interval <= 0 {
+			interval = pollInterval
+		}
+		result = append(result, Scheduled{
+			Name:      ExecSourcePrefix + command.Name,
+			Collector: NewExec(command),
+			Interval:  interval,
+		})
+	}
+
+	return result
+}
+
+// ExecCollector Запуск команды и разбор ее вывода.
+// Ошибки запуска, таймаута и разбора не прерывают сбор, а учитываются в метриках самоконтроля:
+// ExecErrors_<имя> (counter), ExecExitCode_<имя> и ExecDuration_<имя> в секундах (gauge)
+type ExecCollector struct {
+	command ExecCommand
+	suffix  string
+
+	mutex   sync.Mutex
+	nagiosC *deltas // накопленные счетчики perfdata с единицей c
+}
+
+// NewExec Создание коллектора команды
+func NewExec(command ExecCommand) *ExecCollector {
+
+	if command.Format == "" {
+		command.Format = FormatPlain
+	}
+	if command.timeout <= 0 {
+		command.timeout = defaultExecTimeout
+	}
+
+	return &ExecCollector{command: command, suffix: metricSuffix(command.Name), nagiosC: newDeltas()}
+}
+
+// Collect Запуск команды и разбор вывода. Ошибка не возвращается никогда: при ошибке сборщик
+// пропустил бы выборку вместе с метриками самоконтроля, поэтому ошибки учитываются в ExecErrors_<имя>
+func (c *ExecCollector) Collect(ctx context.Context) (Sample, error) {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	sample := Sample{Gauges: make(map[string]float64), Counters: make(map[string]int64)}
+
+	start := time.Now()
+	output, exitCode, err := c.run(ctx)
+	sample.Gauges["ExecDuration_"+c.suffix] = time.Since(start).Seconds()
+	sample.Gauges["ExecExitCode_"+c.suffix] = float64(exitCode)
+
+	if err == nil {
+		switch c.command.Format {
+		case FormatNagios:
+			err = parseNagios(output, sample, c.nagiosC)
+		default:
+			err = parsePlain(output, sample)
+		}
+	}
+
+	sample.Counters["ExecErrors_"+c.suffix] = 0
+	if err != nil {
+		sample.Counters["ExecErrors_"+c.suffix] = 1
+		logger.Log.Infow("exec collector error", "command", c.command.Name, "error", err.Error())
+	}
+
+	return sample, nil
+}
+
+// run Запуск команды с таймаутом. Для Nagios коды 1 (WARNING) и 2 (CRITICAL) не ошибка
+func (c *ExecCollector) run(ctx context.Context) ([]byte, int, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, c.command.timeout)
+	defer cancel()
+
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.command.Command, c.command.Args...)
+	cmd.Stdout = &limitedWriter{w: &stdout, left: maxExecOutput}
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, -1, fmt.Errorf("timeout %s exceeded", c.command.timeout)
+	}
+
+	exitCode := 0
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode = exitErr.ExitCode()
+		if c.command.Format == FormatNagios && (exitCode == 1 || exitCode == 2) {
+			err = nil
+		}
+	}
+	if err != nil {
+		if exitCode == 0 {
+			exitCode = -1
+		}
+		return nil, exitCode, err
+	}
+
+	return stdout.Bytes(), exitCode, nil
+}
+
+type limitedWriter struct {
+	w    io.Writer
+	left int
+}
+
+// Write Вывод сверх лимита отбрасывается без ошибки, чтобы команда не завершилась по SIGPIPE
+func (l *limitedWriter) Write(p []byte) (int, error) {
+
+	n := len(p)
+	if l.left <= 0 {
+		return n, nil
+	}
+	if len(p) > l.left {
+		p = p[:l.left]
+	}
+	l.left -= len(p)
+
+	if _, err := l.w.Write(p); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// parsePlain Разбор строк <имя> <gauge|counter> <значение>, пустые строки и строки с # пропускаются.
+// Значение counter - приращение. Неверные строки пропускаются, о них сообщает ошибка
+func parsePlain(output []byte, sample Sample) error {
+
+	var errs []error
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for line := 1; scanner.Scan(); line++ {
+
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 3 {
+			errs = append(errs, fmt.Errorf("line %d: expected <name> <type> <value>, got %q", line, text))
+			continue
+		}
+
+		name, metricType, value := fields[0], fields[1], fields[2]
+		switch metricType {
+		case "gauge":
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("line %d: %w", line, err))
+				continue
+			}
+			sample.Gauges[name] = v
+		case "counter":
+			v, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("line %d: %w", line, err))
+				continue
+			}
+			sample.Counters[name] += v
+		default:
+			errs = append(errs, fmt.Errorf("line %d: unknown type %q", line, metricType))
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// parseNagios Разбор perfdata: 'метка'=значение[единица];warn;crit;min;max после | в первой и последующих строках.
+// Значения с единицей c - накопленные счетчики, отправляются приращениями, остальные - gauge
+func parseNagios(output []byte, sample Sample, counters *deltas) error {
+
+	var perfdata []string
+	lines := strings.Split(string(output), "\n")
+	if _, data, found := strings.Cut(lines[0], "|"); found {
+		perfdata = append(perfdata, data)
+	}
+	// в многострочном выводе perfdata продолжается после | в последующих строках
+	for _, line := range lines[1:] {
+		if _, data, found := strings.Cut(line, "|"); found {
+			perfdata = append(perfdata, data)
+		}
+	}
+
+	var errs []error
+	for _, item := range splitPerfdata(strings.Join(perfdata, " ")) {
+
+		label, rest, found := strings.Cut(item, "=")
+		if !found || label == "" {
+			errs = append(errs, fmt.Errorf("perfdata %q: expected label=value", item))
+			continue
+		}
+		label = strings.Trim(label, "'")
+
+		value, _, _ := strings.Cut(rest, ";")
+		// число - символы, допустимые в strconv.ParseFloat, слева, единица - остаток: 1e-3s, 512B, 80%
+		end := strings.IndexFunc(value, func(r rune) bool {
+			return !strings.ContainsRune("+-eE.0123456789", r)
+		})
+		if end < 0 {
+			end = len(value)
+		}
+		number, unit := value[:end], value[end:]
+
+		v, err := strconv.ParseFloat(number, 64)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("perfdata %q: %w", item, err))
+			continue
+		}
+
+		name := metricSuffix(label)
+		if unit == "c" && v >= 0 {
+			counters.add(sample.Counters, name, uint64(v))
+			continue
+		}
+		sample.Gauges[name] = v
+	}
+
+	return errors.Join(errs...)
+}
+
+// splitPerfdata Разделение perfdata по пробелам с учетом меток в одинарных кавычках
+func splitPerfdata(data string) []string {
+
+	var items []string
+	var item strings.Builder
+	quoted := false
+	for _, r := range data {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+			item.WriteRune(r)
+		case (r == ' ' || r == '\t') && !quoted:
+			if item.Len() > 0 {
+				items = append(items, item.String())
+				item.Reset()
+			}
+		default:
+			item.WriteRune(r)
+		}
+	}
+	if item.Len() > 0 {
+		items = append(items, item.String())
+	}
+
+	return items
+}
@@ -0,0 +1,155 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadExecCommands(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{name: "корректные команды", data: `[{"name":"queue","command":"/usr/local/bin/queue.sh","interval":"30s"},{"name":"nginx","command":"check_http","format":"nagios","timeout":"2s"}]`},
+		{name: "без команды", data: `[{"name":"queue"}]`, wantErr: true},
+		{name: "повтор имени", data: `[{"name":"queue","command":"a"},{"name":"queue","command":"b"}]`, wantErr: true},
+		{name: "неизвестный формат", data: `[{"name":"queue","command":"a","format":"xml"}]`, wantErr: true},
+		{name: "неверный интервал", data: `[{"name":"queue","command":"a","interval":"30"}]`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			path := filepath.Join(t.TempDir(), "exec.json")
+			require.NoError(t, os.WriteFile(path, []byte(tt.data), 0o600))
+
+			commands, err := LoadExecCommands(path)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			scheduled := ExecCollectors(commands, 2*time.Second)
+			require.Len(t, scheduled, 2)
+			assert.Equal(t, ExecSourcePrefix+"queue", scheduled[0].Name)
+			assert.Equal(t, 30*time.Second, scheduled[0].Interval)
+			assert.Equal(t, 2*time.Second, scheduled[1].Interval, "без интервала - интервал опроса агента")
+		})
+	}
+}
+
+func TestExecCollector(t *testing.T) {
+
+	tests := []struct {
+		name         string
+		command      ExecCommand
+		wantGauges   map[string]float64
+		wantCounters map[string]int64
+		wantExitCode float64
+		wantErrors   int64
+	}{
+		{
+			name: "plain",
+			command: ExecCommand{Name: "queue", Command: "sh", Args: []string{"-c",
+				"echo '# очередь'; echo 'QueueLength gauge 12.5'; echo; echo 'QueueProcessed counter 3'"}},
+			wantGauges:   map[string]float64{"QueueLength": 12.5},
+			wantCounters: map[string]int64{"QueueProcessed": 3},
+		},
+		{
+			name: "plain с неверной строкой",
+			command: ExecCommand{Name: "queue", Command: "sh", Args: []string{"-c",
+				"echo 'QueueLength gauge 7'; echo 'QueueLength 7'"}},
+			wantGauges:   map[string]float64{"QueueLength": 7},
+			wantCounters: map[string]int64{},
+			wantErrors:   1,
+		},
+		{
+			name: "nagios WARNING",
+			command: ExecCommand{Name: "http", Command: "sh", Format: FormatNagios, Args: []string{"-c",
+				"echo \"HTTP WARNING: slow | time=1.5s;1;2;0 size=512B 'bytes in'=100c\"; exit 1"}},
+			wantGauges:   map[string]float64{"time": 1.5, "size": 512},
+			wantCounters: map[string]int64{},
+			wantExitCode: 1,
+		},
+		{
+			name: "nagios экспонента и единицы",
+			command: ExecCommand{Name: "http", Command: "sh", Format: FormatNagios, Args: []string{"-c",
+				"echo \"OK | time=1e-3s;1;2 load=-2.5E+1 usage=80%\""}},
+			wantGauges:   map[string]float64{"time": 0.001, "load": -25, "usage": 80},
+			wantCounters: map[string]int64{},
+		},
+		{
+			name:         "nagios UNKNOWN",
+			command:      ExecCommand{Name: "http", Command: "sh", Format: FormatNagios, Args: []string{"-c", "echo 'UNKNOWN | time=1s'; exit 3"}},
+			wantGauges:   map[string]float64{},
+			wantCounters: map[string]int64{},
+			wantExitCode: 3,
+			wantErrors:   1,
+		},
+		{
+			name:         "команда не найдена",
+			command:      ExecCommand{Name: "missing", Command: "/nonexistent/check"},
+			wantGauges:   map[string]float64{},
+			wantCounters: map[string]int64{},
+			wantExitCode: -1,
+			wantErrors:   1,
+		},
+		{
+			name:         "таймаут",
+			command:      ExecCommand{Name: "slow", Command: "sleep", Args: []string{"5"}, timeout: 100 * time.Millisecond},
+			wantGauges:   map[string]float64{},
+			wantCounters: map[string]int64{},
+			wantExitCode: -1,
+			wantErrors:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			c := NewExec(tt.command)
+			sample, err := c.Collect(context.Background())
+			require.NoError(t, err, "ошибки команды только в метриках самоконтроля")
+
+			suffix := metricSuffix(tt.command.Name)
+			assert.Equal(t, tt.wantExitCode, sample.Gauges["ExecExitCode_"+suffix])
+			assert.Equal(t, tt.wantErrors, sample.Counters["ExecErrors_"+suffix])
+			assert.Less(t, sample.Gauges["ExecDuration_"+suffix], 3.0)
+
+			delete(sample.Gauges, "ExecExitCode_"+suffix)
+			delete(sample.Gauges, "ExecDuration_"+suffix)
+			delete(sample.Counters, "ExecErrors_"+suffix)
+			assert.Equal(t, tt.wantGauges, sample.Gauges)
+			assert.Equal(t, tt.wantCounters, sample.Counters)
+		})
+	}
+}
+
+func TestExecCollector_NagiosCounter(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "value")
+	c := NewExec(ExecCommand{Name: "requests", Command: "sh", Format: FormatNagios,
+		Args: []string{"-c", "echo \"OK | requests=$(cat " + path + ")c\""}})
+
+	for _, tt := range []struct {
+		value string
+		want  map[string]int64
+	}{
+		{value: "100", want: map[string]int64{"ExecErrors_requests": 0}},
+		{value: "130", want: map[string]int64{"ExecErrors_requests": 0, "requests": 30}},
+	} {
+		require.NoError(t, os.WriteFile(path, []byte(tt.value), 0o600))
+		sample, err := c.Collect(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, sample.Counters)
+	}
+}
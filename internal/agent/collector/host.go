@@ -0,0 +1,289 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/shirou/gopsutil/v4/disk"
+	"github.com/shirou/gopsutil/v4/host"
+	"github.com/shirou/gopsutil/v4/load"
+	"github.com/shirou/gopsutil/v4/net"
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// Имена коллекторов метрик хоста
+const (
+	Disk    = "disk"
+	Net     = "net"
+	Load    = "load"
+	Uptime  = "uptime"
+	FD      = "fd"
+	Process = "process"
+)
+
+// fileNrPath Счетчики дескрипторов файлов ядра Linux: выделено, свободно, максимум
+const fileNrPath = "/proc/sys/fs/file-nr"
+
+func init() {
+	Register(Disk, true, func(Options) Collector { return &diskCollector{io: newDeltas()} })
+	Register(Net, true, func(Options) Collector { return &netCollector{io: newDeltas()} })
+	Register(Load, true, func(Options) Collector { return CollectorFunc(collectLoad) })
+	Register(Uptime, true, func(Options) Collector { return CollectorFunc(collectUptime) })
+	Register(FD, runtime.GOOS == "linux", func(Options) Collector { return CollectorFunc(collectFD) })
+	Register(Process, true, func(opts Options) Collector {
+		return &processCollector{names: opts.ProcessNames, processes: make(map[int32]*process.Process)}
+	})
+}
+
+// deltas Приращения монотонных счетчиков ОС между сборами.
+// Первый сбор счетчика запоминает значение без приращения, уменьшение значения считается сбросом счетчика
+type deltas struct {
+	previous map[string]uint64
+}
+
+func newDeltas() *deltas {
+	return &deltas{previous: make(map[string]uint64)}
+}
+
+func (d *deltas) add(counters map[string]int64, name string, value uint64) {
+
+	previous, exist := d.previous[name]
+	d.previous[name] = value
+	if !exist {
+		return
+	}
+
+	if value < previous {
+		counters[name] = int64(value)
+		return
+	}
+	counters[name] = int64(value - previous)
+}
+
+var nameReplacer = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// metricSuffix Точка монтирования, устройство или процесс как часть имени метрики: / - root, /var/lib - var_lib
+func metricSuffix(name string) string {
+
+	suffix := strings.Trim(nameReplacer.ReplaceAllString(name, "_"), "_")
+	if suffix == "" {
+		return "root"
+	}
+
+	return suffix
+}
+
+// diskCollector Заполненность каждой точки монтирования и операции ввода-вывода каждого устройства
+type diskCollector struct {
+	mutex sync.Mutex
+	io    *deltas
+}
+
+func (c *diskCollector) Collect(ctx context.Context) (Sample, error) {
+
+	partitions, err := disk.PartitionsWithContext(ctx, false)
+	if err != nil {
+		return Sample{}, err
+	}
+
+	gauges := make(map[string]float64, 4*len(partitions))
+	for _, partition := range partitions {
+
+		usage, err := disk.UsageWithContext(ctx, partition.Mountpoint)
+		if err != nil || usage.Total == 0 {
+			continue
+		}
+
+		suffix := metricSuffix(partition.Mountpoint)
+		gauges["DiskTotal_"+suffix] = float64(usage.Total)
+		gauges["DiskUsed_"+suffix] = float64(usage.Used)
+		gauges["DiskFree_"+suffix] = float64(usage.Free)
+		gauges["DiskUsedPercent_"+suffix] = usage.UsedPercent
+	}
+
+	ioCounters, err := disk.IOCountersWithContext(ctx)
+	if err != nil {
+		return Sample{}, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	counters := make(map[string]int64, 4*len(ioCounters))
+	for device, stat := range ioCounters {
+
+		suffix := metricSuffix(device)
+		c.io.add(counters, "DiskReadBytes_"+suffix, stat.ReadBytes)
+		c.io.add(counters, "DiskWriteBytes_"+suffix, stat.WriteBytes)
+		c.io.add(counters, "DiskReadCount_"+suffix, stat.ReadCount)
+		c.io.add(counters, "DiskWriteCount_"+suffix, stat.WriteCount)
+	}
+
+	return Sample{Gauges: gauges, Counters: counters}, nil
+}
+
+// netCollector Байты, пакеты и ошибки каждого сетевого интерфейса
+type netCollector struct {
+	mutex sync.Mutex
+	io    *deltas
+}
+
+func (c *netCollector) Collect(ctx context.Context) (Sample, error) {
+
+	ioCounters, err := net.IOCountersWithContext(ctx, true)
+	if err != nil {
+		return Sample{}, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	counters := make(map[string]int64, 6*len(ioCounters))
+	for _, stat := range ioCounters {
+
+		suffix := metricSuffix(stat.Name)
+		c.io.add(counters, "NetBytesSent_"+suffix, stat.BytesSent)
+		c.io.add(counters, "NetBytesRecv_"+suffix, stat.BytesRecv)
+		c.io.add(counters, "NetPacketsSent_"+suffix, stat.PacketsSent)
+		c.io.add(counters, "NetPacketsRecv_"+suffix, stat.PacketsRecv)
+		c.io.add(counters, "NetErrIn_"+suffix, stat.Errin)
+		c.io.add(counters, "NetErrOut_"+suffix, stat.Errout)
+	}
+
+	return Sample{Counters: counters}, nil
+}
+
+func collectLoad(ctx context.Context) (Sample, error) {
+
+	avg, err := load.AvgWithContext(ctx)
+	if err != nil {
+		return Sample{}, err
+	}
+
+	return Sample{Gauges: map[string]float64{
+		"Load1":  avg.Load1,
+		"Load5":  avg.Load5,
+		"Load15": avg.Load15,
+	}}, nil
+}
+
+// collectUptime Время работы хоста в секундах
+func collectUptime(ctx context.Context) (Sample, error) {
+
+	uptime, err := host.UptimeWithContext(ctx)
+	if err != nil {
+		return Sample{}, err
+	}
+
+	return Sample{Gauges: map[string]float64{"Uptime": float64(uptime)}}, nil
+}
+
+// collectFD Открытые дескрипторы файлов хоста и их максимум, только Linux
+func collectFD(_ context.Context) (Sample, error) {
+
+	data, err := os.ReadFile(fileNrPath)
+	if err != nil {
+		return Sample{}, err
+	}
+
+	return parseFileNr(string(data))
+}
+
+func parseFileNr(data string) (Sample, error) {
+
+	fields := strings.Fields(data)
+	if len(fields) != 3 {
+		return Sample{}, fmt.Errorf("unexpected %s format: %q", fileNrPath, data)
+	}
+
+	values := make([]float64, len(fields))
+	for i, field := range fields {
+		value, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return Sample{}, fmt.Errorf("unexpected %s format: %w", fileNrPath, err)
+		}
+		values[i] = float64(value)
+	}
+
+	return Sample{Gauges: map[string]float64{
+		"OpenFileDescriptors": values[0] - values[1],
+		"MaxFileDescriptors":  values[2],
+	}}, nil
+}
+
+// processCollector Загрузка процессора и резидентная память процессов с заданными именами,
+// значения процессов с одинаковым именем складываются
+type processCollector struct {
+	names []string
+
+	mutex     sync.Mutex
+	processes map[int32]*process.Process // процессы прошлого сбора, загрузка процессора считается от него
+}
+
+func (c *processCollector) Collect(ctx context.Context) (Sample, error) {
+
+	if len(c.names) == 0 {
+		return Sample{}, nil
+	}
+
+	all, err := process.ProcessesWithContext(ctx)
+	if err != nil {
+		return Sample{}, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	gauges := make(map[string]float64, 3*len(c.names))
+	for _, name := range c.names {
+		suffix := metricSuffix(name)
+		gauges["ProcessCount_"+suffix] = 0
+		gauges["ProcessCPU_"+suffix] = 0
+		gauges["ProcessRSS_"+suffix] = 0
+	}
+
+	current := make(map[int32]*process.Process, len(c.processes))
+	for _, p := range all {
+
+		processName, err := p.NameWithContext(ctx)
+		if err != nil || !c.configured(processName) {
+			continue
+		}
+
+		// загрузка процессора считается между сборами одним и тем же *process.Process
+		if previous, exist := c.processes[p.Pid]; exist {
+			p = previous
+		}
+		current[p.Pid] = p
+
+		suffix := metricSuffix(processName)
+		gauges["ProcessCount_"+suffix]++
+
+		if percent, err := p.PercentWithContext(ctx, 0); err == nil {
+			gauges["ProcessCPU_"+suffix] += percent
+		}
+		if memory, err := p.MemoryInfoWithContext(ctx); err == nil {
+			gauges["ProcessRSS_"+suffix] += float64(memory.RSS)
+		}
+	}
+	c.processes = current
+
+	return Sample{Gauges: gauges}, nil
+}
+
+func (c *processCollector) configured(name string) bool {
+
+	for _, configured := range c.names {
+		if configured == name {
+			return true
+		}
+	}
+
+	return false
+}
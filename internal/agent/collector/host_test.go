@@ -0,0 +1,99 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/shirou/gopsutil/v4/process"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeltas(t *testing.T) {
+
+	d := newDeltas()
+
+	tests := []struct {
+		name  string
+		value uint64
+		want  map[string]int64
+	}{
+		{name: "первый сбор без приращения", value: 100, want: map[string]int64{}},
+		{name: "приращение", value: 150, want: map[string]int64{"Bytes": 50}},
+		{name: "без изменений", value: 150, want: map[string]int64{"Bytes": 0}},
+		{name: "сброс счетчика", value: 20, want: map[string]int64{"Bytes": 20}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counters := make(map[string]int64)
+			d.add(counters, "Bytes", tt.value)
+			assert.Equal(t, tt.want, counters)
+		})
+	}
+}
+
+func TestMetricSuffix(t *testing.T) {
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "/", want: "root"},
+		{name: "/var/lib/docker", want: "var_lib_docker"},
+		{name: "C:", want: "C"},
+		{name: "eth0", want: "eth0"},
+		{name: "postgres: writer", want: "postgres_writer"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, metricSuffix(tt.name))
+		})
+	}
+}
+
+func TestParseFileNr(t *testing.T) {
+
+	sample, err := parseFileNr("2048\t0\t9223372036854775807\n")
+	require.NoError(t, err)
+	assert.Equal(t, 2048.0, sample.Gauges["OpenFileDescriptors"])
+	assert.Equal(t, 9223372036854775807.0, sample.Gauges["MaxFileDescriptors"])
+
+	_, err = parseFileNr("2048 0")
+	assert.Error(t, err)
+}
+
+func TestNetCollector_Deltas(t *testing.T) {
+
+	c := &netCollector{io: newDeltas()}
+
+	sample, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, sample.Counters, "первый сбор только запоминает значения счетчиков")
+
+	sample, err = c.Collect(context.Background())
+	require.NoError(t, err)
+	for name, delta := range sample.Counters {
+		assert.GreaterOrEqual(t, delta, int64(0), name)
+	}
+}
+
+func TestProcessCollector(t *testing.T) {
+
+	self, err := process.NewProcess(int32(os.Getpid()))
+	require.NoError(t, err)
+	name, err := self.Name()
+	require.NoError(t, err)
+
+	c := &processCollector{names: []string{name, "no-such-process"}, processes: make(map[int32]*process.Process)}
+
+	sample, err := c.Collect(context.Background())
+	require.NoError(t, err)
+
+	suffix := metricSuffix(name)
+	assert.GreaterOrEqual(t, sample.Gauges["ProcessCount_"+suffix], 1.0)
+	assert.Greater(t, sample.Gauges["ProcessRSS_"+suffix], 0.0)
+	assert.Equal(t, 0.0, sample.Gauges["ProcessCount_no_such_process"], "отсутствующий процесс с нулевыми значениями")
+}
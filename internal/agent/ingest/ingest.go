@@ -0,0 +1,182 @@
+// Package ingest Прием метрик от приложений на хосте агента по HTTP и UDP.
+// Метрики в формате models.Metrics складываются в хранилище агента и отправляются на сервер вместе с собственными:
+// приращения counter суммируются до отправки, у gauge остается последнее значение до истечения времени жизни без обновления
+package ingest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/s-turchinskiy/metrics/internal/agent/logger"
+	"github.com/s-turchinskiy/metrics/internal/agent/models"
+)
+
+// maxMessageSize Максимальный размер тела запроса HTTP и датаграммы UDP
+const maxMessageSize = 1024 * 1024
+
+const shutdownTimeout = 5 * time.Second
+
+// Storage Хранилище агента
+type Storage interface {
+	Merge(gauges map[string]float64, counters map[string]int64) error
+}
+
+// Decode Разбор одной метрики или массива метрик в значения gauge и приращения counter
+func Decode(data []byte) (map[string]float64, map[string]int64, error) {
+
+	var metrics []models.Metrics
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		if err := json.Unmarshal(data, &metrics); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		var metric models.Metrics
+		if err := json.Unmarshal(data, &metric); err != nil {
+			return nil, nil, err
+		}
+		metrics = append(metrics, metric)
+	}
+
+	gauges := make(map[string]float64)
+	counters := make(map[string]int64)
+	for _, metric := range metrics {
+
+		if metric.ID == "" {
+			return nil, nil, errors.New("metric id is empty")
+		}
+		// хранилище агента не различает серии одной метрики
+		if len(metric.Labels) > 0 {
+			return nil, nil, fmt.Errorf("metric %s: labels are not supported by agent", metric.ID)
+		}
+
+		switch metric.MType {
+		case "gauge":
+			if metric.Value == nil {
+				return nil, nil, fmt.Errorf("metric %s: value is required for gauge", metric.ID)
+			}
+			gauges[metric.ID] = *metric.Value
+		case "counter":
+			if metric.Delta == nil {
+				return nil, nil, fmt.Errorf("metric %s: delta is required for counter", metric.ID)
+			}
+			counters[metric.ID] += *metric.Delta
+		default:
+			return nil, nil, fmt.Errorf("metric %s: unknown type %q", metric.ID, metric.MType)
+		}
+	}
+
+	return gauges, counters, nil
+}
+
+// Handler Обработчик POST /update/ и /updates/ с телом как у сервера, в том числе сжатым gzip
+func Handler(storage Storage) http.Handler {
+
+	mux := http.NewServeMux()
+	for _, pattern := range []string{"POST /update", "POST /update/", "POST /updates", "POST /updates/"} {
+		mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			handle(w, r, storage)
+		})
+	}
+
+	return mux
+}
+
+func handle(w http.ResponseWriter, r *http.Request, storage Storage) {
+
+	var body io.Reader = http.MaxBytesReader(w, r.Body, maxMessageSize)
+	if strings.Contains(r.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer gz.Close()
+		body = io.LimitReader(gz, maxMessageSize)
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	gauges, counters, err := Decode(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err = storage.Merge(gauges, counters); err != nil {
+		logger.Log.Infow("ingest storage error", "error", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// ServeHTTP Прием метрик по HTTP на listener до отмены ctx
+func ServeHTTP(ctx context.Context, listener net.Listener, storage Storage) error {
+
+	server := &http.Server{
+		Handler:           Handler(storage),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Log.Infow("ingest http shutdown error", "error", err.Error())
+		}
+	}()
+
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// ServeUDP Прием метрик по UDP до отмены ctx: датаграмма - одна метрика или массив метрик в JSON.
+// Неверные датаграммы пропускаются
+func ServeUDP(ctx context.Context, conn net.PacketConn, storage Storage) error {
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	buffer := make([]byte, maxMessageSize)
+	for {
+
+		n, addr, err := conn.ReadFrom(buffer)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		gauges, counters, err := Decode(buffer[:n])
+		if err != nil {
+			logger.Log.Infow("ingest udp decode error", "from", addr.String(), "error", err.Error())
+			continue
+		}
+
+		if err = storage.Merge(gauges, counters); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package ingest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type storage struct {
+	mutex   sync.Mutex
+	gauges  map[string]float64
+	counter map[string]int64
+}
+
+func newStorage() *storage {
+	return &storage{gauges: make(map[string]float64), counter: make(map[string]int64)}
+}
+
+func (s *storage) Merge(gauges map[string]float64, counters map[string]int64) error {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for name, value := range gauges {
+		s.gauges[name] = value
+	}
+	for name, delta := range counters {
+		s.counter[name] += delta
+	}
+
+	return nil
+}
+
+func (s *storage) snapshot() (map[string]float64, map[string]int64) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	gauges := make(map[string]float64, len(s.gauges))
+	for name, value := range s.gauges {
+		gauges[name] = value
+	}
+	counters := make(map[string]int64, len(s.counter))
+	for name, value := range s.counter {
+		counters[name] = value
+	}
+
+	return gauges, counters
+}
+
+func TestDecode(t *testing.T) {
+
+	tests := []struct {
+		name         string
+		data         string
+		wantGauges   map[string]float64
+		wantCounters map[string]int64
+		wantErr      bool
+	}{
+		{
+			name:         "одна метрика",
+			data:         `{"id":"QueueLength","type":"gauge","value":5}`,
+			wantGauges:   map[string]float64{"QueueLength": 5},
+			wantCounters: map[string]int64{},
+		},
+		{
+			name:         "массив: counter суммируются, последнее значение gauge",
+			data:         ` [{"id":"Orders","type":"counter","delta":2},{"id":"QueueLength","type":"gauge","value":5},{"id":"Orders","type":"counter","delta":3},{"id":"QueueLength","type":"gauge","value":1}]`,
+			wantGauges:   map[string]float64{"QueueLength": 1},
+			wantCounters: map[string]int64{"Orders": 5},
+		},
+		{name: "неверный JSON", data: `{"id":`, wantErr: true},
+		{name: "без имени", data: `{"type":"gauge","value":1}`, wantErr: true},
+		{name: "gauge без значения", data: `{"id":"QueueLength","type":"gauge"}`, wantErr: true},
+		{name: "counter без значения", data: `{"id":"Orders","type":"counter","value":1}`, wantErr: true},
+		{name: "неизвестный тип", data: `{"id":"Orders","type":"histogram","value":1}`, wantErr: true},
+		{name: "метки", data: `{"id":"Orders","type":"counter","delta":1,"labels":{"shop":"a"}}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			gauges, counters, err := Decode([]byte(tt.data))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantGauges, gauges)
+			assert.Equal(t, tt.wantCounters, counters)
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+
+	gzipped := func(data string) []byte {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, _ = zw.Write([]byte(data))
+		_ = zw.Close()
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     []byte
+		encoding string
+		want     int
+	}{
+		{name: "update", method: http.MethodPost, path: "/update/", body: []byte(`{"id":"Orders","type":"counter","delta":2}`), want: http.StatusOK},
+		{name: "updates", method: http.MethodPost, path: "/updates", body: []byte(`[{"id":"Orders","type":"counter","delta":3}]`), want: http.StatusOK},
+		{name: "gzip", method: http.MethodPost, path: "/update", body: gzipped(`{"id":"QueueLength","type":"gauge","value":7}`), encoding: "gzip", want: http.StatusOK},
+		{name: "неверная метрика", method: http.MethodPost, path: "/update/", body: []byte(`{"id":"Orders","type":"counter"}`), want: http.StatusBadRequest},
+		{name: "GET", method: http.MethodGet, path: "/update/", want: http.StatusMethodNotAllowed},
+	}
+
+	s := newStorage()
+	handler := Handler(s)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			request := httptest.NewRequest(tt.method, tt.path, bytes.NewReader(tt.body))
+			if tt.encoding != "" {
+				request.Header.Set("Content-Encoding", tt.encoding)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+			assert.Equal(t, tt.want, recorder.Code)
+		})
+	}
+
+	gauges, counters := s.snapshot()
+	assert.Equal(t, map[string]float64{"QueueLength": 7}, gauges)
+	assert.Equal(t, map[string]int64{"Orders": 5}, counters)
+}
+
+func TestServeUDP(t *testing.T) {
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := newStorage()
+	done := make(chan error, 1)
+	go func() {
+		done <- ServeUDP(ctx, conn, s)
+	}()
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	for _, message := range []string{
+		`{"id":"Orders","type":"counter","delta":2}`,
+		`not json`,
+		`[{"id":"Orders","type":"counter","delta":3},{"id":"QueueLength","type":"gauge","value":1.5}]`,
+	} {
+		_, err = client.Write([]byte(message))
+		require.NoError(t, err)
+	}
+
+	assert.Eventually(t, func() bool {
+		_, counters := s.snapshot()
+		return counters["Orders"] == 5
+	}, time.Second, 10*time.Millisecond)
+
+	gauges, _ := s.snapshot()
+	assert.Equal(t, map[string]float64{"QueueLength": 1.5}, gauges)
+
+	cancel()
+	select {
+	case err = <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("ServeUDP не завершился после отмены контекста")
+	}
+}
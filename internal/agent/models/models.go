@@ -2,9 +2,10 @@
 package models
 
 type Metrics struct {
-	ID    string   `json:"id"`              // имя метрики
-	MType string   `json:"type"`            // параметр, принимающий значение gauge или counter
-	Delta *int64   `json:"delta,omitempty"` // значение метрики в случае передачи counter
-	Value *float64 `json:"value,omitempty"` // значение метрики в случае передачи gauge
+	ID     string            `json:"id"`               // имя метрики
+	MType  string            `json:"type"`             // параметр, принимающий значение gauge или counter
+	Delta  *int64            `json:"delta,omitempty"`  // значение метрики в случае передачи counter
+	Value  *float64          `json:"value,omitempty"`  // значение метрики в случае передачи gauge
+	Labels map[string]string `json:"labels,omitempty"` // метки серии, вместе с именем определяют метрику на сервере
 
 }
@@ -11,15 +11,35 @@ import (
 	"github.com/s-turchinskiy/metrics/internal/agent/retrier"
 	"github.com/s-turchinskiy/metrics/internal/agent/services"
 	"github.com/s-turchinskiy/metrics/internal/agent/services/sendmetrics"
+	"github.com/s-turchinskiy/metrics/internal/agent/spool"
 )
 
+// ReportMetrics При непустом metricsSpool неотправленные метрики сохраняются в очередь на диске
+// и отправляются по порядку перед новыми, пока очередь не опустеет
 func ReportMetrics(ctx context.Context,
 	h *services.MetricsHandler,
 	sender sendmetric.MetricSender,
+	metricsSpool *spool.Spool,
 	reportInterval,
 	rateLimit int,
 	errorsChan chan error) {
 
+	report(ctx, h, sender, metricsSpool, reportInterval, rateLimit, errorsChan,
+		func(metrics []models.Metrics, opts []sendmetrics.Option) *sendmetrics.SendMetrics {
+			return sendmetrics.New(generator(ctx, metrics), sender, retrier.ReportMetricRetry1{}, opts...)
+		})
+}
+
+// report Отправка метрик хранилища каждые reportInterval секунд заданиями newSendMetrics в rateLimit воркеров
+func report(ctx context.Context,
+	h *services.MetricsHandler,
+	sender sendmetric.MetricSender,
+	metricsSpool *spool.Spool,
+	reportInterval,
+	rateLimit int,
+	errorsChan chan error,
+	newSendMetrics func(metrics []models.Metrics, opts []sendmetrics.Option) *sendmetrics.SendMetrics) {
+
 	ticker := time.NewTicker(time.Duration(reportInterval) * time.Second)
 	for range ticker.C {
 
@@ -27,33 +47,75 @@ func ReportMetrics(ctx context.Context,
 		case <-ctx.Done():
 			return
 		default:
-
-			metrics, err := h.Storage.GetMetrics()
-			if err != nil {
-				logger.Log.Infoln("failed to report metrics", err.Error())
+			if err := reportOnce(ctx, h, sender, metricsSpool, rateLimit, newSendMetrics); err != nil {
 				errorsChan <- err
 				return
 			}
+		}
+	}
+}
 
-			jobs := generator(ctx, metrics)
+// reportOnce Одна отправка метрик хранилища. Значения counter отправленных и сохраненных в очередь метрик
+// вычитаются из хранилища, поэтому каждая отправка содержит только приращения с прошлой
+func reportOnce(ctx context.Context,
+	h *services.MetricsHandler,
+	sender sendmetric.MetricSender,
+	metricsSpool *spool.Spool,
+	rateLimit int,
+	newSendMetrics func(metrics []models.Metrics, opts []sendmetrics.Option) *sendmetrics.SendMetrics) error {
 
-			sendMetrics := sendmetrics.New(
-				jobs,
-				sender,
-				retrier.ReportMetricRetry1{},
-			)
+	metrics, err := h.Storage.GetMetrics()
+	if err != nil {
+		logger.Log.Infoln("failed to report metrics", err.Error())
+		return err
+	}
 
-			for w := 1; w <= rateLimit; w++ {
-				go sendMetrics.WorkerSender(ctx)
+	opts := []sendmetrics.Option{sendmetrics.WithCounterReset(h.Storage)}
+	if metricsSpool != nil {
+		if !replaySpool(metricsSpool, sender) {
+			// сервер недоступен, новые метрики встают в очередь за неотправленными
+			if err = metricsSpool.Push(metrics...); err != nil {
+				logger.Log.Infow("spool push error", "error", err.Error())
+			} else if err = h.Storage.ResetCounters(metrics); err != nil {
+				logger.Log.Infow("reset counters error", "error", err.Error())
 			}
-
-			sendMetrics.ResultHandling(ctx)
+			return nil
 		}
+		opts = append(opts, sendmetrics.WithSpool(metricsSpool))
+	}
+
+	sendMetrics := newSendMetrics(metrics, opts)
+
+	for w := 1; w <= rateLimit; w++ {
+		go sendMetrics.WorkerSender(ctx)
+	}
+
+	sendMetrics.ResultHandling(ctx)
+
+	return nil
+}
+
+// replaySpool Отправка очереди, false - очередь отправлена не полностью
+func replaySpool(metricsSpool *spool.Spool, sender sendmetric.MetricSender) bool {
+
+	if metricsSpool.Len() == 0 {
+		return true
 	}
+
+	sent, err := metricsSpool.Replay(sender.Send)
+	if sent != 0 {
+		logger.Log.Infow("spooled metrics sent", "count", sent, "left", metricsSpool.Len())
+	}
+	if err != nil {
+		logger.Log.Infow("spool replay error", "error", err.Error(), "left", metricsSpool.Len())
+		return false
+	}
+
+	return true
 }
 
-func generator(ctx context.Context, input []models.Metrics) chan models.Metrics {
-	inputCh := make(chan models.Metrics, len(input))
+func generator[T any](ctx context.Context, input []T) chan T {
+	inputCh := make(chan T, len(input))
 
 	go func() {
 		defer close(inputCh)
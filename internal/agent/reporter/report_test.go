@@ -0,0 +1,216 @@
+package reporter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/s-turchinskiy/metrics/internal/agent/collector"
+	"github.com/s-turchinskiy/metrics/internal/agent/models"
+	"github.com/s-turchinskiy/metrics/internal/agent/repositories"
+	"github.com/s-turchinskiy/metrics/internal/agent/services"
+	"github.com/s-turchinskiy/metrics/internal/agent/services/sendmetric/httpresty"
+	"github.com/s-turchinskiy/metrics/internal/agent/services/sendmetrics"
+	"github.com/s-turchinskiy/metrics/internal/agent/spool"
+	"github.com/s-turchinskiy/metrics/internal/server/handlers"
+	"github.com/s-turchinskiy/metrics/internal/server/repository/memcashed"
+)
+
+// recordSender Запоминает отправленные приращения counter, при fail возвращает ошибку
+type recordSender struct {
+	mutex    sync.Mutex
+	fail     bool
+	counters map[string]int64
+}
+
+func (s *recordSender) Send(metric models.Metrics) error {
+	return s.SendBatch([]models.Metrics{metric})
+}
+
+func (s *recordSender) SendBatch(metrics []models.Metrics) error {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.fail {
+		return errors.New("server unavailable")
+	}
+
+	for _, metric := range metrics {
+		if metric.MType == "counter" {
+			s.counters[metric.ID] += *metric.Delta
+		}
+	}
+
+	return nil
+}
+
+// take Отправленные с прошлого вызова приращения
+func (s *recordSender) take() map[string]int64 {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	counters := s.counters
+	s.counters = make(map[string]int64)
+	return counters
+}
+
+func TestReportOnce_CounterIncrements(t *testing.T) {
+
+	ctx := context.Background()
+	sender := &recordSender{counters: make(map[string]int64)}
+	h := &services.MetricsHandler{Storage: &repositories.MetricsStorage{}}
+
+	newSendMetrics := func(metrics []models.Metrics, opts []sendmetrics.Option) *sendmetrics.SendMetrics {
+		return sendmetrics.New(generator(ctx, metrics), sender, nil, opts...)
+	}
+
+	tests := []struct {
+		name     string
+		counters map[string]int64
+		fail     bool
+		want     map[string]int64
+	}{
+		{name: "первая отправка", counters: map[string]int64{"BytesSent": 100}, want: map[string]int64{"BytesSent": 100}},
+		{name: "вторая отправка содержит только новое приращение", counters: map[string]int64{"BytesSent": 50}, want: map[string]int64{"BytesSent": 50}},
+		{name: "ошибка отправки без очереди", counters: map[string]int64{"BytesSent": 10}, fail: true, want: map[string]int64{}},
+		{name: "неотправленное приращение уходит со следующей отправкой", counters: map[string]int64{"BytesSent": 5}, want: map[string]int64{"BytesSent": 15}},
+		{name: "без новых приращений counter не отправляется", want: map[string]int64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			require.NoError(t, h.Storage.UpdateSource("net", nil, tt.counters))
+			sender.fail = tt.fail
+
+			require.NoError(t, reportOnce(ctx, h, sender, nil, 2, newSendMetrics))
+
+			assert.Equal(t, tt.want, sender.take())
+		})
+	}
+}
+
+func TestReportOnce_ExecCollector(t *testing.T) {
+
+	ctx := context.Background()
+	sender := &recordSender{counters: make(map[string]int64)}
+	h := &services.MetricsHandler{Storage: &repositories.MetricsStorage{}}
+
+	newSendMetrics := func(metrics []models.Metrics, opts []sendmetrics.Option) *sendmetrics.SendMetrics {
+		return sendmetrics.New(generator(ctx, metrics), sender, nil, opts...)
+	}
+
+	plainPath := filepath.Join(t.TempDir(), "plain")
+	nagiosPath := filepath.Join(t.TempDir(), "nagios")
+	collectors := collector.ExecCollectors([]collector.ExecCommand{
+		{Name: "queue", Command: "cat", Args: []string{plainPath}},
+		{Name: "http", Command: "sh", Format: collector.FormatNagios,
+			Args: []string{"-c", "echo \"OK | requests=$(cat " + nagiosPath + ")c\""}},
+	}, time.Second)
+
+	tests := []struct {
+		name   string
+		plain  string
+		nagios []string // значения накопленного счетчика на каждом опросе
+		want   map[string]int64
+	}{
+		{
+			name:   "первый отчет",
+			plain:  "QueueProcessed counter 3",
+			nagios: []string{"100", "130"},
+			want:   map[string]int64{"QueueProcessed": 6, "requests": 30, "ExecErrors_queue": 0, "ExecErrors_http": 0},
+		},
+		{
+			name:   "второй отчет содержит только приращения",
+			plain:  "QueueProcessed 3",
+			nagios: []string{"150", "160"},
+			want:   map[string]int64{"requests": 30, "ExecErrors_queue": 2, "ExecErrors_http": 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			require.NoError(t, os.WriteFile(plainPath, []byte(tt.plain), 0o600))
+			for _, value := range tt.nagios {
+				require.NoError(t, os.WriteFile(nagiosPath, []byte(value), 0o600))
+				for _, c := range collectors {
+					sample, err := c.Collector.Collect(ctx)
+					require.NoError(t, err)
+					require.NoError(t, h.Storage.UpdateSource(c.Name, sample.Gauges, sample.Counters))
+				}
+			}
+
+			require.NoError(t, reportOnce(ctx, h, sender, nil, 2, newSendMetrics))
+
+			assert.Equal(t, tt.want, sender.take())
+		})
+	}
+}
+
+func TestReportOnce_SpoolOutage(t *testing.T) {
+
+	ctx := context.Background()
+	rep := memcashed.New()
+	router := handlers.Router(handlers.NewHandler(ctx, rep, "", true), nil)
+
+	var unavailable atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unavailable.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		router.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	metricsSpool, err := spool.Open(t.TempDir(), 0, 0)
+	require.NoError(t, err)
+
+	sender := httpresty.New(server.URL + "/update/")
+	h := &services.MetricsHandler{Storage: &repositories.MetricsStorage{}}
+	newSendMetrics := func(metrics []models.Metrics, opts []sendmetrics.Option) *sendmetrics.SendMetrics {
+		return sendmetrics.New(generator(ctx, metrics), sender, nil, opts...)
+	}
+
+	tests := []struct {
+		name        string
+		delta       int64
+		unavailable bool
+		wantSpooled int
+		want        int64
+	}{
+		{name: "сервер доступен", delta: 10, want: 10},
+		{name: "ошибка отправки, приращение в очереди", delta: 5, unavailable: true, wantSpooled: 1, want: 10},
+		{name: "очередь не отправлена, приращения суммируются", delta: 7, unavailable: true, wantSpooled: 1, want: 10},
+		{name: "восстановление: очередь и новое приращение", delta: 3, want: 25},
+		{name: "без новых приращений итог не меняется", want: 25},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			require.NoError(t, h.Storage.UpdateSource("net", nil, map[string]int64{"BytesSent": tt.delta}))
+			unavailable.Store(tt.unavailable)
+
+			require.NoError(t, reportOnce(ctx, h, sender, metricsSpool, 2, newSendMetrics))
+
+			assert.Equal(t, tt.wantSpooled, metricsSpool.Len())
+			value, _, err := rep.GetCounter(ctx, "BytesSent")
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, value, "итог на сервере - сумма приращений")
+		})
+	}
+}
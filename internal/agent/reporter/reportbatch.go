@@ -1,71 +1,65 @@
 package reporter
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
-	"net/http"
-	"time"
 
-	"github.com/go-resty/resty/v2"
-
-	"github.com/s-turchinskiy/metrics/internal/agent/logger"
+	"github.com/s-turchinskiy/metrics/internal/agent/models"
+	"github.com/s-turchinskiy/metrics/internal/agent/retrier"
 	"github.com/s-turchinskiy/metrics/internal/agent/services"
+	"github.com/s-turchinskiy/metrics/internal/agent/services/sendmetric"
+	"github.com/s-turchinskiy/metrics/internal/agent/services/sendmetrics"
+	"github.com/s-turchinskiy/metrics/internal/agent/spool"
 )
 
-func ReportMetricsBatch(h *services.MetricsHandler, reportInterval int, errors chan error) {
-
-	url := fmt.Sprintf("%s/updates/", h.ServerAddress)
-
-	ticker := time.NewTicker(time.Duration(reportInterval) * time.Second)
-	for range ticker.C {
+// ReportMetricsBatch Отправка метрик пачками не больше batchMaxSize байт JSON, 0 - одной пачкой.
+// Сервер складывает пачки с сохраненными метриками и по HTTP (/updates/add/), и по gRPC, поэтому отчет можно делить на пачки.
+// Повторы, воркеры и очередь неотправленных - как в ReportMetrics, очередь отправляется по одной метрике
+func ReportMetricsBatch(ctx context.Context,
+	h *services.MetricsHandler,
+	sender sendmetric.BatchSender,
+	metricsSpool *spool.Spool,
+	reportInterval,
+	rateLimit,
+	batchMaxSize int,
+	errorsChan chan error) {
+
+	report(ctx, h, sender, metricsSpool, reportInterval, rateLimit, errorsChan,
+		func(metrics []models.Metrics, opts []sendmetrics.Option) *sendmetrics.SendMetrics {
+			return sendmetrics.NewBatch(generator(ctx, chunk(metrics, batchMaxSize)), sender, retrier.ReportMetricRetry1{}, opts...)
+		})
+}
 
-		client := resty.New()
+// chunk Разбиение метрик на пачки, размер каждой в JSON не больше maxSize байт.
+// Метрика больше maxSize отправляется отдельной пачкой
+func chunk(metrics []models.Metrics, maxSize int) [][]models.Metrics {
 
-		metrics, err := h.Storage.GetMetrics()
-		if err != nil {
-			logger.Log.Infoln("failed to report metrics batch", err.Error())
-			errors <- err
-			return
-		}
+	if len(metrics) == 0 {
+		return nil
+	}
+	if maxSize <= 0 {
+		return [][]models.Metrics{metrics}
+	}
 
-		resp, err := client.R().
-			SetHeader("Content-Type", "application/json").
-			SetBody(metrics).
-			Post(url)
+	var result [][]models.Metrics
+	var current []models.Metrics
+	size := 2 // скобки массива
+	for _, metric := range metrics {
 
+		data, err := json.Marshal(metric)
 		if err != nil {
-
-			var bytes []byte
-			bytes, err2 := json.Marshal(metrics)
-			if err2 != nil {
-				logger.Log.Infow("conversion error metric",
-					"error", err2.Error(),
-					"url", url,
-				)
-			}
-
-			logger.Log.Infow("error sending request",
-				"error", err.Error(),
-				"url", url,
-				"body", string(bytes))
-
-			errors <- err
-			return
+			// ошибку кодирования вернет отправка пачки
+			data = nil
 		}
+		metricSize := len(data) + 1 // запятая
 
-		if resp.StatusCode() != http.StatusOK {
-
-			logger.Log.Infow("error. status code <> 200",
-				"status code", resp.StatusCode(),
-				"url", url,
-				"body", string(resp.Body()))
-			err := fmt.Errorf("status code <> 200, = %d, url : %s", resp.StatusCode(), url)
-
-			errors <- err
-			return
-
+		if len(current) > 0 && size+metricSize > maxSize {
+			result = append(result, current)
+			current, size = nil, 2
 		}
-
-		logger.Log.Info("Success ReportMetricsBatch ", string(resp.Body()))
+		current = append(current, metric)
+		size += metricSize
 	}
+
+	return append(result, current)
 }
@@ -0,0 +1,97 @@
+package reporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/s-turchinskiy/metrics/internal/agent/models"
+	"github.com/s-turchinskiy/metrics/internal/agent/repositories"
+	"github.com/s-turchinskiy/metrics/internal/agent/services"
+	"github.com/s-turchinskiy/metrics/internal/agent/services/sendmetrics"
+)
+
+func TestChunk(t *testing.T) {
+
+	metrics := make([]models.Metrics, 10)
+	for i := range metrics {
+		value := float64(i)
+		metrics[i] = models.Metrics{ID: fmt.Sprintf("Metric%d", i), MType: "gauge", Value: &value}
+	}
+
+	one, err := json.Marshal(metrics[:1])
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		maxSize int
+		want    []int
+	}{
+		{name: "без ограничения", maxSize: 0, want: []int{10}},
+		{name: "по 3 метрики", maxSize: 3*len(one) + 1, want: []int{3, 3, 3, 1}},
+		{name: "метрика больше ограничения", maxSize: 10, want: []int{1, 1, 1, 1, 1, 1, 1, 1, 1, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			chunks := chunk(metrics, tt.maxSize)
+
+			var sizes []int
+			var all []models.Metrics
+			for _, c := range chunks {
+				sizes = append(sizes, len(c))
+				all = append(all, c...)
+
+				if tt.maxSize > 0 && len(c) > 1 {
+					data, err := json.Marshal(c)
+					require.NoError(t, err)
+					assert.LessOrEqual(t, len(data), tt.maxSize)
+				}
+			}
+			assert.Equal(t, tt.want, sizes)
+			assert.Equal(t, metrics, all, "порядок и состав метрик сохраняются")
+		})
+	}
+
+	assert.Empty(t, chunk(nil, 100))
+}
+
+func TestReportOnce_Chunks(t *testing.T) {
+
+	ctx := context.Background()
+	// сервер складывает пачки /updates/add/ с сохраненными метриками, получатель суммирует так же
+	sender := &recordSender{counters: make(map[string]int64)}
+	h := &services.MetricsHandler{Storage: &repositories.MetricsStorage{}}
+
+	var batches int
+	newSendMetrics := func(metrics []models.Metrics, opts []sendmetrics.Option) *sendmetrics.SendMetrics {
+		chunks := chunk(metrics, 100)
+		batches += len(chunks)
+		return sendmetrics.NewBatch(generator(ctx, chunks), sender, nil, opts...)
+	}
+
+	counters := make(map[string]int64)
+	for i := 0; i < 10; i++ {
+		counters[fmt.Sprintf("Counter%d", i)] = int64(i + 1)
+	}
+
+	want := make(map[string]int64)
+	for report := 0; report < 2; report++ {
+
+		require.NoError(t, h.Storage.UpdateSource("net", nil, counters))
+		require.NoError(t, h.Storage.UpdateSource("net", nil, counters))
+		for name, delta := range counters {
+			want[name] += 2 * delta
+		}
+
+		require.NoError(t, reportOnce(ctx, h, sender, nil, 3, newSendMetrics))
+	}
+
+	assert.Greater(t, batches, 2, "отчет разбит на несколько пачек")
+	assert.Equal(t, want, sender.counters, "итог на сервере - сумма приращений всех опросов")
+}
@@ -3,15 +3,20 @@ package repositories
 
 import (
 	"sync"
+	"time"
 
 	"github.com/s-turchinskiy/metrics/internal/agent/logger"
 	"github.com/s-turchinskiy/metrics/internal/agent/models"
 )
 
 type MetricsStorage struct {
-	Gauge   map[string]float64
-	Counter map[string]int64
-	mutex   sync.Mutex
+	Gauge     map[string]float64
+	Counter   map[string]int64
+	IngestTTL time.Duration       // время жизни gauge, принятых через Merge, без обновления; 0 - без ограничения
+	sources   map[string][]string // имена gauge каждого источника
+	merged    map[string]time.Time
+	now       func() time.Time
+	mutex     sync.Mutex
 }
 
 func (s *MetricsStorage) GetMetrics() ([]models.Metrics, error) {
@@ -21,6 +26,8 @@ func (s *MetricsStorage) GetMetrics() ([]models.Metrics, error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	s.expireMerged()
+
 	for ID, value := range s.Gauge {
 
 		metric := models.Metrics{ID: ID, MType: "gauge", Value: &value}
@@ -39,6 +46,33 @@ func (s *MetricsStorage) GetMetrics() ([]models.Metrics, error) {
 
 }
 
+// ResetCounters Вычитание отправленных значений counter, следующая отправка содержит только новые приращения.
+// Вызывается после успешной отправки или сохранения в очередь, приращения после GetMetrics сохраняются
+func (s *MetricsStorage) ResetCounters(sent []models.Metrics) error {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, metric := range sent {
+		if metric.MType != "counter" || metric.Delta == nil {
+			continue
+		}
+
+		value, exist := s.Counter[metric.ID]
+		if !exist {
+			continue
+		}
+
+		if value -= *metric.Delta; value == 0 {
+			delete(s.Counter, metric.ID)
+		} else {
+			s.Counter[metric.ID] = value
+		}
+	}
+
+	return nil
+}
+
 func (s *MetricsStorage) UpdateMetrics(metrics map[string]float64) error {
 
 	s.mutex.Lock()
@@ -51,3 +85,97 @@ func (s *MetricsStorage) UpdateMetrics(metrics map[string]float64) error {
 
 	return nil
 }
+
+// UpdateSource Значения gauge источника source заменяют его прежние значения, значения counters прибавляются
+func (s *MetricsStorage) UpdateSource(source string, gauges map[string]float64, counters map[string]int64) error {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.Gauge == nil {
+		s.Gauge = make(map[string]float64, len(gauges))
+	}
+	if s.Counter == nil {
+		s.Counter = make(map[string]int64, len(counters))
+	}
+	if s.sources == nil {
+		s.sources = make(map[string][]string)
+	}
+
+	for _, name := range s.sources[source] {
+		delete(s.Gauge, name)
+	}
+
+	names := make([]string, 0, len(gauges))
+	for name, value := range gauges {
+		s.Gauge[name] = value
+		names = append(names, name)
+		delete(s.merged, name)
+	}
+	s.sources[source] = names
+
+	for name, delta := range counters {
+		s.Counter[name] += delta
+	}
+
+	logger.Log.Debugw("UpdateSource", "source", source, "gauges", len(gauges), "PollCount", s.Counter["PollCount"])
+
+	return nil
+}
+
+// Merge Значения gauge заменяют прежние значения с тем же именем, значения counters прибавляются.
+// Метрики не привязаны к источнику: gauge без обновления дольше IngestTTL удаляются при GetMetrics
+func (s *MetricsStorage) Merge(gauges map[string]float64, counters map[string]int64) error {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.Gauge == nil {
+		s.Gauge = make(map[string]float64, len(gauges))
+	}
+	if s.Counter == nil {
+		s.Counter = make(map[string]int64, len(counters))
+	}
+
+	if s.merged == nil {
+		s.merged = make(map[string]time.Time, len(gauges))
+	}
+
+	now := s.clock()
+	for name, value := range gauges {
+		s.Gauge[name] = value
+		s.merged[name] = now
+	}
+
+	for name, delta := range counters {
+		s.Counter[name] += delta
+	}
+
+	logger.Log.Debugw("Merge", "gauges", len(gauges), "counters", len(counters))
+
+	return nil
+}
+
+// expireMerged Удаление gauge, принятых через Merge и не обновлявшихся дольше IngestTTL
+func (s *MetricsStorage) expireMerged() {
+
+	if s.IngestTTL <= 0 {
+		return
+	}
+
+	now := s.clock()
+	for name, updated := range s.merged {
+		if now.Sub(updated) > s.IngestTTL {
+			delete(s.Gauge, name)
+			delete(s.merged, name)
+		}
+	}
+}
+
+func (s *MetricsStorage) clock() time.Time {
+
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestMetricsStorage_GetMetrics(t *testing.T) {
@@ -93,3 +94,77 @@ func TestMetricsStorage_UpdateMetrics(t *testing.T) {
 		})
 	}
 }
+
+func TestMetricsStorage_UpdateSource(t *testing.T) {
+
+	s := &MetricsStorage{}
+
+	assert.NoError(t, s.UpdateSource("cpu", map[string]float64{"CPUutilization0": 10, "CPUutilization1": 20}, nil))
+	assert.NoError(t, s.UpdateSource("runtime", map[string]float64{"Alloc": 1}, map[string]int64{"PollCount": 1}))
+	assert.NoError(t, s.UpdateSource("cpu", map[string]float64{"CPUutilization0": 30}, nil))
+	assert.NoError(t, s.UpdateSource("runtime", map[string]float64{"Alloc": 2}, map[string]int64{"PollCount": 1}))
+
+	assert.Equal(t, map[string]float64{"CPUutilization0": 30, "Alloc": 2}, s.Gauge, "значения источника заменяются целиком")
+	assert.Equal(t, map[string]int64{"PollCount": 2}, s.Counter, "приращения counter складываются")
+}
+
+func TestMetricsStorage_Merge(t *testing.T) {
+
+	s := &MetricsStorage{}
+
+	assert.NoError(t, s.UpdateSource("runtime", map[string]float64{"Alloc": 1}, map[string]int64{"PollCount": 1}))
+	assert.NoError(t, s.Merge(map[string]float64{"QueueLength": 5}, map[string]int64{"Orders": 2}))
+	assert.NoError(t, s.Merge(map[string]float64{"QueueLength": 3}, map[string]int64{"Orders": 1}))
+	assert.NoError(t, s.UpdateSource("runtime", map[string]float64{"Alloc": 2}, map[string]int64{"PollCount": 1}))
+
+	assert.Equal(t, map[string]float64{"Alloc": 2, "QueueLength": 3}, s.Gauge, "последнее значение gauge, источники его не удаляют")
+	assert.Equal(t, map[string]int64{"PollCount": 2, "Orders": 3}, s.Counter)
+}
+
+func TestMetricsStorage_ResetCounters(t *testing.T) {
+
+	s := &MetricsStorage{}
+
+	assert.NoError(t, s.UpdateSource("net", map[string]float64{"Load": 1}, map[string]int64{"BytesSent": 100, "PollCount": 1}))
+
+	sent, err := s.GetMetrics()
+	assert.NoError(t, err)
+
+	// приращение после GetMetrics не должно потеряться
+	assert.NoError(t, s.UpdateSource("net", map[string]float64{"Load": 2}, map[string]int64{"BytesSent": 30}))
+	assert.NoError(t, s.ResetCounters(sent))
+
+	assert.Equal(t, map[string]int64{"BytesSent": 30}, s.Counter, "остаются только новые приращения")
+	assert.Equal(t, map[string]float64{"Load": 2}, s.Gauge, "gauge не сбрасываются")
+
+	assert.NoError(t, s.ResetCounters(nil))
+	assert.Equal(t, map[string]int64{"BytesSent": 30}, s.Counter)
+}
+
+func TestMetricsStorage_IngestTTL(t *testing.T) {
+
+	now := time.Now()
+	s := &MetricsStorage{IngestTTL: time.Minute, now: func() time.Time { return now }}
+
+	assert.NoError(t, s.UpdateSource("runtime", map[string]float64{"Alloc": 1}, nil))
+	assert.NoError(t, s.Merge(map[string]float64{"QueueLength": 5, "Workers": 2}, map[string]int64{"Orders": 1}))
+
+	now = now.Add(30 * time.Second)
+	assert.NoError(t, s.Merge(map[string]float64{"Workers": 3}, nil))
+
+	now = now.Add(45 * time.Second)
+	_, err := s.GetMetrics()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]float64{"Alloc": 1, "Workers": 3}, s.Gauge, "необновляемый gauge приложения удален, gauge источников не истекают")
+
+	now = now.Add(time.Minute)
+	_, err = s.GetMetrics()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]float64{"Alloc": 1}, s.Gauge)
+	assert.Equal(t, map[string]int64{"Orders": 1}, s.Counter, "counter удаляются только после отправки")
+
+	sent, err := s.GetMetrics()
+	assert.NoError(t, err)
+	assert.NoError(t, s.ResetCounters(sent))
+	assert.Empty(t, s.Counter, "принятый counter отправляется один раз")
+}
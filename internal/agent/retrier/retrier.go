@@ -14,6 +14,11 @@ type ReportMetricRetrier interface {
 	SendWithRetries(models.Metrics, func(models.Metrics) error) error
 }
 
+// ReportBatchRetrier Повторная отправка пачки метрик
+type ReportBatchRetrier interface {
+	SendBatchWithRetries([]models.Metrics, func([]models.Metrics) error) error
+}
+
 type ReportMetricRetry1 struct {
 	ReportMetricRetrier
 }
@@ -26,17 +31,26 @@ var retryIntervals = []time.Duration{
 }
 
 func (r ReportMetricRetry1) SendWithRetries(metric models.Metrics, f func(models.Metrics) error) error {
+	return retry(metric, func() error { return f(metric) })
+}
+
+// SendBatchWithRetries Повтор отправки пачки с теми же задержками, что и для одной метрики
+func (r ReportMetricRetry1) SendBatchWithRetries(metrics []models.Metrics, f func([]models.Metrics) error) error {
+	return retry(fmt.Sprintf("batch of %d metrics", len(metrics)), func() error { return f(metrics) })
+}
+
+func retry(data any, send func() error) error {
 
 	var err error
 	for i, delay := range retryIntervals {
 		time.Sleep(delay)
-		err = f(metric)
+		err = send()
 
 		if !itIsErrorConnectionRefused(err) {
 			return err
 		}
 
-		logger.Log.Infow(fmt.Sprintf("reportMetric attempt %d, server is not responding", i+1), "data", metric)
+		logger.Log.Infow(fmt.Sprintf("reportMetric attempt %d, server is not responding", i+1), "data", data)
 
 	}
 
@@ -0,0 +1,221 @@
+// Package grpcsender Отправка метрики через gRPC
+package grpcsender
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/s-turchinskiy/metrics/internal/agent/logger"
+	"github.com/s-turchinskiy/metrics/internal/agent/models"
+	"github.com/s-turchinskiy/metrics/internal/agent/services/sendmetric"
+	pb "github.com/s-turchinskiy/metrics/internal/proto"
+	"github.com/s-turchinskiy/metrics/internal/utils/errutil"
+	"github.com/s-turchinskiy/metrics/internal/utils/grpcutil"
+	"github.com/s-turchinskiy/metrics/internal/utils/netutil"
+)
+
+const defaultTimeout = 10 * time.Second
+
+type ReportMetricsGRPC struct {
+	conn      *grpc.ClientConn
+	client    pb.MetricsClient
+	addr      string
+	hashKey   string
+	keyID     string
+	apiKey    string
+	realIP    string
+	timeout   time.Duration
+	tlsConfig *tls.Config
+	dialOpts  []grpc.DialOption
+}
+
+type OptionGRPC func(*ReportMetricsGRPC)
+
+// New Создание клиента. Соединение устанавливается при первой отправке
+func New(addr string, opts ...OptionGRPC) (*ReportMetricsGRPC, error) {
+
+	r := &ReportMetricsGRPC{
+		addr:    addr,
+		timeout: defaultTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	// адрес для x-real-ip, по которому сервер проверяет доверенную подсеть
+	if r.realIP == "" {
+		if ip, err := netutil.OutboundIP("grpc://" + addr); err != nil {
+			logger.Log.Infow("error getting outbound ip", "error", err.Error(), "addr", addr)
+		} else {
+			r.realIP = ip.String()
+		}
+	}
+
+	creds := insecure.NewCredentials()
+	if r.tlsConfig != nil {
+		creds = credentials.NewTLS(r.tlsConfig)
+	}
+
+	md := metadata.MD{}
+	if r.apiKey != "" {
+		md.Set(grpcutil.AuthorizationMetadataKey, "Bearer "+r.apiKey)
+	}
+	if r.realIP != "" {
+		md.Set(grpcutil.RealIPMetadataKey, r.realIP)
+	}
+
+	dialOpts := append([]grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithChainUnaryInterceptor(MetadataUnaryInterceptor(md), HashUnaryInterceptor(r.hashKey, r.keyID)),
+		grpc.WithChainStreamInterceptor(MetadataStreamInterceptor(md), HashStreamInterceptor(r.hashKey, r.keyID)),
+	}, r.dialOpts...)
+
+	conn, err := grpc.NewClient(addr, dialOpts...)
+	if err != nil {
+		return nil, errutil.WrapError(err)
+	}
+
+	r.conn = conn
+	r.client = pb.NewMetricsClient(conn)
+
+	return r, nil
+}
+
+func WithHash(hashKey string) OptionGRPC {
+	return func(r *ReportMetricsGRPC) {
+		r.hashKey = hashKey
+	}
+}
+
+// WithKeyID Идентификатор ключа подписи на сервере, пустой - без идентификатора
+func WithKeyID(keyID string) OptionGRPC {
+	return func(r *ReportMetricsGRPC) {
+		r.keyID = keyID
+	}
+}
+
+// WithAPIKey Ключ API в метаданных authorization, если на сервере включена проверка ключей
+func WithAPIKey(apiKey string) OptionGRPC {
+	return func(r *ReportMetricsGRPC) {
+		r.apiKey = apiKey
+	}
+}
+
+// WithRealIP IP-адрес агента в метаданных x-real-ip. По умолчанию - адрес интерфейса, через который идут запросы к серверу
+func WithRealIP(ip string) OptionGRPC {
+	return func(r *ReportMetricsGRPC) {
+		r.realIP = ip
+	}
+}
+
+func WithTimeout(timeout time.Duration) OptionGRPC {
+	return func(r *ReportMetricsGRPC) {
+		r.timeout = timeout
+	}
+}
+
+// WithTLSConfig mTLS: сертификат агента и CA, которым проверяется сервер. nil - соединение без TLS
+func WithTLSConfig(config *tls.Config) OptionGRPC {
+	return func(r *ReportMetricsGRPC) {
+		r.tlsConfig = config
+	}
+}
+
+// WithDialOptions Дополнительные параметры соединения, например транспорт для тестов
+func WithDialOptions(opts ...grpc.DialOption) OptionGRPC {
+	return func(r *ReportMetricsGRPC) {
+		r.dialOpts = append(r.dialOpts, opts...)
+	}
+}
+
+func (r *ReportMetricsGRPC) Send(metric models.Metrics) error {
+
+	in, err := toProto(metric)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	_, err = r.client.UpdateMetric(ctx, &pb.UpdateMetricRequest{Metric: in})
+	if err != nil {
+		sendmetric.HandlerErrors(err, metric, r.addr)
+		return err
+	}
+
+	return nil
+}
+
+// SendBatch Отправка метрик одним потоком UpdateMetrics
+func (r *ReportMetricsGRPC) SendBatch(metrics []models.Metrics) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	stream, err := r.client.UpdateMetrics(ctx)
+	if err != nil {
+		return errutil.WrapError(err)
+	}
+
+	for _, metric := range metrics {
+
+		in, err := toProto(metric)
+		if err != nil {
+			stream.CloseSend()
+			return err
+		}
+
+		if err = stream.Send(&pb.UpdateMetricsRequest{Request: &pb.UpdateMetricRequest{Metric: in}}); err != nil {
+			// причина ошибки отправки возвращается из CloseAndRecv
+			break
+		}
+	}
+
+	response, err := stream.CloseAndRecv()
+	if err != nil {
+		return errutil.WrapError(err)
+	}
+
+	if response.GetCount() != int64(len(metrics)) {
+		return fmt.Errorf("sent %d metrics, server updated %d", len(metrics), response.GetCount())
+	}
+
+	return nil
+}
+
+func (r *ReportMetricsGRPC) Close(_ context.Context) error {
+	return r.conn.Close()
+}
+
+func toProto(metric models.Metrics) (*pb.Metric, error) {
+
+	result := &pb.Metric{Id: metric.ID, Labels: metric.Labels}
+
+	switch metric.MType {
+	case "gauge":
+		if metric.Value == nil {
+			return nil, fmt.Errorf("value is not defined for gauge %s", metric.ID)
+		}
+		result.Type = pb.Metric_GAUGE
+		result.Value = *metric.Value
+	case "counter":
+		if metric.Delta == nil {
+			return nil, fmt.Errorf("delta is not defined for counter %s", metric.ID)
+		}
+		result.Type = pb.Metric_COUNTER
+		result.Delta = *metric.Delta
+	default:
+		return nil, fmt.Errorf("unclown MType %s", metric.MType)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,228 @@
+package grpcsender
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/s-turchinskiy/metrics/internal/agent/models"
+	"github.com/s-turchinskiy/metrics/internal/server/auth"
+	"github.com/s-turchinskiy/metrics/internal/server/grpcserver"
+	"github.com/s-turchinskiy/metrics/internal/server/keyring"
+	"github.com/s-turchinskiy/metrics/internal/server/replay"
+	"github.com/s-turchinskiy/metrics/internal/server/repository/memcashed"
+	"github.com/s-turchinskiy/metrics/internal/server/service"
+)
+
+func TestReportMetricsGRPC(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		serverHashKey string
+		agentHashKey  string
+		agentKeyID    string
+		serverAPIKeys string
+		agentAPIKey   string
+		serverSubnet  string
+		agentRealIP   string
+		wantErr       bool
+	}{
+		{name: "Без ключа. Успешно"},
+		{name: "Одинаковый ключ. Успешно", serverHashKey: "secret", agentHashKey: "secret"},
+		{name: "Ключ из файла по идентификатору. Успешно", serverHashKey: "secret", agentHashKey: "rotated", agentKeyID: "2025"},
+		{name: "Ключ не соответствует идентификатору. Ошибка", serverHashKey: "secret", agentHashKey: "secret", agentKeyID: "2025", wantErr: true},
+		{name: "Разные ключи. Ошибка", serverHashKey: "secret", agentHashKey: "other", wantErr: true},
+		{name: "Ключ API. Успешно", serverAPIKeys: "agent:write", agentAPIKey: "agent"},
+		{name: "Ключ API без роли write. Ошибка", serverAPIKeys: "agent:read", agentAPIKey: "agent", wantErr: true},
+		{name: "Без ключа API. Ошибка", serverAPIKeys: "agent:write", wantErr: true},
+		{name: "Агент из доверенной подсети. Успешно", serverSubnet: "10.0.0.0/8", agentRealIP: "10.1.2.3"},
+		{name: "Агент вне доверенной подсети. Ошибка", serverSubnet: "10.0.0.0/8", agentRealIP: "192.168.0.1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			hashKeysPath := filepath.Join(t.TempDir(), "hash_keys")
+			require.NoError(t, os.WriteFile(hashKeysPath, []byte("2025:rotated\n"), 0600))
+			keys, err := keyring.New(tt.serverHashKey, nil, hashKeysPath, "")
+			require.NoError(t, err)
+			if tt.serverHashKey == "" {
+				keys = nil
+			}
+
+			serverOpts := []grpcserver.Option{grpcserver.WithKeys(keys), grpcserver.WithReplayGuard(replay.New(time.Minute, 100))}
+			if tt.serverAPIKeys != "" {
+				apiKeys, err := auth.ParseKeys(tt.serverAPIKeys)
+				require.NoError(t, err)
+				serverOpts = append(serverOpts, grpcserver.WithKeyStore(apiKeys))
+			}
+			if tt.serverSubnet != "" {
+				_, trusted, err := net.ParseCIDR(tt.serverSubnet)
+				require.NoError(t, err)
+				serverOpts = append(serverOpts, grpcserver.WithTrustedSubnet(trusted))
+			}
+
+			storage := memcashed.New()
+			listener := bufconn.Listen(1024 * 1024)
+			server := grpcserver.New(service.New(storage, []time.Duration{0}, ""), "", serverOpts...)
+			go server.Serve(listener)
+			defer server.Stop()
+
+			sender, err := New("passthrough:///bufnet",
+				WithHash(tt.agentHashKey),
+				WithKeyID(tt.agentKeyID),
+				WithAPIKey(tt.agentAPIKey),
+				WithRealIP(tt.agentRealIP),
+				WithDialOptions(grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+					return listener.DialContext(ctx)
+				})),
+			)
+			require.NoError(t, err)
+			defer sender.Close(context.Background())
+
+			value := 1.25
+			delta := int64(3)
+
+			err = sender.Send(models.Metrics{ID: "Alloc", MType: "gauge", Value: &value})
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			err = sender.SendBatch([]models.Metrics{
+				{ID: "PollCount", MType: "counter", Delta: &delta},
+				{ID: "PollCount", MType: "counter", Delta: &delta},
+			})
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			assert.Equal(t, map[string]float64{"Alloc": 1.25}, storage.Gauge)
+			assert.Equal(t, map[string]int64{"PollCount": 6}, storage.Counter)
+		})
+	}
+}
+
+func TestReportMetricsGRPC_UnknownType(t *testing.T) {
+
+	sender, err := New("passthrough:///bufnet")
+	require.NoError(t, err)
+	defer sender.Close(context.Background())
+
+	assert.Error(t, sender.Send(models.Metrics{ID: "Alloc", MType: "unknown"}))
+}
+
+func TestReportMetricsGRPC_MutualTLS(t *testing.T) {
+
+	ca, caKey := testCertificate(t, "ca", nil, nil)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+
+	serverCert, _ := testCertificate(t, "server", ca.Leaf, caKey)
+	serverConfig := &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	tests := []struct {
+		name    string
+		agent   string
+		tls     bool
+		wantErr bool
+	}{
+		{name: "Агент из списка", agent: "agent-1", tls: true},
+		{name: "Агент не из списка", agent: "agent-2", tls: true, wantErr: true},
+		{name: "Без сертификата клиента", tls: true, wantErr: true},
+		{name: "Без TLS", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			storage := memcashed.New()
+			listener := bufconn.Listen(1024 * 1024)
+			server := grpcserver.New(service.New(storage, []time.Duration{0}, ""), "",
+				grpcserver.WithTLS(serverConfig, []string{"agent-1"}))
+			go server.Serve(listener)
+			defer server.Stop()
+
+			opts := []OptionGRPC{
+				WithTimeout(time.Second),
+				WithDialOptions(grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+					return listener.DialContext(ctx)
+				})),
+			}
+			if tt.tls {
+				clientConfig := &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+				if tt.agent != "" {
+					cert, _ := testCertificate(t, tt.agent, ca.Leaf, caKey)
+					clientConfig.Certificates = []tls.Certificate{cert}
+				}
+				opts = append(opts, WithTLSConfig(clientConfig))
+			}
+
+			sender, err := New("passthrough:///bufnet", opts...)
+			require.NoError(t, err)
+			defer sender.Close(context.Background())
+
+			value := 1.25
+			err = sender.Send(models.Metrics{ID: "Alloc", MType: "gauge", Value: &value})
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Empty(t, storage.Gauge)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, map[string]float64{"Alloc": 1.25}, storage.Gauge)
+		})
+	}
+}
+
+// testCertificate Сертификат name для адреса bufnet, без parent - самоподписанный CA
+func testCertificate(t *testing.T, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (tls.Certificate, *ecdsa.PrivateKey) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"bufnet"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, key
+}
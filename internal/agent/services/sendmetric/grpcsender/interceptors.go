@@ -0,0 +1,122 @@
+package grpcsender
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/s-turchinskiy/metrics/internal/utils/grpcutil"
+	"github.com/s-turchinskiy/metrics/internal/utils/hashutil"
+)
+
+// MetadataUnaryInterceptor Добавление метаданных md к каждому запросу, аналог постоянных заголовков HTTP
+func MetadataUnaryInterceptor(md metadata.MD) grpc.UnaryClientInterceptor {
+
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(appendMetadata(ctx, md), method, req, reply, cc, opts...)
+	}
+}
+
+func MetadataStreamInterceptor(md metadata.MD) grpc.StreamClientInterceptor {
+
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(appendMetadata(ctx, md), desc, cc, method, opts...)
+	}
+}
+
+func appendMetadata(ctx context.Context, md metadata.MD) context.Context {
+
+	for key, values := range md {
+		for _, value := range values {
+			ctx = metadata.AppendToOutgoingContext(ctx, key, value)
+		}
+	}
+
+	return ctx
+}
+
+// HashUnaryInterceptor Подпись запроса ключом hashKey в метаданных hashsha256, аналог заголовка HashSHA256.
+// Метка времени и nonce передаются в метаданных и входят в подпись вместе с методом, чтобы сервер отклонял повтор запроса.
+// Непустой keyID передается в метаданных hashsha256-key-id, чтобы сервер проверял подпись этим ключом
+func HashUnaryInterceptor(hashKey, keyID string) grpc.UnaryClientInterceptor {
+
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+
+		if message, ok := req.(proto.Message); ok && hashKey != "" {
+			timestamp, nonce, err := hashutil.NewReplayValues(time.Now())
+			if err != nil {
+				return err
+			}
+
+			hash, err := grpcutil.RequestHash(hashKey, method, timestamp, nonce, message)
+			if err != nil {
+				return err
+			}
+
+			ctx = metadata.AppendToOutgoingContext(ctx,
+				grpcutil.HashMetadataKey, hash,
+				grpcutil.TimestampMetadataKey, timestamp,
+				grpcutil.NonceMetadataKey, nonce,
+			)
+			if keyID != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, grpcutil.KeyIDMetadataKey, keyID)
+			}
+		}
+
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// HashStreamInterceptor Подпись каждого сообщения потока в его поле hash.
+// Метка времени и nonce создаются на поток и вместе с keyID передаются в метаданных потока
+func HashStreamInterceptor(hashKey, keyID string) grpc.StreamClientInterceptor {
+
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+
+		if hashKey == "" {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+
+		timestamp, nonce, err := hashutil.NewReplayValues(time.Now())
+		if err != nil {
+			return nil, err
+		}
+
+		ctx = metadata.AppendToOutgoingContext(ctx,
+			grpcutil.TimestampMetadataKey, timestamp,
+			grpcutil.NonceMetadataKey, nonce,
+		)
+		if keyID != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, grpcutil.KeyIDMetadataKey, keyID)
+		}
+
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			return stream, err
+		}
+
+		return &hashClientStream{ClientStream: stream, hashKey: hashKey, method: method, timestamp: timestamp, nonce: nonce}, nil
+	}
+}
+
+type hashClientStream struct {
+	grpc.ClientStream
+	hashKey   string
+	method    string
+	timestamp string
+	nonce     string
+}
+
+func (s *hashClientStream) SendMsg(m any) error {
+
+	if message, ok := m.(proto.Message); ok {
+		if err := grpcutil.SignMessage(s.hashKey, s.method, s.timestamp, s.nonce, message); err != nil {
+			return err
+		}
+	}
+
+	return s.ClientStream.SendMsg(m)
+}
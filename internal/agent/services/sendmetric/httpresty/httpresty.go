@@ -2,14 +2,22 @@
 package httpresty
 
 import (
+	"bytes"
+	"compress/gzip"
 	"crypto/rsa"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"github.com/go-resty/resty/v2"
 	"github.com/s-turchinskiy/metrics/internal/utils/errutil"
 	"github.com/s-turchinskiy/metrics/internal/utils/hashutil"
+	"github.com/s-turchinskiy/metrics/internal/utils/netutil"
 	"github.com/s-turchinskiy/metrics/internal/utils/rsautil"
+	"net/http"
+	"net/url"
+	"time"
 
+	"github.com/s-turchinskiy/metrics/internal/agent/logger"
 	"github.com/s-turchinskiy/metrics/internal/agent/models"
 	"github.com/s-turchinskiy/metrics/internal/agent/services/sendmetric"
 )
@@ -17,9 +25,14 @@ import (
 type ReportMetricsHTTPResty struct {
 	client       *resty.Client
 	url          string
+	batchURL     string
 	hashFunc     hashutil.HashFunc
 	hashKey      string
+	hashKeyID    string
 	rsaPublicKey *rsa.PublicKey
+	cryptoKeyID  string
+	apiKey       string
+	realIP       string
 }
 
 type OptionHTTPResty func(*ReportMetricsHTTPResty)
@@ -35,6 +48,13 @@ func New(url string, opts ...OptionHTTPResty) *ReportMetricsHTTPResty {
 		opt(r)
 	}
 
+	// адрес для X-Real-IP, по которому сервер проверяет доверенную подсеть
+	if ip, err := netutil.OutboundIP(url); err != nil {
+		logger.Log.Infow("error getting outbound ip", "error", err.Error(), "url", url)
+	} else {
+		r.realIP = ip.String()
+	}
+
 	return r
 
 }
@@ -52,6 +72,37 @@ func WithRsaPublicKey(rsaPublicKey *rsa.PublicKey) OptionHTTPResty {
 	}
 }
 
+// WithKeyIDs Идентификаторы ключей HashSHA256 и RSA, по которым сервер выбирает ключ при ротации
+func WithKeyIDs(hashKeyID, cryptoKeyID string) OptionHTTPResty {
+	return func(r *ReportMetricsHTTPResty) {
+		r.hashKeyID = hashKeyID
+		r.cryptoKeyID = cryptoKeyID
+	}
+}
+
+// WithBatchURL Адрес отправки пачек метрик, например http://localhost:8080/updates/add/
+func WithBatchURL(url string) OptionHTTPResty {
+	return func(r *ReportMetricsHTTPResty) {
+		r.batchURL = url
+	}
+}
+
+// WithTLSConfig Настройки TLS клиента для mTLS
+func WithTLSConfig(config *tls.Config) OptionHTTPResty {
+	return func(r *ReportMetricsHTTPResty) {
+		if config != nil {
+			r.client.SetTLSClientConfig(config)
+		}
+	}
+}
+
+// WithAPIKey Ключ API в заголовке Authorization, если на сервере включена проверка ключей
+func WithAPIKey(apiKey string) OptionHTTPResty {
+	return func(r *ReportMetricsHTTPResty) {
+		r.apiKey = apiKey
+	}
+}
+
 func (r *ReportMetricsHTTPResty) Send(metric models.Metrics) error {
 
 	body, err := json.Marshal(metric)
@@ -59,10 +110,68 @@ func (r *ReportMetricsHTTPResty) Send(metric models.Metrics) error {
 		return errutil.WrapError(fmt.Errorf("error json marshal data"))
 	}
 
+	request, err := r.newRequest(r.url, body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := request.Post(r.url)
+
+	if err != nil {
+		sendmetric.HandlerErrors(err, metric, r.url)
+		return err
+	}
+
+	return sendmetric.CheckResponseStatus(
+		resp.StatusCode(),
+		resp.Body(),
+		r.url,
+	)
+
+}
+
+// SendBatch Отправка пачки метрик одним запросом: JSON сжимается gzip, затем шифруется, подписывается итоговое тело.
+// Сервер /updates/add/ складывает пачку с сохраненными метриками, как Send. В отличие от Send ответ не 200 - ошибка
+func (r *ReportMetricsHTTPResty) SendBatch(metrics []models.Metrics) error {
+
+	data, err := json.Marshal(metrics)
+	if err != nil {
+		return errutil.WrapError(fmt.Errorf("error json marshal data"))
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err = zw.Write(data); err != nil {
+		return errutil.WrapError(err)
+	}
+	if err = zw.Close(); err != nil {
+		return errutil.WrapError(err)
+	}
+
+	request, err := r.newRequest(r.batchURL, buf.Bytes())
+	if err != nil {
+		return err
+	}
+	request.SetHeader("Content-Encoding", "gzip")
+
+	resp, err := request.Post(r.batchURL)
+	if err != nil {
+		logger.Log.Infow("error sending request", "error", err.Error(), "url", r.batchURL, "count", len(metrics))
+		return err
+	}
+
+	return sendmetric.CheckResponseStatus(resp.StatusCode(), resp.Body(), r.batchURL)
+}
+
+// newRequest Запрос POST на requestURL с телом body: шифрование, подпись и заголовки агента
+func (r *ReportMetricsHTTPResty) newRequest(requestURL string, body []byte) (*resty.Request, error) {
+
+	var wrappedKey string
+	var err error
 	if r.rsaPublicKey != nil {
-		body, err = rsautil.Encrypt(r.rsaPublicKey, body)
+		body, wrappedKey, err = rsautil.EncryptHybrid(r.rsaPublicKey, body)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
@@ -70,27 +179,41 @@ func (r *ReportMetricsHTTPResty) Send(metric models.Metrics) error {
 		SetHeader("Content-Type", "application/json").
 		SetBody(body)
 
+	if wrappedKey != "" {
+		request.SetHeader(rsautil.EncryptedKeyHeader, wrappedKey)
+		if r.cryptoKeyID != "" {
+			request.SetHeader(rsautil.KeyIDHeader, r.cryptoKeyID)
+		}
+	}
+
 	if r.hashKey != "" && r.hashFunc != nil {
 
-		hash := r.hashFunc(r.hashKey, body)
+		// метка времени, nonce, метод и URI подписываются вместе с телом, повтор запроса сервер отклонит
+		timestamp, nonce, err := hashutil.NewReplayValues(time.Now())
+		if err != nil {
+			return nil, errutil.WrapError(err)
+		}
+		parsedURL, err := url.Parse(requestURL)
+		if err != nil {
+			return nil, errutil.WrapError(err)
+		}
+		request.SetHeader(hashutil.TimestampHeader, timestamp)
+		request.SetHeader(hashutil.NonceHeader, nonce)
+
+		hash := r.hashFunc(r.hashKey, hashutil.SignedData(http.MethodPost, parsedURL.RequestURI(), timestamp, nonce, body))
 		request.SetHeader("HashSHA256", hash)
+		if r.hashKeyID != "" {
+			request.SetHeader(hashutil.KeyIDHeader, r.hashKeyID)
+		}
 	}
 
-	resp, err := request.Post(r.url)
-
-	if err != nil {
-		sendmetric.HandlerErrors(err, metric, r.url)
-		return err
+	if r.realIP != "" {
+		request.SetHeader("X-Real-IP", r.realIP)
 	}
 
-	if err := sendmetric.CheckResponseStatus(
-		resp.StatusCode(),
-		resp.Body(),
-		r.url,
-	); err != nil {
-		return nil
+	if r.apiKey != "" {
+		request.SetHeader("Authorization", "Bearer "+r.apiKey)
 	}
 
-	return nil
-
+	return request, nil
 }
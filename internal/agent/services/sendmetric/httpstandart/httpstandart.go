@@ -7,8 +7,10 @@ import (
 	"fmt"
 	"github.com/s-turchinskiy/metrics/internal/utils/errutil"
 	"github.com/s-turchinskiy/metrics/internal/utils/hashutil"
+	"github.com/s-turchinskiy/metrics/internal/utils/netutil"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/s-turchinskiy/metrics/internal/agent/logger"
 	"github.com/s-turchinskiy/metrics/internal/agent/models"
@@ -19,15 +21,25 @@ type ReportMetricsHTTPStandart struct {
 	url      string
 	hashFunc hashutil.HashFunc
 	hashKey  string
+	realIP   string
 }
 
 func New(url string, hashFunc hashutil.HashFunc, hashKey string) *ReportMetricsHTTPStandart {
 
-	return &ReportMetricsHTTPStandart{
+	r := &ReportMetricsHTTPStandart{
 		url:      url,
 		hashFunc: hashFunc,
 		hashKey:  hashKey,
 	}
+
+	// адрес для X-Real-IP, по которому сервер проверяет доверенную подсеть
+	if ip, err := netutil.OutboundIP(url); err != nil {
+		logger.Log.Infow("error getting outbound ip", "error", err.Error(), "url", url)
+	} else {
+		r.realIP = ip.String()
+	}
+
+	return r
 }
 
 func (r *ReportMetricsHTTPStandart) Send(metric models.Metrics) error {
@@ -38,12 +50,26 @@ func (r *ReportMetricsHTTPStandart) Send(metric models.Metrics) error {
 	}
 
 	client := new(http.Client)
-	request, _ := http.NewRequest("POST", r.url, bytes.NewReader(data))
+	request, err := http.NewRequest(http.MethodPost, r.url, bytes.NewReader(data))
+	if err != nil {
+		return errutil.WrapError(err)
+	}
 	request.Header.Add("Content-Type", "application/json")
+	if r.realIP != "" {
+		request.Header.Add("X-Real-IP", r.realIP)
+	}
 
 	if r.hashKey != "" && r.hashFunc != nil {
 
-		hash := r.hashFunc(r.hashKey, data)
+		// метка времени, nonce, метод и URI подписываются вместе с телом, повтор запроса сервер отклонит
+		timestamp, nonce, err := hashutil.NewReplayValues(time.Now())
+		if err != nil {
+			return errutil.WrapError(err)
+		}
+		request.Header.Add(hashutil.TimestampHeader, timestamp)
+		request.Header.Add(hashutil.NonceHeader, nonce)
+
+		hash := r.hashFunc(r.hashKey, hashutil.SignedData(request.Method, request.URL.RequestURI(), timestamp, nonce, data))
 		request.Header.Add("HashSHA256", hash)
 	}
 
@@ -62,14 +88,10 @@ func (r *ReportMetricsHTTPStandart) Send(metric models.Metrics) error {
 
 	resp.Body.Close()
 
-	if err := sendmetric.CheckResponseStatus(
+	return sendmetric.CheckResponseStatus(
 		resp.StatusCode,
 		body,
 		r.url,
-	); err != nil {
-		return nil
-	}
-
-	return nil
+	)
 
 }
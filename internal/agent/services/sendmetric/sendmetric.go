@@ -1,4 +1,4 @@
-// Package sendmetric Интерфейс отправки метрики и 2 общих метода
+// Package sendmetric Интерфейсы отправки метрики и пачки метрик и 2 общих метода
 package sendmetric
 
 import (
@@ -14,6 +14,12 @@ type MetricSender interface {
 	Send(models.Metrics) error
 }
 
+// BatchSender Отправка метрик пачкой, отдельными метриками отправляется очередь неотправленных
+type BatchSender interface {
+	MetricSender
+	SendBatch([]models.Metrics) error
+}
+
 func HandlerErrors(err error, metric models.Metrics, url string) {
 
 	if err != nil {
@@ -1,4 +1,4 @@
-// Package sendmetrics Воркер отправки метрик
+// Package sendmetrics Воркер отправки метрик по одной или пачками
 package sendmetrics
 
 import (
@@ -16,40 +16,137 @@ type MetricsSender interface {
 	ResultHandling()
 }
 
+// Spooler Очередь для метрик, которые не удалось отправить
+type Spooler interface {
+	Push(metrics ...models.Metrics) error
+}
+
+// CounterResetter Хранилище, из которого вычитаются отправленные значения counter
+type CounterResetter interface {
+	ResetCounters(sent []models.Metrics) error
+}
+
 type SendMetrics struct {
 	MetricsSender
 	numJobs int
-	jobs    <-chan models.Metrics
-	results chan error
-	sender  sendmetric.MetricSender
-	retrier retrier.ReportMetricRetrier
+	jobs    <-chan []models.Metrics // задание - одна метрика или пачка
+	results chan result
+	send    func([]models.Metrics) error
+	spool   Spooler
+	storage CounterResetter
+}
+
+type Option func(*SendMetrics)
+
+// result Итог задания: неотправленные метрики сохраняются в spool одним вызовом после всех заданий
+type result struct {
+	batch []models.Metrics
+	err   error
+}
+
+// WithSpool Метрики, не отправленные после всех попыток, сохраняются в spool.
+// Push вызывается один раз за отправку, а не на каждое задание: spool переписывает файл при каждом вызове
+func WithSpool(spool Spooler) Option {
+	return func(s *SendMetrics) {
+		s.spool = spool
+	}
+}
+
+// WithCounterReset Значения counter отправленных и сохраненных в spool метрик вычитаются из storage
+// один раз после всех заданий. Неотправленные без spool останутся в storage и уйдут со следующей отправкой
+func WithCounterReset(storage CounterResetter) Option {
+	return func(s *SendMetrics) {
+		s.storage = storage
+	}
 }
 
 func New(
 	jobs <-chan models.Metrics,
 	sender sendmetric.MetricSender,
-	retrier retrier.ReportMetricRetrier) *SendMetrics {
+	retrier retrier.ReportMetricRetrier,
+	opts ...Option) *SendMetrics {
+
+	batches := make(chan []models.Metrics, cap(jobs))
+	go func() {
+		defer close(batches)
+		for metric := range jobs {
+			batches <- []models.Metrics{metric}
+		}
+	}()
+
+	send := func(batch []models.Metrics) error {
+		if retrier != nil {
+			return retrier.SendWithRetries(batch[0], sender.Send)
+		}
+		return sender.Send(batch[0])
+	}
+
+	return newSendMetrics(batches, send, opts)
+}
 
-	return &SendMetrics{
+// NewBatch Отправка пачек метрик, каждая пачка - одно задание воркера
+func NewBatch(
+	jobs <-chan []models.Metrics,
+	sender sendmetric.BatchSender,
+	retrier retrier.ReportBatchRetrier,
+	opts ...Option) *SendMetrics {
+
+	send := func(batch []models.Metrics) error {
+		if retrier != nil {
+			return retrier.SendBatchWithRetries(batch, sender.SendBatch)
+		}
+		return sender.SendBatch(batch)
+	}
+
+	return newSendMetrics(jobs, send, opts)
+}
+
+func newSendMetrics(jobs <-chan []models.Metrics, send func([]models.Metrics) error, opts []Option) *SendMetrics {
+
+	s := &SendMetrics{
 		numJobs: cap(jobs),
 		jobs:    jobs,
-		results: make(chan error, cap(jobs)),
-		sender:  sender,
-		retrier: retrier,
+		results: make(chan result, cap(jobs)),
+		send:    send,
 	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
 }
 
 func (s *SendMetrics) ResultHandling(ctx context.Context) {
 
-	var err error
 	var errs []error
+	var sent, failed []models.Metrics
+	defer func() {
+		if s.spool != nil && len(failed) != 0 {
+			if err := s.spool.Push(failed...); err != nil {
+				logger.Log.Infow("spool push error", "error", err.Error())
+			} else {
+				sent = append(sent, failed...)
+			}
+		}
+
+		if s.storage != nil && len(sent) != 0 {
+			if err := s.storage.ResetCounters(sent); err != nil {
+				logger.Log.Infow("reset counters error", "error", err.Error())
+			}
+		}
+	}()
+
 	for a := 1; a <= s.numJobs; a++ {
 		select {
 		case <-ctx.Done():
 			return
-		case err = <-s.results:
-			if err != nil {
-				errs = append(errs, err)
+		case r := <-s.results:
+			if r.err != nil {
+				errs = append(errs, r.err)
+				failed = append(failed, r.batch...)
+			} else {
+				sent = append(sent, r.batch...)
 			}
 		}
 	}
@@ -67,17 +164,13 @@ func (s *SendMetrics) ResultHandling(ctx context.Context) {
 
 func (s *SendMetrics) WorkerSender(ctx context.Context) {
 
-	for metric := range s.jobs {
+	for batch := range s.jobs {
 
 		select {
 		case <-ctx.Done():
 			return
 		default:
-			if s.retrier != nil {
-				s.results <- s.retrier.SendWithRetries(metric, s.sender.Send)
-			} else {
-				s.results <- s.sender.Send(metric)
-			}
+			s.results <- result{batch: batch, err: s.send(batch)}
 		}
 	}
 }
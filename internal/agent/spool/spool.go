@@ -0,0 +1,282 @@
+// Package spool Очередь неотправленных метрик на диске для работы агента без связи с сервером
+package spool
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/s-turchinskiy/metrics/internal/agent/logger"
+	"github.com/s-turchinskiy/metrics/internal/agent/models"
+	"github.com/s-turchinskiy/metrics/internal/utils/errutil"
+)
+
+// FileName Имя файла очереди в каталоге
+const FileName = "spool.jsonl"
+
+type record struct {
+	Time   time.Time      `json:"time"`
+	Metric models.Metrics `json:"metric"`
+	size   int64
+}
+
+// Spool Очередь метрик в порядке поступления, ограниченная размером файла и возрастом записей.
+// Counter одной серии не дублируется: дельта складывается с уже ожидающей отправки.
+// Очередь целиком хранится в памяти и после каждого изменения переписывается в файл
+type Spool struct {
+	path    string
+	maxSize int64
+	maxAge  time.Duration
+	now     func() time.Time
+
+	mutex   sync.Mutex
+	records []*record
+	size    int64
+}
+
+// Open Открытие очереди в каталоге dir с загрузкой сохраненных записей.
+// maxSize - максимальный размер файла в байтах, maxAge - максимальный возраст записи, 0 - без ограничения
+func Open(dir string, maxSize int64, maxAge time.Duration) (*Spool, error) {
+
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, errutil.WrapError(err)
+	}
+
+	s := &Spool{
+		path:    filepath.Join(dir, FileName),
+		maxSize: maxSize,
+		maxAge:  maxAge,
+		now:     time.Now,
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.trim() {
+		if err := s.save(); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// Len Количество метрик в очереди
+func (s *Spool) Len() int {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.records)
+}
+
+// Push Добавление метрик в конец очереди. Counter - приращения с прошлой отправки:
+// агент вычитает их из хранилища после сохранения в очередь, поэтому ожидающие приращения суммируются
+func (s *Spool) Push(metrics ...models.Metrics) error {
+
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.now()
+	for _, metric := range metrics {
+
+		if metric.MType == "counter" && metric.Delta != nil {
+			if pending := s.findCounter(metric); pending != nil {
+				delta := *pending.Metric.Delta + *metric.Delta
+				pending.Metric.Delta = &delta
+				s.resize(pending)
+				continue
+			}
+		}
+
+		r := &record{Time: now, Metric: metric}
+		s.resize(r)
+		s.records = append(s.records, r)
+	}
+
+	s.trim()
+
+	return s.save()
+}
+
+// Replay Отправка метрик из очереди по порядку. Отправленные удаляются из очереди,
+// на первой ошибке отправка прекращается, остальные метрики остаются в очереди.
+// Возвращает количество отправленных метрик
+func (s *Spool) Replay(send func(models.Metrics) error) (int, error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	trimmed := s.trim()
+
+	sent := 0
+	var sendErr error
+	for _, r := range s.records {
+		if sendErr = send(r.Metric); sendErr != nil {
+			break
+		}
+		s.size -= r.size
+		sent++
+	}
+
+	if sent == 0 && !trimmed {
+		return 0, sendErr
+	}
+
+	s.records = s.records[sent:]
+	if err := s.save(); err != nil {
+		return sent, errors.Join(sendErr, err)
+	}
+
+	return sent, sendErr
+}
+
+func (s *Spool) findCounter(metric models.Metrics) *record {
+
+	key := seriesKey(metric)
+	for _, r := range s.records {
+		if r.Metric.MType == "counter" && r.Metric.Delta != nil && seriesKey(r.Metric) == key {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// resize Пересчет размера записи в файле и общего размера очереди
+func (s *Spool) resize(r *record) {
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+
+	s.size += int64(len(data)+1) - r.size
+	r.size = int64(len(data) + 1)
+}
+
+// trim Удаление устаревших записей и самых старых при превышении размера
+func (s *Spool) trim() bool {
+
+	drop := 0
+	if s.maxAge > 0 {
+		oldest := s.now().Add(-s.maxAge)
+		for drop < len(s.records) && s.records[drop].Time.Before(oldest) {
+			drop++
+		}
+	}
+
+	size := s.size
+	for i := 0; i < drop; i++ {
+		size -= s.records[i].size
+	}
+	for s.maxSize > 0 && size > s.maxSize && drop < len(s.records) {
+		size -= s.records[drop].size
+		drop++
+	}
+
+	if drop == 0 {
+		return false
+	}
+
+	logger.Log.Infow("spool overflow, oldest metrics dropped", "count", drop)
+	s.records = s.records[drop:]
+	s.size = size
+
+	return true
+}
+
+func (s *Spool) load() error {
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return errutil.WrapError(err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		r := &record{}
+		if err = json.Unmarshal(scanner.Bytes(), r); err != nil {
+			// оборванная запись в конце файла после аварийного завершения
+			logger.Log.Infow("spool record skipped", "error", err.Error(), "path", s.path)
+			continue
+		}
+		s.resize(r)
+		s.records = append(s.records, r)
+	}
+
+	if err = scanner.Err(); err != nil {
+		return errutil.WrapError(fmt.Errorf("path: %s, error: %w", s.path, err))
+	}
+
+	return nil
+}
+
+// save Запись очереди во временный файл с заменой файла очереди
+func (s *Spool) save() error {
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, r := range s.records {
+		if err := encoder.Encode(r); err != nil {
+			return errutil.WrapError(err)
+		}
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0640); err != nil {
+		return errutil.WrapError(err)
+	}
+
+	if err := os.Rename(tmp, s.path); err != nil {
+		return errutil.WrapError(err)
+	}
+
+	return nil
+}
+
+func seriesKey(metric models.Metrics) string {
+
+	if len(metric.Labels) == 0 {
+		return metric.ID
+	}
+
+	names := make([]string, 0, len(metric.Labels))
+	for name := range metric.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var key strings.Builder
+	key.WriteString(metric.ID)
+	for _, name := range names {
+		key.WriteString("\x00" + name + "=" + metric.Labels[name])
+	}
+
+	return key.String()
+}
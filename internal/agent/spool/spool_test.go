@@ -0,0 +1,119 @@
+package spool
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/s-turchinskiy/metrics/internal/agent/models"
+)
+
+func gauge(id string, value float64) models.Metrics {
+	return models.Metrics{ID: id, MType: "gauge", Value: &value}
+}
+
+func counter(id string, delta int64) models.Metrics {
+	return models.Metrics{ID: id, MType: "counter", Delta: &delta}
+}
+
+func TestSpool_ReplayInOrder(t *testing.T) {
+
+	dir := t.TempDir()
+	s, err := Open(dir, 0, 0)
+	require.NoError(t, err)
+
+	require.NoError(t, s.Push(gauge("Alloc", 1), counter("PollCount", 2)))
+	require.NoError(t, s.Push(gauge("Alloc", 3), counter("PollCount", 5)))
+	assert.Equal(t, 3, s.Len(), "counter складывается, gauge сохраняются все")
+
+	s, err = Open(dir, 0, 0)
+	require.NoError(t, err)
+	require.Equal(t, 3, s.Len(), "очередь восстановлена из файла")
+
+	var sent []models.Metrics
+	failed := false
+	send := func(m models.Metrics) error {
+		if len(sent) == 1 && !failed {
+			failed = true
+			return errors.New("connection refused")
+		}
+		sent = append(sent, m)
+		return nil
+	}
+
+	n, err := s.Replay(send)
+	assert.Error(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, 2, s.Len(), "после ошибки остаток в очереди")
+
+	n, err = s.Replay(send)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, 0, s.Len())
+
+	require.Len(t, sent, 3)
+	assert.Equal(t, 1.0, *sent[0].Value)
+	assert.Equal(t, int64(7), *sent[1].Delta)
+	assert.Equal(t, 3.0, *sent[2].Value)
+
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	require.NoError(t, err)
+	assert.Empty(t, data)
+}
+
+func TestSpool_CounterLabels(t *testing.T) {
+
+	s, err := Open(t.TempDir(), 0, 0)
+	require.NoError(t, err)
+
+	a := counter("Requests", 1)
+	a.Labels = map[string]string{"host": "a"}
+	b := counter("Requests", 1)
+	b.Labels = map[string]string{"host": "b"}
+
+	require.NoError(t, s.Push(a, b, a))
+	assert.Equal(t, 2, s.Len(), "разные серии не складываются")
+}
+
+func TestSpool_Limits(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		maxSize int64
+		maxAge  time.Duration
+		want    []float64
+	}{
+		{name: "без ограничений", want: []float64{1, 2, 3}},
+		{name: "размер", maxSize: 180, want: []float64{2, 3}},
+		{name: "возраст", maxAge: 90 * time.Second, want: []float64{2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			now := time.Unix(1700000000, 0)
+			s, err := Open(t.TempDir(), tt.maxSize, tt.maxAge)
+			require.NoError(t, err)
+			s.now = func() time.Time { return now }
+
+			for i := 1; i <= 3; i++ {
+				require.NoError(t, s.Push(gauge("Alloc", float64(i))))
+				now = now.Add(time.Minute)
+			}
+			now = now.Add(-time.Minute)
+
+			var got []float64
+			_, err = s.Replay(func(m models.Metrics) error {
+				got = append(got, *m.Value)
+				return nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,153 @@
+// Package auth Ключи API и роли доступа к HTTP API
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Заголовки с ключом API
+const (
+	AuthorizationHeader = "Authorization" // ключ или Bearer ключ
+	APIKeyHeader        = "X-API-Key"
+)
+
+// Role Роль ключа API
+type Role string
+
+const (
+	// RoleRead Чтение метрик: /value, /, /metrics, /history
+	RoleRead Role = "read"
+	// RoleWrite Запись метрик: /update, /updates, /write
+	RoleWrite Role = "write"
+	// RoleAdmin Администрирование: /debug/pprof, /swagger, удаление метрик. Разрешает любые запросы
+	RoleAdmin Role = "admin"
+)
+
+// Roles Роли ключа API
+type Roles []Role
+
+// Allows Разрешен ли запрос, требующий роль required
+func (r Roles) Allows(required Role) bool {
+	return slices.Contains(r, RoleAdmin) || slices.Contains(r, required)
+}
+
+// ParseRoles Роли из строки вида read|write
+func ParseRoles(s string) (Roles, error) {
+
+	var result Roles
+	for _, part := range strings.Split(s, "|") {
+		role := Role(strings.TrimSpace(part))
+		switch role {
+		case RoleRead, RoleWrite, RoleAdmin:
+			if !slices.Contains(result, role) {
+				result = append(result, role)
+			}
+		default:
+			return nil, fmt.Errorf("unknown role %q", role)
+		}
+	}
+
+	return result, nil
+}
+
+// KeyStore Источник ключей API
+type KeyStore interface {
+	// LookupAPIKey Роли ключа key, false если ключ неизвестен
+	LookupAPIKey(ctx context.Context, key string) (Roles, bool, error)
+}
+
+// Keys Ключи API из настроек
+type Keys map[string]Roles
+
+// ParseKeys Ключи из строки вида key1:read|write,key2:admin
+func ParseKeys(s string) (Keys, error) {
+
+	result := make(Keys)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		key, roles, found := strings.Cut(part, ":")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("incorrect api key %q, need key:role", part)
+		}
+
+		parsed, err := ParseRoles(roles)
+		if err != nil {
+			return nil, err
+		}
+		result[key] = parsed
+	}
+
+	return result, nil
+}
+
+func (k Keys) LookupAPIKey(_ context.Context, key string) (Roles, bool, error) {
+
+	roles, exist := k[key]
+	return roles, exist, nil
+}
+
+// Stores Несколько источников ключей, ключ ищется по порядку
+type Stores []KeyStore
+
+func (s Stores) LookupAPIKey(ctx context.Context, key string) (Roles, bool, error) {
+
+	for _, store := range s {
+		roles, exist, err := store.LookupAPIKey(ctx, key)
+		if err != nil || exist {
+			return roles, exist, err
+		}
+	}
+
+	return nil, false, nil
+}
+
+// APIKey Ключ API из значения Authorization, иначе из X-API-Key. Один разбор для проверки роли
+// и определения арендатора, чтобы один ключ мог определять и то, и другое, в заголовках HTTP и метаданных gRPC
+func APIKey(authorization, apiKey string) string {
+
+	if authorization != "" {
+		return strings.TrimSpace(strings.TrimPrefix(authorization, "Bearer "))
+	}
+
+	return apiKey
+}
+
+// HashKey Хэш ключа API, в базе данных хранятся только хэши
+func HashKey(key string) string {
+
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// RequiredRole Роль, необходимая для запроса. false - запрос доступен без ключа
+func RequiredRole(method, path string) (Role, bool) {
+
+	switch {
+	case path == "/ping" || strings.HasPrefix(path, "/ping/"):
+		return "", false
+	case hasPrefix(path, "/debug/pprof"), hasPrefix(path, "/swagger"):
+		return RoleAdmin, true
+	case method == http.MethodDelete:
+		return RoleAdmin, true
+	case hasPrefix(path, "/update"), hasPrefix(path, "/updates"), hasPrefix(path, "/write"):
+		return RoleWrite, true
+	default:
+		return RoleRead, true
+	}
+}
+
+// hasPrefix Путь совпадает с prefix или начинается с prefix/
+func hasPrefix(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
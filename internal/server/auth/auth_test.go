@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKeys(t *testing.T) {
+
+	keys, err := ParseKeys("agent:write, viewer:read|write|read,root:admin")
+	require.NoError(t, err)
+	assert.Equal(t, Keys{"agent": {RoleWrite}, "viewer": {RoleRead, RoleWrite}, "root": {RoleAdmin}}, keys)
+
+	_, err = ParseKeys("agent:owner")
+	assert.Error(t, err, "неизвестная роль")
+
+	_, err = ParseKeys("agent")
+	assert.Error(t, err, "роль не задана")
+}
+
+func TestRoles_Allows(t *testing.T) {
+
+	assert.True(t, Roles{RoleWrite}.Allows(RoleWrite))
+	assert.False(t, Roles{RoleWrite}.Allows(RoleRead), "запись не дает права чтения")
+	assert.True(t, Roles{RoleAdmin}.Allows(RoleRead))
+	assert.False(t, Roles{}.Allows(RoleRead))
+}
+
+func TestRequiredRole(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		want      Role
+		protected bool
+	}{
+		{name: "Обновление метрики", method: http.MethodPost, path: "/update/", want: RoleWrite, protected: true},
+		{name: "Обновление пакета", method: http.MethodPost, path: "/updates/", want: RoleWrite, protected: true},
+		{name: "Получение метрики", method: http.MethodGet, path: "/value/gauge/Alloc", want: RoleRead, protected: true},
+		{name: "Все метрики", method: http.MethodGet, path: "/", want: RoleRead, protected: true},
+		{name: "Удаление метрики", method: http.MethodDelete, path: "/value/gauge/Alloc", want: RoleAdmin, protected: true},
+		{name: "Профилирование", method: http.MethodGet, path: "/debug/pprof/heap", want: RoleAdmin, protected: true},
+		{name: "Swagger", method: http.MethodGet, path: "/swagger/index.html", want: RoleAdmin, protected: true},
+		{name: "Проверка работоспособности", method: http.MethodGet, path: "/ping"},
+		{name: "Префикс не путается с другим путем", method: http.MethodGet, path: "/updatesX", want: RoleRead, protected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			got, protected := RequiredRole(tt.method, tt.path)
+			assert.Equal(t, tt.protected, protected)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestStores_LookupAPIKey(t *testing.T) {
+
+	stores := Stores{Keys{"agent": {RoleWrite}}, Keys{"agent": {RoleAdmin}, "root": {RoleAdmin}}}
+
+	roles, exist, err := stores.LookupAPIKey(context.Background(), "agent")
+	require.NoError(t, err)
+	assert.True(t, exist)
+	assert.Equal(t, Roles{RoleWrite}, roles, "ключ берется из первого источника")
+
+	_, exist, err = stores.LookupAPIKey(context.Background(), "root")
+	require.NoError(t, err)
+	assert.True(t, exist)
+
+	_, exist, err = stores.LookupAPIKey(context.Background(), "unknown")
+	require.NoError(t, err)
+	assert.False(t, exist)
+}
@@ -0,0 +1,162 @@
+// Package grpcserver Обработка входящих gRPC-запросов
+package grpcserver
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	pb "github.com/s-turchinskiy/metrics/internal/proto"
+	"github.com/s-turchinskiy/metrics/internal/server/auth"
+	"github.com/s-turchinskiy/metrics/internal/server/keyring"
+	"github.com/s-turchinskiy/metrics/internal/server/replay"
+	"github.com/s-turchinskiy/metrics/internal/server/service"
+)
+
+type GRPCServer struct {
+	*grpc.Server
+	addr string
+}
+
+type options struct {
+	keys         *keyring.Keyring
+	guard        *replay.Guard
+	keyStore     auth.KeyStore
+	tenantKeys   map[string]string
+	tenantHeader string
+	trusted      *net.IPNet
+	tlsConfig    *tls.Config
+	tlsClients   []string
+}
+
+type Option func(*options)
+
+// WithKeys Ключи HashSHA256 того же Keyring, что и у HTTP-сервера: запросы с хэшем проверяются,
+// а ответы подписываются. nil отключает проверку
+func WithKeys(keys *keyring.Keyring) Option {
+	return func(o *options) {
+		o.keys = keys
+	}
+}
+
+// WithReplayGuard Проверка метки времени и nonce подписанных запросов тем же Guard, что и у HTTP-сервера
+func WithReplayGuard(guard *replay.Guard) Option {
+	return func(o *options) {
+		o.guard = guard
+	}
+}
+
+// WithKeyStore Проверка ключа API и роли тем же источником ключей, что и у HTTP-сервера. nil отключает проверку
+func WithKeyStore(store auth.KeyStore) Option {
+	return func(o *options) {
+		o.keyStore = store
+	}
+}
+
+// WithTenants Определение арендатора так же, как у HTTP-сервера: по ключу API из keys,
+// без keys - по метаданным header. Без опции все запросы относятся к арендатору по умолчанию
+func WithTenants(keys map[string]string, header string) Option {
+	return func(o *options) {
+		o.tenantKeys = keys
+		o.tenantHeader = header
+	}
+}
+
+// WithTrustedSubnet Методы записи принимаются только от агентов из подсети trusted, как у HTTP-сервера. nil отключает проверку
+func WithTrustedSubnet(trusted *net.IPNet) Option {
+	return func(o *options) {
+		o.trusted = trusted
+	}
+}
+
+// WithTLS Соединения принимаются только по TLS с настройками config, как у HTTP-сервера:
+// сертификат клиента проверяется по CA из config. При непустом allowed агенты не из списка отклоняются.
+// При config == nil соединения без TLS
+func WithTLS(config *tls.Config, allowed []string) Option {
+	return func(o *options) {
+		o.tlsConfig = config
+		o.tlsClients = allowed
+	}
+}
+
+// New Создание gRPC-сервера поверх того же сервиса, что и у HTTP-обработчиков
+func New(svc service.MetricsUpdater, addr string, opts ...Option) *GRPCServer {
+
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	unary := []grpc.UnaryServerInterceptor{LoggerUnaryInterceptor}
+	stream := []grpc.StreamServerInterceptor{LoggerStreamInterceptor}
+	var serverOpts []grpc.ServerOption
+
+	if o.tlsConfig != nil {
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(o.tlsConfig)))
+		unary = append(unary, ClientCertUnaryInterceptor(o.tlsClients))
+		stream = append(stream, ClientCertStreamInterceptor(o.tlsClients))
+	}
+
+	if o.keyStore != nil {
+		unary = append(unary, APIKeyUnaryInterceptor(o.keyStore))
+		stream = append(stream, APIKeyStreamInterceptor(o.keyStore))
+	}
+
+	if len(o.tenantKeys) != 0 || o.tenantHeader != "" {
+		unary = append(unary, TenantUnaryInterceptor(o.tenantKeys, o.tenantHeader))
+		stream = append(stream, TenantStreamInterceptor(o.tenantKeys, o.tenantHeader))
+	}
+
+	if o.trusted != nil {
+		unary = append(unary, TrustedSubnetUnaryInterceptor(o.trusted))
+		stream = append(stream, TrustedSubnetStreamInterceptor(o.trusted))
+	}
+
+	unary = append(unary, HashUnaryInterceptor(o.keys, o.guard))
+	stream = append(stream, HashStreamInterceptor(o.keys, o.guard))
+
+	serverOpts = append(serverOpts, grpc.ChainUnaryInterceptor(unary...), grpc.ChainStreamInterceptor(stream...))
+	server := grpc.NewServer(serverOpts...)
+	pb.RegisterMetricsServer(server, &MetricsServer{Service: svc})
+
+	return &GRPCServer{Server: server, addr: addr}
+}
+
+func (s *GRPCServer) Run() error {
+
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(listener)
+}
+
+// FuncShutdown Остановка с ожиданием текущих запросов, по истечении ctx соединения закрываются принудительно
+func (s *GRPCServer) FuncShutdown(zaplog *zap.SugaredLogger) func(ctx context.Context) error {
+
+	return func(ctx context.Context) error {
+
+		stopped := make(chan struct{})
+		go func() {
+			s.GracefulStop()
+			close(stopped)
+		}()
+
+		select {
+		case <-stopped:
+			zaplog.Infow("gRPC server stopped")
+			return nil
+		case <-ctx.Done():
+			s.Stop()
+			err := errors.Join(errors.New("gRPC server stopped forcibly"), ctx.Err())
+			zaplog.Infow("gRPC server stopped with error", zap.String("error", err.Error()))
+			return err
+		}
+	}
+}
@@ -0,0 +1,426 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	pb "github.com/s-turchinskiy/metrics/internal/proto"
+	"github.com/s-turchinskiy/metrics/internal/server/auth"
+	"github.com/s-turchinskiy/metrics/internal/server/keyring"
+	"github.com/s-turchinskiy/metrics/internal/server/replay"
+	"github.com/s-turchinskiy/metrics/internal/server/repository/memcashed"
+	"github.com/s-turchinskiy/metrics/internal/server/service"
+	"github.com/s-turchinskiy/metrics/internal/utils/grpcutil"
+	"github.com/s-turchinskiy/metrics/internal/utils/hashutil"
+)
+
+func startServer(t *testing.T, opts ...Option) pb.MetricsClient {
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := New(service.New(memcashed.New(), []time.Duration{0}, ""), "", opts...)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return pb.NewMetricsClient(conn)
+}
+
+func TestMetricsServer(t *testing.T) {
+
+	ctx := context.Background()
+	client := startServer(t)
+
+	response, err := client.UpdateMetric(ctx, &pb.UpdateMetricRequest{
+		Metric: &pb.Metric{Id: "PollCount", Type: pb.Metric_COUNTER, Delta: 2},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), response.GetMetric().GetDelta())
+
+	stream, err := client.UpdateMetrics(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&pb.UpdateMetricsRequest{Request: &pb.UpdateMetricRequest{Metric: &pb.Metric{Id: "PollCount", Type: pb.Metric_COUNTER, Delta: 3}}}))
+	require.NoError(t, stream.Send(&pb.UpdateMetricsRequest{Request: &pb.UpdateMetricRequest{Metric: &pb.Metric{Id: "Alloc", Type: pb.Metric_GAUGE, Value: 1.5}}}))
+	streamResponse, err := stream.CloseAndRecv()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), streamResponse.GetCount())
+
+	metric, err := client.GetMetric(ctx, &pb.GetMetricRequest{Id: "PollCount", Type: pb.Metric_COUNTER})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), metric.GetMetric().GetDelta())
+
+	_, err = client.GetMetric(ctx, &pb.GetMetricRequest{Id: "Unknown", Type: pb.Metric_GAUGE})
+	assert.Equal(t, codes.NotFound, status.Code(err), "неизвестная метрика")
+
+	list, err := client.ListMetrics(ctx, &pb.ListMetricsRequest{})
+	require.NoError(t, err)
+	require.Len(t, list.GetMetrics(), 2)
+	assert.Equal(t, "PollCount", list.GetMetrics()[0].GetId())
+	assert.Equal(t, "Alloc", list.GetMetrics()[1].GetId())
+	assert.Equal(t, 1.5, list.GetMetrics()[1].GetValue())
+
+	tests := []struct {
+		name    string
+		request *pb.UpdateMetricRequest
+	}{
+		{name: "Не указана метрика", request: &pb.UpdateMetricRequest{}},
+		{name: "Не указано имя", request: &pb.UpdateMetricRequest{Metric: &pb.Metric{Type: pb.Metric_GAUGE}}},
+		{name: "Не указан тип", request: &pb.UpdateMetricRequest{Metric: &pb.Metric{Id: "Alloc"}}},
+		{name: "Некорректное имя метки", request: &pb.UpdateMetricRequest{Metric: &pb.Metric{Id: "Alloc", Type: pb.Metric_GAUGE, Labels: map[string]string{"1host": "a"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.UpdateMetric(ctx, tt.request)
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		})
+	}
+}
+
+func Test_statusError(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		err      error
+		wantCode codes.Code
+	}{
+		{name: "Превышена квота", err: fmt.Errorf("%w: 2 series", service.ErrQuotaExceeded), wantCode: codes.ResourceExhausted},
+		{name: "Метрика не найдена", err: service.ErrMetricNotFound, wantCode: codes.NotFound},
+		{name: "Ошибка репозитория", err: errors.New("connection refused"), wantCode: codes.Internal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantCode, status.Code(statusError(tt.err)))
+		})
+	}
+}
+
+func TestHashInterceptors(t *testing.T) {
+
+	const hashKey = "secret"
+
+	hashKeysPath := filepath.Join(t.TempDir(), "hash_keys")
+	require.NoError(t, os.WriteFile(hashKeysPath, []byte("2025:rotated\n"), 0600))
+	keys, err := keyring.New(hashKey, nil, hashKeysPath, "")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	client := startServer(t, WithKeys(keys))
+
+	request := &pb.UpdateMetricRequest{Metric: &pb.Metric{Id: "Alloc", Type: pb.Metric_GAUGE, Value: 1}}
+	hash, err := grpcutil.MessageHash(hashKey, request)
+	require.NoError(t, err)
+
+	var header metadata.MD
+	response, err := client.UpdateMetric(
+		metadata.AppendToOutgoingContext(ctx, grpcutil.HashMetadataKey, hash),
+		request,
+		grpc.Header(&header),
+	)
+	require.NoError(t, err)
+	require.Len(t, header.Get(grpcutil.HashMetadataKey), 1)
+	assert.NoError(t, grpcutil.VerifyHash(hashKey, response, header.Get(grpcutil.HashMetadataKey)[0]))
+
+	wrongHash, err := grpcutil.MessageHash("wrong", request)
+	require.NoError(t, err)
+	_, err = client.UpdateMetric(metadata.AppendToOutgoingContext(ctx, grpcutil.HashMetadataKey, wrongHash), request)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	stream, err := client.UpdateMetrics(ctx)
+	require.NoError(t, err)
+	streamRequest := &pb.UpdateMetricsRequest{Request: &pb.UpdateMetricRequest{Metric: &pb.Metric{Id: "Alloc", Type: pb.Metric_GAUGE, Value: 2}}}
+	require.NoError(t, grpcutil.SignMessage("wrong", "", "", "", streamRequest))
+	require.NoError(t, stream.Send(streamRequest))
+	_, err = stream.CloseAndRecv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	rotatedHash, err := grpcutil.MessageHash("rotated", request)
+	require.NoError(t, err)
+	header = nil
+	response, err = client.UpdateMetric(
+		metadata.AppendToOutgoingContext(ctx, grpcutil.HashMetadataKey, rotatedHash, grpcutil.KeyIDMetadataKey, "2025"),
+		request,
+		grpc.Header(&header),
+	)
+	require.NoError(t, err, "ключ из файла по идентификатору")
+	assert.Equal(t, []string{"2025"}, header.Get(grpcutil.KeyIDMetadataKey))
+	assert.NoError(t, grpcutil.VerifyHash("rotated", response, header.Get(grpcutil.HashMetadataKey)[0]), "ответ подписан тем же ключом")
+
+	_, err = client.UpdateMetric(
+		metadata.AppendToOutgoingContext(ctx, grpcutil.HashMetadataKey, hash, grpcutil.KeyIDMetadataKey, "2025"),
+		request,
+	)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "подпись другим ключом, чем указан")
+
+	_, err = client.UpdateMetric(
+		metadata.AppendToOutgoingContext(ctx, grpcutil.HashMetadataKey, hash, grpcutil.KeyIDMetadataKey, "unknown"),
+		request,
+	)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "неизвестный идентификатор ключа")
+
+	stream, err = client.UpdateMetrics(metadata.AppendToOutgoingContext(ctx, grpcutil.KeyIDMetadataKey, "2025"))
+	require.NoError(t, err)
+	streamRequest = &pb.UpdateMetricsRequest{Request: &pb.UpdateMetricRequest{Metric: &pb.Metric{Id: "Alloc", Type: pb.Metric_GAUGE, Value: 3}}}
+	require.NoError(t, grpcutil.SignMessage("rotated", "", "", "", streamRequest))
+	require.NoError(t, stream.Send(streamRequest))
+	streamResponse, err := stream.CloseAndRecv()
+	require.NoError(t, err, "сообщения потока проверяются ключом из метаданных")
+	assert.Equal(t, int64(1), streamResponse.GetCount())
+}
+
+func TestReplayInterceptors(t *testing.T) {
+
+	const hashKey = "secret"
+
+	keys, err := keyring.New(hashKey, nil, "", "")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	client := startServer(t, WithKeys(keys), WithReplayGuard(replay.New(time.Minute, 100)))
+
+	request := &pb.UpdateMetricRequest{Metric: &pb.Metric{Id: "Alloc", Type: pb.Metric_GAUGE, Value: 1}}
+	timestamp, nonce, err := hashutil.NewReplayValues(time.Now())
+	require.NoError(t, err)
+	hash, err := grpcutil.RequestHash(hashKey, pb.Metrics_UpdateMetric_FullMethodName, timestamp, nonce, request)
+	require.NoError(t, err)
+	signed := metadata.AppendToOutgoingContext(ctx,
+		grpcutil.HashMetadataKey, hash,
+		grpcutil.TimestampMetadataKey, timestamp,
+		grpcutil.NonceMetadataKey, nonce,
+	)
+
+	_, err = client.UpdateMetric(signed, request)
+	require.NoError(t, err)
+
+	_, err = client.UpdateMetric(signed, request)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "повтор запроса")
+
+	_, err = client.UpdateMetric(ctx, request)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "запрос без подписи")
+
+	hash, err = grpcutil.MessageHash(hashKey, request)
+	require.NoError(t, err)
+	_, err = client.UpdateMetric(metadata.AppendToOutgoingContext(ctx, grpcutil.HashMetadataKey, hash), request)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "подпись без метки времени и nonce")
+
+	_, err = client.UpdateMetric(metadata.AppendToOutgoingContext(ctx,
+		grpcutil.HashMetadataKey, hash,
+		grpcutil.TimestampMetadataKey, timestamp,
+		grpcutil.NonceMetadataKey, "other",
+	), request)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "nonce не входит в подпись")
+
+	timestamp, nonce, err = hashutil.NewReplayValues(time.Now())
+	require.NoError(t, err)
+	hash, err = grpcutil.RequestHash(hashKey, pb.Metrics_UpdateMetrics_FullMethodName, timestamp, nonce, request)
+	require.NoError(t, err)
+	_, err = client.UpdateMetric(metadata.AppendToOutgoingContext(ctx,
+		grpcutil.HashMetadataKey, hash,
+		grpcutil.TimestampMetadataKey, timestamp,
+		grpcutil.NonceMetadataKey, nonce,
+	), request)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "подпись другого метода")
+
+	sendStream := func(ctx context.Context, timestamp, nonce string, sign bool) error {
+		stream, err := client.UpdateMetrics(ctx)
+		require.NoError(t, err)
+		streamRequest := &pb.UpdateMetricsRequest{Request: &pb.UpdateMetricRequest{Metric: &pb.Metric{Id: "PollCount", Type: pb.Metric_COUNTER, Delta: 1}}}
+		if sign {
+			require.NoError(t, grpcutil.SignMessage(hashKey, pb.Metrics_UpdateMetrics_FullMethodName, timestamp, nonce, streamRequest))
+		}
+		require.NoError(t, stream.Send(streamRequest))
+		_, err = stream.CloseAndRecv()
+		return err
+	}
+
+	timestamp, nonce, err = hashutil.NewReplayValues(time.Now())
+	require.NoError(t, err)
+	streamCtx := metadata.AppendToOutgoingContext(ctx,
+		grpcutil.TimestampMetadataKey, timestamp,
+		grpcutil.NonceMetadataKey, nonce,
+	)
+	require.NoError(t, sendStream(streamCtx, timestamp, nonce, true))
+	assert.Equal(t, codes.InvalidArgument, status.Code(sendStream(streamCtx, timestamp, nonce, true)), "повтор потока")
+	assert.Equal(t, codes.Unauthenticated, status.Code(sendStream(ctx, "", "", false)), "поток без подписи")
+}
+
+func TestAPIKeyInterceptors(t *testing.T) {
+
+	keys, err := auth.ParseKeys("writer:write,reader:read")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	client := startServer(t, WithKeyStore(keys))
+
+	request := &pb.UpdateMetricRequest{Metric: &pb.Metric{Id: "Alloc", Type: pb.Metric_GAUGE, Value: 1}}
+	withKey := func(key, value string) context.Context {
+		return metadata.AppendToOutgoingContext(ctx, key, value)
+	}
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		stream   bool
+		list     bool
+		wantCode codes.Code
+	}{
+		{name: "Без ключа. Ошибка", ctx: ctx, wantCode: codes.Unauthenticated},
+		{name: "Неизвестный ключ. Ошибка", ctx: withKey(grpcutil.AuthorizationMetadataKey, "Bearer unknown"), wantCode: codes.Unauthenticated},
+		{name: "Ключ без роли write. Ошибка", ctx: withKey(grpcutil.AuthorizationMetadataKey, "Bearer reader"), wantCode: codes.PermissionDenied},
+		{name: "Ключ с ролью write. Успешно", ctx: withKey(grpcutil.AuthorizationMetadataKey, "Bearer writer"), wantCode: codes.OK},
+		{name: "Ключ в x-api-key. Успешно", ctx: withKey(auth.APIKeyHeader, "writer"), wantCode: codes.OK},
+		{name: "Поток без роли write. Ошибка", ctx: withKey(grpcutil.AuthorizationMetadataKey, "reader"), stream: true, wantCode: codes.PermissionDenied},
+		{name: "Поток с ролью write. Успешно", ctx: withKey(grpcutil.AuthorizationMetadataKey, "writer"), stream: true, wantCode: codes.OK},
+		{name: "Чтение с ролью read. Успешно", ctx: withKey(grpcutil.AuthorizationMetadataKey, "Bearer reader"), list: true, wantCode: codes.OK},
+		{name: "Чтение без ключа. Ошибка", ctx: ctx, list: true, wantCode: codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			switch {
+			case tt.stream:
+				stream, err := client.UpdateMetrics(tt.ctx)
+				require.NoError(t, err)
+				_ = stream.Send(&pb.UpdateMetricsRequest{Request: request})
+				_, err = stream.CloseAndRecv()
+				assert.Equal(t, tt.wantCode, status.Code(err))
+			case tt.list:
+				_, err := client.ListMetrics(tt.ctx, &pb.ListMetricsRequest{})
+				assert.Equal(t, tt.wantCode, status.Code(err))
+			default:
+				_, err := client.UpdateMetric(tt.ctx, request)
+				assert.Equal(t, tt.wantCode, status.Code(err))
+			}
+		})
+	}
+}
+
+func TestTenantInterceptors(t *testing.T) {
+
+	ctx := context.Background()
+	getCounter := func(ctx context.Context, client pb.MetricsClient) (int64, error) {
+		response, err := client.GetMetric(ctx, &pb.GetMetricRequest{Id: "PollCount", Type: pb.Metric_COUNTER})
+		return response.GetMetric().GetDelta(), err
+	}
+	update := func(ctx context.Context, t *testing.T, client pb.MetricsClient, delta int64) {
+		_, err := client.UpdateMetric(ctx, &pb.UpdateMetricRequest{Metric: &pb.Metric{Id: "PollCount", Type: pb.Metric_COUNTER, Delta: delta}})
+		require.NoError(t, err)
+	}
+
+	t.Run("Арендатор по ключу API", func(t *testing.T) {
+
+		client := startServer(t, WithTenants(map[string]string{"key-a": "a", "key-b": "b"}, "X-Tenant"))
+		tenantA := metadata.AppendToOutgoingContext(ctx, grpcutil.AuthorizationMetadataKey, "Bearer key-a")
+		tenantB := metadata.AppendToOutgoingContext(ctx, auth.APIKeyHeader, "key-b")
+
+		update(tenantA, t, client, 2)
+		update(tenantB, t, client, 7)
+
+		stream, err := client.UpdateMetrics(tenantA)
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&pb.UpdateMetricsRequest{Request: &pb.UpdateMetricRequest{Metric: &pb.Metric{Id: "PollCount", Type: pb.Metric_COUNTER, Delta: 3}}}))
+		_, err = stream.CloseAndRecv()
+		require.NoError(t, err)
+
+		delta, err := getCounter(tenantA, client)
+		require.NoError(t, err)
+		assert.Equal(t, int64(5), delta)
+
+		delta, err = getCounter(tenantB, client)
+		require.NoError(t, err)
+		assert.Equal(t, int64(7), delta)
+
+		_, err = getCounter(metadata.AppendToOutgoingContext(ctx, "X-Tenant", "a"), client)
+		assert.Equal(t, codes.NotFound, status.Code(err), "метаданным арендатора при ключах не доверяют")
+
+		_, err = getCounter(metadata.AppendToOutgoingContext(ctx, grpcutil.AuthorizationMetadataKey, "unknown"), client)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("Арендатор из метаданных", func(t *testing.T) {
+
+		client := startServer(t, WithTenants(nil, "X-Tenant"))
+		tenantA := metadata.AppendToOutgoingContext(ctx, "X-Tenant", "a")
+
+		update(tenantA, t, client, 2)
+
+		delta, err := getCounter(tenantA, client)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), delta)
+
+		_, err = getCounter(ctx, client)
+		assert.Equal(t, codes.NotFound, status.Code(err), "арендатор по умолчанию не видит метрики арендатора a")
+
+		_, err = getCounter(metadata.AppendToOutgoingContext(ctx, "X-Tenant", "a/b"), client)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+func TestTrustedSubnetInterceptors(t *testing.T) {
+
+	_, trusted, err := net.ParseCIDR("10.0.0.0/8")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	client := startServer(t, WithTrustedSubnet(trusted))
+
+	request := &pb.UpdateMetricRequest{Metric: &pb.Metric{Id: "Alloc", Type: pb.Metric_GAUGE, Value: 1}}
+	withIP := func(ip string) context.Context {
+		return metadata.AppendToOutgoingContext(ctx, grpcutil.RealIPMetadataKey, ip)
+	}
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		stream   bool
+		list     bool
+		wantCode codes.Code
+	}{
+		{name: "Адрес из доверенной подсети. Успешно", ctx: withIP("10.1.2.3"), wantCode: codes.OK},
+		{name: "Адрес вне доверенной подсети. Ошибка", ctx: withIP("192.168.0.1"), wantCode: codes.PermissionDenied},
+		{name: "Без x-real-ip адрес соединения. Ошибка", ctx: ctx, wantCode: codes.PermissionDenied},
+		{name: "Поток из доверенной подсети. Успешно", ctx: withIP("10.1.2.3"), stream: true, wantCode: codes.OK},
+		{name: "Поток вне доверенной подсети. Ошибка", ctx: withIP("192.168.0.1"), stream: true, wantCode: codes.PermissionDenied},
+		{name: "Чтение не проверяется. Успешно", ctx: ctx, list: true, wantCode: codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			switch {
+			case tt.stream:
+				stream, err := client.UpdateMetrics(tt.ctx)
+				require.NoError(t, err)
+				_ = stream.Send(&pb.UpdateMetricsRequest{Request: request})
+				_, err = stream.CloseAndRecv()
+				assert.Equal(t, tt.wantCode, status.Code(err))
+			case tt.list:
+				_, err := client.ListMetrics(tt.ctx, &pb.ListMetricsRequest{})
+				assert.Equal(t, tt.wantCode, status.Code(err))
+			default:
+				_, err := client.UpdateMetric(tt.ctx, request)
+				assert.Equal(t, tt.wantCode, status.Code(err))
+			}
+		})
+	}
+}
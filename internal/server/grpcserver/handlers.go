@@ -0,0 +1,232 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/s-turchinskiy/metrics/internal/proto"
+	"github.com/s-turchinskiy/metrics/internal/server/middleware/logger"
+	"github.com/s-turchinskiy/metrics/internal/server/models"
+	"github.com/s-turchinskiy/metrics/internal/server/service"
+)
+
+type MetricsServer struct {
+	pb.UnimplementedMetricsServer
+	Service service.MetricsUpdater
+}
+
+var errEmptyMetric = status.Error(codes.InvalidArgument, "metric is not defined")
+
+func (s *MetricsServer) UpdateMetric(ctx context.Context, in *pb.UpdateMetricRequest) (*pb.UpdateMetricResponse, error) {
+
+	metric, err := s.updateMetric(ctx, in.GetMetric())
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.UpdateMetricResponse{Metric: metric}, nil
+}
+
+// UpdateMetrics Каждая метрика потока применяется сразу после получения, в отличие от /updates остальные метрики не удаляются
+func (s *MetricsServer) UpdateMetrics(stream grpc.ClientStreamingServer[pb.UpdateMetricsRequest, pb.UpdateMetricsResponse]) error {
+
+	var count int64
+	for {
+		in, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.SendAndClose(&pb.UpdateMetricsResponse{Count: count})
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err = s.updateMetric(stream.Context(), in.GetRequest().GetMetric()); err != nil {
+			return err
+		}
+		count++
+	}
+}
+
+func (s *MetricsServer) GetMetric(ctx context.Context, in *pb.GetMetricRequest) (*pb.GetMetricResponse, error) {
+
+	if in.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "metric id is not defined")
+	}
+
+	metricsType, err := fromProtoType(in.GetType())
+	if err != nil {
+		return nil, err
+	}
+
+	if err = models.Labels(in.GetLabels()).Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// В отличие от GetTypedMetric отсутствующая метрика возвращается ошибкой, а не нулевым значением
+	value, err := s.Service.GetMetric(ctx, models.UntypedMetric{MetricsType: metricsType, MetricsName: in.GetId(), Labels: in.GetLabels()})
+	if err != nil {
+		logger.Log.Infow("gRPC GetMetric error", "error", err.Error())
+		return nil, statusError(err)
+	}
+
+	metric := &pb.Metric{Id: in.GetId(), Type: in.GetType(), Labels: in.GetLabels()}
+	if in.GetType() == pb.Metric_COUNTER {
+		metric.Delta, err = strconv.ParseInt(value, 10, 64)
+	} else {
+		metric.Value, err = strconv.ParseFloat(value, 64)
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.GetMetricResponse{Metric: metric}, nil
+}
+
+func (s *MetricsServer) ListMetrics(ctx context.Context, in *pb.ListMetricsRequest) (*pb.ListMetricsResponse, error) {
+
+	all, err := s.Service.GetAllMetrics(ctx, in.GetLabels())
+	if err != nil {
+		logger.Log.Infow("gRPC ListMetrics error", "error", err.Error())
+		return nil, statusError(err)
+	}
+
+	response := &pb.ListMetricsResponse{}
+
+	for _, group := range []struct {
+		name  string
+		mtype pb.Metric_MType
+	}{
+		{name: "Counter", mtype: pb.Metric_COUNTER},
+		{name: "Gauge", mtype: pb.Metric_GAUGE},
+	} {
+
+		keys := make([]string, 0, len(all[group.name]))
+		for key := range all[group.name] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+
+			name, labels, err := models.ParseSeriesKey(key)
+			if err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+
+			metric := &pb.Metric{Id: name, Type: group.mtype, Labels: labels}
+			value := all[group.name][key]
+
+			if group.mtype == pb.Metric_COUNTER {
+				metric.Delta, err = strconv.ParseInt(value, 10, 64)
+			} else {
+				metric.Value, err = strconv.ParseFloat(value, 64)
+			}
+			if err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+
+			response.Metrics = append(response.Metrics, metric)
+		}
+	}
+
+	return response, nil
+}
+
+func (s *MetricsServer) updateMetric(ctx context.Context, in *pb.Metric) (*pb.Metric, error) {
+
+	if in == nil {
+		return nil, errEmptyMetric
+	}
+
+	metric, err := fromProto(in)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.Service.UpdateTypedMetric(ctx, metric)
+	if err != nil {
+		logger.Log.Infow("gRPC UpdateMetric error", "error", err.Error(), "id", in.GetId())
+		return nil, statusError(err)
+	}
+
+	return toProto(result), nil
+}
+
+// statusError Код ответа gRPC по ошибке сервиса: превышение квоты, отсутствие метрики или внутренняя ошибка
+func statusError(err error) error {
+
+	switch {
+	case errors.Is(err, service.ErrQuotaExceeded):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, service.ErrMetricNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func fromProto(in *pb.Metric) (models.StorageMetrics, error) {
+
+	if in.GetId() == "" {
+		return models.StorageMetrics{}, status.Error(codes.InvalidArgument, "metric id is not defined")
+	}
+
+	metricsType, err := fromProtoType(in.GetType())
+	if err != nil {
+		return models.StorageMetrics{}, err
+	}
+
+	if err = models.Labels(in.GetLabels()).Validate(); err != nil {
+		return models.StorageMetrics{}, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	metric := models.StorageMetrics{Name: in.GetId(), MType: metricsType, Labels: in.GetLabels()}
+	if in.GetType() == pb.Metric_COUNTER {
+		delta := in.GetDelta()
+		metric.Delta = &delta
+	} else {
+		value := in.GetValue()
+		metric.Value = &value
+	}
+
+	return metric, nil
+}
+
+func fromProtoType(mtype pb.Metric_MType) (string, error) {
+
+	switch mtype {
+	case pb.Metric_GAUGE:
+		return "gauge", nil
+	case pb.Metric_COUNTER:
+		return "counter", nil
+	default:
+		return "", status.Errorf(codes.InvalidArgument, "unclown MType %s", mtype)
+	}
+}
+
+func toProto(metric *models.StorageMetrics) *pb.Metric {
+
+	result := &pb.Metric{Id: metric.Name, Labels: metric.Labels}
+
+	switch metric.MType {
+	case "counter":
+		result.Type = pb.Metric_COUNTER
+		if metric.Delta != nil {
+			result.Delta = *metric.Delta
+		}
+	case "gauge":
+		result.Type = pb.Metric_GAUGE
+		if metric.Value != nil {
+			result.Value = *metric.Value
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,513 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/s-turchinskiy/metrics/internal/proto"
+	"github.com/s-turchinskiy/metrics/internal/server/auth"
+	"github.com/s-turchinskiy/metrics/internal/server/keyring"
+	"github.com/s-turchinskiy/metrics/internal/server/middleware/logger"
+	"github.com/s-turchinskiy/metrics/internal/server/replay"
+	"github.com/s-turchinskiy/metrics/internal/server/tenant"
+	"github.com/s-turchinskiy/metrics/internal/utils/grpcutil"
+)
+
+// HashUnaryInterceptor Аналог hash.HashRequiredMiddleware, hash.HashReadMiddleware и hash.HashWriteMiddleware:
+// хэш запроса из метаданных hashsha256 проверяется ключом из метаданных hashsha256-key-id,
+// без идентификатора - всеми действующими ключами. Метод, метка времени и nonce из метаданных входят в подписываемые данные,
+// при guard != nil они обязательны и проверяются на повтор. UpdateMetric без хэша отклоняется.
+// Ответ подписывается тем же ключом
+func HashUnaryInterceptor(keys *keyring.Keyring, guard *replay.Guard) grpc.UnaryServerInterceptor {
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+
+		keyID := metadataValue(ctx, grpcutil.KeyIDMetadataKey)
+		hashKeys, err := requestHashKeys(keys, keyID)
+		if err != nil {
+			return nil, err
+		}
+		if len(hashKeys) == 0 {
+			return handler(ctx, req)
+		}
+
+		hash := metadataValue(ctx, grpcutil.HashMetadataKey)
+		if hash == "" && info.FullMethod == pb.Metrics_UpdateMetric_FullMethodName {
+			logger.Log.Infow("rejected unsigned request", "method", info.FullMethod)
+			return nil, status.Error(codes.Unauthenticated, "Missing request hash")
+		}
+
+		if hash != "" {
+			message, ok := req.(proto.Message)
+			if !ok {
+				return nil, status.Error(codes.Internal, "request is not a protobuf message")
+			}
+
+			timestamp := metadataValue(ctx, grpcutil.TimestampMetadataKey)
+			nonce := metadataValue(ctx, grpcutil.NonceMetadataKey)
+			if !verifyAny(hashKeys, info.FullMethod, timestamp, nonce, message, hash) {
+				return nil, status.Error(codes.InvalidArgument, "Invalid request hash")
+			}
+
+			if err = checkReplay(guard, timestamp, nonce, info.FullMethod); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return resp, err
+		}
+
+		if message, ok := resp.(proto.Message); ok {
+			responseKey, responseKeyID := responseHashKey(keys, keyID)
+			hash, errHash := grpcutil.MessageHash(responseKey, message)
+			if errHash == nil {
+				md := metadata.Pairs(grpcutil.HashMetadataKey, hash)
+				if responseKeyID != "" {
+					md.Set(grpcutil.KeyIDMetadataKey, responseKeyID)
+				}
+				errHash = grpc.SetHeader(ctx, md)
+			}
+			if errHash != nil {
+				logger.Log.Infow("error signing gRPC response", "error", errHash.Error(), "method", info.FullMethod)
+			}
+		}
+
+		return resp, nil
+	}
+}
+
+// HashStreamInterceptor Проверка хэша поля request каждого сообщения потока из его поля hash
+// ключом из метаданных потока hashsha256-key-id, без идентификатора - всеми действующими ключами.
+// Сообщения без хэша отклоняются. Метод, метка времени и nonce потока из метаданных входят в хэш каждого сообщения
+// и проверяются на повтор после проверки первого сообщения
+func HashStreamInterceptor(keys *keyring.Keyring, guard *replay.Guard) grpc.StreamServerInterceptor {
+
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+		ctx := ss.Context()
+		hashKeys, err := requestHashKeys(keys, metadataValue(ctx, grpcutil.KeyIDMetadataKey))
+		if err != nil {
+			return err
+		}
+		if len(hashKeys) == 0 {
+			return handler(srv, ss)
+		}
+
+		return handler(srv, &hashServerStream{
+			ServerStream: ss,
+			hashKeys:     hashKeys,
+			guard:        guard,
+			method:       info.FullMethod,
+			timestamp:    metadataValue(ctx, grpcutil.TimestampMetadataKey),
+			nonce:        metadataValue(ctx, grpcutil.NonceMetadataKey),
+		})
+	}
+}
+
+type hashServerStream struct {
+	grpc.ServerStream
+	hashKeys  []string
+	guard     *replay.Guard
+	method    string
+	timestamp string
+	nonce     string
+	checked   bool
+}
+
+func (s *hashServerStream) RecvMsg(m any) error {
+
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	message, ok := m.(proto.Message)
+	if !ok {
+		return nil
+	}
+
+	valid := false
+	for _, hashKey := range s.hashKeys {
+		err := grpcutil.VerifyMessage(hashKey, s.method, s.timestamp, s.nonce, message)
+		if errors.Is(err, grpcutil.ErrMissingHash) {
+			logger.Log.Infow("rejected unsigned request", "method", s.method)
+			return status.Error(codes.Unauthenticated, "Missing request hash")
+		}
+		if err == nil {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return status.Error(codes.InvalidArgument, "Invalid request hash")
+	}
+
+	if !s.checked {
+		if err := checkReplay(s.guard, s.timestamp, s.nonce, s.method); err != nil {
+			return err
+		}
+		s.checked = true
+	}
+
+	return nil
+}
+
+// checkReplay Проверка метки времени и nonce подписанного запроса, guard == nil - проверка отключена
+func checkReplay(guard *replay.Guard, timestamp, nonce, method string) error {
+
+	if guard == nil {
+		return nil
+	}
+
+	err := guard.Check(timestamp, nonce)
+	if err == nil {
+		return nil
+	}
+
+	logger.Log.Infow("rejected signed request", "error", err.Error(), "method", method)
+	if errors.Is(err, replay.ErrFull) {
+		// агент повторит запрос, когда окно освободится
+		return status.Error(codes.Unavailable, err.Error())
+	}
+
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+// requestHashKeys Ключи проверки запроса: ключ keyID или все действующие. Пустой результат - проверка отключена
+func requestHashKeys(keys *keyring.Keyring, keyID string) ([]string, error) {
+
+	hashKeys := keys.HashKeys()
+	if len(hashKeys) == 0 || keyID == "" {
+		return hashKeys, nil
+	}
+
+	hashKey, ok := keys.HashKey(keyID)
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, "Unknown hash key id")
+	}
+
+	return []string{hashKey}, nil
+}
+
+// responseHashKey Ключ подписи ответа, как в hash.HashWriteMiddleware: ключ keyID,
+// иначе ключ keyring.DefaultID или первый из действующих
+func responseHashKey(keys *keyring.Keyring, keyID string) (string, string) {
+
+	if hashKey, ok := keys.HashKey(keyID); ok {
+		return hashKey, keyID
+	}
+
+	if hashKeys := keys.HashKeys(); len(hashKeys) != 0 {
+		return hashKeys[0], ""
+	}
+
+	return "", ""
+}
+
+func verifyAny(hashKeys []string, method, timestamp, nonce string, m proto.Message, hash string) bool {
+
+	for _, hashKey := range hashKeys {
+		if grpcutil.VerifyRequestHash(hashKey, method, timestamp, nonce, m, hash) == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ClientCertUnaryInterceptor Аналог mtls.ClientCertMiddleware: агент определяется по субъекту проверенного
+// сертификата клиента, при непустом allowed агенты не из списка отклоняются
+func ClientCertUnaryInterceptor(allowed []string) grpc.UnaryServerInterceptor {
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+
+		if err := checkClientCert(ctx, allowed, info.FullMethod); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func ClientCertStreamInterceptor(allowed []string) grpc.StreamServerInterceptor {
+
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+		if err := checkClientCert(ss.Context(), allowed, info.FullMethod); err != nil {
+			return err
+		}
+
+		return handler(srv, ss)
+	}
+}
+
+func checkClientCert(ctx context.Context, allowed []string, method string) error {
+
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "Client certificate required")
+	}
+
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 {
+		return status.Error(codes.Unauthenticated, "Client certificate required")
+	}
+
+	subject := tlsInfo.State.VerifiedChains[0][0].Subject
+	if len(allowed) != 0 && !slices.Contains(allowed, subject.CommonName) {
+		logger.Log.Infow("unknown client certificate", "subject", subject.String(), "method", method)
+		return status.Error(codes.PermissionDenied, "Unknown client certificate")
+	}
+
+	logger.Log.Debugw("client certificate", "agent", subject.CommonName, "method", method)
+
+	return nil
+}
+
+// methodRoles Роли, необходимые для методов gRPC, аналог auth.RequiredRole
+var methodRoles = map[string]auth.Role{
+	pb.Metrics_UpdateMetric_FullMethodName:  auth.RoleWrite,
+	pb.Metrics_UpdateMetrics_FullMethodName: auth.RoleWrite,
+	pb.Metrics_GetMetric_FullMethodName:     auth.RoleRead,
+	pb.Metrics_ListMetrics_FullMethodName:   auth.RoleRead,
+}
+
+// APIKeyUnaryInterceptor Аналог auth.AuthMiddleware: ключ API берется из метаданных authorization, иначе из x-api-key,
+// и проверяется тем же источником ключей, что и у HTTP-сервера. Без store проверка отключена
+func APIKeyUnaryInterceptor(store auth.KeyStore) grpc.UnaryServerInterceptor {
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+
+		if err := checkAPIKey(ctx, store, info.FullMethod); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func APIKeyStreamInterceptor(store auth.KeyStore) grpc.StreamServerInterceptor {
+
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+		if err := checkAPIKey(ss.Context(), store, info.FullMethod); err != nil {
+			return err
+		}
+
+		return handler(srv, ss)
+	}
+}
+
+func checkAPIKey(ctx context.Context, store auth.KeyStore, method string) error {
+
+	if store == nil {
+		return nil
+	}
+
+	key := metadataAPIKey(ctx)
+	if key == "" {
+		return status.Error(codes.Unauthenticated, "API key required")
+	}
+
+	roles, exist, err := store.LookupAPIKey(ctx, key)
+	if err != nil {
+		logger.Log.Infow("error lookup api key", "error", err.Error())
+		return status.Error(codes.Internal, "Error checking API key")
+	}
+
+	if !exist {
+		logger.Log.Infow("unknown api key", "method", method)
+		return status.Error(codes.Unauthenticated, "Unknown API key")
+	}
+
+	required, ok := methodRoles[method]
+	if !ok {
+		required = auth.RoleAdmin
+	}
+	if !roles.Allows(required) {
+		logger.Log.Infow("access denied", "method", method, "required", required)
+		return status.Error(codes.PermissionDenied, "Access denied")
+	}
+
+	return nil
+}
+
+// metadataAPIKey Ключ API из метаданных тем же разбором, что и из заголовков HTTP
+func metadataAPIKey(ctx context.Context) string {
+	return auth.APIKey(metadataValue(ctx, grpcutil.AuthorizationMetadataKey), metadataValue(ctx, auth.APIKeyHeader))
+}
+
+// TenantUnaryInterceptor Аналог tenant.TenantMiddleware: если заданы keys, арендатор определяется только по ключу API
+// из метаданных authorization или x-api-key, метаданным header в этом случае не доверяют.
+// Без keys арендатор берется из метаданных header. Без ключа и метаданных - арендатор по умолчанию
+func TenantUnaryInterceptor(keys map[string]string, header string) grpc.UnaryServerInterceptor {
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+
+		id, err := requestTenant(ctx, keys, header, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(tenant.WithTenant(ctx, id), req)
+	}
+}
+
+func TenantStreamInterceptor(keys map[string]string, header string) grpc.StreamServerInterceptor {
+
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+		id, err := requestTenant(ss.Context(), keys, header, info.FullMethod)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &tenantServerStream{ServerStream: ss, ctx: tenant.WithTenant(ss.Context(), id)})
+	}
+}
+
+type tenantServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *tenantServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func requestTenant(ctx context.Context, keys map[string]string, header, method string) (string, error) {
+
+	if len(keys) != 0 {
+
+		apiKey := metadataAPIKey(ctx)
+		if apiKey == "" {
+			return tenant.Default, nil
+		}
+
+		id, exist := keys[apiKey]
+		if !exist {
+			logger.Log.Infow("unknown api key", "method", method)
+			return "", status.Error(codes.Unauthenticated, "Unknown API key")
+		}
+		return id, nil
+	}
+
+	if header == "" {
+		return tenant.Default, nil
+	}
+
+	id := metadataValue(ctx, header)
+	if id == "" {
+		return tenant.Default, nil
+	}
+
+	if err := tenant.Validate(id); err != nil {
+		return "", status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return id, nil
+}
+
+// TrustedSubnetUnaryInterceptor Аналог subnet.TrustedSubnetMiddleware для методов записи: IP-адрес агента
+// берется из метаданных x-real-ip, без них - из адреса соединения. Без trusted проверка отключена
+func TrustedSubnetUnaryInterceptor(trusted *net.IPNet) grpc.UnaryServerInterceptor {
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+
+		if err := checkTrustedSubnet(ctx, trusted, info.FullMethod); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func TrustedSubnetStreamInterceptor(trusted *net.IPNet) grpc.StreamServerInterceptor {
+
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+		if err := checkTrustedSubnet(ss.Context(), trusted, info.FullMethod); err != nil {
+			return err
+		}
+
+		return handler(srv, ss)
+	}
+}
+
+func checkTrustedSubnet(ctx context.Context, trusted *net.IPNet, method string) error {
+
+	if trusted == nil || methodRoles[method] != auth.RoleWrite {
+		return nil
+	}
+
+	realIP := metadataValue(ctx, grpcutil.RealIPMetadataKey)
+	if realIP == "" {
+		if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+			realIP, _, _ = net.SplitHostPort(p.Addr.String())
+		}
+	}
+
+	ip := net.ParseIP(realIP)
+	if ip == nil || !trusted.Contains(ip) {
+		logger.Log.Infow("request from untrusted ip", "ip", realIP, "method", method)
+		return status.Error(codes.PermissionDenied, "IP address is not in trusted subnet")
+	}
+
+	return nil
+}
+
+// LoggerUnaryInterceptor Аналог logger.Logger для gRPC
+func LoggerUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+
+	start := time.Now()
+	resp, err := handler(ctx, req)
+
+	logger.Log.Debugln(
+		"method", info.FullMethod,
+		"code", status.Code(err).String(),
+		"duration", time.Since(start),
+	)
+
+	return resp, err
+}
+
+func LoggerStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+	start := time.Now()
+	err := handler(srv, ss)
+
+	logger.Log.Debugln(
+		"method", info.FullMethod,
+		"code", status.Code(err).String(),
+		"duration", time.Since(start),
+	)
+
+	return err
+}
+
+func metadataValue(ctx context.Context, key string) string {
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	values := md.Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/s-turchinskiy/metrics/internal/server/middleware/logger"
+	"github.com/s-turchinskiy/metrics/internal/server/models"
+)
+
+// Параметры запроса массового удаления
+const (
+	queryType    = "type"
+	queryPrefix  = "prefix"
+	queryPattern = "pattern"
+)
+
+// DeleteMetric godoc
+// @Tags Update
+// @Summary Удаление метрики
+// @Description Удаление серии метрики по типу и наименованию. История значений не удаляется.
+// @Description Параметры запроса - метки серии: /value/gauge/CPUutilization?cpu=3
+// @ID updateDeleteMetric
+// @Produce plain
+// @Param MetricsType path string true "Metrics Type" Enums(counter, gauge, histogram, summary)
+// @Param MetricsName path string true "Metrics Name"
+// @Success 200 {string} string "OK"
+// @Failure 403 {string} string "Ошибка авторизации"
+// @Failure 404 {string} string "Метрика не найдена"
+// @Security ApiKeyAuth
+// @Router /value/{MetricsType}/{MetricsName} [delete]
+func (h *MetricsHandler) DeleteMetric(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", ContentTypeTextPlainCharset)
+
+	metric := models.UntypedMetric{
+		MetricsType: chi.URLParam(r, "MetricsType"),
+		MetricsName: chi.URLParam(r, "MetricsName"),
+		Labels:      labelsFromQuery(r.URL.Query()),
+	}
+
+	err := h.Service.DeleteMetric(r.Context(), metric)
+	if err != nil {
+		logger.Log.Infoln(err.Error())
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	h.saveMetricsToFile(r)
+}
+
+// PurgeMetrics godoc
+// @Tags Update
+// @Summary Массовое удаление метрик
+// @Description Удаление серий, имя которых начинается с prefix и/или подходит под шаблон pattern (синтаксис path.Match).
+// @Description Остальные параметры запроса - фильтр по меткам серий. Удаление всех метрик - pattern=*
+// @ID updatePurgeMetrics
+// @Produce plain
+// @Param type query string false "Типы метрик через запятую, по умолчанию все"
+// @Param prefix query string false "Префикс имени метрики"
+// @Param pattern query string false "Шаблон имени метрики"
+// @Success 200 {string} string "Deleted 5 records"
+// @Failure 400 {string} string "Неверный запрос"
+// @Failure 403 {string} string "Ошибка авторизации"
+// @Failure 500 {string} string "Внутренняя ошибка"
+// @Security ApiKeyAuth
+// @Router /value [delete]
+func (h *MetricsHandler) PurgeMetrics(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", ContentTypeTextPlainCharset)
+
+	query := r.URL.Query()
+	filter := models.PurgeFilter{
+		Prefix:  query.Get(queryPrefix),
+		Pattern: query.Get(queryPattern),
+		Labels:  labelsFromQuery(query, queryType, queryPrefix, queryPattern),
+	}
+	if types := query.Get(queryType); types != "" {
+		filter.Types = strings.Split(types, ",")
+	}
+
+	if err := filter.Validate(); err != nil {
+		logger.Log.Infoln(err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	count, err := h.Service.PurgeMetrics(r.Context(), filter)
+	if err != nil {
+		logger.Log.Infoln(err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	fmt.Fprintf(w, "Deleted %d records", count)
+
+	h.saveMetricsToFile(r)
+}
+
+// saveMetricsToFile Синхронное сохранение метрик в файл после изменения, если не настроено периодическое
+func (h *MetricsHandler) saveMetricsToFile(r *http.Request) {
+
+	if h.asynchronousWritingDataToFile {
+		return
+	}
+
+	if err := h.Service.SaveMetricsToFile(r.Context()); err != nil {
+		logger.Log.Info("error SaveMetricsToFile", zap.Error(err))
+	}
+}
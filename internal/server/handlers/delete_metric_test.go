@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	mocksrepository "github.com/s-turchinskiy/metrics/internal/server/repository/mock"
+)
+
+func TestMetricsHandler_DeleteMetric(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mocksrepository.NewMockRepository(ctrl)
+	mock.EXPECT().DeleteMetrics(gomock.Any(), "gauge", []string{`CPUutilization{cpu="3"}`}).Return(int64(1), nil)
+	mock.EXPECT().DeleteMetrics(gomock.Any(), "gauge", []string{"CPUutilization"}).Return(int64(0), nil)
+
+	router := Router(NewHandler(context.Background(), mock, "", true), nil)
+
+	tests := []struct {
+		name       string
+		address    string
+		statusCode int
+		response   string
+	}{
+		{
+			name:       "Удаление серии по меткам",
+			address:    "/value/gauge/CPUutilization?cpu=3",
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "Серия не найдена",
+			address:    "/value/gauge/CPUutilization",
+			statusCode: http.StatusNotFound,
+			response:   "not found",
+		},
+		{
+			name:       "Неизвестный тип метрики",
+			address:    "/value/unknown/CPUutilization",
+			statusCode: http.StatusNotFound,
+			response:   "metrics type not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodDelete, tt.address, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.statusCode, w.Code)
+			assert.Equal(t, tt.response, w.Body.String())
+		})
+	}
+}
+
+func TestMetricsHandler_PurgeMetrics(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mocksrepository.NewMockRepository(ctrl)
+	mock.EXPECT().GetAllGauges(gomock.Any()).Return(map[string]float64{
+		`CPUutilization1{host="server01"}`: 1,
+		`CPUutilization2{host="server01"}`: 2,
+		`CPUutilization1{host="server02"}`: 3,
+		"Alloc":                            4,
+	}, nil)
+	mock.EXPECT().GetAllCounters(gomock.Any()).Return(map[string]int64{"CPUcount": 1}, nil)
+	mock.EXPECT().DeleteMetrics(gomock.Any(), "gauge", gomock.InAnyOrder([]string{
+		`CPUutilization1{host="server01"}`,
+		`CPUutilization2{host="server01"}`,
+	})).Return(int64(2), nil)
+
+	router := Router(NewHandler(context.Background(), mock, "", true), nil)
+
+	tests := []struct {
+		name       string
+		address    string
+		statusCode int
+		response   string
+	}{
+		{
+			name:       "Удаление по шаблону и меткам",
+			address:    "/value?type=gauge,counter&pattern=CPUutilization*&host=server01",
+			statusCode: http.StatusOK,
+			response:   "Deleted 2 records",
+		},
+		{
+			name:       "Без префикса и шаблона",
+			address:    "/value?type=gauge",
+			statusCode: http.StatusBadRequest,
+			response:   "prefix or pattern is not defined",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodDelete, tt.address, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.statusCode, w.Code)
+			assert.Equal(t, tt.response, w.Body.String())
+		})
+	}
+}
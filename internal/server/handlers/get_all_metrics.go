@@ -20,6 +20,9 @@ type OutputAllMetrics struct {
 // GetAllMetrics godoc
 // @Tags Info
 // @Summary Получение всех метрик на текущий момент
+// @Description Метрики выводятся с метками серии: CPUutilization{cpu="3"}. Параметры запроса - фильтр по меткам
+// @Description Выводятся метрики арендатора, определенного по ключу API X-API-Key или заголовку TENANT_HEADER
+// @Param X-Tenant-ID header string false "Арендатор"
 // @ID infoGetAllMetrics
 // @Accept  json
 // @Produce html
@@ -36,7 +39,7 @@ func (h *MetricsHandler) GetAllMetrics(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := h.Service.GetAllMetrics(r.Context())
+	result, err := h.Service.GetAllMetrics(r.Context(), labelsFromQuery(r.URL.Query()))
 	if err != nil {
 		logger.Log.Info("error getting data", zap.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
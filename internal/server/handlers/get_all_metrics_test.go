@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/golang/mock/gomock"
+	"github.com/s-turchinskiy/metrics/internal/server/models"
 	mocksrepository "github.com/s-turchinskiy/metrics/internal/server/repository/mock"
 	"github.com/s-turchinskiy/metrics/internal/utils/testingcommon"
 	"github.com/stretchr/testify/assert"
@@ -25,6 +26,8 @@ func TestMetricsHandler_GetAllMetrics(t *testing.T) {
 	ctx1 := context.Background()
 	mock.EXPECT().GetAllGauges(ctx1).Return(make(map[string]float64), nil)
 	mock.EXPECT().GetAllCounters(ctx1).Return(make(map[string]int64), nil)
+	mock.EXPECT().GetAllHistograms(ctx1).Return(make(map[string]models.Histogram), nil)
+	mock.EXPECT().GetAllSummaries(ctx1).Return(make(map[string]models.Summary), nil)
 
 	ctx2 := context.Background()
 	mock.EXPECT().GetAllGauges(ctx2).Return(make(map[string]float64), nil)
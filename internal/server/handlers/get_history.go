@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/s-turchinskiy/metrics/internal/server/middleware/logger"
+	"github.com/s-turchinskiy/metrics/internal/server/models"
+)
+
+// GetHistory godoc
+// @Tags Info
+// @Summary Получение истории значений метрики
+// @Description Получение значений метрики за интервал времени. Границы интервала в RFC3339 или unix-секундах, по умолчанию вся история.
+// @Description При указании resolution возвращаются агрегаты models.RollupSample с этим интервалом.
+// @Description Остальные параметры запроса - метки серии
+// @ID infoGetHistory
+// @Accept  json
+// @Produce json
+// @Param MetricsType path string true "Metrics Type" Enums(counter, gauge)
+// @Param MetricsName path string true "Metrics Name"
+// @Param from query string false "Начало интервала" example(2025-10-06T15:00:00Z)
+// @Param to query string false "Конец интервала" example(1759763022)
+// @Param resolution query string false "Интервал агрегатов из настройки RETENTION" example(1m)
+// @Success 200 {array} models.HistorySample "OK"
+// @Failure 400 {string} string "Неверный запрос"
+// @Failure 403 {string} string "Ошибка авторизации"
+// @Failure 500 {string} string "Внутренняя ошибка"
+// @Security ApiKeyAuth
+// @Router /history/{MetricsType}/{MetricsName} [get]
+func (h *MetricsHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
+
+	path := strings.TrimPrefix(r.URL.Path, "/history/")
+	pathSlice := strings.Split(path, "/")
+	if len(pathSlice) != 2 || pathSlice[1] == "" {
+		logger.Log.Infow("error, incorrect path", "path", r.URL.Path)
+		http.NotFound(w, r)
+		return
+	}
+
+	from, err := parseTimeParam(r.URL.Query().Get("from"), time.Time{})
+	if err != nil {
+		logger.Log.Infoln(err.Error())
+		w.Header().Set("Content-Type", ContentTypeTextPlainCharset)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	to, err := parseTimeParam(r.URL.Query().Get("to"), time.Now())
+	if err != nil {
+		logger.Log.Infoln(err.Error())
+		w.Header().Set("Content-Type", ContentTypeTextPlainCharset)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	var result any
+	series := models.SeriesKey(pathSlice[1], labelsFromQuery(r.URL.Query(), "from", "to", "resolution"))
+
+	if resolution := r.URL.Query().Get("resolution"); resolution != "" {
+		var duration time.Duration
+		duration, err = time.ParseDuration(resolution)
+		if err == nil {
+			result, err = h.Service.GetMetricHistoryRollups(r.Context(), pathSlice[0], series, duration, from, to)
+		}
+	} else {
+		result, err = h.Service.GetMetricHistory(r.Context(), pathSlice[0], series, from, to)
+	}
+	if err != nil {
+		logger.Log.Infoln(err.Error())
+		w.Header().Set("Content-Type", ContentTypeTextPlainCharset)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentTypeApplicationJSON)
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(result); err != nil {
+		logger.Log.Info("error encoding response", zap.Error(err))
+		return
+	}
+
+}
+
+// parseTimeParam Разбор времени из RFC3339 или unix-секунд
+func parseTimeParam(value string, defaultValue time.Time) (time.Time, error) {
+
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+
+	result, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("incorrect time %s, expected RFC3339 or unix seconds", value)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/s-turchinskiy/metrics/internal/server/models"
+	mocksrepository "github.com/s-turchinskiy/metrics/internal/server/repository/mock"
+	"github.com/s-turchinskiy/metrics/internal/utils/testingcommon"
+)
+
+func TestMetricsHandler_GetHistory(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mocksrepository.NewMockRepository(ctrl)
+
+	ctx := context.Background()
+	from := time.Date(2025, 10, 6, 15, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 10, 6, 16, 0, 0, 0, time.UTC)
+
+	mock.EXPECT().GetHistory(gomock.Any(), "gauge", "Alloc", from, to.Local()).
+		Return([]models.HistorySample{{Timestamp: from, Value: 1.23}}, nil)
+	mock.EXPECT().GetHistory(gomock.Any(), "counter", "PollCount", gomock.Any(), gomock.Any()).
+		Return(nil, fmt.Errorf("error"))
+	mock.EXPECT().GetHistoryRollups(gomock.Any(), "gauge", "Alloc", time.Minute, gomock.Any(), gomock.Any()).
+		Return([]models.RollupSample{{Timestamp: from, Min: 1, Max: 3, Avg: 2, Sum: 4, Last: 3, Count: 2}}, nil)
+
+	handler := NewHandler(ctx, mock, "", true)
+
+	tests := []testingcommon.Test{
+		{
+			Name:    "Успешно",
+			Method:  http.MethodGet,
+			Address: fmt.Sprintf("/history/gauge/Alloc?from=%s&to=%d", from.Format(time.RFC3339), to.Unix()),
+			Want: testingcommon.Want{
+				ContentType: ContentTypeApplicationJSON,
+				StatusCode:  http.StatusOK,
+				Response:    `[{"timestamp":"2025-10-06T15:00:00Z","value":1.23}]`,
+			},
+		},
+		{
+			Name:    "Агрегаты",
+			Method:  http.MethodGet,
+			Address: "/history/gauge/Alloc?resolution=1m",
+			Want: testingcommon.Want{
+				ContentType: ContentTypeApplicationJSON,
+				StatusCode:  http.StatusOK,
+				Response:    `[{"timestamp":"2025-10-06T15:00:00Z","min":1,"max":3,"avg":2,"sum":4,"last":3,"count":2}]`,
+			},
+		},
+		{
+			Name:    "Неверный интервал агрегатов",
+			Method:  http.MethodGet,
+			Address: "/history/gauge/Alloc?resolution=minute",
+			Want: testingcommon.Want{
+				ContentType: ContentTypeTextPlainCharset,
+				StatusCode:  http.StatusBadRequest,
+			},
+		},
+		{
+			Name:    "Неверный формат времени",
+			Method:  http.MethodGet,
+			Address: "/history/gauge/Alloc?from=yesterday",
+			Want: testingcommon.Want{
+				ContentType: ContentTypeTextPlainCharset,
+				StatusCode:  http.StatusBadRequest,
+			},
+		},
+		{
+			Name:    "Неизвестный тип метрики",
+			Method:  http.MethodGet,
+			Address: "/history/unknown/Alloc",
+			Want: testingcommon.Want{
+				ContentType: ContentTypeTextPlainCharset,
+				StatusCode:  http.StatusBadRequest,
+			},
+		},
+		{
+			Name:    "Ошибка репозитория",
+			Method:  http.MethodGet,
+			Address: "/history/counter/PollCount",
+			Want: testingcommon.Want{
+				ContentType: ContentTypeTextPlainCharset,
+				StatusCode:  http.StatusBadRequest,
+			},
+		},
+		{
+			Name:    "Не указано имя метрики",
+			Method:  http.MethodGet,
+			Address: "/history/gauge",
+			Want: testingcommon.Want{
+				StatusCode: http.StatusNotFound,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.Method, tt.Address, strings.NewReader(tt.Request))
+			w := httptest.NewRecorder()
+			handler.GetHistory(w, r)
+
+			result := w.Result()
+			defer result.Body.Close()
+
+			assert.Equal(t, tt.Want.StatusCode, result.StatusCode)
+			if tt.Want.ContentType != "" {
+				assert.Equal(t, tt.Want.ContentType, result.Header.Get("Content-Type"))
+			}
+			if tt.Want.Response != "" {
+				assert.JSONEq(t, tt.Want.Response, w.Body.String())
+			}
+		})
+	}
+}
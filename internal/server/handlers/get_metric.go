@@ -2,21 +2,28 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/s-turchinskiy/metrics/internal/server/middleware/logger"
 	"github.com/s-turchinskiy/metrics/internal/server/models"
 )
 
+// queryQuantile Параметр запроса с квантилем для histogram и summary
+const queryQuantile = "quantile"
+
 // GetMetric godoc
 // @Tags Info
 // @Summary Получение значения метрики
-// @Description Получение значения метрики по типу и наименованию метрики
+// @Description Получение значения метрики по типу и наименованию метрики.
+// @Description Параметры запроса - метки серии: /value/gauge/CPUutilization?cpu=3
+// @Description Для histogram и summary обязателен параметр quantile: /value/histogram/Latency?quantile=0.99
 // @ID infoGetMetric
 // @Accept  json
 // @Produce html
-// @Param MetricsType path string true "Metrics Type" Enums(counter, gauge)
+// @Param MetricsType path string true "Metrics Type" Enums(counter, gauge, histogram, summary)
 // @Param MetricsName path string true "Metrics Name"
+// @Param quantile query number false "Квантиль для histogram и summary"
 // @Success 200 {string} string "100"
 // @Failure 400 {string} string "Неверный запрос"
 // @Failure 403 {string} string "Ошибка авторизации"
@@ -44,9 +51,22 @@ func (h *MetricsHandler) GetMetric(w http.ResponseWriter, r *http.Request) {
 	path := strings.TrimPrefix(r.URL.Path, "/value/")
 	pathSlice := strings.Split(path, "/")
 
+	query := r.URL.Query()
 	metric := models.UntypedMetric{
 		MetricsType: pathSlice[0],
 		MetricsName: pathSlice[1],
+		Labels:      labelsFromQuery(query, queryQuantile),
+	}
+
+	if query.Has(queryQuantile) {
+		q, err := strconv.ParseFloat(query.Get(queryQuantile), 64)
+		if err != nil {
+			logger.Log.Infoln(err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		metric.Quantile = &q
 	}
 
 	value, err := h.Service.GetMetric(r.Context(), metric)
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/s-turchinskiy/metrics/internal/server/models"
+	mocksrepository "github.com/s-turchinskiy/metrics/internal/server/repository/mock"
+)
+
+func TestMetricsHandler_GetMetricLabels(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mocksrepository.NewMockRepository(ctrl)
+	mock.EXPECT().GetGauge(gomock.Any(), `CPUutilization{cpu="3",host="server01"}`).Return(2.5, true, nil)
+	mock.EXPECT().GetGauge(gomock.Any(), "CPUutilization").Return(float64(0), false, nil)
+
+	router := Router(NewHandler(context.Background(), mock, "", true), nil)
+
+	tests := []struct {
+		name       string
+		address    string
+		statusCode int
+		response   string
+	}{
+		{
+			name:       "Серия по меткам из параметров запроса",
+			address:    "/value/gauge/CPUutilization?host=server01&cpu=3",
+			statusCode: http.StatusOK,
+			response:   "2.5",
+		},
+		{
+			name:       "Серия без меток не найдена",
+			address:    "/value/gauge/CPUutilization",
+			statusCode: http.StatusNotFound,
+			response:   "not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.address, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.statusCode, w.Code)
+			assert.Equal(t, tt.response, w.Body.String())
+		})
+	}
+}
+
+func TestMetricsHandler_GetMetricQuantile(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	h := models.Histogram{Bounds: []float64{1, 2}, Counts: []uint64{2, 2, 0}, Sum: 4, Count: 4}
+
+	mock := mocksrepository.NewMockRepository(ctrl)
+	mock.EXPECT().GetHistogram(gomock.Any(), `Latency{host="server01"}`).Return(h, true, nil).AnyTimes()
+
+	router := Router(NewHandler(context.Background(), mock, "", true), nil)
+
+	tests := []struct {
+		name       string
+		address    string
+		statusCode int
+		response   string
+	}{
+		{
+			name:       "Квантиль гистограммы, quantile не является меткой",
+			address:    "/value/histogram/Latency?host=server01&quantile=0.75",
+			statusCode: http.StatusOK,
+			response:   "1.5",
+		},
+		{
+			name:       "Квантиль не задан",
+			address:    "/value/histogram/Latency?host=server01",
+			statusCode: http.StatusNotFound,
+			response:   "quantile is not defined",
+		},
+		{
+			name:       "Квантиль не число",
+			address:    "/value/histogram/Latency?host=server01&quantile=p99",
+			statusCode: http.StatusBadRequest,
+			response:   `strconv.ParseFloat: parsing "p99": invalid syntax`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.address, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.statusCode, w.Code)
+			assert.Equal(t, tt.response, w.Body.String())
+		})
+	}
+}
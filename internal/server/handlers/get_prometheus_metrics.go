@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"go.uber.org/zap"
+
+	"github.com/s-turchinskiy/metrics/internal/server/middleware/logger"
+	"github.com/s-turchinskiy/metrics/internal/server/models"
+)
+
+const (
+	ContentTypePrometheusText = "text/plain; version=0.0.4; charset=utf-8"
+	ContentTypeOpenMetrics    = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+	mediaTypeOpenMetrics = "application/openmetrics-text"
+	mediaTypeTextPlain   = "text/plain"
+)
+
+// prometheusTypes Соответствие групп из Service.GetAllMetrics типам Prometheus
+var prometheusTypes = []struct {
+	group      string
+	metricType string
+}{
+	{group: "Counter", metricType: "counter"},
+	{group: "Gauge", metricType: "gauge"},
+}
+
+// GetPrometheusMetrics godoc
+// @Tags Info
+// @Summary Получение всех метрик в формате Prometheus
+// @Description Формат выбирается по заголовку Accept: application/openmetrics-text - OpenMetrics 1.0.0, иначе Prometheus text 0.0.4.
+// @Description Недопустимые в именах Prometheus символы заменяются на _, имя не может начинаться с цифры.
+// @Description Параметры запроса - фильтр по меткам серий
+// @ID infoGetPrometheusMetrics
+// @Produce plain
+// @Success 200 {string} string "# TYPE PollCount counter"
+// @Failure 500 {string} string "Внутренняя ошибка"
+// @Router /metrics [get]
+func (h *MetricsHandler) GetPrometheusMetrics(w http.ResponseWriter, r *http.Request) {
+
+	result, err := h.Service.GetAllMetrics(r.Context(), labelsFromQuery(r.URL.Query()))
+	if err != nil {
+		logger.Log.Info("error getting data", zap.Error(err))
+		w.Header().Set("Content-Type", ContentTypeTextPlainCharset)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	openMetrics := acceptsOpenMetrics(r.Header.Get("Accept"))
+
+	var body bytes.Buffer
+	writePrometheusMetrics(&body, result, openMetrics)
+
+	if openMetrics {
+		w.Header().Set("Content-Type", ContentTypeOpenMetrics)
+	} else {
+		w.Header().Set("Content-Type", ContentTypePrometheusText)
+	}
+
+	w.Write(body.Bytes())
+}
+
+// prometheusFamily Серии одной метрики Prometheus
+type prometheusFamily struct {
+	name    string
+	samples []string
+}
+
+func writePrometheusMetrics(body *bytes.Buffer, metrics map[string]map[string]string, openMetrics bool) {
+
+	// одно имя Prometheus может получиться из разных исходных имен, выводится только первое из них
+	used := make(map[string]string)
+
+	for _, t := range prometheusTypes {
+
+		keys := make([]string, 0, len(metrics[t.group]))
+		for key := range metrics[t.group] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		// серии одной метрики выводятся под общим # TYPE
+		var families []*prometheusFamily
+		byName := make(map[string]*prometheusFamily)
+
+		for _, key := range keys {
+
+			name, labels, err := models.ParseSeriesKey(key)
+			if err != nil {
+				logger.Log.Infow("incorrect series key, metric skipped", "key", key, "error", err.Error())
+				continue
+			}
+
+			family := sanitizePrometheusName(name)
+			if openMetrics && t.metricType == "counter" {
+				family = strings.TrimSuffix(family, "_total")
+			}
+
+			if original, exist := used[family]; exist && original != name {
+				logger.Log.Infow("prometheus name conflict, metric skipped",
+					"name", name, "prometheusName", family, "conflictWith", original)
+				continue
+			}
+			used[family] = name
+
+			f, exist := byName[family]
+			if !exist {
+				f = &prometheusFamily{name: family}
+				byName[family] = f
+				families = append(families, f)
+			}
+
+			sample := family
+			if openMetrics && t.metricType == "counter" {
+				sample += "_total"
+			}
+			f.samples = append(f.samples, sample+formatPrometheusLabels(labels)+" "+metrics[t.group][key])
+		}
+
+		for _, f := range families {
+			fmt.Fprintf(body, "# TYPE %s %s\n", f.name, t.metricType)
+			for _, sample := range f.samples {
+				body.WriteString(sample)
+				body.WriteByte('\n')
+			}
+		}
+	}
+
+	if openMetrics {
+		body.WriteString("# EOF\n")
+	}
+}
+
+// formatPrometheusLabels Метки в формате {a="1",b="2"} с экранированием \\, \" и \n.
+// Имена меток приводятся sanitizePrometheusLabelName
+func formatPrometheusLabels(labels models.Labels) string {
+
+	if len(labels) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(sanitizePrometheusLabelName(name))
+		b.WriteString(`="`)
+		b.WriteString(replacer.Replace(labels[name]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+
+	return b.String()
+}
+
+// sanitizePrometheusName Приведение имени к допустимому в Prometheus виду [a-zA-Z_:][a-zA-Z0-9_:]*.
+// Регистр сохраняется: CPUutilization0 допустимое имя и не меняется, Disk.Used% - Disk_Used_
+func sanitizePrometheusName(name string) string {
+
+	var b strings.Builder
+	for i, c := range name {
+
+		if !isPrometheusNameRune(c) {
+			c = '_'
+		}
+
+		if i == 0 && unicode.IsDigit(c) {
+			b.WriteRune('_')
+		}
+
+		b.WriteRune(c)
+	}
+
+	if b.Len() == 0 {
+		return "_"
+	}
+
+	return b.String()
+}
+
+// sanitizePrometheusLabelName Приведение имени метки к допустимому в Prometheus виду [a-zA-Z_][a-zA-Z0-9_]*:
+// в отличие от имени метрики ':' недопустимо. Префикс __ зарезервирован для служебных меток Prometheus,
+// начальные подчеркивания заменяются одним: __name__ - _name__
+func sanitizePrometheusLabelName(name string) string {
+
+	name = strings.ReplaceAll(sanitizePrometheusName(name), ":", "_")
+	if strings.HasPrefix(name, "__") {
+		name = "_" + strings.TrimLeft(name, "_")
+	}
+
+	return name
+}
+
+func isPrometheusNameRune(c rune) bool {
+	return c < unicode.MaxASCII && (unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' || c == ':')
+}
+
+// acceptsOpenMetrics Выбор OpenMetrics, если клиент предпочитает его формату text/plain
+func acceptsOpenMetrics(accept string) bool {
+
+	var qOpenMetrics, qText float64 = -1, -1
+
+	for _, part := range strings.Split(accept, ",") {
+
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if value, exist := params["q"]; exist {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+
+		switch mediaType {
+		case mediaTypeOpenMetrics:
+			qOpenMetrics = max(qOpenMetrics, q)
+		case mediaTypeTextPlain, "text/*", "*/*":
+			qText = max(qText, q)
+		}
+	}
+
+	return qOpenMetrics > 0 && qOpenMetrics >= qText
+}
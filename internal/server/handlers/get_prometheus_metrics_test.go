@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/s-turchinskiy/metrics/internal/server/models"
+	mocksrepository "github.com/s-turchinskiy/metrics/internal/server/repository/mock"
+)
+
+func TestMetricsHandler_GetPrometheusMetrics(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mocksrepository.NewMockRepository(ctrl)
+	mock.EXPECT().GetAllGauges(gomock.Any()).
+		Return(map[string]float64{"CPUutilization0": 9.5, "Alloc": 2829408, "1st.gauge": 1}, nil).Times(2)
+	mock.EXPECT().GetAllCounters(gomock.Any()).
+		Return(map[string]int64{"PollCount": 3}, nil).Times(2)
+	mock.EXPECT().GetAllHistograms(gomock.Any()).Return(map[string]models.Histogram{}, nil).Times(2)
+	mock.EXPECT().GetAllSummaries(gomock.Any()).Return(map[string]models.Summary{}, nil).Times(2)
+	mock.EXPECT().GetAllGauges(gomock.Any()).Return(nil, fmt.Errorf("error"))
+
+	handler := NewHandler(context.Background(), mock, "", true)
+
+	tests := []struct {
+		name        string
+		accept      string
+		statusCode  int
+		contentType string
+		response    string
+	}{
+		{
+			name:        "Prometheus text 0.0.4 по умолчанию",
+			accept:      "",
+			statusCode:  http.StatusOK,
+			contentType: ContentTypePrometheusText,
+			response: "# TYPE PollCount counter\nPollCount 3\n" +
+				"# TYPE _1st_gauge gauge\n_1st_gauge 1\n" +
+				"# TYPE Alloc gauge\nAlloc 2829408\n" +
+				"# TYPE CPUutilization0 gauge\nCPUutilization0 9.5\n",
+		},
+		{
+			name:        "OpenMetrics по заголовку Accept от Prometheus",
+			accept:      "application/openmetrics-text;version=1.0.0,text/plain;version=0.0.4;q=0.5,*/*;q=0.1",
+			statusCode:  http.StatusOK,
+			contentType: ContentTypeOpenMetrics,
+			response: "# TYPE PollCount counter\nPollCount_total 3\n" +
+				"# TYPE _1st_gauge gauge\n_1st_gauge 1\n" +
+				"# TYPE Alloc gauge\nAlloc 2829408\n" +
+				"# TYPE CPUutilization0 gauge\nCPUutilization0 9.5\n" +
+				"# EOF\n",
+		},
+		{
+			name:        "Ошибка репозитория",
+			statusCode:  http.StatusInternalServerError,
+			contentType: ContentTypeTextPlainCharset,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			handler.GetPrometheusMetrics(w, r)
+
+			result := w.Result()
+			defer result.Body.Close()
+
+			assert.Equal(t, tt.statusCode, result.StatusCode)
+			assert.Equal(t, tt.contentType, result.Header.Get("Content-Type"))
+			if tt.response != "" {
+				assert.Equal(t, tt.response, w.Body.String())
+			}
+		})
+	}
+}
+
+func Test_sanitizePrometheusName(t *testing.T) {
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "CPUutilization0", want: "CPUutilization0"},
+		{name: "go_gc:duration", want: "go_gc:duration"},
+		{name: "Disk.Used%", want: "Disk_Used_"},
+		{name: "0metric", want: "_0metric"},
+		{name: "метрика", want: "_______"},
+		{name: "", want: "_"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, sanitizePrometheusName(tt.name))
+		})
+	}
+}
+
+func Test_sanitizePrometheusLabelName(t *testing.T) {
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "host", want: "host"},
+		{name: "k8s:pod", want: "k8s_pod"},
+		{name: "__name__", want: "_name__"},
+		{name: "___meta", want: "_meta"},
+		{name: "__", want: "_"},
+		{name: "_private", want: "_private"},
+		{name: "0zone", want: "_0zone"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, sanitizePrometheusLabelName(tt.name))
+		})
+	}
+}
+
+func Test_acceptsOpenMetrics(t *testing.T) {
+
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{accept: "", want: false},
+		{accept: "*/*", want: false},
+		{accept: "application/openmetrics-text", want: true},
+		{accept: "text/plain;version=0.0.4,application/openmetrics-text;q=0.5", want: false},
+		{accept: "application/openmetrics-text;version=1.0.0;q=0.9,text/plain;q=0.5", want: true},
+		{accept: "application/openmetrics-text;q=0", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			assert.Equal(t, tt.want, acceptsOpenMetrics(tt.accept))
+		})
+	}
+}
+
+func TestRouter_PrometheusMetrics(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mocksrepository.NewMockRepository(ctrl)
+	mock.EXPECT().GetAllGauges(gomock.Any()).Return(map[string]float64{"Alloc": 1}, nil)
+	mock.EXPECT().GetAllCounters(gomock.Any()).Return(map[string]int64{}, nil)
+	mock.EXPECT().GetAllHistograms(gomock.Any()).Return(map[string]models.Histogram{}, nil)
+	mock.EXPECT().GetAllSummaries(gomock.Any()).Return(map[string]models.Summary{}, nil)
+
+	router := Router(NewHandler(context.Background(), mock, "", true), nil)
+
+	r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ContentTypePrometheusText, w.Header().Get("Content-Type"))
+	assert.Equal(t, "# TYPE Alloc gauge\nAlloc 1\n", w.Body.String())
+}
+
+func TestMetricsHandler_GetPrometheusMetricsLabels(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mocksrepository.NewMockRepository(ctrl)
+	mock.EXPECT().GetAllGauges(gomock.Any()).Return(map[string]float64{
+		`CPUutilization{cpu="1",host="server01"}`: 2.5,
+		`CPUutilization{cpu="0",host="server01"}`: 1.5,
+		`CPUutilization{cpu="0",host="server02"}`: 7,
+		"CPUutilizationTotal":                     4,
+		`Disk{path="C:\\ \"d\""}`:                 1,
+	}, nil)
+	mock.EXPECT().GetAllCounters(gomock.Any()).Return(map[string]int64{}, nil)
+	mock.EXPECT().GetAllHistograms(gomock.Any()).Return(map[string]models.Histogram{}, nil)
+	mock.EXPECT().GetAllSummaries(gomock.Any()).Return(map[string]models.Summary{}, nil)
+
+	handler := NewHandler(context.Background(), mock, "", true)
+
+	r := httptest.NewRequest(http.MethodGet, "/metrics?host=server01", nil)
+	w := httptest.NewRecorder()
+	handler.GetPrometheusMetrics(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "# TYPE CPUutilization gauge\n"+
+		`CPUutilization{cpu="0",host="server01"} 1.5`+"\n"+
+		`CPUutilization{cpu="1",host="server01"} 2.5`+"\n", w.Body.String())
+
+	mock.EXPECT().GetAllGauges(gomock.Any()).Return(map[string]float64{
+		`CPUutilization{cpu="1"}`: 2.5,
+		"CPUutilizationTotal":     4,
+		`CPUutilization{cpu="0"}`: 1.5,
+		`Disk{path="C:\\ \"d\""}`: 1,
+	}, nil)
+	mock.EXPECT().GetAllCounters(gomock.Any()).Return(map[string]int64{}, nil)
+	mock.EXPECT().GetAllHistograms(gomock.Any()).Return(map[string]models.Histogram{}, nil)
+	mock.EXPECT().GetAllSummaries(gomock.Any()).Return(map[string]models.Summary{}, nil)
+
+	r = httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w = httptest.NewRecorder()
+	handler.GetPrometheusMetrics(w, r)
+
+	// метрики выводятся в порядке ключей серий, серии одной метрики - под общим # TYPE
+	assert.Equal(t, "# TYPE CPUutilizationTotal gauge\nCPUutilizationTotal 4\n"+
+		"# TYPE CPUutilization gauge\n"+
+		`CPUutilization{cpu="0"} 1.5`+"\n"+
+		`CPUutilization{cpu="1"} 2.5`+"\n"+
+		"# TYPE Disk gauge\n"+
+		`Disk{path="C:\\ \"d\""} 1`+"\n", w.Body.String())
+}
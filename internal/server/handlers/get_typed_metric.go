@@ -14,7 +14,8 @@ import (
 // GetTypedMetric godoc
 // @Tags Info
 // @Summary Получение метрики
-// @Description Получение значение метрики в json
+// @Description Получение значение метрики в json. Метрика определяется именем и метками labels
+// @Description В ответе updated - время последнего обновления, для gauge при заданном GAUGE_TTL stale - значение устарело
 // @ID infoGetTypedMetric
 // @Accept  json
 // @Produce json
@@ -34,7 +35,16 @@ func (h *MetricsHandler) GetTypedMetric(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	metric := models.StorageMetrics{Name: req.ID, MType: req.MType, Delta: req.Delta, Value: req.Value}
+	metric := models.StorageMetrics{
+		Name:      req.ID,
+		MType:     req.MType,
+		Delta:     req.Delta,
+		Value:     req.Value,
+		Labels:    req.Labels,
+		Histogram: req.Histogram,
+		Summary:   req.Summary,
+		Quantiles: req.Quantiles,
+	}
 
 	result, err := h.Service.GetTypedMetric(r.Context(), metric)
 	if err != nil {
@@ -47,7 +57,18 @@ func (h *MetricsHandler) GetTypedMetric(w http.ResponseWriter, r *http.Request)
 
 	w.Header().Set("Content-Type", "application/json")
 
-	resp := &models.Metrics{ID: result.Name, MType: result.MType, Delta: result.Delta, Value: result.Value}
+	resp := &models.Metrics{
+		ID:        result.Name,
+		MType:     result.MType,
+		Delta:     result.Delta,
+		Value:     result.Value,
+		Labels:    result.Labels,
+		Histogram: result.Histogram,
+		Summary:   result.Summary,
+		Quantiles: result.Quantiles,
+		Updated:   result.Updated,
+		Stale:     result.Stale,
+	}
 	rawBytes, err := easyjson.Marshal(resp)
 	if err != nil {
 		logger.Log.Info("error encoding response", zap.Error(err))
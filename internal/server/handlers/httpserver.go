@@ -2,9 +2,9 @@ package handlers
 
 import (
 	"context"
-	"crypto/rsa"
 	"errors"
 	"github.com/s-turchinskiy/metrics/internal/agent/logger"
+	"github.com/s-turchinskiy/metrics/internal/server/keyring"
 	"github.com/s-turchinskiy/metrics/internal/utils/rsautil"
 	"go.uber.org/zap"
 	"net/http"
@@ -20,12 +20,11 @@ func NewHTTPServer(
 	handler *MetricsHandler,
 	addr string, readTimeout,
 	writeTimeout time.Duration,
-	rsaPrivateKey *rsa.PrivateKey,
-	hashKey string) *HTTPServer {
+	keys *keyring.Keyring) *HTTPServer {
 
 	server := &http.Server{
 		Addr:         addr,
-		Handler:      Router(handler, rsaPrivateKey, hashKey),
+		Handler:      Router(handler, keys),
 		ReadTimeout:  readTimeout,
 		WriteTimeout: writeTimeout,
 	}
@@ -34,11 +33,17 @@ func NewHTTPServer(
 
 }
 
+// Run При заданном TLSConfig сервер работает в режиме mTLS с сертификатами из TLSConfig,
+// иначе при enableHTTPS - по HTTPS с самоподписанным сертификатом
 func (httpServer *HTTPServer) Run(enableHTTPS bool, pathCert, pathRSAPrivateKey string) error {
 
 	var err error
 
-	if enableHTTPS {
+	if httpServer.TLSConfig != nil {
+
+		err = httpServer.ListenAndServeTLS("", "")
+
+	} else if enableHTTPS {
 
 		if _, err = os.Stat(pathCert); err != nil && errors.Is(err, os.ErrNotExist) {
 			err = rsautil.GenerateCertificateHTTPS(pathCert, pathRSAPrivateKey)
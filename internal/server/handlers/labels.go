@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"net/url"
+	"slices"
+
+	"github.com/s-turchinskiy/metrics/internal/server/models"
+)
+
+// labelsFromQuery Метки серии из параметров запроса: каждый параметр, кроме reserved, - метка.
+// Для /value/gauge/CPUutilization?cpu=3 метки {cpu="3"}
+func labelsFromQuery(query url.Values, reserved ...string) models.Labels {
+
+	var labels models.Labels
+	for name, values := range query {
+		if slices.Contains(reserved, name) || len(values) == 0 {
+			continue
+		}
+		if labels == nil {
+			labels = make(models.Labels, len(query))
+		}
+		labels[name] = values[0]
+	}
+
+	return labels
+}
@@ -5,9 +5,13 @@ import (
 	"context"
 	"github.com/s-turchinskiy/metrics/internal/server/repository"
 	"log"
+	"net"
 	"time"
 
+	"github.com/s-turchinskiy/metrics/internal/server/auth"
+	"github.com/s-turchinskiy/metrics/internal/server/influx"
 	"github.com/s-turchinskiy/metrics/internal/server/middleware/logger"
+	"github.com/s-turchinskiy/metrics/internal/server/replay"
 	"github.com/s-turchinskiy/metrics/internal/server/service"
 	"github.com/s-turchinskiy/metrics/internal/server/settings"
 )
@@ -15,6 +19,14 @@ import (
 type MetricsHandler struct {
 	Service                       service.MetricsUpdater
 	asynchronousWritingDataToFile bool
+	influxConverter               *influx.Converter
+	tenantKeys                    map[string]string
+	tenantHeader                  string
+	keyStore                      auth.KeyStore
+	trustedSubnet                 *net.IPNet
+	tlsClients                    []string
+	cryptoRequired                bool
+	replayGuard                   *replay.Guard
 }
 
 const (
@@ -30,13 +42,34 @@ var (
 	templateOutputAllMetrics = `<div>{{.Header}}</div><table style="margin-left: 40px">{{range $k, $v:= .Table}}<tr><td>{{$k}}</td><td>{{$v}}</td></tr>{{end}}</table>`
 )
 
+// ReplayGuard Защита от повтора подписанных запросов, общая для HTTP и gRPC
+func (h *MetricsHandler) ReplayGuard() *replay.Guard {
+	return h.replayGuard
+}
+
+// KeyStore Источник ключей API, общий для HTTP и gRPC. nil - проверка ключей отключена
+func (h *MetricsHandler) KeyStore() auth.KeyStore {
+	return h.keyStore
+}
+
 func NewHandler(
 	ctx context.Context,
 	rep repository.Repository,
 	fileStoragePath string,
 	asynchronousWritingDataToFile bool) *MetricsHandler {
-	metricsHandler := &MetricsHandler{asynchronousWritingDataToFile: asynchronousWritingDataToFile}
-	if settings.Settings.Store == settings.Database {
+	metricsHandler := &MetricsHandler{
+		asynchronousWritingDataToFile: asynchronousWritingDataToFile,
+		influxConverter:               influx.NewConverter(settings.Settings.InfluxMapping),
+		tenantKeys:                    settings.Settings.TenantKeyMap,
+		tenantHeader:                  settings.Settings.TenantHeader,
+		keyStore:                      newKeyStore(rep),
+		trustedSubnet:                 settings.Settings.TrustedNet,
+		tlsClients:                    settings.Settings.TLSClients,
+		cryptoRequired:                settings.Settings.CryptoRequired,
+		replayGuard:                   replay.New(time.Duration(settings.Settings.ReplayWindow)*time.Second, settings.Settings.ReplayNonceCache),
+	}
+	switch settings.Settings.Store {
+	case settings.Database:
 
 		retryStrategy := []time.Duration{
 			0,
@@ -45,7 +78,13 @@ func NewHandler(
 
 		metricsHandler.Service = service.New(rep, retryStrategy, fileStoragePath)
 
-	} else {
+	case settings.File:
+
+		// file.Repository пишет каждое изменение в журнал сам, синхронные снимки не нужны
+		metricsHandler.asynchronousWritingDataToFile = true
+		metricsHandler.Service = service.New(rep, []time.Duration{0}, fileStoragePath)
+
+	default:
 
 		metricsHandler.Service = service.New(rep, []time.Duration{0}, fileStoragePath)
 
@@ -61,3 +100,26 @@ func NewHandler(
 	return metricsHandler
 
 }
+
+// newKeyStore Источник ключей API по настройкам, nil - проверка ключей отключена
+func newKeyStore(rep repository.Repository) auth.KeyStore {
+
+	var stores auth.Stores
+	if len(settings.Settings.APIKeyMap) != 0 {
+		stores = append(stores, settings.Settings.APIKeyMap)
+	}
+
+	if settings.Settings.APIKeysDatabase {
+		if store, ok := rep.(auth.KeyStore); ok {
+			stores = append(stores, store)
+		} else {
+			logger.Log.Errorw("API_KEYS_DATABASE is set, but storage is not a database")
+		}
+	}
+
+	if len(stores) == 0 {
+		return nil
+	}
+
+	return stores
+}
@@ -1,17 +1,22 @@
 package handlers
 
 import (
-	"crypto/rsa"
 	"github.com/go-chi/chi/v5"
 	_ "github.com/s-turchinskiy/metrics/internal/server/handlers/swagger"
+	"github.com/s-turchinskiy/metrics/internal/server/keyring"
+	authmiddleware "github.com/s-turchinskiy/metrics/internal/server/middleware/auth"
 	"github.com/s-turchinskiy/metrics/internal/server/middleware/gzip"
 	"github.com/s-turchinskiy/metrics/internal/server/middleware/hash"
 	"github.com/s-turchinskiy/metrics/internal/server/middleware/logger"
+	mtlsmiddleware "github.com/s-turchinskiy/metrics/internal/server/middleware/mtls"
 	rsamiddleware "github.com/s-turchinskiy/metrics/internal/server/middleware/rsa"
+	subnetmiddleware "github.com/s-turchinskiy/metrics/internal/server/middleware/subnet"
+	tenantmiddleware "github.com/s-turchinskiy/metrics/internal/server/middleware/tenant"
 	httpswagger "github.com/swaggo/http-swagger"
 	"golang.org/x/exp/slices"
 	"net/http"
 	"net/http/pprof"
+	"strings"
 )
 
 // @Title MetricStorage API
@@ -26,6 +31,7 @@ import (
 // @SecurityDefinitions.apikey ApiKeyAuth
 // @In header
 // @Name authorization
+// @Description Ключ API или Bearer ключ. Роли: read - чтение, write - /update, /updates, /write, admin - все запросы
 
 // @Tag.name Info
 // @Tag.description "Группа запросов метрик"
@@ -39,35 +45,62 @@ import (
 type filterType map[string]map[string][]string
 type middlewareType func(next http.Handler) http.Handler
 
-func Router(h *MetricsHandler, rsaPrivateKey *rsa.PrivateKey, hashKey string) chi.Router {
+// Router keys - ключи HashSHA256 и RSA, nil отключает проверку хэша и расшифровку
+func Router(h *MetricsHandler, keys *keyring.Keyring) chi.Router {
 
-	filter := make(map[string]map[string][]string, 1)
-	filterRSA := make(map[string][]string, 1)
+	filter := make(map[string]map[string][]string, 2)
+	filterRSA := make(map[string][]string, 3)
 	filterRSA["/update"] = []string{http.MethodPost}
+	filterRSA["/updates"] = []string{http.MethodPost}
+	filterRSA["/write"] = []string{http.MethodPost}
 	filter["RSA"] = filterRSA
 
+	// изменения метрик принимаются только подписанными, если настроен ключ HashSHA256
+	filterHash := make(map[string][]string, 4)
+	filterHash["/update"] = []string{http.MethodPost, http.MethodGet}
+	filterHash["/updates"] = []string{http.MethodPost}
+	filterHash["/write"] = []string{http.MethodPost}
+	filterHash["/value"] = []string{http.MethodDelete}
+	filter["Hash"] = filterHash
+
 	router := chi.NewRouter()
-	router.Use(hash.HashWriteMiddleware(hashKey))
-	router.Use(hash.HashReadMiddleware(hashKey))
-	router.Use(filteringMiddleware(filter, "RSA", rsamiddleware.RSADecrypt(rsaPrivateKey)))
+	router.Use(hash.HashWriteMiddleware(keys))
+	router.Use(filteringMiddleware(filter, "Hash", hash.HashRequiredMiddleware(keys)))
+	router.Use(hash.HashReadMiddleware(keys, h.replayGuard))
+	router.Use(filteringMiddleware(filter, "RSA", rsamiddleware.RSADecrypt(keys, h.cryptoRequired)))
 	router.Use(gzip.GzipMiddleware)
 	router.Use(logger.Logger)
+	router.Use(mtlsmiddleware.ClientCertMiddleware(h.tlsClients))
+	router.Use(authmiddleware.AuthMiddleware(h.keyStore))
+	router.Use(tenantmiddleware.TenantMiddleware(h.tenantKeys, h.tenantHeader))
+	trusted := subnetmiddleware.TrustedSubnetMiddleware(h.trustedSubnet)
+
 	router.Route("/update", func(r chi.Router) {
+		r.Use(trusted)
 		r.Post("/", h.UpdateMetricJSON)
 		r.Get("/{MetricsType}/{MetricsName}/{MetricsValue}", h.UpdateMetric)
 	})
 	router.Route("/updates", func(r chi.Router) {
+		r.Use(trusted)
 		r.Post("/", h.UpdateMetricsBatch)
+		r.Post("/add/", h.AddMetricsBatch)
 	})
+	router.With(trusted).Post("/write", h.WriteInflux)
 	router.Route("/value", func(r chi.Router) {
 		r.Post("/", h.GetTypedMetric)
+		r.Delete("/", h.PurgeMetrics)
 		r.Get("/{MetricsType}/{MetricsName}", h.GetMetric)
+		r.Delete("/{MetricsType}/{MetricsName}", h.DeleteMetric)
+	})
+	router.Route("/history", func(r chi.Router) {
+		r.Get("/{MetricsType}/{MetricsName}", h.GetHistory)
 	})
 	router.Route("/ping", func(r chi.Router) {
 		r.Get("/", h.Ping)
 	})
 
 	router.Get(`/`, h.GetAllMetrics)
+	router.Get("/metrics", h.GetPrometheusMetrics)
 	router.Mount("/swagger", httpswagger.WrapHandler)
 
 	router.Route("/debug/pprof", func(r chi.Router) {
@@ -90,23 +123,25 @@ func Router(h *MetricsHandler, rsaPrivateKey *rsa.PrivateKey, hashKey string) ch
 
 }
 
-func filteredMiddleware(filter map[string]map[string][]string, nameMiddleware, RequestURI, Method string) bool {
+// filteredMiddleware Путь фильтра совпадает с path или является его началом: /update включает /update/ и /update/gauge/...
+func filteredMiddleware(filter map[string]map[string][]string, nameMiddleware, path, Method string) bool {
 
 	filterURI, exist := filter[nameMiddleware]
 	if !exist {
 		return false
 	}
 
-	methods, exist := filterURI[RequestURI]
-	if !exist {
-		return false
-	}
+	for uri, methods := range filterURI {
+		if path != uri && !strings.HasPrefix(path, uri+"/") {
+			continue
+		}
 
-	if !slices.Contains(methods, Method) {
-		return false
+		if slices.Contains(methods, Method) {
+			return true
+		}
 	}
 
-	return true
+	return false
 }
 
 func filteringMiddleware(filter filterType, nameMiddleware string,
@@ -115,9 +150,12 @@ func filteringMiddleware(filter filterType, nameMiddleware string,
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
 
-			if filteredMiddleware(filter, nameMiddleware, r.RequestURI, r.Method) {
-				middleware(next)
+			if filteredMiddleware(filter, nameMiddleware, r.URL.Path, r.Method) {
+				middleware(next).ServeHTTP(w, r)
+				return
 			}
+
+			next.ServeHTTP(w, r)
 		}
 
 		return http.HandlerFunc(fn)
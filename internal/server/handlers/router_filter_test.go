@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilteringMiddleware(t *testing.T) {
+
+	filter := filterType{"RSA": {"/update": {http.MethodPost}}}
+
+	var applied bool
+	middleware := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			applied = true
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	handler := filteringMiddleware(filter, "RSA", middleware)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("handled"))
+	}))
+
+	tests := []struct {
+		name        string
+		method      string
+		uri         string
+		wantApplied bool
+	}{
+		{name: "Запрос из фильтра через middleware", method: http.MethodPost, uri: "/update", wantApplied: true},
+		{name: "Другой метод без middleware", method: http.MethodGet, uri: "/update"},
+		{name: "Другой путь без middleware", method: http.MethodGet, uri: "/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			applied = false
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(tt.method, tt.uri, nil))
+
+			assert.Equal(t, tt.wantApplied, applied)
+			assert.Equal(t, "handled", w.Body.String(), "запрос доходит до обработчика")
+		})
+	}
+}
@@ -0,0 +1,776 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/s-turchinskiy/metrics/client"
+	agentmodels "github.com/s-turchinskiy/metrics/internal/agent/models"
+	"github.com/s-turchinskiy/metrics/internal/agent/services/sendmetric/httpresty"
+	"github.com/s-turchinskiy/metrics/internal/agent/services/sendmetric/httpstandart"
+	"github.com/s-turchinskiy/metrics/internal/server/auth"
+	"github.com/s-turchinskiy/metrics/internal/server/keyring"
+	authmiddleware "github.com/s-turchinskiy/metrics/internal/server/middleware/auth"
+	subnetmiddleware "github.com/s-turchinskiy/metrics/internal/server/middleware/subnet"
+	tenantmiddleware "github.com/s-turchinskiy/metrics/internal/server/middleware/tenant"
+	"github.com/s-turchinskiy/metrics/internal/server/replay"
+	"github.com/s-turchinskiy/metrics/internal/server/repository/memcashed"
+	"github.com/s-turchinskiy/metrics/internal/utils/hashutil"
+	"github.com/s-turchinskiy/metrics/internal/utils/rsautil"
+	"github.com/s-turchinskiy/metrics/internal/utils/tlsutil"
+)
+
+func TestRouter_Tenants(t *testing.T) {
+
+	rep := memcashed.New()
+
+	// без ключей арендатор берется из заголовка
+	hHeader := NewHandler(context.Background(), rep, "", true)
+	hHeader.tenantHeader = "X-Tenant-ID"
+	routerHeader := Router(hHeader, nil)
+
+	// с ключами арендатор определяется только по ключу API
+	hKeys := NewHandler(context.Background(), rep, "", true)
+	hKeys.tenantKeys = map[string]string{"secret": "team-b"}
+	hKeys.tenantHeader = "X-Tenant-ID"
+	routerKeys := Router(hKeys, nil)
+
+	tests := []struct {
+		name       string
+		router     http.Handler
+		method     string
+		address    string
+		body       string
+		header     string
+		value      string
+		statusCode int
+		response   string
+	}{
+		{
+			name:       "Обновление у арендатора из заголовка",
+			router:     routerHeader,
+			method:     http.MethodPost,
+			address:    "/update/",
+			body:       `{"id":"Alloc","type":"gauge","value":1}`,
+			header:     "X-Tenant-ID",
+			value:      "team-a",
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "Обновление у арендатора по ключу API",
+			router:     routerKeys,
+			method:     http.MethodPost,
+			address:    "/update/",
+			body:       `{"id":"Alloc","type":"gauge","value":2}`,
+			header:     tenantmiddleware.APIKeyHeader,
+			value:      "secret",
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "Значение арендатора из заголовка",
+			router:     routerHeader,
+			method:     http.MethodGet,
+			address:    "/value/gauge/Alloc",
+			header:     "X-Tenant-ID",
+			value:      "team-a",
+			statusCode: http.StatusOK,
+			response:   "1",
+		},
+		{
+			name:       "Значение арендатора по ключу API",
+			router:     routerKeys,
+			method:     http.MethodGet,
+			address:    "/value/gauge/Alloc",
+			header:     tenantmiddleware.APIKeyHeader,
+			value:      "secret",
+			statusCode: http.StatusOK,
+			response:   "2",
+		},
+		{
+			name:       "Значение арендатора по ключу Bearer",
+			router:     routerKeys,
+			method:     http.MethodGet,
+			address:    "/value/gauge/Alloc",
+			header:     "Authorization",
+			value:      "Bearer secret",
+			statusCode: http.StatusOK,
+			response:   "2",
+		},
+		{
+			name:       "Заголовок арендатора не используется при ключах",
+			router:     routerKeys,
+			method:     http.MethodGet,
+			address:    "/value/gauge/Alloc",
+			header:     "X-Tenant-ID",
+			value:      "team-a",
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "У арендатора по умолчанию метрики нет",
+			router:     routerHeader,
+			method:     http.MethodGet,
+			address:    "/value/gauge/Alloc",
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "Неизвестный ключ API",
+			router:     routerKeys,
+			method:     http.MethodGet,
+			address:    "/",
+			header:     tenantmiddleware.APIKeyHeader,
+			value:      "unknown",
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			name:       "Некорректный арендатор",
+			router:     routerHeader,
+			method:     http.MethodGet,
+			address:    "/",
+			header:     "X-Tenant-ID",
+			value:      "team a",
+			statusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			r := httptest.NewRequest(tt.method, tt.address, strings.NewReader(tt.body))
+			if tt.header != "" {
+				r.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+			tt.router.ServeHTTP(w, r)
+
+			require.Equal(t, tt.statusCode, w.Code)
+			if tt.response != "" {
+				assert.Equal(t, tt.response, strings.TrimSpace(w.Body.String()))
+			}
+		})
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Tenant-ID", "team-a")
+	w := httptest.NewRecorder()
+	routerHeader.ServeHTTP(w, r)
+
+	assert.Contains(t, w.Body.String(), "<td>Alloc</td><td>1</td>", "отдельный список метрик арендатора")
+	assert.NotContains(t, w.Body.String(), "<td>2</td>")
+}
+
+func TestRouter_Auth(t *testing.T) {
+
+	h := NewHandler(context.Background(), memcashed.New(), "", true)
+	h.keyStore = auth.Keys{"agent": {auth.RoleWrite}, "viewer": {auth.RoleRead}, "root": {auth.RoleAdmin}}
+	router := Router(h, nil)
+
+	tests := []struct {
+		name       string
+		method     string
+		address    string
+		body       string
+		key        string
+		statusCode int
+	}{
+		{
+			name:       "Запись с ролью write",
+			method:     http.MethodPost,
+			address:    "/update/",
+			body:       `{"id":"Alloc","type":"gauge","value":1}`,
+			key:        "Bearer agent",
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "Запись с ролью read запрещена",
+			method:     http.MethodPost,
+			address:    "/update/",
+			body:       `{"id":"Alloc","type":"gauge","value":1}`,
+			key:        "viewer",
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:       "Чтение с ролью read",
+			method:     http.MethodGet,
+			address:    "/value/gauge/Alloc",
+			key:        "viewer",
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "Чтение с ролью write запрещено",
+			method:     http.MethodGet,
+			address:    "/",
+			key:        "agent",
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:       "Профилирование только для admin",
+			method:     http.MethodGet,
+			address:    "/debug/pprof/cmdline",
+			key:        "viewer",
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:       "Профилирование с ролью admin",
+			method:     http.MethodGet,
+			address:    "/debug/pprof/cmdline",
+			key:        "root",
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "Без ключа",
+			method:     http.MethodGet,
+			address:    "/",
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			name:       "Неизвестный ключ",
+			method:     http.MethodGet,
+			address:    "/",
+			key:        "unknown",
+			statusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			r := httptest.NewRequest(tt.method, tt.address, strings.NewReader(tt.body))
+			if tt.key != "" {
+				r.Header.Set(authmiddleware.AuthorizationHeader, tt.key)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.statusCode, w.Code)
+		})
+	}
+}
+
+func TestRouter_TrustedSubnet(t *testing.T) {
+
+	_, trusted, err := net.ParseCIDR("192.168.1.0/24")
+	require.NoError(t, err)
+
+	h := NewHandler(context.Background(), memcashed.New(), "", true)
+	h.trustedSubnet = trusted
+	router := Router(h, nil)
+
+	tests := []struct {
+		name       string
+		method     string
+		address    string
+		body       string
+		realIP     string
+		statusCode int
+	}{
+		{
+			name:       "Адрес из доверенной подсети",
+			method:     http.MethodPost,
+			address:    "/update/",
+			body:       `{"id":"Alloc","type":"gauge","value":1}`,
+			realIP:     "192.168.1.10",
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "Адрес вне доверенной подсети",
+			method:     http.MethodPost,
+			address:    "/updates/",
+			body:       `[{"id":"Alloc","type":"gauge","value":1}]`,
+			realIP:     "10.0.0.1",
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:       "Без заголовка X-Real-IP",
+			method:     http.MethodPost,
+			address:    "/write",
+			body:       "cpu usage=1",
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:       "Чтение не проверяется",
+			method:     http.MethodGet,
+			address:    "/value/gauge/Alloc",
+			realIP:     "10.0.0.1",
+			statusCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			r := httptest.NewRequest(tt.method, tt.address, strings.NewReader(tt.body))
+			if tt.realIP != "" {
+				r.Header.Set(subnetmiddleware.RealIPHeader, tt.realIP)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.statusCode, w.Code)
+		})
+	}
+}
+
+func TestRouter_RSADecrypt(t *testing.T) {
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	keys, err := keyring.New("", privateKey, "", "")
+	require.NoError(t, err)
+
+	rep := memcashed.New()
+	router := Router(NewHandler(context.Background(), rep, "", true), keys)
+
+	// пакет больше размера ключа RSA, целиком RSA-OAEP его не зашифровать
+	var batch strings.Builder
+	batch.WriteString("[")
+	for i := 0; i < 50; i++ {
+		if i > 0 {
+			batch.WriteString(",")
+		}
+		fmt.Fprintf(&batch, `{"id":"Metric%d","type":"gauge","value":%d}`, i, i)
+	}
+	batch.WriteString("]")
+
+	body, wrappedKey, err := rsautil.EncryptHybrid(&privateKey.PublicKey, []byte(batch.String()))
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(body))
+	r.Header.Set(rsautil.EncryptedKeyHeader, wrappedKey)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, 50, rep.CountGauges(context.Background()))
+
+	body, err = rsautil.Encrypt(&privateKey.PublicKey, []byte(`{"id":"Alloc","type":"gauge","value":1}`))
+	require.NoError(t, err)
+
+	r = httptest.NewRequest(http.MethodPost, "/update/", bytes.NewReader(body))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code, "шифрование RSA без ключа AES")
+
+	r = httptest.NewRequest(http.MethodPost, "/update/", bytes.NewReader(body))
+	r.Header.Set(rsautil.EncryptedKeyHeader, wrappedKey)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "ключ AES от другого сообщения")
+
+	plain := []byte(`{"id":"Plain","type":"gauge","value":2}`)
+	r = httptest.NewRequest(http.MethodPost, "/update/", bytes.NewReader(plain))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code, "открытое тело от агента без ключа RSA")
+
+	h := NewHandler(context.Background(), memcashed.New(), "", true)
+	h.cryptoRequired = true
+	r = httptest.NewRequest(http.MethodPost, "/update/", bytes.NewReader(plain))
+	w = httptest.NewRecorder()
+	Router(h, keys).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "открытое тело при обязательном шифровании")
+}
+
+func TestRouter_KeyRotation(t *testing.T) {
+
+	dir := t.TempDir()
+	hashKeysPath := filepath.Join(dir, "hash_keys")
+	require.NoError(t, os.WriteFile(hashKeysPath, []byte("2024:old\n2025:new\n"), 0600))
+
+	keys, err := keyring.New("", nil, hashKeysPath, "")
+	require.NoError(t, err)
+
+	router := Router(NewHandler(context.Background(), memcashed.New(), "", true), keys)
+	body := []byte(`{"id":"Alloc","type":"gauge","value":1}`)
+
+	tests := []struct {
+		name       string
+		hashKey    string
+		keyID      string
+		statusCode int
+	}{
+		{name: "новый ключ по идентификатору", hashKey: "new", keyID: "2025", statusCode: http.StatusOK},
+		{name: "старый ключ по идентификатору", hashKey: "old", keyID: "2024", statusCode: http.StatusOK},
+		{name: "ключ не соответствует идентификатору", hashKey: "old", keyID: "2025", statusCode: http.StatusBadRequest},
+		{name: "неизвестный идентификатор", hashKey: "new", keyID: "2026", statusCode: http.StatusBadRequest},
+		{name: "без идентификатора", hashKey: "old", statusCode: http.StatusOK},
+		{name: "без идентификатора, чужой ключ", hashKey: "other", statusCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			r := httptest.NewRequest(http.MethodPost, "/update/", bytes.NewReader(body))
+			r.Header.Set("HashSHA256", hashutil.СomputeHexadecimalSha256Hash(tt.hashKey, body))
+			if tt.keyID != "" {
+				r.Header.Set(hashutil.KeyIDHeader, tt.keyID)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			assert.Equal(t, tt.statusCode, w.Code, w.Body.String())
+			if tt.statusCode == http.StatusOK && tt.keyID != "" {
+				assert.Equal(t, tt.keyID, w.Header().Get(hashutil.KeyIDHeader), "ответ подписан ключом запроса")
+			}
+		})
+	}
+
+	require.NoError(t, os.WriteFile(hashKeysPath, []byte("2025:new\n"), 0600))
+	require.NoError(t, keys.Reload())
+
+	r := httptest.NewRequest(http.MethodPost, "/update/", bytes.NewReader(body))
+	r.Header.Set("HashSHA256", hashutil.СomputeHexadecimalSha256Hash("old", body))
+	r.Header.Set(hashutil.KeyIDHeader, "2024")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "выведенный из ротации ключ")
+}
+
+func TestRouter_ReplayProtection(t *testing.T) {
+
+	keys, err := keyring.New("secret", nil, "", "")
+	require.NoError(t, err)
+
+	rep := memcashed.New()
+	h := NewHandler(context.Background(), rep, "", true)
+	h.replayGuard = replay.New(time.Minute, 100)
+	router := Router(h, keys)
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	value := 3.5
+	sender := httpstandart.New(server.URL+"/update/", hashutil.СomputeHexadecimalSha256Hash, "secret")
+	require.NoError(t, sender.Send(agentmodels.Metrics{ID: "Alloc", MType: "gauge", Value: &value}))
+	gauge, exist, err := rep.GetGauge(context.Background(), "Alloc")
+	require.NoError(t, err)
+	require.True(t, exist, "агент подписывает метку времени и nonce")
+	assert.Equal(t, value, gauge)
+
+	body := []byte(`{"id":"Alloc","type":"gauge","value":1}`)
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	old := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+
+	tests := []struct {
+		name       string
+		timestamp  string
+		nonce      string
+		signed     string
+		signedURI  string
+		unsigned   bool
+		statusCode int
+	}{
+		{name: "подписанный запрос", timestamp: now, nonce: "n1", signed: now, statusCode: http.StatusOK},
+		{name: "без подписи", timestamp: now, nonce: "n4", unsigned: true, statusCode: http.StatusBadRequest},
+		{name: "повтор запроса", timestamp: now, nonce: "n1", signed: now, statusCode: http.StatusBadRequest},
+		{name: "старый запрос", timestamp: old, nonce: "n2", signed: old, statusCode: http.StatusBadRequest},
+		{name: "подмена метки времени", timestamp: now, nonce: "n3", signed: old, statusCode: http.StatusBadRequest},
+		{name: "без метки времени и nonce", statusCode: http.StatusBadRequest},
+		{name: "подпись другого URI", timestamp: now, nonce: "n5", signed: now, signedURI: "/updates/add/", statusCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			signedURI := tt.signedURI
+			if signedURI == "" {
+				signedURI = "/update/"
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/update/", bytes.NewReader(body))
+			if !tt.unsigned {
+				r.Header.Set("HashSHA256", hashutil.СomputeHexadecimalSha256Hash("secret", hashutil.SignedData(http.MethodPost, signedURI, tt.signed, tt.nonce, body)))
+			}
+			if tt.timestamp != "" {
+				r.Header.Set(hashutil.TimestampHeader, tt.timestamp)
+				r.Header.Set(hashutil.NonceHeader, tt.nonce)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			assert.Equal(t, tt.statusCode, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestRouter_SignedRequestWithoutBody(t *testing.T) {
+
+	keys, err := keyring.New("secret", nil, "", "")
+	require.NoError(t, err)
+
+	rep := memcashed.New()
+	h := NewHandler(context.Background(), rep, "", true)
+	h.replayGuard = replay.New(time.Minute, 100)
+	router := Router(h, keys)
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	send := func(method, uri, signedMethod, signedURI, nonce string) int {
+		r := httptest.NewRequest(method, uri, nil)
+		r.Header.Set("HashSHA256", hashutil.СomputeHexadecimalSha256Hash("secret", hashutil.SignedData(signedMethod, signedURI, now, nonce, nil)))
+		r.Header.Set(hashutil.TimestampHeader, now)
+		r.Header.Set(hashutil.NonceHeader, nonce)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	ctx := context.Background()
+	require.NoError(t, rep.UpdateGauge(ctx, "Alloc", 5))
+	require.NoError(t, rep.UpdateGauge(ctx, "Keep", 1))
+
+	assert.Equal(t, http.StatusOK, send(http.MethodDelete, "/value/gauge/Alloc", http.MethodDelete, "/value/gauge/Alloc", "n1"))
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodDelete, "/value/gauge/Keep", http.MethodDelete, "/value/gauge/Alloc", "n2"),
+		"подпись пустого тела другого запроса")
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodDelete, "/value/gauge/Keep", http.MethodGet, "/value/gauge/Keep", "n3"),
+		"подпись другого метода")
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodDelete, "/value/?pattern=*", http.MethodDelete, "/value/?pattern=Other", "n4"),
+		"параметры запроса входят в подпись")
+
+	_, exist, err := rep.GetGauge(ctx, "Alloc")
+	require.NoError(t, err)
+	assert.False(t, exist)
+
+	_, exist, err = rep.GetGauge(ctx, "Keep")
+	require.NoError(t, err)
+	assert.True(t, exist, "чужая подпись не удаляет метрику")
+}
+
+func TestRouter_ReplayGuardFull(t *testing.T) {
+
+	keys, err := keyring.New("secret", nil, "", "")
+	require.NoError(t, err)
+
+	h := NewHandler(context.Background(), memcashed.New(), "", true)
+	h.replayGuard = replay.New(time.Minute, 1)
+	router := Router(h, keys)
+
+	body := []byte(`{"id":"Alloc","type":"gauge","value":1}`)
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	for _, want := range []int{http.StatusOK, http.StatusServiceUnavailable} {
+		nonce := strconv.Itoa(want)
+		r := httptest.NewRequest(http.MethodPost, "/update/", bytes.NewReader(body))
+		r.Header.Set("HashSHA256", hashutil.СomputeHexadecimalSha256Hash("secret", hashutil.SignedData(http.MethodPost, "/update/", now, nonce, body)))
+		r.Header.Set(hashutil.TimestampHeader, now)
+		r.Header.Set(hashutil.NonceHeader, nonce)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		assert.Equal(t, want, w.Code, w.Body.String())
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/value/gauge/Alloc", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code, "чтение без подписи")
+}
+
+func TestRouter_Client(t *testing.T) {
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	keys, err := keyring.New("secret", privateKey, "", "")
+	require.NoError(t, err)
+
+	rep := memcashed.New()
+	h := NewHandler(context.Background(), rep, "", true)
+	h.replayGuard = replay.New(time.Minute, 100)
+
+	server := httptest.NewServer(Router(h, keys))
+	defer server.Close()
+
+	c := client.New(server.URL, client.WithHashKey("secret", ""), client.WithRSAPublicKey(&privateKey.PublicKey, ""))
+	c.Counter("Requests").Add(3)
+	c.Gauge("QueueLength").Set(12)
+	c.Histogram("Duration", []float64{0.1, 1}).Observe(0.5)
+	require.NoError(t, c.Flush(context.Background()), "клиент отправляет /updates/add/ в формате агента")
+
+	counter, _, err := rep.GetCounter(context.Background(), "Requests")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), counter)
+
+	gauge, _, err := rep.GetGauge(context.Background(), "QueueLength")
+	require.NoError(t, err)
+	assert.Equal(t, 12.0, gauge)
+
+	histogram, exist, err := rep.GetHistogram(context.Background(), "Duration")
+	require.NoError(t, err)
+	require.True(t, exist)
+	assert.Equal(t, []uint64{0, 1, 0}, histogram.Counts)
+
+	other := client.New(server.URL, client.WithHashKey("secret", ""), client.WithRSAPublicKey(&privateKey.PublicKey, ""))
+	other.Counter("Requests").Add(10)
+	other.Gauge("Workers").Set(4)
+	require.NoError(t, other.Flush(context.Background()))
+
+	c.Counter("Requests").Inc()
+	require.NoError(t, c.Flush(context.Background()))
+
+	counter, _, err = rep.GetCounter(context.Background(), "Requests")
+	require.NoError(t, err)
+	assert.Equal(t, int64(14), counter, "приращения клиентов складываются")
+
+	gauge, exist, err = rep.GetGauge(context.Background(), "QueueLength")
+	require.NoError(t, err)
+	require.True(t, exist, "отправка другого клиента не затирает gauge")
+	assert.Equal(t, 12.0, gauge)
+
+	gauge, exist, err = rep.GetGauge(context.Background(), "Workers")
+	require.NoError(t, err)
+	require.True(t, exist)
+	assert.Equal(t, 4.0, gauge)
+
+	wrongKey := client.New(server.URL, client.WithHashKey("other", ""))
+	wrongKey.Counter("Requests").Inc()
+	assert.Error(t, wrongKey.Flush(context.Background()), "чужой ключ подписи")
+}
+
+func TestRouter_AgentBatch(t *testing.T) {
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	keys, err := keyring.New("secret", privateKey, "", "")
+	require.NoError(t, err)
+
+	rep := memcashed.New()
+	h := NewHandler(context.Background(), rep, "", true)
+	h.replayGuard = replay.New(time.Minute, 100)
+
+	server := httptest.NewServer(Router(h, keys))
+	defer server.Close()
+
+	value := 3.5
+	delta := int64(2)
+	metrics := []agentmodels.Metrics{
+		{ID: "Alloc", MType: "gauge", Value: &value},
+		{ID: "PollCount", MType: "counter", Delta: &delta},
+	}
+
+	sender := httpresty.New(server.URL+"/update/",
+		httpresty.WithHash("secret", hashutil.СomputeHexadecimalSha256Hash),
+		httpresty.WithRsaPublicKey(&privateKey.PublicKey),
+		httpresty.WithBatchURL(server.URL+"/updates/add/"),
+	)
+	require.NoError(t, sender.SendBatch(metrics[:1]), "пачка сжата gzip, зашифрована и подписана")
+	require.NoError(t, sender.SendBatch(metrics[1:]))
+	require.NoError(t, sender.SendBatch(metrics[1:]))
+
+	gauge, exist, err := rep.GetGauge(context.Background(), "Alloc")
+	require.NoError(t, err)
+	require.True(t, exist, "следующая пачка не затирает метрики предыдущей")
+	assert.Equal(t, value, gauge)
+
+	counter, _, err := rep.GetCounter(context.Background(), "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, 2*delta, counter, "пачки складываются с сохраненными метриками")
+
+	wrongKey := httpresty.New(server.URL+"/update/",
+		httpresty.WithHash("other", hashutil.СomputeHexadecimalSha256Hash),
+		httpresty.WithBatchURL(server.URL+"/updates/add/"),
+	)
+	assert.Error(t, wrongKey.SendBatch(metrics), "ответ не 200 - ошибка отправки пачки")
+}
+
+func TestRouter_MutualTLS(t *testing.T) {
+
+	dir := t.TempDir()
+	ca, caKey := writeTestCertificate(t, dir, "ca", nil, nil)
+	writeTestCertificate(t, dir, "server", ca, caKey)
+	writeTestCertificate(t, dir, "agent-1", ca, caKey)
+	writeTestCertificate(t, dir, "agent-2", ca, caKey)
+
+	serverConfig, err := tlsutil.ServerConfig(filepath.Join(dir, "ca.pem"), filepath.Join(dir, "server.pem"), filepath.Join(dir, "server.key"))
+	require.NoError(t, err)
+
+	h := NewHandler(context.Background(), memcashed.New(), "", true)
+	h.tlsClients = []string{"agent-1"}
+
+	server := httptest.NewUnstartedServer(Router(h, nil))
+	server.TLS = serverConfig
+	server.StartTLS()
+	defer server.Close()
+
+	get := func(agent string) (int, error) {
+
+		cert := ""
+		if agent != "" {
+			cert = filepath.Join(dir, agent+".pem")
+		}
+		clientConfig, err := tlsutil.ClientConfig(filepath.Join(dir, "ca.pem"), cert, filepath.Join(dir, agent+".key"))
+		require.NoError(t, err)
+
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig}}
+		resp, err := client.Get(server.URL + "/value/gauge/Alloc")
+		if err != nil {
+			return 0, err
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	status, err := get("agent-1")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, status, "агент из списка допущен")
+
+	status, err = get("agent-2")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, status, "агент не из списка")
+
+	_, err = get("")
+	assert.Error(t, err, "без сертификата клиента")
+}
+
+// writeTestCertificate Сертификат name с ключом в dir, без parent - самоподписанный CA
+func writeTestCertificate(t *testing.T, dir, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name+".pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name+".key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return cert, key
+}
@@ -19,6 +19,7 @@ const docTemplate = `{
     "paths": {
         "/": {
             "get": {
+                "description": "Метрики выводятся с метками серии: CPUutilization{cpu=\"3\"}. Параметры запроса - фильтр по меткам\nВыводятся метрики арендатора, определенного по ключу API X-API-Key или заголовку TENANT_HEADER",
                 "consumes": [
                     "application/json"
                 ],
@@ -30,6 +31,14 @@ const docTemplate = `{
                 ],
                 "summary": "Получение всех метрик на текущий момент",
                 "operationId": "infoGetAllMetrics",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Арендатор",
+                        "name": "X-Tenant-ID",
+                        "in": "header"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "Counter PollCount 3119064 someMetric\t26 Gauge Alloc\t2829408 BuckHashSys\t3349 CPUutilization0\t9.708737864123963",
@@ -53,6 +62,124 @@ const docTemplate = `{
                 }
             }
         },
+        "/history/{MetricsType}/{MetricsName}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Получение значений метрики за интервал времени. Границы интервала в RFC3339 или unix-секундах, по умолчанию вся история.\nПри указании resolution возвращаются агрегаты models.RollupSample с этим интервалом.\nОстальные параметры запроса - метки серии",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Info"
+                ],
+                "summary": "Получение истории значений метрики",
+                "operationId": "infoGetHistory",
+                "parameters": [
+                    {
+                        "enum": [
+                            "counter",
+                            "gauge"
+                        ],
+                        "type": "string",
+                        "description": "Metrics Type",
+                        "name": "MetricsType",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Metrics Name",
+                        "name": "MetricsName",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "2025-10-06T15:00:00Z",
+                        "description": "Начало интервала",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "1759763022",
+                        "description": "Конец интервала",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "1m",
+                        "description": "Интервал агрегатов из настройки RETENTION",
+                        "name": "resolution",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.HistorySample"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный запрос",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Ошибка авторизации",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/metrics": {
+            "get": {
+                "description": "Формат выбирается по заголовку Accept: application/openmetrics-text - OpenMetrics 1.0.0, иначе Prometheus text 0.0.4.\nНедопустимые в именах Prometheus символы заменяются на _, имя не может начинаться с цифры.\nПараметры запроса - фильтр по меткам серий",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "Info"
+                ],
+                "summary": "Получение всех метрик в формате Prometheus",
+                "operationId": "infoGetPrometheusMetrics",
+                "responses": {
+                    "200": {
+                        "description": "# TYPE PollCount counter",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
         "/ping": {
             "get": {
                 "consumes": [
@@ -201,6 +328,67 @@ const docTemplate = `{
                 }
             }
         },
+        "/updates/add": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Массовое обновление метрик из json с семантикой /update - counter складывается с сохраненным, gauge заменяется. Пакет можно отправлять частями",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "Update"
+                ],
+                "summary": "Сложение метрик",
+                "operationId": "updateAddMetricsBatch",
+                "parameters": [
+                    {
+                        "description": "Метрики",
+                        "name": "metric_data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Metrics"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Load 5 records",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный запрос",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Ошибка авторизации",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
         "/value": {
             "post": {
                 "security": [
@@ -208,7 +396,7 @@ const docTemplate = `{
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Получение значение метрики в json",
+                "description": "Получение значение метрики в json. Метрика определяется именем и метками labels\nВ ответе updated - время последнего обновления, для gauge при заданном GAUGE_TTL stale - значение устарело",
                 "consumes": [
                     "application/json"
                 ],
@@ -257,6 +445,68 @@ const docTemplate = `{
                         }
                     }
                 }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Удаление серий, имя которых начинается с prefix и/или подходит под шаблон pattern (синтаксис path.Match).\nОстальные параметры запроса - фильтр по меткам серий. Удаление всех метрик - pattern=*",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "Update"
+                ],
+                "summary": "Массовое удаление метрик",
+                "operationId": "updatePurgeMetrics",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Типы метрик через запятую, по умолчанию все",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Префикс имени метрики",
+                        "name": "prefix",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Шаблон имени метрики",
+                        "name": "pattern",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Deleted 5 records",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный запрос",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Ошибка авторизации",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
             }
         },
         "/value/{MetricsType}/{MetricsName}": {
@@ -266,7 +516,7 @@ const docTemplate = `{
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Получение значения метрики по типу и наименованию метрики",
+                "description": "Получение значения метрики по типу и наименованию метрики.\nПараметры запроса - метки серии: /value/gauge/CPUutilization?cpu=3\nДля histogram и summary обязателен параметр quantile: /value/histogram/Latency?quantile=0.99",
                 "consumes": [
                     "application/json"
                 ],
@@ -282,7 +532,9 @@ const docTemplate = `{
                     {
                         "enum": [
                             "counter",
-                            "gauge"
+                            "gauge",
+                            "histogram",
+                            "summary"
                         ],
                         "type": "string",
                         "description": "Metrics Type",
@@ -296,6 +548,12 @@ const docTemplate = `{
                         "name": "MetricsName",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "number",
+                        "description": "Квантиль для histogram и summary",
+                        "name": "quantile",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -330,6 +588,64 @@ const docTemplate = `{
                         }
                     }
                 }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Удаление серии метрики по типу и наименованию. История значений не удаляется.\nПараметры запроса - метки серии: /value/gauge/CPUutilization?cpu=3",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "Update"
+                ],
+                "summary": "Удаление метрики",
+                "operationId": "updateDeleteMetric",
+                "parameters": [
+                    {
+                        "enum": [
+                            "counter",
+                            "gauge",
+                            "histogram",
+                            "summary"
+                        ],
+                        "type": "string",
+                        "description": "Metrics Type",
+                        "name": "MetricsType",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Metrics Name",
+                        "name": "MetricsName",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Ошибка авторизации",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Метрика не найдена",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
             }
         },
         "/value/{MetricsType}/{MetricsName}/{MetricsValue}": {
@@ -339,7 +655,7 @@ const docTemplate = `{
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Обновление значения метрики с передачей в параметрах запроса типа, наименования, значения метрики",
+                "description": "Обновление значения метрики с передачей в параметрах запроса типа, наименования, значения метрики.\nПараметры запроса - метки серии",
                 "consumes": [
                     "application/json"
                 ],
@@ -355,7 +671,8 @@ const docTemplate = `{
                     {
                         "enum": [
                             "counter",
-                            "gauge"
+                            "gauge",
+                            "histogram"
                         ],
                         "type": "string",
                         "description": "Metrics Type",
@@ -412,9 +729,139 @@ const docTemplate = `{
                     }
                 }
             }
+        },
+        "/write": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Каждая строка: measurement[,tag=value...] field=value[,field=value...] [timestamp].\nПоля с суффиксами из INFLUX_COUNTER_SUFFIXES сохраняются как counter, остальные числовые и логические - как gauge, строковые пропускаются.\nЗначения тегов из INFLUX_TAGS включаются в имя метрики. При ошибке разбора любой строки ничего не сохраняется",
+                "consumes": [
+                    "text/plain"
+                ],
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "Update"
+                ],
+                "summary": "Обновление метрик в формате InfluxDB line protocol",
+                "operationId": "updateWriteInflux",
+                "parameters": [
+                    {
+                        "enum": [
+                            "ns",
+                            "us",
+                            "ms",
+                            "s"
+                        ],
+                        "type": "string",
+                        "description": "Единицы времени timestamp",
+                        "name": "precision",
+                        "in": "query"
+                    },
+                    {
+                        "example": "cpu,host=server01 usage_idle=92.5,interrupts_total=1024i 1759763022000000000",
+                        "description": "Точки line protocol",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Метрики сохранены",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный запрос",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Ошибка авторизации",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
         }
     },
     "definitions": {
+        "models.Histogram": {
+            "type": "object",
+            "properties": {
+                "bounds": {
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    },
+                    "example": [
+                        0.1,
+                        0.5,
+                        1
+                    ]
+                },
+                "count": {
+                    "type": "integer",
+                    "example": 6
+                },
+                "counts": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        3,
+                        2,
+                        1,
+                        0
+                    ]
+                },
+                "sum": {
+                    "type": "number",
+                    "example": 1.7
+                }
+            }
+        },
+        "models.HistorySample": {
+            "type": "object",
+            "properties": {
+                "delta": {
+                    "type": "integer",
+                    "example": 5
+                },
+                "timestamp": {
+                    "type": "string",
+                    "example": "2025-10-06T15:03:42Z"
+                },
+                "value": {
+                    "type": "number",
+                    "example": 6649272
+                }
+            }
+        },
+        "models.Labels": {
+            "type": "object",
+            "additionalProperties": {
+                "type": "string"
+            }
+        },
         "models.Metrics": {
             "type": "object",
             "properties": {
@@ -423,6 +870,14 @@ const docTemplate = `{
                     "type": "integer",
                     "example": 100
                 },
+                "histogram": {
+                    "description": "значение метрики в случае передачи histogram",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Histogram"
+                        }
+                    ]
+                },
                 "id": {
                     "description": "имя метрики",
                     "type": "string",
@@ -432,21 +887,85 @@ const docTemplate = `{
                     ],
                     "example": "gauge"
                 },
+                "labels": {
+                    "description": "метки серии, вместе с именем определяют метрику",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Labels"
+                        }
+                    ]
+                },
+                "quantiles": {
+                    "description": "в запросе /value - запрошенные квантили, в ответе - их значения",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Quantile"
+                    }
+                },
+                "stale": {
+                    "description": "в ответе /value - gauge не обновлялся дольше GAUGE_TTL",
+                    "type": "boolean"
+                },
+                "summary": {
+                    "description": "значение метрики в случае передачи summary",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Summary"
+                        }
+                    ]
+                },
                 "type": {
-                    "description": "параметр, принимающий значение gauge или counter",
+                    "description": "параметр, принимающий значение gauge, counter, histogram или summary",
                     "type": "string",
                     "example": "Alloc"
                 },
+                "updated": {
+                    "description": "в ответе /value - время последнего обновления",
+                    "type": "string"
+                },
                 "value": {
                     "description": "значение метрики в случае передачи gauge",
                     "type": "number",
                     "example": 6649272
                 }
             }
+        },
+        "models.Quantile": {
+            "type": "object",
+            "properties": {
+                "quantile": {
+                    "type": "number",
+                    "example": 0.99
+                },
+                "value": {
+                    "type": "number",
+                    "example": 0.9
+                }
+            }
+        },
+        "models.Summary": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer",
+                    "example": 6
+                },
+                "quantiles": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Quantile"
+                    }
+                },
+                "sum": {
+                    "type": "number",
+                    "example": 1.7
+                }
+            }
         }
     },
     "securityDefinitions": {
         "ApiKeyAuth": {
+            "description": "Ключ API или Bearer ключ. Роли: read - чтение, write - /update, /updates, /write, admin - все запросы",
             "type": "apiKey",
             "name": "authorization",
             "in": "header"
@@ -11,11 +11,12 @@ import (
 // UpdateMetric godoc
 // @Tags Update
 // @Summary Обновление значения метрики
-// @Description Обновление значения метрики с передачей в параметрах запроса типа, наименования, значения метрики
+// @Description Обновление значения метрики с передачей в параметрах запроса типа, наименования, значения метрики.
+// @Description Параметры запроса - метки серии
 // @ID updateUpdateMetric
 // @Accept  json
 // @Produce html
-// @Param MetricsType path string true "Metrics Type" Enums(counter, gauge)
+// @Param MetricsType path string true "Metrics Type" Enums(counter, gauge, histogram)
 // @Param MetricsName path string true "Metrics Name"
 // @Param MetricsValue path float64 true "Metrics Value"
 // @Success 200 {Object} string "100, установленное значение метрики"
@@ -42,6 +43,7 @@ func (h *MetricsHandler) UpdateMetric(w http.ResponseWriter, r *http.Request) {
 		MetricsType:  pathSlice[0],
 		MetricsName:  pathSlice[1],
 		MetricsValue: pathSlice[2], //r.PathValue("MetricsValue"),
+		Labels:       labelsFromQuery(r.URL.Query()),
 	}
 
 	err := h.Service.UpdateMetric(r.Context(), metric)
@@ -45,7 +45,16 @@ func (h *MetricsHandler) UpdateMetricJSON(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	metric := models.StorageMetrics{Name: req.ID, MType: req.MType, Delta: req.Delta, Value: req.Value}
+	metric := models.StorageMetrics{
+		Name:      req.ID,
+		MType:     req.MType,
+		Delta:     req.Delta,
+		Value:     req.Value,
+		Labels:    req.Labels,
+		Histogram: req.Histogram,
+		Summary:   req.Summary,
+		Quantiles: req.Quantiles,
+	}
 	result, err := h.Service.UpdateTypedMetric(r.Context(), metric)
 	if err != nil {
 		logger.Log.Infoln("error", err.Error(), "metric", metric)
@@ -57,7 +66,16 @@ func (h *MetricsHandler) UpdateMetricJSON(w http.ResponseWriter, r *http.Request
 
 	w.Header().Set("Content-Type", "application/json")
 
-	resp := models.Metrics{ID: result.Name, MType: result.MType, Delta: result.Delta, Value: result.Value}
+	resp := models.Metrics{
+		ID:        result.Name,
+		MType:     result.MType,
+		Delta:     result.Delta,
+		Value:     result.Value,
+		Labels:    result.Labels,
+		Histogram: result.Histogram,
+		Summary:   result.Summary,
+		Quantiles: result.Quantiles,
+	}
 	enc := json.NewEncoder(w)
 	if err := enc.Encode(resp); err != nil {
 		logger.Log.Info("error encoding response", zap.Error(err))
@@ -27,19 +27,71 @@ import (
 // @Router /updates [post]
 func (h *MetricsHandler) UpdateMetricsBatch(w http.ResponseWriter, r *http.Request) {
 
-	var req []models.Metrics
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	metrics, err := decodeMetricsBatch(r)
+	if err != nil {
 		logger.Log.Info("cannot decode request JSON body", zap.Error(err))
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
+	count, err := h.Service.UpdateTypedMetrics(r.Context(), metrics)
+	writeBatchResult(w, metrics, count, err)
+}
+
+// AddMetricsBatch godoc
+// @Tags Update
+// @Summary Сложение метрик
+// @Description Массовое обновление метрик из json с семантикой /update - counter складывается с сохраненным, gauge заменяется. Пакет можно отправлять частями
+// @ID updateAddMetricsBatch
+// @Accept  json
+// @Produce html
+// @Param metric_data body []models.Metrics true "Метрики"
+// @Success 200 {string} string "Load 5 records"
+// @Failure 400 {string} string "Неверный запрос"
+// @Failure 403 {string} string "Ошибка авторизации"
+// @Failure 500 {string} string "Внутренняя ошибка"
+// @Security ApiKeyAuth
+// @Router /updates/add [post]
+func (h *MetricsHandler) AddMetricsBatch(w http.ResponseWriter, r *http.Request) {
+
+	metrics, err := decodeMetricsBatch(r)
+	if err != nil {
+		logger.Log.Info("cannot decode request JSON body", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	count, err := h.Service.AddTypedMetrics(r.Context(), metrics)
+	writeBatchResult(w, metrics, count, err)
+}
+
+func decodeMetricsBatch(r *http.Request) ([]models.StorageMetrics, error) {
+
+	var req []models.Metrics
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, err
+	}
+
 	metrics := make([]models.StorageMetrics, 0, len(req))
 	for _, reqMetric := range req {
-		metric := models.StorageMetrics{Name: reqMetric.ID, MType: reqMetric.MType, Delta: reqMetric.Delta, Value: reqMetric.Value}
+		metric := models.StorageMetrics{
+			Name:      reqMetric.ID,
+			MType:     reqMetric.MType,
+			Delta:     reqMetric.Delta,
+			Value:     reqMetric.Value,
+			Labels:    reqMetric.Labels,
+			Histogram: reqMetric.Histogram,
+			Summary:   reqMetric.Summary,
+			Quantiles: reqMetric.Quantiles,
+		}
 		metrics = append(metrics, metric)
 	}
-	count, err := h.Service.UpdateTypedMetrics(r.Context(), metrics)
+
+	return metrics, nil
+}
+
+func writeBatchResult(w http.ResponseWriter, metrics []models.StorageMetrics, count int64, err error) {
+
 	if err != nil {
 		logger.Log.Infoln("error", err.Error(), "metrics", metrics)
 		w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
@@ -50,5 +102,4 @@ func (h *MetricsHandler) UpdateMetricsBatch(w http.ResponseWriter, r *http.Reque
 
 	w.Header().Set("Content-Type", "application/json")
 	fmt.Fprintf(w, "Load %d records", count)
-
 }
@@ -7,6 +7,7 @@ import (
 	mocksrepository "github.com/s-turchinskiy/metrics/internal/server/repository/mock"
 	"github.com/s-turchinskiy/metrics/internal/utils/testingcommon"
 	"github.com/stretchr/testify/assert"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -108,3 +109,102 @@ func TestMetricsHandler_UpdateMetricsBatch(t *testing.T) {
 		})
 	}
 }
+
+func TestMetricsHandler_AddMetricsBatch(t *testing.T) {
+
+	address := "/updates/add"
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mocksrepository.NewMockRepository(ctrl)
+
+	ctx := context.Background()
+	gomock.InOrder(
+		mock.EXPECT().UpdateGauge(gomock.Any(), "PauseTotalNs", float64(128)).Return(nil),
+		mock.EXPECT().UpdateCounter(gomock.Any(), "PollCount", int64(5)).Return(nil),
+		mock.EXPECT().GetCounter(gomock.Any(), "PollCount").Return(int64(12), true, nil),
+	)
+
+	tests := []test{
+		{
+			handler: NewHandler(ctx, mock, "", true),
+			ct: testingcommon.Test{
+				Name:        "Успешно",
+				Method:      http.MethodPost,
+				Address:     address,
+				ContentType: ContentTypeApplicationJSON,
+				Request: `[
+   						{
+      						"id": "PauseTotalNs",
+      						"type": "gauge",
+      						"value": 128
+  					 	},
+   	 					{
+      						"id": "PollCount",
+      						"type": "counter",
+      						"delta": 5
+						}
+						]`,
+				Want: testingcommon.Want{
+					StatusCode: http.StatusOK,
+					Response:   "Load 2 records",
+				}},
+		},
+		{
+			handler: NewHandler(ctx, mock, "", true),
+			ct: testingcommon.Test{
+				Name:        "Метрика без значения, пакет не применяется",
+				Method:      http.MethodPost,
+				Address:     address,
+				ContentType: ContentTypeApplicationJSON,
+				Request: `[
+   						{
+      						"id": "PauseTotalNs",
+      						"type": "gauge",
+      						"value": 128
+  					 	},
+   	 					{
+      						"id": "PollCount",
+      						"type": "counter"
+						}
+						]`,
+				Want: testingcommon.Want{
+					StatusCode: http.StatusBadRequest,
+				}},
+		},
+		{
+			handler: NewHandler(ctx, mock, "", true),
+			ct: testingcommon.Test{
+				Name:        "Неправильный json",
+				Method:      http.MethodPost,
+				Address:     address,
+				ContentType: ContentTypeApplicationJSON,
+				Request:     `[{"id": "PauseTotalNs", "type": "gauge",}]`,
+				Want: testingcommon.Want{
+					StatusCode: http.StatusBadRequest,
+				}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ct.Name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.ct.Method, tt.ct.Address, strings.NewReader(tt.ct.Request))
+			if tt.ct.ContentType != "" {
+				r.Header.Set("Content-Type", tt.ct.ContentType)
+			}
+			w := httptest.NewRecorder()
+			tt.handler.AddMetricsBatch(w, r)
+
+			result := w.Result()
+			defer result.Body.Close()
+
+			assert.Equal(t, tt.ct.Want.StatusCode, result.StatusCode)
+			if tt.ct.Want.Response != "" {
+				body, err := io.ReadAll(result.Body)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.ct.Want.Response, string(body))
+			}
+		})
+	}
+}
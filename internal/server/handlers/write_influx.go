@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/s-turchinskiy/metrics/internal/server/influx"
+	"github.com/s-turchinskiy/metrics/internal/server/middleware/logger"
+)
+
+// WriteInflux godoc
+// @Tags Update
+// @Summary Обновление метрик в формате InfluxDB line protocol
+// @Description Каждая строка: measurement[,tag=value...] field=value[,field=value...] [timestamp].
+// @Description Поля с суффиксами из INFLUX_COUNTER_SUFFIXES сохраняются как counter, остальные числовые и логические - как gauge, строковые пропускаются.
+// @Description Значения тегов из INFLUX_TAGS включаются в имя метрики. При ошибке разбора любой строки ничего не сохраняется
+// @ID updateWriteInflux
+// @Accept plain
+// @Produce plain
+// @Param precision query string false "Единицы времени timestamp" Enums(ns, us, ms, s)
+// @Param data body string true "Точки line protocol" example(cpu,host=server01 usage_idle=92.5,interrupts_total=1024i 1759763022000000000)
+// @Success 204 {string} string "Метрики сохранены"
+// @Failure 400 {string} string "Неверный запрос"
+// @Failure 403 {string} string "Ошибка авторизации"
+// @Failure 500 {string} string "Внутренняя ошибка"
+// @Security ApiKeyAuth
+// @Router /write [post]
+func (h *MetricsHandler) WriteInflux(w http.ResponseWriter, r *http.Request) {
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		logger.Log.Infoln(err.Error())
+		w.Header().Set("Content-Type", ContentTypeTextPlainCharset)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	points, err := influx.Parse(data, r.URL.Query().Get("precision"), time.Now())
+	if err != nil {
+		logger.Log.Infoln(err.Error())
+		w.Header().Set("Content-Type", ContentTypeTextPlainCharset)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	for _, metric := range h.influxConverter.Convert(points) {
+		if _, err = h.Service.UpdateTypedMetric(r.Context(), metric); err != nil {
+			logger.Log.Infow("error updating metric", "name", metric.Name, "error", err.Error())
+			w.Header().Set("Content-Type", ContentTypeTextPlainCharset)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
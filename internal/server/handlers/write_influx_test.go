@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/s-turchinskiy/metrics/internal/server/influx"
+	mocksrepository "github.com/s-turchinskiy/metrics/internal/server/repository/mock"
+)
+
+func TestMetricsHandler_WriteInflux(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mocksrepository.NewMockRepository(ctrl)
+	mock.EXPECT().UpdateGauge(gomock.Any(), "cpu_server01_usage", 92.5).Return(nil)
+	mock.EXPECT().UpdateCounter(gomock.Any(), "cpu_server01_interrupts_total", int64(1024)).Return(nil)
+	mock.EXPECT().GetCounter(gomock.Any(), "cpu_server01_interrupts_total").Return(int64(1024), true, nil)
+	mock.EXPECT().UpdateGauge(gomock.Any(), "mem", float64(1)).Return(fmt.Errorf("error"))
+
+	handler := NewHandler(context.Background(), mock, "", true)
+	handler.influxConverter = influx.NewConverter(influx.NewMapping("_", "host", influx.DefaultCounterSuffixes, true))
+
+	tests := []struct {
+		name       string
+		request    string
+		statusCode int
+	}{
+		{
+			name:       "Успешно",
+			request:    "cpu,host=server01 usage=92.5,interrupts_total=1024i 1759763022\n",
+			statusCode: http.StatusNoContent,
+		},
+		{
+			name:       "Ошибка разбора, ничего не сохраняется",
+			request:    "cpu usage=1\ncpu usage=",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "Ошибка хранилища",
+			request:    "mem value=1",
+			statusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/write?precision=s", strings.NewReader(tt.request))
+			w := httptest.NewRecorder()
+			handler.WriteInflux(w, r)
+
+			assert.Equal(t, tt.statusCode, w.Code)
+		})
+	}
+}
+
+func TestRouter_WriteInfluxGzip(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mocksrepository.NewMockRepository(ctrl)
+	mock.EXPECT().UpdateGauge(gomock.Any(), "cpu_usage", 92.5).Return(nil)
+
+	handler := NewHandler(context.Background(), mock, "", true)
+	handler.influxConverter = influx.NewConverter(influx.NewMapping("_", "", influx.DefaultCounterSuffixes, true))
+	router := Router(handler, nil)
+
+	var body bytes.Buffer
+	zw := gzip.NewWriter(&body)
+	_, err := zw.Write([]byte("cpu,host=server01 usage=92.5"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	r := httptest.NewRequest(http.MethodPost, "/write", &body)
+	r.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
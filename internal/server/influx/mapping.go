@@ -0,0 +1,198 @@
+package influx
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/s-turchinskiy/metrics/internal/server/models"
+)
+
+const (
+	// AllTags Все теги точки включаются в имя метрики в порядке сортировки ключей
+	AllTags = "*"
+	// ValueField Поле, имя которого не добавляется к имени метрики
+	ValueField = "value"
+
+	DefaultSeparator       = "_"
+	DefaultCounterSuffixes = "_total,_count"
+)
+
+// Mapping Правила преобразования полей точки в метрики.
+// Имя метрики: measurement[_значение тега ...]_поле, поле value в имя не входит.
+// Поля с суффиксами CounterSuffixes становятся counter, остальные числа и bool - gauge, строки пропускаются
+type Mapping struct {
+	Separator string
+	// Tags Ключи тегов, значения которых включаются в имя метрики, в указанном порядке
+	Tags []string
+	// AllTags Включать в имя значения всех тегов
+	AllTags         bool
+	CounterSuffixes []string
+	// CumulativeCounters Значения counter-полей накопленные: в хранилище пишется разница с предыдущим значением
+	CumulativeCounters bool
+}
+
+// NewMapping Создание правил из строк настроек: tags - ключи тегов через запятую или *,
+// counterSuffixes - суффиксы полей через запятую
+func NewMapping(separator, tags, counterSuffixes string, cumulativeCounters bool) Mapping {
+
+	mapping := Mapping{
+		Separator:          separator,
+		CounterSuffixes:    splitList(counterSuffixes),
+		CumulativeCounters: cumulativeCounters,
+	}
+
+	if strings.TrimSpace(tags) == AllTags {
+		mapping.AllTags = true
+	} else {
+		mapping.Tags = splitList(tags)
+	}
+
+	return mapping
+}
+
+func (m Mapping) isCounter(field string) bool {
+
+	for _, suffix := range m.CounterSuffixes {
+		if strings.HasSuffix(field, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// MetricName Имя метрики для поля точки
+func (m Mapping) MetricName(point Point, field string) string {
+
+	parts := []string{point.Measurement}
+
+	if m.AllTags {
+		tags := make([]Tag, len(point.Tags))
+		copy(tags, point.Tags)
+		sort.Slice(tags, func(i, j int) bool { return tags[i].Key < tags[j].Key })
+		for _, tag := range tags {
+			parts = append(parts, tag.Value)
+		}
+	} else {
+		for _, key := range m.Tags {
+			for _, tag := range point.Tags {
+				if tag.Key == key {
+					parts = append(parts, tag.Value)
+					break
+				}
+			}
+		}
+	}
+
+	if field != ValueField {
+		parts = append(parts, field)
+	}
+
+	return strings.Join(parts, m.Separator)
+}
+
+// Converter Преобразование точек в метрики хранилища.
+// Хранит последние накопленные значения counter-полей для вычисления приращений
+type Converter struct {
+	mapping Mapping
+	last    map[string]int64
+	mutex   sync.Mutex
+}
+
+func NewConverter(mapping Mapping) *Converter {
+	return &Converter{
+		mapping: mapping,
+		last:    make(map[string]int64),
+	}
+}
+
+// Convert Метрики для точек. При первом накопленном значении приращение равно самому значению,
+// при уменьшении значения (перезапуск источника) - тоже
+func (c *Converter) Convert(points []Point) []models.StorageMetrics {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var result []models.StorageMetrics
+
+	for _, point := range points {
+		for _, field := range point.Fields {
+
+			name := c.mapping.MetricName(point, field.Key)
+
+			if c.mapping.isCounter(field.Key) {
+				delta, ok := toInt64(field.Value)
+				if !ok {
+					continue
+				}
+
+				if c.mapping.CumulativeCounters {
+					previous, exist := c.last[name]
+					c.last[name] = delta
+					if exist && delta >= previous {
+						delta -= previous
+					}
+				}
+
+				result = append(result, models.StorageMetrics{Name: name, MType: "counter", Delta: &delta})
+				continue
+			}
+
+			value, ok := toFloat64(field.Value)
+			if !ok {
+				continue
+			}
+			result = append(result, models.StorageMetrics{Name: name, MType: "gauge", Value: &value})
+		}
+	}
+
+	return result
+}
+
+func toFloat64(value any) (float64, bool) {
+
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+func toInt64(value any) (int64, bool) {
+
+	switch v := value.(type) {
+	case int64:
+		return v, true
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(v), true
+	case float64:
+		return int64(math.Round(v)), true
+	default:
+		return 0, false
+	}
+}
+
+func splitList(s string) []string {
+
+	var result []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,90 @@
+package influx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/s-turchinskiy/metrics/internal/server/models"
+)
+
+func TestMapping_MetricName(t *testing.T) {
+
+	point := Point{
+		Measurement: "cpu",
+		Tags:        []Tag{{Key: "region", Value: "eu"}, {Key: "host", Value: "server01"}},
+	}
+
+	tests := []struct {
+		name    string
+		mapping Mapping
+		field   string
+		want    string
+	}{
+		{
+			name:    "Без тегов",
+			mapping: NewMapping("_", "", DefaultCounterSuffixes, true),
+			field:   "usage",
+			want:    "cpu_usage",
+		},
+		{
+			name:    "Теги в указанном порядке, отсутствующий тег пропускается",
+			mapping: NewMapping(".", "host,zone,region", DefaultCounterSuffixes, true),
+			field:   "usage",
+			want:    "cpu.server01.eu.usage",
+		},
+		{
+			name:    "Все теги в порядке ключей, поле value не входит в имя",
+			mapping: NewMapping("_", AllTags, DefaultCounterSuffixes, true),
+			field:   ValueField,
+			want:    "cpu_server01_eu",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.mapping.MetricName(point, tt.field))
+		})
+	}
+}
+
+func TestConverter_Convert(t *testing.T) {
+
+	converter := NewConverter(NewMapping("_", "", "_total", true))
+
+	points := func(total int64) []Point {
+		return []Point{{
+			Measurement: "net",
+			Fields: []Field{
+				{Key: "bytes_total", Value: total},
+				{Key: "up", Value: true},
+				{Key: "iface", Value: "eth0"},
+			},
+			Timestamp: time.Now(),
+		}}
+	}
+
+	deltas := []int64{}
+	for _, total := range []int64{100, 150, 30} {
+		metrics := converter.Convert(points(total))
+		require.Equal(t, 2, len(metrics))
+
+		assert.Equal(t, "net_bytes_total", metrics[0].Name)
+		assert.Equal(t, "counter", metrics[0].MType)
+		deltas = append(deltas, *metrics[0].Delta)
+
+		assert.Equal(t, models.StorageMetrics{Name: "net_up", MType: "gauge", Value: metrics[1].Value}, metrics[1])
+		assert.Equal(t, float64(1), *metrics[1].Value)
+	}
+
+	// первое значение и значение после сброса источника записываются целиком
+	assert.Equal(t, []int64{100, 50, 30}, deltas)
+
+	converter = NewConverter(NewMapping("_", "", "_total", false))
+	for range 2 {
+		metrics := converter.Convert(points(100))
+		assert.Equal(t, int64(100), *metrics[0].Delta)
+	}
+}
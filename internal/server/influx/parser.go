@@ -0,0 +1,234 @@
+// Package influx Прием метрик в формате InfluxDB line protocol
+package influx
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Point Точка line protocol: measurement,tag=value field=value timestamp
+type Point struct {
+	Measurement string
+	Tags        []Tag
+	Fields      []Field
+	Timestamp   time.Time
+}
+
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// Field Поле точки. Value - float64, int64, uint64, bool или string
+type Field struct {
+	Key   string
+	Value any
+}
+
+var (
+	errEmptyMeasurement = errors.New("measurement is empty")
+	errNoFields         = errors.New("point has no fields")
+	errIncorrectPair    = errors.New("incorrect key=value pair")
+	errUnclosedString   = errors.New("unclosed string field value")
+	errUnknownPrecision = errors.New("unknown precision")
+)
+
+// precisions Множители для параметра precision, включая сокращения InfluxDB 1.x
+var precisions = map[string]time.Duration{
+	"":   time.Nanosecond,
+	"ns": time.Nanosecond,
+	"n":  time.Nanosecond,
+	"us": time.Microsecond,
+	"u":  time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+}
+
+// Parse Разбор точек из тела запроса. Пустые строки и комментарии пропускаются,
+// точки без времени получают время now
+func Parse(data []byte, precision string, now time.Time) ([]Point, error) {
+
+	multiplier, ok := precisions[precision]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", errUnknownPrecision, precision)
+	}
+
+	var points []Point
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		point, err := ParseLine(text, multiplier, now)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		points = append(points, point)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return points, nil
+}
+
+// ParseLine Разбор одной строки, время в единицах multiplier
+func ParseLine(line string, multiplier time.Duration, now time.Time) (Point, error) {
+
+	sections := split(line, ' ', true)
+	if len(sections) < 2 || len(sections) > 3 {
+		return Point{}, fmt.Errorf("incorrect line %q, need measurement[,tags] fields [timestamp]", line)
+	}
+
+	point := Point{Timestamp: now}
+
+	keys := split(sections[0], ',', false)
+	point.Measurement = unescape(keys[0])
+	if point.Measurement == "" {
+		return Point{}, errEmptyMeasurement
+	}
+
+	for _, pair := range keys[1:] {
+		key, value, err := splitPair(pair)
+		if err != nil {
+			return Point{}, err
+		}
+		point.Tags = append(point.Tags, Tag{Key: unescape(key), Value: unescape(value)})
+	}
+
+	for _, pair := range split(sections[1], ',', true) {
+		key, rawValue, err := splitPair(pair)
+		if err != nil {
+			return Point{}, err
+		}
+
+		value, err := parseFieldValue(rawValue)
+		if err != nil {
+			return Point{}, fmt.Errorf("field %s: %w", key, err)
+		}
+		point.Fields = append(point.Fields, Field{Key: unescape(key), Value: value})
+	}
+
+	if len(point.Fields) == 0 {
+		return Point{}, errNoFields
+	}
+
+	if len(sections) == 3 {
+		timestamp, err := strconv.ParseInt(sections[2], 10, 64)
+		if err != nil {
+			return Point{}, fmt.Errorf("incorrect timestamp %q: %w", sections[2], err)
+		}
+		point.Timestamp = time.Unix(0, timestamp*int64(multiplier))
+	}
+
+	return point, nil
+}
+
+func parseFieldValue(value string) (any, error) {
+
+	if strings.HasPrefix(value, `"`) {
+		if len(value) < 2 || !strings.HasSuffix(value, `"`) {
+			return nil, errUnclosedString
+		}
+		replacer := strings.NewReplacer(`\"`, `"`, `\\`, `\`)
+		return replacer.Replace(value[1 : len(value)-1]), nil
+	}
+
+	switch value {
+	case "t", "T", "true", "True", "TRUE":
+		return true, nil
+	case "f", "F", "false", "False", "FALSE":
+		return false, nil
+	}
+
+	if number, found := strings.CutSuffix(value, "i"); found {
+		return strconv.ParseInt(number, 10, 64)
+	}
+
+	if number, found := strings.CutSuffix(value, "u"); found {
+		return strconv.ParseUint(number, 10, 64)
+	}
+
+	return strconv.ParseFloat(value, 64)
+}
+
+// split Деление строки по неэкранированному разделителю sep.
+// При quoted = true разделители внутри строк в двойных кавычках не учитываются
+func split(s string, sep byte, quoted bool) []string {
+
+	var result []string
+	var inQuotes bool
+
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case quoted && s[i] == '"':
+			inQuotes = !inQuotes
+		case s[i] == sep && !inQuotes:
+			if i > start || sep != ' ' {
+				result = append(result, s[start:i])
+			}
+			start = i + 1
+		}
+	}
+
+	if start < len(s) || sep != ' ' {
+		result = append(result, s[start:])
+	}
+
+	return result
+}
+
+// splitPair Деление key=value по первому неэкранированному =
+func splitPair(pair string) (string, string, error) {
+
+	for i := 0; i < len(pair); i++ {
+		switch pair[i] {
+		case '\\':
+			i++
+		case '=':
+			if i == 0 || i == len(pair)-1 {
+				return "", "", fmt.Errorf("%w %q", errIncorrectPair, pair)
+			}
+			return pair[:i], pair[i+1:], nil
+		}
+	}
+
+	return "", "", fmt.Errorf("%w %q", errIncorrectPair, pair)
+}
+
+func unescape(s string) string {
+
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && strings.IndexByte(`, ="\`, s[i+1]) >= 0 {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
@@ -0,0 +1,104 @@
+package influx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+
+	now := time.Unix(1759763022, 0)
+
+	tests := []struct {
+		name      string
+		data      string
+		precision string
+		want      []Point
+		wantErr   bool
+	}{
+		{
+			name: "Теги, поля всех типов и время. Успешно",
+			data: `cpu,host=server01,region=eu usage=92.5,count=10i,big=7u,ok=t,state="run, fast" 1759763022000000000`,
+			want: []Point{{
+				Measurement: "cpu",
+				Tags:        []Tag{{Key: "host", Value: "server01"}, {Key: "region", Value: "eu"}},
+				Fields: []Field{
+					{Key: "usage", Value: 92.5},
+					{Key: "count", Value: int64(10)},
+					{Key: "big", Value: uint64(7)},
+					{Key: "ok", Value: true},
+					{Key: "state", Value: "run, fast"},
+				},
+				Timestamp: time.Unix(1759763022, 0),
+			}},
+		},
+		{
+			name:      "Время в секундах, без тегов, пустые строки и комментарии. Успешно",
+			data:      "# комментарий\n\nmem used=1 1759763000\nmem free=FALSE\n",
+			precision: "s",
+			want: []Point{
+				{Measurement: "mem", Fields: []Field{{Key: "used", Value: float64(1)}}, Timestamp: time.Unix(1759763000, 0)},
+				{Measurement: "mem", Fields: []Field{{Key: "free", Value: false}}, Timestamp: now},
+			},
+		},
+		{
+			name: "Экранированные символы. Успешно",
+			data: `disk\ io,path=C:\,\ d value=1`,
+			want: []Point{{
+				Measurement: "disk io",
+				Tags:        []Tag{{Key: "path", Value: "C:, d"}},
+				Fields:      []Field{{Key: "value", Value: float64(1)}},
+				Timestamp:   now,
+			}},
+		},
+		{
+			name:    "Нет полей. Ошибка",
+			data:    "cpu,host=server01",
+			wantErr: true,
+		},
+		{
+			name:    "Некорректное значение поля. Ошибка",
+			data:    "cpu usage=abc",
+			wantErr: true,
+		},
+		{
+			name:    "Незакрытая строка. Ошибка",
+			data:    `cpu state="run`,
+			wantErr: true,
+		},
+		{
+			name:    "Некорректное время. Ошибка",
+			data:    "cpu usage=1 now",
+			wantErr: true,
+		},
+		{
+			name:      "Неизвестная точность. Ошибка",
+			data:      "cpu usage=1",
+			precision: "days",
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			points, err := Parse([]byte(tt.data), tt.precision, now)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, len(tt.want), len(points))
+			for i := range tt.want {
+				assert.Equal(t, tt.want[i].Measurement, points[i].Measurement)
+				assert.Equal(t, tt.want[i].Tags, points[i].Tags)
+				assert.Equal(t, tt.want[i].Fields, points[i].Fields)
+				assert.True(t, tt.want[i].Timestamp.Equal(points[i].Timestamp))
+			}
+		})
+	}
+}
@@ -0,0 +1,323 @@
+// Package keyring Действующие ключи HashSHA256 и приватные ключи RSA с идентификаторами.
+// Несколько действующих ключей позволяют менять ключи без одновременного перезапуска агентов и сервера
+package keyring
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/s-turchinskiy/metrics/internal/server/middleware/logger"
+	"github.com/s-turchinskiy/metrics/internal/utils/errutil"
+	"github.com/s-turchinskiy/metrics/internal/utils/rsautil"
+)
+
+// DefaultID Идентификатор ключей из одиночных настроек KEY и CRYPTO_KEY
+const DefaultID = ""
+
+type keys struct {
+	hash map[string]string
+	rsa  map[string]*rsa.PrivateKey
+}
+
+// Keyring Набор действующих ключей. Одиночные ключи задаются при создании,
+// ключи из файла HashSHA256 и каталога RSA перечитываются методом Reload.
+// Методы чтения nil Keyring возвращают пустой набор
+type Keyring struct {
+	hashKey       string
+	privateKey    *rsa.PrivateKey
+	hashKeysPath  string
+	cryptoKeysDir string
+
+	current atomic.Pointer[keys]
+
+	mutex sync.Mutex
+	stamp string
+}
+
+// New Создание Keyring с загрузкой ключей.
+// hashKeysPath - файл со строками <идентификатор>:<ключ>, пустые строки и строки с # пропускаются.
+// cryptoKeysDir - каталог с приватными ключами RSA <идентификатор>.pem
+func New(hashKey string, privateKey *rsa.PrivateKey, hashKeysPath, cryptoKeysDir string) (*Keyring, error) {
+
+	k := &Keyring{
+		hashKey:       hashKey,
+		privateKey:    privateKey,
+		hashKeysPath:  hashKeysPath,
+		cryptoKeysDir: cryptoKeysDir,
+	}
+
+	if err := k.Reload(); err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// Reload Перечитывание ключей из файла и каталога. При ошибке остаются прежние ключи
+func (k *Keyring) Reload() error {
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	stamp, err := k.sourcesStamp()
+	if err != nil {
+		return err
+	}
+
+	next := &keys{
+		hash: make(map[string]string),
+		rsa:  make(map[string]*rsa.PrivateKey),
+	}
+
+	if k.hashKey != "" {
+		next.hash[DefaultID] = k.hashKey
+	}
+	if k.privateKey != nil {
+		next.rsa[DefaultID] = k.privateKey
+	}
+
+	if k.hashKeysPath != "" {
+		if err = readHashKeys(k.hashKeysPath, next.hash); err != nil {
+			return err
+		}
+	}
+
+	if k.cryptoKeysDir != "" {
+		if err = readPrivateKeys(k.cryptoKeysDir, next.rsa); err != nil {
+			return err
+		}
+	}
+
+	k.current.Store(next)
+	k.stamp = stamp
+
+	return nil
+}
+
+// Run Перечитывание ключей по сигналу SIGHUP и при изменении файлов ключей,
+// изменения проверяются с периодом interval (0 - только по сигналу). Работает до отмены ctx
+func (k *Keyring) Run(ctx context.Context, interval time.Duration) {
+
+	if k.hashKeysPath == "" && k.cryptoKeysDir == "" {
+		return
+	}
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			k.reload("SIGHUP")
+		case <-tick:
+			changed, err := k.Changed()
+			if err != nil {
+				logger.Log.Infow("keys check error", "error", err.Error())
+				continue
+			}
+			if changed {
+				k.reload("file change")
+			}
+		}
+	}
+}
+
+// Changed Изменились ли файлы ключей после последней загрузки
+func (k *Keyring) Changed() (bool, error) {
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	stamp, err := k.sourcesStamp()
+	if err != nil {
+		return false, err
+	}
+
+	return stamp != k.stamp, nil
+}
+
+func (k *Keyring) reload(reason string) {
+
+	if err := k.Reload(); err != nil {
+		logger.Log.Errorw("keys reload error, previous keys are kept", "reason", reason, "error", err.Error())
+		return
+	}
+
+	current := k.current.Load()
+	logger.Log.Infow("keys reloaded", "reason", reason, "hashKeys", len(current.hash), "rsaKeys", len(current.rsa))
+}
+
+// HashKey Ключ HashSHA256 по идентификатору
+func (k *Keyring) HashKey(id string) (string, bool) {
+
+	if k == nil {
+		return "", false
+	}
+
+	key, ok := k.current.Load().hash[id]
+	return key, ok
+}
+
+// HashKeys Все действующие ключи HashSHA256, первым - ключ DefaultID
+func (k *Keyring) HashKeys() []string {
+
+	if k == nil {
+		return nil
+	}
+
+	hash := k.current.Load().hash
+	result := make([]string, 0, len(hash))
+	for _, id := range sortedIDs(hash) {
+		result = append(result, hash[id])
+	}
+
+	return result
+}
+
+// PrivateKey Приватный ключ RSA по идентификатору
+func (k *Keyring) PrivateKey(id string) (*rsa.PrivateKey, bool) {
+
+	if k == nil {
+		return nil, false
+	}
+
+	key, ok := k.current.Load().rsa[id]
+	return key, ok
+}
+
+// PrivateKeys Все действующие приватные ключи RSA, первым - ключ DefaultID
+func (k *Keyring) PrivateKeys() []*rsa.PrivateKey {
+
+	if k == nil {
+		return nil
+	}
+
+	private := k.current.Load().rsa
+	result := make([]*rsa.PrivateKey, 0, len(private))
+	for _, id := range sortedIDs(private) {
+		result = append(result, private[id])
+	}
+
+	return result
+}
+
+// sortedIDs DefaultID пустой и при сортировке оказывается первым
+func sortedIDs[V any](m map[string]V) []string {
+
+	ids := make([]string, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+// sourcesStamp Имена, размеры и время изменения файлов ключей для обнаружения изменений
+func (k *Keyring) sourcesStamp() (string, error) {
+
+	var stamp strings.Builder
+
+	paths := make([]string, 0)
+	if k.hashKeysPath != "" {
+		paths = append(paths, k.hashKeysPath)
+	}
+	if k.cryptoKeysDir != "" {
+		files, err := filepath.Glob(filepath.Join(k.cryptoKeysDir, "*.pem"))
+		if err != nil {
+			return "", errutil.WrapError(err)
+		}
+		paths = append(paths, files...)
+	}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", errutil.WrapError(err)
+		}
+		fmt.Fprintf(&stamp, "%s:%d:%d;", path, info.Size(), info.ModTime().UnixNano())
+	}
+
+	return stamp.String(), nil
+}
+
+func readHashKeys(path string, hash map[string]string) error {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return errutil.WrapError(err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		id, key, found := strings.Cut(text, ":")
+		id = strings.TrimSpace(id)
+		if !found || id == "" || key == "" {
+			return errutil.WrapError(fmt.Errorf("%s:%d: expected <id>:<key>", path, line))
+		}
+		if _, exist := hash[id]; exist {
+			return errutil.WrapError(fmt.Errorf("%s:%d: duplicate key id %q", path, line, id))
+		}
+
+		hash[id] = key
+	}
+
+	if err = scanner.Err(); err != nil {
+		return errutil.WrapError(err)
+	}
+
+	return nil
+}
+
+func readPrivateKeys(dir string, private map[string]*rsa.PrivateKey) error {
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return errutil.WrapError(err)
+	}
+
+	for _, path := range files {
+
+		id := strings.TrimSuffix(filepath.Base(path), ".pem")
+		if _, exist := private[id]; exist {
+			return errutil.WrapError(fmt.Errorf("duplicate key id %q", id))
+		}
+
+		key, err := rsautil.ReadPrivateKey(path)
+		if err != nil {
+			return err
+		}
+
+		private[id] = key
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package keyring
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePrivateKey(t *testing.T, path string) *rsa.PrivateKey {
+
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	require.NoError(t, os.WriteFile(path, data, 0600))
+
+	return key
+}
+
+func TestKeyring_Reload(t *testing.T) {
+
+	dir := t.TempDir()
+	hashKeysPath := filepath.Join(dir, "hash_keys")
+	cryptoKeysDir := filepath.Join(dir, "rsa")
+	require.NoError(t, os.Mkdir(cryptoKeysDir, 0700))
+
+	require.NoError(t, os.WriteFile(hashKeysPath, []byte("# ключи\n\n2024:old\n2025:new\n"), 0600))
+	private2024 := writePrivateKey(t, filepath.Join(cryptoKeysDir, "2024.pem"))
+
+	k, err := New("single", nil, hashKeysPath, cryptoKeysDir)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"single", "old", "new"}, k.HashKeys())
+	key, ok := k.HashKey("2025")
+	assert.True(t, ok)
+	assert.Equal(t, "new", key)
+
+	privateKey, ok := k.PrivateKey("2024")
+	require.True(t, ok)
+	assert.True(t, private2024.Equal(privateKey))
+
+	changed, err := k.Changed()
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	private2025 := writePrivateKey(t, filepath.Join(cryptoKeysDir, "2025.pem"))
+	require.NoError(t, os.WriteFile(hashKeysPath, []byte("2025:new\n"), 0600))
+
+	changed, err = k.Changed()
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	require.NoError(t, k.Reload())
+	_, ok = k.HashKey("2024")
+	assert.False(t, ok, "ключ удален из файла")
+	assert.Len(t, k.PrivateKeys(), 2)
+	privateKey, ok = k.PrivateKey("2025")
+	require.True(t, ok)
+	assert.True(t, private2025.Equal(privateKey))
+
+	require.NoError(t, os.WriteFile(hashKeysPath, []byte("2026\n"), 0600))
+	assert.Error(t, k.Reload())
+	assert.Equal(t, []string{"single", "new"}, k.HashKeys(), "при ошибке остаются прежние ключи")
+}
+
+func TestKeyring_New(t *testing.T) {
+
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{name: "ключи", content: "1:a\n2:b:c\n", wantErr: false},
+		{name: "без идентификатора", content: ":a\n", wantErr: true},
+		{name: "без ключа", content: "1:\n", wantErr: true},
+		{name: "повтор идентификатора", content: "1:a\n1:b\n", wantErr: true},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			path := filepath.Join(dir, string(rune('a'+i)))
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0600))
+
+			_, err := New("", nil, path, "")
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+
+	_, err := New("", nil, filepath.Join(dir, "missing"), "")
+	assert.Error(t, err, "нет файла ключей")
+
+	var k *Keyring
+	assert.Empty(t, k.HashKeys(), "nil Keyring без ключей")
+	assert.Empty(t, k.PrivateKeys())
+}
+
+func TestKeyring_RunFileChange(t *testing.T) {
+
+	hashKeysPath := filepath.Join(t.TempDir(), "hash_keys")
+	require.NoError(t, os.WriteFile(hashKeysPath, []byte("1:a\n"), 0600))
+
+	k, err := New("", nil, hashKeysPath, "")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		k.Run(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+
+	require.NoError(t, os.WriteFile(hashKeysPath, []byte("1:a\n2:bb\n"), 0600))
+
+	assert.Eventually(t, func() bool {
+		_, ok := k.HashKey("2")
+		return ok
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+}
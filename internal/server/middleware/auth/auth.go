@@ -0,0 +1,60 @@
+// Package auth Проверка ключа API и роли запроса
+package auth
+
+import (
+	"net/http"
+
+	"github.com/s-turchinskiy/metrics/internal/server/auth"
+	"github.com/s-turchinskiy/metrics/internal/server/middleware/logger"
+)
+
+// AuthorizationHeader Заголовок с ключом API, значение - ключ или Bearer ключ
+const AuthorizationHeader = auth.AuthorizationHeader
+
+// AuthMiddleware Ключ API берется из заголовка Authorization, иначе из X-API-Key,
+// чтобы один ключ мог определять и арендатора, и роль. Без store проверка отключена
+func AuthMiddleware(store auth.KeyStore) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+
+			required, protected := auth.RequiredRole(r.Method, r.URL.Path)
+			if store == nil || !protected {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := apiKey(r)
+			if key == "" {
+				http.Error(w, "API key required", http.StatusUnauthorized)
+				return
+			}
+
+			roles, exist, err := store.LookupAPIKey(r.Context(), key)
+			if err != nil {
+				logger.Log.Infow("error lookup api key", "error", err.Error())
+				http.Error(w, "Error checking API key", http.StatusInternalServerError)
+				return
+			}
+
+			if !exist {
+				logger.Log.Infow("unknown api key", "uri", r.RequestURI)
+				http.Error(w, "Unknown API key", http.StatusUnauthorized)
+				return
+			}
+
+			if !roles.Allows(required) {
+				logger.Log.Infow("access denied", "uri", r.RequestURI, "required", required)
+				http.Error(w, "Access denied", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+func apiKey(r *http.Request) string {
+	return auth.APIKey(r.Header.Get(auth.AuthorizationHeader), r.Header.Get(auth.APIKeyHeader))
+}
@@ -5,7 +5,10 @@ import (
 	"bytes"
 	"crypto/hmac"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"github.com/s-turchinskiy/metrics/internal/server/keyring"
+	"github.com/s-turchinskiy/metrics/internal/server/replay"
 	"github.com/s-turchinskiy/metrics/internal/utils/errutil"
 	"github.com/s-turchinskiy/metrics/internal/utils/hashutil"
 	"io"
@@ -14,11 +17,35 @@ import (
 	"github.com/s-turchinskiy/metrics/internal/server/middleware/logger"
 )
 
-func HashReadMiddleware(hashKey string) func(next http.Handler) http.Handler {
+// HashRequiredMiddleware Запросы без заголовка HashSHA256 отклоняются, если настроен хотя бы один ключ.
+// Подключается к запросам изменения метрик, саму подпись проверяет HashReadMiddleware
+func HashRequiredMiddleware(keys *keyring.Keyring) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		hashFn := func(w http.ResponseWriter, r *http.Request) {
 
-			if hashKey == "" {
+			if len(keys.HashKeys()) != 0 && r.Header.Get("HashSHA256") == "" {
+				logger.Log.Infow("rejected unsigned request", "uri", r.RequestURI)
+				http.Error(w, "Missing request hash", http.StatusBadRequest)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(hashFn)
+	}
+}
+
+// HashReadMiddleware Хэш проверяется ключом из заголовка hashutil.KeyIDHeader,
+// без заголовка - всеми действующими ключами, чтобы агенты без идентификатора ключа работали во время ротации.
+// Метка времени и nonce из заголовков входят в подписываемые данные вместе с методом и URI запроса,
+// при guard != nil они обязательны и проверяются на расхождение часов и повтор
+func HashReadMiddleware(keys *keyring.Keyring, guard *replay.Guard) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		hashFn := func(w http.ResponseWriter, r *http.Request) {
+
+			hashKeys := keys.HashKeys()
+			if len(hashKeys) == 0 {
 				next.ServeHTTP(w, r)
 				return
 			}
@@ -28,6 +55,15 @@ func HashReadMiddleware(hashKey string) func(next http.Handler) http.Handler {
 				return
 			}
 
+			if keyID := r.Header.Get(hashutil.KeyIDHeader); keyID != "" {
+				hashKey, ok := keys.HashKey(keyID)
+				if !ok {
+					http.Error(w, "Unknown hash key id", http.StatusBadRequest)
+					return
+				}
+				hashKeys = []string{hashKey}
+			}
+
 			requestHash, err := hex.DecodeString(requestHexadecimalHash)
 			if err != nil {
 				http.Error(w, "Error decode request hash", http.StatusBadRequest)
@@ -50,13 +86,35 @@ func HashReadMiddleware(hashKey string) func(next http.Handler) http.Handler {
 			r.Body.Close()
 			r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 
-			expectedHash := hashutil.СomputeSha256Hash(hashKey, bodyBytes)
+			timestamp := r.Header.Get(hashutil.TimestampHeader)
+			nonce := r.Header.Get(hashutil.NonceHeader)
+			signedData := hashutil.SignedData(r.Method, r.URL.RequestURI(), timestamp, nonce, bodyBytes)
 
-			if !hmac.Equal(requestHash, expectedHash) {
+			valid := false
+			for _, hashKey := range hashKeys {
+				if hmac.Equal(requestHash, hashutil.СomputeSha256Hash(hashKey, signedData)) {
+					valid = true
+					break
+				}
+			}
+			if !valid {
 				http.Error(w, "Invalid request hash", http.StatusBadRequest)
 				return
 			}
 
+			if guard != nil {
+				if err = guard.Check(timestamp, nonce); err != nil {
+					logger.Log.Infow("rejected signed request", "error", err.Error(), "uri", r.RequestURI)
+					code := http.StatusBadRequest
+					if errors.Is(err, replay.ErrFull) {
+						// агент повторит запрос, когда окно освободится
+						code = http.StatusServiceUnavailable
+					}
+					http.Error(w, err.Error(), code)
+					return
+				}
+			}
+
 			next.ServeHTTP(w, r)
 		}
 
@@ -2,6 +2,7 @@ package hash
 
 import (
 	"bytes"
+	"github.com/s-turchinskiy/metrics/internal/server/keyring"
 	"github.com/s-turchinskiy/metrics/internal/utils/hashutil"
 	"net/http"
 )
@@ -11,6 +12,7 @@ type hashingResponseWriter struct {
 	body          *bytes.Buffer
 	statusCodeSet bool
 	hashKey       string
+	keyID         string
 }
 
 func (hw *hashingResponseWriter) WriteHeader(statusCode int) {
@@ -26,18 +28,33 @@ func (hw *hashingResponseWriter) Write(b []byte) (int, error) {
 	if !hw.statusCodeSet && hw.body.Len() > 0 {
 		hash := hashutil.СomputeHexadecimalSha256Hash(hw.hashKey, hw.body.Bytes())
 		hw.Header().Set("HashSHA256", hash)
+		if hw.keyID != "" {
+			hw.Header().Set(hashutil.KeyIDHeader, hw.keyID)
+		}
 	}
 	return hw.ResponseWriter.Write(b)
 }
 
-func HashWriteMiddleware(hashKey string) func(next http.Handler) http.Handler {
+// HashWriteMiddleware Ответ подписывается ключом, идентификатор которого указан в запросе,
+// иначе ключом keyring.DefaultID или первым из действующих
+func HashWriteMiddleware(keys *keyring.Keyring) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		hashFn := func(w http.ResponseWriter, r *http.Request) {
 
+			keyID := r.Header.Get(hashutil.KeyIDHeader)
+			hashKey, ok := keys.HashKey(keyID)
+			if !ok {
+				keyID = ""
+				if hashKeys := keys.HashKeys(); len(hashKeys) != 0 {
+					hashKey = hashKeys[0]
+				}
+			}
+
 			hashW := &hashingResponseWriter{
 				ResponseWriter: w,
 				body:           &bytes.Buffer{},
 				hashKey:        hashKey,
+				keyID:          keyID,
 			}
 
 			next.ServeHTTP(hashW, r)
@@ -0,0 +1,45 @@
+// Package mtls Определение агента по сертификату клиента при взаимной аутентификации TLS
+package mtls
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/s-turchinskiy/metrics/internal/server/middleware/logger"
+)
+
+type contextKey struct{}
+
+// AgentFromContext Агент - CommonName субъекта сертификата клиента, пустая строка без mTLS
+func AgentFromContext(ctx context.Context) string {
+	agent, _ := ctx.Value(contextKey{}).(string)
+	return agent
+}
+
+// ClientCertMiddleware Агент определяется по субъекту проверенного сертификата клиента.
+// При непустом allowed запросы агентов не из списка отклоняются с кодом 403
+func ClientCertMiddleware(allowed []string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+
+			if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			subject := r.TLS.VerifiedChains[0][0].Subject
+			agent := subject.CommonName
+			if len(allowed) != 0 && !slices.Contains(allowed, agent) {
+				logger.Log.Infow("unknown client certificate", "subject", subject.String(), "uri", r.RequestURI)
+				http.Error(w, "Unknown client certificate", http.StatusForbidden)
+				return
+			}
+
+			logger.Log.Debugw("client certificate", "agent", agent, "uri", r.RequestURI)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, agent)))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"crypto/rsa"
 	"fmt"
+	"github.com/s-turchinskiy/metrics/internal/server/keyring"
 	"github.com/s-turchinskiy/metrics/internal/server/middleware/logger"
 	"github.com/s-turchinskiy/metrics/internal/utils/errutil"
 	"github.com/s-turchinskiy/metrics/internal/utils/rsautil"
@@ -12,16 +13,38 @@ import (
 	"net/http"
 )
 
-func RSADecrypt(privateKey *rsa.PrivateKey) func(next http.Handler) http.Handler {
+// RSADecrypt При заголовке rsautil.EncryptedKeyHeader тело расшифровывается rsautil.DecryptHybrid,
+// без него - RSA-OAEP целиком, как от агентов до гибридного шифрования.
+// Расшифровка ключом из заголовка rsautil.KeyIDHeader, без заголовка - по очереди всеми действующими ключами.
+// Тело без заголовков шифрования, которое не расшифровывается, передается дальше открытым,
+// при required = true такие запросы отклоняются
+func RSADecrypt(keys *keyring.Keyring, required bool) func(next http.Handler) http.Handler {
 
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
 
-			if privateKey == nil {
+			wrappedKey := r.Header.Get(rsautil.EncryptedKeyHeader)
+			keyID := r.Header.Get(rsautil.KeyIDHeader)
+
+			privateKeys := keys.PrivateKeys()
+			if len(privateKeys) == 0 {
+				if wrappedKey != "" || keyID != "" {
+					http.Error(w, "encryption is not configured", http.StatusBadRequest)
+					return
+				}
 				next.ServeHTTP(w, r)
 				return
 			}
 
+			if keyID != "" {
+				privateKey, ok := keys.PrivateKey(keyID)
+				if !ok {
+					http.Error(w, "unknown crypto key id", http.StatusBadRequest)
+					return
+				}
+				privateKeys = []*rsa.PrivateKey{privateKey}
+			}
+
 			if r.Body == nil {
 
 				next.ServeHTTP(w, r)
@@ -37,10 +60,22 @@ func RSADecrypt(privateKey *rsa.PrivateKey) func(next http.Handler) http.Handler
 
 			r.Body.Close()
 
-			bodyBytes, err = rsautil.Decrypt(privateKey, bodyBytes)
+			plain, err := decrypt(privateKeys, wrappedKey, bodyBytes)
+			if err != nil && !required && wrappedKey == "" && keyID == "" {
+				// агент или клиент без ключа RSA
+				r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+				next.ServeHTTP(w, r)
+				return
+			}
 			if err != nil {
+				logger.Log.Infow("cannot decrypt body", "error", err.Error(), "uri", r.RequestURI)
 				http.Error(w, "cannot decrypt body", http.StatusBadRequest)
+				return
 			}
+			bodyBytes = plain
+			r.Header.Del(rsautil.EncryptedKeyHeader)
+			r.Header.Del(rsautil.KeyIDHeader)
+			r.ContentLength = int64(len(bodyBytes))
 			r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 
 			next.ServeHTTP(w, r)
@@ -49,3 +84,22 @@ func RSADecrypt(privateKey *rsa.PrivateKey) func(next http.Handler) http.Handler
 		return http.HandlerFunc(fn)
 	}
 }
+
+func decrypt(privateKeys []*rsa.PrivateKey, wrappedKey string, message []byte) ([]byte, error) {
+
+	var err error
+	for _, privateKey := range privateKeys {
+
+		var plain []byte
+		if wrappedKey != "" {
+			plain, err = rsautil.DecryptHybrid(privateKey, wrappedKey, message)
+		} else {
+			plain, err = rsautil.Decrypt(privateKey, message)
+		}
+		if err == nil {
+			return plain, nil
+		}
+	}
+
+	return nil, err
+}
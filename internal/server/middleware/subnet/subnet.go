@@ -0,0 +1,36 @@
+// Package subnet Проверка IP-адреса агента из заголовка X-Real-IP по доверенной подсети
+package subnet
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/s-turchinskiy/metrics/internal/server/middleware/logger"
+)
+
+// RealIPHeader Заголовок с IP-адресом агента
+const RealIPHeader = "X-Real-IP"
+
+// TrustedSubnetMiddleware Запросы с адресом вне trusted отклоняются с кодом 403. Без trusted проверка отключена
+func TrustedSubnetMiddleware(trusted *net.IPNet) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+
+			if trusted == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ip := net.ParseIP(r.Header.Get(RealIPHeader))
+			if ip == nil || !trusted.Contains(ip) {
+				logger.Log.Infow("request from untrusted ip", "ip", r.Header.Get(RealIPHeader), "uri", r.RequestURI)
+				http.Error(w, "IP address is not in trusted subnet", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,50 @@
+// Package tenant Определение арендатора запроса по ключу API или заголовку
+package tenant
+
+import (
+	"net/http"
+
+	"github.com/s-turchinskiy/metrics/internal/server/auth"
+	"github.com/s-turchinskiy/metrics/internal/server/middleware/logger"
+	"github.com/s-turchinskiy/metrics/internal/server/tenant"
+)
+
+// APIKeyHeader Заголовок с ключом API
+const APIKeyHeader = auth.APIKeyHeader
+
+// TenantMiddleware Если заданы keys, арендатор определяется только по ключу API из заголовка Authorization
+// или X-API-Key, как в проверке роли: заголовку header, который клиент задает сам, в этом случае не доверяют.
+// Без keys арендатор берется из заголовка header, если он не пустой. Без ключа и заголовка - арендатор по умолчанию
+func TenantMiddleware(keys map[string]string, header string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+
+			id := tenant.Default
+
+			if len(keys) != 0 {
+
+				if apiKey := auth.APIKey(r.Header.Get(auth.AuthorizationHeader), r.Header.Get(auth.APIKeyHeader)); apiKey != "" {
+					var exist bool
+					id, exist = keys[apiKey]
+					if !exist {
+						logger.Log.Infow("unknown api key", "uri", r.RequestURI)
+						http.Error(w, "Unknown API key", http.StatusUnauthorized)
+						return
+					}
+				}
+
+			} else if header != "" && r.Header.Get(header) != "" {
+
+				id = r.Header.Get(header)
+				if err := tenant.Validate(id); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r.WithContext(tenant.WithTenant(r.Context(), id)))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,209 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultHistogramBuckets Границы корзин гистограммы по умолчанию, как в клиенте Prometheus
+const DefaultHistogramBuckets = "0.005,0.01,0.025,0.05,0.1,0.25,0.5,1,2.5,5,10"
+
+var (
+	errBucketsMismatch   = errors.New("histogram bucket boundaries mismatch")
+	errQuantileNotFound  = errors.New("quantile not found")
+	errIncorrectQuantile = errors.New("quantile must be in [0, 1]")
+)
+
+// Histogram Распределение значений по корзинам. Bounds - верхние границы корзин по возрастанию,
+// Counts - количество значений в каждой корзине (не накопленное), последняя корзина - до +Inf,
+// поэтому len(Counts) = len(Bounds) + 1. При обновлении гистограммы складываются
+type Histogram struct {
+	Bounds []float64 `json:"bounds" example:"0.1,0.5,1"`
+	Counts []uint64  `json:"counts" example:"3,2,1,0"`
+	Sum    float64   `json:"sum" example:"1.7"`
+	Count  uint64    `json:"count" example:"6"`
+}
+
+// Quantile Значение квантили
+type Quantile struct {
+	Quantile float64 `json:"quantile" example:"0.99"`
+	Value    float64 `json:"value" example:"0.9"`
+}
+
+// Summary Квантили, посчитанные отправителем. Не складываются: при обновлении заменяются целиком
+type Summary struct {
+	Count     uint64     `json:"count" example:"6"`
+	Sum       float64    `json:"sum" example:"1.7"`
+	Quantiles []Quantile `json:"quantiles"`
+}
+
+// NewHistogram Пустая гистограмма с границами bounds
+func NewHistogram(bounds []float64) Histogram {
+	return Histogram{
+		Bounds: append([]float64(nil), bounds...),
+		Counts: make([]uint64, len(bounds)+1),
+	}
+}
+
+// ParseHistogramBuckets Разбор границ корзин, перечисленных через запятую
+func ParseHistogramBuckets(s string) ([]float64, error) {
+
+	var bounds []float64
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		bound, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return nil, fmt.Errorf("incorrect histogram bucket %q: %w", part, err)
+		}
+		bounds = append(bounds, bound)
+	}
+
+	h := NewHistogram(bounds)
+	return bounds, h.Validate()
+}
+
+// Validate Границы строго возрастают и конечны, корзин на одну больше границ,
+// Count равен сумме корзин
+func (h Histogram) Validate() error {
+
+	for i, bound := range h.Bounds {
+		if math.IsNaN(bound) || math.IsInf(bound, 0) {
+			return fmt.Errorf("histogram bucket boundary %v is not finite", bound)
+		}
+		if i > 0 && bound <= h.Bounds[i-1] {
+			return fmt.Errorf("histogram bucket boundaries must increase: %v after %v", bound, h.Bounds[i-1])
+		}
+	}
+
+	if len(h.Counts) != len(h.Bounds)+1 {
+		return fmt.Errorf("histogram has %d counts for %d boundaries, need %d", len(h.Counts), len(h.Bounds), len(h.Bounds)+1)
+	}
+
+	var count uint64
+	for _, c := range h.Counts {
+		count += c
+	}
+	if count != h.Count {
+		return fmt.Errorf("histogram count %d is not equal to sum of buckets %d", h.Count, count)
+	}
+
+	return nil
+}
+
+// Observe Добавление значения
+func (h *Histogram) Observe(value float64) {
+
+	i := sort.SearchFloat64s(h.Bounds, value)
+	h.Counts[i]++
+	h.Sum += value
+	h.Count++
+}
+
+// Merge Сложение с гистограммой other с теми же границами
+func (h *Histogram) Merge(other Histogram) error {
+
+	if len(h.Bounds) != len(other.Bounds) {
+		return errBucketsMismatch
+	}
+	for i := range h.Bounds {
+		if h.Bounds[i] != other.Bounds[i] {
+			return errBucketsMismatch
+		}
+	}
+
+	for i := range h.Counts {
+		h.Counts[i] += other.Counts[i]
+	}
+	h.Sum += other.Sum
+	h.Count += other.Count
+
+	return nil
+}
+
+// Clone Копия, не разделяющая срезы с исходной гистограммой
+func (h Histogram) Clone() Histogram {
+
+	h.Bounds = append([]float64(nil), h.Bounds...)
+	h.Counts = append([]uint64(nil), h.Counts...)
+	return h
+}
+
+// Quantile Оценка квантили q линейной интерполяцией внутри корзины, как histogram_quantile в Prometheus.
+// Нижняя граница первой корзины - 0, если первая граница положительна. Если квантиль попадает
+// в последнюю корзину, возвращается последняя конечная граница. Для пустой гистограммы - NaN
+func (h Histogram) Quantile(q float64) (float64, error) {
+
+	if q < 0 || q > 1 || math.IsNaN(q) {
+		return 0, errIncorrectQuantile
+	}
+
+	if h.Count == 0 {
+		return math.NaN(), nil
+	}
+
+	rank := q * float64(h.Count)
+
+	var cumulative uint64
+	for i, count := range h.Counts {
+
+		previous := cumulative
+		cumulative += count
+		if float64(cumulative) < rank || count == 0 {
+			continue
+		}
+
+		if i == len(h.Bounds) {
+			if len(h.Bounds) == 0 {
+				return math.NaN(), nil
+			}
+			return h.Bounds[len(h.Bounds)-1], nil
+		}
+
+		upper := h.Bounds[i]
+		lower := 0.0
+		if i > 0 {
+			lower = h.Bounds[i-1]
+		} else if upper <= 0 {
+			return upper, nil
+		}
+
+		return lower + (upper-lower)*(rank-float64(previous))/float64(count), nil
+	}
+
+	return h.Bounds[len(h.Bounds)-1], nil
+}
+
+// Validate Квантили в [0, 1] без повторов
+func (s Summary) Validate() error {
+
+	seen := make(map[float64]struct{}, len(s.Quantiles))
+	for _, q := range s.Quantiles {
+		if q.Quantile < 0 || q.Quantile > 1 || math.IsNaN(q.Quantile) {
+			return errIncorrectQuantile
+		}
+		if _, exist := seen[q.Quantile]; exist {
+			return fmt.Errorf("duplicate quantile %v", q.Quantile)
+		}
+		seen[q.Quantile] = struct{}{}
+	}
+
+	return nil
+}
+
+// Quantile Значение квантили q из переданных отправителем, интерполяция не выполняется
+func (s Summary) Quantile(q float64) (float64, error) {
+
+	for _, quantile := range s.Quantiles {
+		if quantile.Quantile == q {
+			return quantile.Value, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: %v", errQuantileNotFound, q)
+}
@@ -0,0 +1,132 @@
+package models
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHistogramBuckets(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		buckets string
+		want    []float64
+		wantErr bool
+	}{
+		{name: "Границы по умолчанию", buckets: DefaultHistogramBuckets, want: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}},
+		{name: "Пробелы игнорируются", buckets: " 1, 2 ,5", want: []float64{1, 2, 5}},
+		{name: "Границы не по возрастанию", buckets: "1,5,2", wantErr: true},
+		{name: "Повтор границы", buckets: "1,1", wantErr: true},
+		{name: "Не число", buckets: "1,a", wantErr: true},
+		{name: "Бесконечность", buckets: "1,+Inf", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			got, err := ParseHistogramBuckets(tt.buckets)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestHistogram_Validate(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		histogram Histogram
+		wantErr   bool
+	}{
+		{name: "Корректная гистограмма", histogram: Histogram{Bounds: []float64{1, 2}, Counts: []uint64{1, 0, 2}, Sum: 7, Count: 3}},
+		{name: "Неверное количество корзин", histogram: Histogram{Bounds: []float64{1, 2}, Counts: []uint64{1, 2}, Count: 3}, wantErr: true},
+		{name: "Count не равен сумме корзин", histogram: Histogram{Bounds: []float64{1}, Counts: []uint64{1, 2}, Count: 4}, wantErr: true},
+		{name: "Границы не по возрастанию", histogram: Histogram{Bounds: []float64{2, 1}, Counts: []uint64{0, 0, 0}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			err := tt.histogram.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestHistogram_Merge(t *testing.T) {
+
+	h := NewHistogram([]float64{1, 2})
+	h.Observe(0.5)
+	h.Observe(3)
+
+	other := NewHistogram([]float64{1, 2})
+	other.Observe(1.5)
+
+	require.NoError(t, h.Merge(other))
+	assert.Equal(t, []uint64{1, 1, 1}, h.Counts)
+	assert.Equal(t, uint64(3), h.Count)
+	assert.Equal(t, 5.0, h.Sum)
+
+	assert.Error(t, h.Merge(NewHistogram([]float64{1, 5})), "разные границы корзин")
+	assert.Equal(t, uint64(3), h.Count, "при ошибке гистограмма не меняется")
+}
+
+func TestHistogram_Quantile(t *testing.T) {
+
+	h := Histogram{Bounds: []float64{1, 2, 4}, Counts: []uint64{2, 2, 0, 1}, Count: 5, Sum: 9}
+
+	tests := []struct {
+		name     string
+		quantile float64
+		want     float64
+		wantErr  bool
+	}{
+		{name: "Медиана", quantile: 0.5, want: 1.25},
+		{name: "Квантиль 0.2 в первой корзине", quantile: 0.2, want: 0.5},
+		{name: "Корзина +Inf - последняя граница", quantile: 0.99, want: 4},
+		{name: "Квантиль больше 1", quantile: 1.5, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			got, err := h.Quantile(tt.quantile)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.InDelta(t, tt.want, got, 1e-9)
+		})
+	}
+
+	got, err := NewHistogram([]float64{1}).Quantile(0.5)
+	require.NoError(t, err)
+	assert.True(t, math.IsNaN(got), "пустая гистограмма")
+}
+
+func TestSummary_Quantile(t *testing.T) {
+
+	summary := Summary{Count: 10, Sum: 12, Quantiles: []Quantile{{Quantile: 0.5, Value: 1}, {Quantile: 0.99, Value: 3}}}
+	require.NoError(t, summary.Validate())
+
+	got, err := summary.Quantile(0.99)
+	require.NoError(t, err)
+	assert.Equal(t, 3.0, got)
+
+	_, err = summary.Quantile(0.9)
+	assert.Error(t, err)
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Labels Измерения метрики. Серия метрики определяется именем и набором меток
+type Labels map[string]string
+
+var errIncorrectSeriesKey = errors.New("incorrect series key")
+
+// Validate Имена меток [a-zA-Z_][a-zA-Z0-9_]*, как в Prometheus
+func (l Labels) Validate() error {
+
+	for name := range l {
+		if !isLabelName(name) {
+			return fmt.Errorf("incorrect label name %q", name)
+		}
+	}
+	return nil
+}
+
+// String Метки в каноническом виде {a="1",b="2"}: ключи по возрастанию, значения в кавычках Go.
+// Для пустого набора - пустая строка
+func (l Labels) String() string {
+
+	if len(l) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(l))
+	for name := range l {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(l[name]))
+	}
+	b.WriteByte('}')
+
+	return b.String()
+}
+
+// Matches Все метки selector присутствуют с теми же значениями. Пустой selector подходит любой серии
+func (l Labels) Matches(selector Labels) bool {
+
+	for name, value := range selector {
+		if actual, exist := l[name]; !exist || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+// SeriesKey Ключ серии в хранилище: имя и метки в каноническом виде, например CPUutilization{cpu="3"}.
+// Без меток ключ совпадает с именем метрики
+func SeriesKey(name string, labels Labels) string {
+	return name + labels.String()
+}
+
+// ParseSeriesKey Разбор ключа серии на имя и метки
+func ParseSeriesKey(key string) (string, Labels, error) {
+
+	start := strings.IndexByte(key, '{')
+	if start < 0 || !strings.HasSuffix(key, "}") {
+		return key, nil, nil
+	}
+
+	name := key[:start]
+	body := key[start+1 : len(key)-1]
+	labels := make(Labels)
+
+	for body != "" {
+
+		eq := strings.IndexByte(body, '=')
+		if eq <= 0 {
+			return "", nil, fmt.Errorf("%w %q", errIncorrectSeriesKey, key)
+		}
+		labelName := body[:eq]
+
+		quoted, err := strconv.QuotedPrefix(body[eq+1:])
+		if err != nil {
+			return "", nil, fmt.Errorf("%w %q: %w", errIncorrectSeriesKey, key, err)
+		}
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			return "", nil, fmt.Errorf("%w %q: %w", errIncorrectSeriesKey, key, err)
+		}
+		labels[labelName] = value
+
+		body = strings.TrimPrefix(body[eq+1+len(quoted):], ",")
+	}
+
+	return name, labels, nil
+}
+
+func isLabelName(name string) bool {
+
+	if name == "" {
+		return false
+	}
+
+	for i, c := range name {
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeriesKey(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		metricName string
+		labels     Labels
+		want       string
+	}{
+		{name: "Без меток", metricName: "Alloc", labels: nil, want: "Alloc"},
+		{name: "Метки по возрастанию ключей", metricName: "CPUutilization", labels: Labels{"host": "server01", "cpu": "3"}, want: `CPUutilization{cpu="3",host="server01"}`},
+		{name: "Экранирование значений", metricName: "Disk", labels: Labels{"path": `C:\ "d",`}, want: `Disk{path="C:\\ \"d\","}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			key := SeriesKey(tt.metricName, tt.labels)
+			assert.Equal(t, tt.want, key)
+
+			name, labels, err := ParseSeriesKey(key)
+			require.NoError(t, err)
+			assert.Equal(t, tt.metricName, name)
+			if len(tt.labels) == 0 {
+				assert.Empty(t, labels)
+			} else {
+				assert.Equal(t, tt.labels, labels)
+			}
+		})
+	}
+}
+
+func TestParseSeriesKey_Error(t *testing.T) {
+
+	for _, key := range []string{`CPU{cpu}`, `CPU{cpu=3}`, `CPU{cpu="3}`} {
+		t.Run(key, func(t *testing.T) {
+			_, _, err := ParseSeriesKey(key)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestLabels_Matches(t *testing.T) {
+
+	labels := Labels{"cpu": "3", "host": "server01"}
+
+	assert.True(t, labels.Matches(nil))
+	assert.True(t, labels.Matches(Labels{"host": "server01"}))
+	assert.False(t, labels.Matches(Labels{"host": "server02"}))
+	assert.False(t, labels.Matches(Labels{"region": "eu"}))
+	assert.False(t, Labels(nil).Matches(Labels{"cpu": "3"}))
+}
+
+func TestLabels_Validate(t *testing.T) {
+
+	assert.NoError(t, Labels{"cpu": "3", "_host1": ""}.Validate())
+	assert.Error(t, Labels{"1cpu": "3"}.Validate())
+	assert.Error(t, Labels{"cpu-id": "3"}.Validate())
+	assert.Error(t, Labels{"": "3"}.Validate())
+}
@@ -1,32 +1,71 @@
 // Package models Модели
 package models
 
+import (
+	"database/sql"
+	"time"
+)
+
 //go:generate easyjson models.go
 
 // Metrics содержит запрос и ответ на обновление данных метрики.
 //
 //easyjson:json
 type Metrics struct {
-	ID    string   `json:"id" enums:"counter,gauge" example:"gauge"` // имя метрики
-	MType string   `json:"type" example:"Alloc"`                     // параметр, принимающий значение gauge или counter
-	Delta *int64   `json:"delta,omitempty" example:"100"`            // значение метрики в случае передачи counter
-	Value *float64 `json:"value,omitempty" example:"6649272"`        // значение метрики в случае передачи gauge
+	ID     string   `json:"id" enums:"counter,gauge" example:"gauge"` // имя метрики
+	MType  string   `json:"type" example:"Alloc"`                     // параметр, принимающий значение gauge, counter, histogram или summary
+	Delta  *int64   `json:"delta,omitempty" example:"100"`            // значение метрики в случае передачи counter
+	Value  *float64 `json:"value,omitempty" example:"6649272"`        // значение метрики в случае передачи gauge
+	Labels Labels   `json:"labels,omitempty"`                         // метки серии, вместе с именем определяют метрику
+
+	Histogram *Histogram `json:"histogram,omitempty"` // значение метрики в случае передачи histogram
+	Summary   *Summary   `json:"summary,omitempty"`   // значение метрики в случае передачи summary
+	Quantiles []Quantile `json:"quantiles,omitempty"` // в запросе /value - запрошенные квантили, в ответе - их значения
+
+	Updated *time.Time `json:"updated,omitempty"` // в ответе /value - время последнего обновления
+	Stale   *bool      `json:"stale,omitempty"`   // в ответе /value - gauge не обновлялся дольше GAUGE_TTL
 }
 
 type StorageMetrics struct {
-	Name  string
-	MType string
-	Delta *int64
-	Value *float64
+	Name   string
+	MType  string
+	Delta  *int64
+	Value  *float64
+	Labels Labels
+
+	Histogram *Histogram
+	Summary   *Summary
+	Quantiles []Quantile
+
+	Updated *time.Time
+	Stale   *bool
 }
 
 type UntypedMetric struct {
 	MetricsType  string
 	MetricsName  string
 	MetricsValue string
+	Labels       Labels
+	Quantile     *float64 // квантиль при получении histogram и summary
 }
 
 type DatabaseTableGauges struct {
 	MetricsName string  `db:"metrics_name"`
+	Labels      string  `db:"labels"`
 	Value       float64 `db:"value"`
 }
+
+// HistorySample Значение метрики на момент времени.
+// Для gauge Value - значение метрики, для counter Delta - приращение,
+// Value - накопленное значение после приращения
+type HistorySample struct {
+	Timestamp time.Time `json:"timestamp" example:"2025-10-06T15:03:42Z"`
+	Value     float64   `json:"value" example:"6649272"`
+	Delta     *int64    `json:"delta,omitempty" example:"5"`
+}
+
+type DatabaseTableHistory struct {
+	Value   float64       `db:"value"`
+	Delta   sql.NullInt64 `db:"delta"`
+	Created time.Time     `db:"created"`
+}
@@ -8,6 +8,7 @@ import (
 	easyjson "github.com/mailru/easyjson"
 	jlexer "github.com/mailru/easyjson/jlexer"
 	jwriter "github.com/mailru/easyjson/jwriter"
+	time "time"
 )
 
 // suppress unused package warning
@@ -31,16 +32,19 @@ func easyjsonD2b7633eDecodeGithubComSTurchinskiyMetricsInternalServerModels(in *
 	for !in.IsDelim('}') {
 		key := in.UnsafeFieldName(false)
 		in.WantColon()
-		if in.IsNull() {
-			in.Skip()
-			in.WantComma()
-			continue
-		}
 		switch key {
 		case "id":
-			out.ID = string(in.String())
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.ID = string(in.String())
+			}
 		case "type":
-			out.MType = string(in.String())
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.MType = string(in.String())
+			}
 		case "delta":
 			if in.IsNull() {
 				in.Skip()
@@ -49,7 +53,11 @@ func easyjsonD2b7633eDecodeGithubComSTurchinskiyMetricsInternalServerModels(in *
 				if out.Delta == nil {
 					out.Delta = new(int64)
 				}
-				*out.Delta = int64(in.Int64())
+				if in.IsNull() {
+					in.Skip()
+				} else {
+					*out.Delta = int64(in.Int64())
+				}
 			}
 		case "value":
 			if in.IsNull() {
@@ -59,7 +67,108 @@ func easyjsonD2b7633eDecodeGithubComSTurchinskiyMetricsInternalServerModels(in *
 				if out.Value == nil {
 					out.Value = new(float64)
 				}
-				*out.Value = float64(in.Float64())
+				if in.IsNull() {
+					in.Skip()
+				} else {
+					*out.Value = float64(in.Float64())
+				}
+			}
+		case "labels":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				in.Delim('{')
+				if !in.IsDelim('}') {
+					out.Labels = make(Labels)
+				} else {
+					out.Labels = nil
+				}
+				for !in.IsDelim('}') {
+					key := string(in.String())
+					in.WantColon()
+					var v1 string
+					if in.IsNull() {
+						in.Skip()
+					} else {
+						v1 = string(in.String())
+					}
+					(out.Labels)[key] = v1
+					in.WantComma()
+				}
+				in.Delim('}')
+			}
+		case "histogram":
+			if in.IsNull() {
+				in.Skip()
+				out.Histogram = nil
+			} else {
+				if out.Histogram == nil {
+					out.Histogram = new(Histogram)
+				}
+				easyjsonD2b7633eDecodeGithubComSTurchinskiyMetricsInternalServerModels1(in, out.Histogram)
+			}
+		case "summary":
+			if in.IsNull() {
+				in.Skip()
+				out.Summary = nil
+			} else {
+				if out.Summary == nil {
+					out.Summary = new(Summary)
+				}
+				easyjsonD2b7633eDecodeGithubComSTurchinskiyMetricsInternalServerModels2(in, out.Summary)
+			}
+		case "quantiles":
+			if in.IsNull() {
+				in.Skip()
+				out.Quantiles = nil
+			} else {
+				in.Delim('[')
+				if out.Quantiles == nil {
+					if !in.IsDelim(']') {
+						out.Quantiles = make([]Quantile, 0, 4)
+					} else {
+						out.Quantiles = []Quantile{}
+					}
+				} else {
+					out.Quantiles = (out.Quantiles)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v2 Quantile
+					easyjsonD2b7633eDecodeGithubComSTurchinskiyMetricsInternalServerModels3(in, &v2)
+					out.Quantiles = append(out.Quantiles, v2)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		case "updated":
+			if in.IsNull() {
+				in.Skip()
+				out.Updated = nil
+			} else {
+				if out.Updated == nil {
+					out.Updated = new(time.Time)
+				}
+				if in.IsNull() {
+					in.Skip()
+				} else {
+					if data := in.Raw(); in.Ok() {
+						in.AddError((*out.Updated).UnmarshalJSON(data))
+					}
+				}
+			}
+		case "stale":
+			if in.IsNull() {
+				in.Skip()
+				out.Stale = nil
+			} else {
+				if out.Stale == nil {
+					out.Stale = new(bool)
+				}
+				if in.IsNull() {
+					in.Skip()
+				} else {
+					*out.Stale = bool(in.Bool())
+				}
 			}
 		default:
 			in.SkipRecursive()
@@ -95,6 +204,59 @@ func easyjsonD2b7633eEncodeGithubComSTurchinskiyMetricsInternalServerModels(out
 		out.RawString(prefix)
 		out.Float64(float64(*in.Value))
 	}
+	if len(in.Labels) != 0 {
+		const prefix string = ",\"labels\":"
+		out.RawString(prefix)
+		{
+			out.RawByte('{')
+			v3First := true
+			for v3Name, v3Value := range in.Labels {
+				if v3First {
+					v3First = false
+				} else {
+					out.RawByte(',')
+				}
+				out.String(string(v3Name))
+				out.RawByte(':')
+				out.String(string(v3Value))
+			}
+			out.RawByte('}')
+		}
+	}
+	if in.Histogram != nil {
+		const prefix string = ",\"histogram\":"
+		out.RawString(prefix)
+		easyjsonD2b7633eEncodeGithubComSTurchinskiyMetricsInternalServerModels1(out, *in.Histogram)
+	}
+	if in.Summary != nil {
+		const prefix string = ",\"summary\":"
+		out.RawString(prefix)
+		easyjsonD2b7633eEncodeGithubComSTurchinskiyMetricsInternalServerModels2(out, *in.Summary)
+	}
+	if len(in.Quantiles) != 0 {
+		const prefix string = ",\"quantiles\":"
+		out.RawString(prefix)
+		{
+			out.RawByte('[')
+			for v4, v5 := range in.Quantiles {
+				if v4 > 0 {
+					out.RawByte(',')
+				}
+				easyjsonD2b7633eEncodeGithubComSTurchinskiyMetricsInternalServerModels3(out, v5)
+			}
+			out.RawByte(']')
+		}
+	}
+	if in.Updated != nil {
+		const prefix string = ",\"updated\":"
+		out.RawString(prefix)
+		out.Raw((*in.Updated).MarshalJSON())
+	}
+	if in.Stale != nil {
+		const prefix string = ",\"stale\":"
+		out.RawString(prefix)
+		out.Bool(bool(*in.Stale))
+	}
 	out.RawByte('}')
 }
 
@@ -121,3 +283,284 @@ func (v *Metrics) UnmarshalJSON(data []byte) error {
 func (v *Metrics) UnmarshalEasyJSON(l *jlexer.Lexer) {
 	easyjsonD2b7633eDecodeGithubComSTurchinskiyMetricsInternalServerModels(l, v)
 }
+func easyjsonD2b7633eDecodeGithubComSTurchinskiyMetricsInternalServerModels3(in *jlexer.Lexer, out *Quantile) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		switch key {
+		case "quantile":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Quantile = float64(in.Float64())
+			}
+		case "value":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Value = float64(in.Float64())
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonD2b7633eEncodeGithubComSTurchinskiyMetricsInternalServerModels3(out *jwriter.Writer, in Quantile) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"quantile\":"
+		out.RawString(prefix[1:])
+		out.Float64(float64(in.Quantile))
+	}
+	{
+		const prefix string = ",\"value\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Value))
+	}
+	out.RawByte('}')
+}
+func easyjsonD2b7633eDecodeGithubComSTurchinskiyMetricsInternalServerModels2(in *jlexer.Lexer, out *Summary) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		switch key {
+		case "count":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Count = uint64(in.Uint64())
+			}
+		case "sum":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Sum = float64(in.Float64())
+			}
+		case "quantiles":
+			if in.IsNull() {
+				in.Skip()
+				out.Quantiles = nil
+			} else {
+				in.Delim('[')
+				if out.Quantiles == nil {
+					if !in.IsDelim(']') {
+						out.Quantiles = make([]Quantile, 0, 4)
+					} else {
+						out.Quantiles = []Quantile{}
+					}
+				} else {
+					out.Quantiles = (out.Quantiles)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v6 Quantile
+					easyjsonD2b7633eDecodeGithubComSTurchinskiyMetricsInternalServerModels3(in, &v6)
+					out.Quantiles = append(out.Quantiles, v6)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonD2b7633eEncodeGithubComSTurchinskiyMetricsInternalServerModels2(out *jwriter.Writer, in Summary) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"count\":"
+		out.RawString(prefix[1:])
+		out.Uint64(uint64(in.Count))
+	}
+	{
+		const prefix string = ",\"sum\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Sum))
+	}
+	{
+		const prefix string = ",\"quantiles\":"
+		out.RawString(prefix)
+		if in.Quantiles == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+			out.RawString("null")
+		} else {
+			out.RawByte('[')
+			for v7, v8 := range in.Quantiles {
+				if v7 > 0 {
+					out.RawByte(',')
+				}
+				easyjsonD2b7633eEncodeGithubComSTurchinskiyMetricsInternalServerModels3(out, v8)
+			}
+			out.RawByte(']')
+		}
+	}
+	out.RawByte('}')
+}
+func easyjsonD2b7633eDecodeGithubComSTurchinskiyMetricsInternalServerModels1(in *jlexer.Lexer, out *Histogram) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		switch key {
+		case "bounds":
+			if in.IsNull() {
+				in.Skip()
+				out.Bounds = nil
+			} else {
+				in.Delim('[')
+				if out.Bounds == nil {
+					if !in.IsDelim(']') {
+						out.Bounds = make([]float64, 0, 8)
+					} else {
+						out.Bounds = []float64{}
+					}
+				} else {
+					out.Bounds = (out.Bounds)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v9 float64
+					if in.IsNull() {
+						in.Skip()
+					} else {
+						v9 = float64(in.Float64())
+					}
+					out.Bounds = append(out.Bounds, v9)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		case "counts":
+			if in.IsNull() {
+				in.Skip()
+				out.Counts = nil
+			} else {
+				in.Delim('[')
+				if out.Counts == nil {
+					if !in.IsDelim(']') {
+						out.Counts = make([]uint64, 0, 8)
+					} else {
+						out.Counts = []uint64{}
+					}
+				} else {
+					out.Counts = (out.Counts)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v10 uint64
+					if in.IsNull() {
+						in.Skip()
+					} else {
+						v10 = uint64(in.Uint64())
+					}
+					out.Counts = append(out.Counts, v10)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		case "sum":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Sum = float64(in.Float64())
+			}
+		case "count":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Count = uint64(in.Uint64())
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonD2b7633eEncodeGithubComSTurchinskiyMetricsInternalServerModels1(out *jwriter.Writer, in Histogram) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"bounds\":"
+		out.RawString(prefix[1:])
+		if in.Bounds == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+			out.RawString("null")
+		} else {
+			out.RawByte('[')
+			for v11, v12 := range in.Bounds {
+				if v11 > 0 {
+					out.RawByte(',')
+				}
+				out.Float64(float64(v12))
+			}
+			out.RawByte(']')
+		}
+	}
+	{
+		const prefix string = ",\"counts\":"
+		out.RawString(prefix)
+		if in.Counts == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+			out.RawString("null")
+		} else {
+			out.RawByte('[')
+			for v13, v14 := range in.Counts {
+				if v13 > 0 {
+					out.RawByte(',')
+				}
+				out.Uint64(uint64(v14))
+			}
+			out.RawByte(']')
+		}
+	}
+	{
+		const prefix string = ",\"sum\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Sum))
+	}
+	{
+		const prefix string = ",\"count\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(in.Count))
+	}
+	out.RawByte('}')
+}
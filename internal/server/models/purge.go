@@ -0,0 +1,59 @@
+package models
+
+import (
+	"errors"
+	"path"
+	"strings"
+)
+
+// MetricsTypes Все типы метрик
+var MetricsTypes = []string{"gauge", "counter", "histogram", "summary"}
+
+var errEmptyPurgeFilter = errors.New("prefix or pattern is not defined")
+
+// PurgeFilter Отбор серий для массового удаления.
+// Pattern - шаблон имени метрики в синтаксисе path.Match: CPUutilization*
+type PurgeFilter struct {
+	Types   []string
+	Prefix  string
+	Pattern string
+	Labels  Labels
+}
+
+// Validate Фильтр без префикса и шаблона не допускается, чтобы случайно не удалить все метрики.
+// Удаление всех метрик - Pattern = "*"
+func (f PurgeFilter) Validate() error {
+
+	if f.Prefix == "" && f.Pattern == "" {
+		return errEmptyPurgeFilter
+	}
+
+	if f.Pattern != "" {
+		if _, err := path.Match(f.Pattern, ""); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Matches Подходит ли серия с ключом key под фильтр
+func (f PurgeFilter) Matches(key string) bool {
+
+	name, labels, err := ParseSeriesKey(key)
+	if err != nil {
+		return false
+	}
+
+	if !strings.HasPrefix(name, f.Prefix) {
+		return false
+	}
+
+	if f.Pattern != "" {
+		if matched, _ := path.Match(f.Pattern, name); !matched {
+			return false
+		}
+	}
+
+	return labels.Matches(f.Labels)
+}
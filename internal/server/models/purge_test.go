@@ -0,0 +1,39 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPurgeFilter_Validate(t *testing.T) {
+
+	assert.Error(t, PurgeFilter{}.Validate(), "пустой фильтр")
+	assert.Error(t, PurgeFilter{Pattern: "CPU["}.Validate(), "некорректный шаблон")
+	assert.NoError(t, PurgeFilter{Prefix: "CPU"}.Validate())
+	assert.NoError(t, PurgeFilter{Pattern: "*"}.Validate())
+}
+
+func TestPurgeFilter_Matches(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		filter PurgeFilter
+		key    string
+		want   bool
+	}{
+		{name: "Префикс", filter: PurgeFilter{Prefix: "CPU"}, key: "CPUutilization1", want: true},
+		{name: "Префикс не совпадает", filter: PurgeFilter{Prefix: "CPU"}, key: "Alloc", want: false},
+		{name: "Шаблон", filter: PurgeFilter{Pattern: "CPUutilization?"}, key: "CPUutilization1", want: true},
+		{name: "Шаблон не совпадает", filter: PurgeFilter{Pattern: "CPUutilization?"}, key: "CPUutilization10", want: false},
+		{name: "Шаблон проверяется по имени без меток", filter: PurgeFilter{Pattern: "CPU*"}, key: `CPUutilization{host="server01"}`, want: true},
+		{name: "Метки", filter: PurgeFilter{Pattern: "*", Labels: Labels{"host": "server01"}}, key: `CPUutilization{host="server01"}`, want: true},
+		{name: "Метки не совпадают", filter: PurgeFilter{Pattern: "*", Labels: Labels{"host": "server01"}}, key: `CPUutilization{host="server02"}`, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.filter.Matches(tt.key))
+		})
+	}
+}
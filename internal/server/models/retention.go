@@ -0,0 +1,201 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RollupRule Правило прореживания: значения агрегируются в интервалы Resolution и хранятся Retention
+type RollupRule struct {
+	Resolution time.Duration
+	Retention  time.Duration
+}
+
+// RetentionPolicy Сроки хранения истории: исходные значения хранятся Raw,
+// агрегаты - по правилам Rollups от мелкого интервала к крупному.
+// Задается строкой вида "raw:24h,1m:30d,1h:365d", пустая строка отключает очистку истории
+type RetentionPolicy struct {
+	Raw     time.Duration
+	Rollups []RollupRule
+}
+
+// RollupSample Агрегат значений метрики за интервал, начинающийся в Timestamp.
+// Для gauge Min, Max, Avg, Sum считаются по значениям, для counter - по приращениям,
+// Last - последнее значение (для counter накопленное)
+type RollupSample struct {
+	Timestamp time.Time `json:"timestamp" example:"2025-10-06T15:03:00Z"`
+	Min       float64   `json:"min" example:"1"`
+	Max       float64   `json:"max" example:"5"`
+	Avg       float64   `json:"avg" example:"3"`
+	Sum       float64   `json:"sum" example:"9"`
+	Last      float64   `json:"last" example:"5"`
+	Count     int64     `json:"count" example:"3"`
+}
+
+const rawRetentionName = "raw"
+
+func ParseRetentionPolicy(s string) (RetentionPolicy, error) {
+
+	var policy RetentionPolicy
+
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return policy, nil
+	}
+
+	for i, part := range strings.Split(s, ",") {
+
+		pair := strings.Split(strings.TrimSpace(part), ":")
+		if len(pair) != 2 {
+			return RetentionPolicy{}, fmt.Errorf("incorrect retention rule %q, need resolution:retention", part)
+		}
+
+		retention, err := parseDuration(pair[1])
+		if err != nil {
+			return RetentionPolicy{}, err
+		}
+
+		if i == 0 {
+			if pair[0] != rawRetentionName {
+				return RetentionPolicy{}, fmt.Errorf("first retention rule must be %s:<retention>", rawRetentionName)
+			}
+			policy.Raw = retention
+			continue
+		}
+
+		resolution, err := parseDuration(pair[0])
+		if err != nil {
+			return RetentionPolicy{}, err
+		}
+
+		policy.Rollups = append(policy.Rollups, RollupRule{Resolution: resolution, Retention: retention})
+	}
+
+	return policy, policy.Validate()
+}
+
+// Validate Каждый следующий интервал кратен предыдущему,
+// а исходные данные для него хранятся не меньше самого интервала
+func (p RetentionPolicy) Validate() error {
+
+	if !p.Enabled() {
+		if len(p.Rollups) != 0 {
+			return errors.New("raw retention is not defined")
+		}
+		return nil
+	}
+
+	sourceRetention := p.Raw
+	var previous time.Duration
+	for _, rule := range p.Rollups {
+
+		if rule.Resolution <= previous {
+			return fmt.Errorf("rollup resolution %s must be greater than %s", rule.Resolution, previous)
+		}
+
+		if previous != 0 && rule.Resolution%previous != 0 {
+			return fmt.Errorf("rollup resolution %s must be a multiple of %s", rule.Resolution, previous)
+		}
+
+		if sourceRetention < rule.Resolution {
+			return fmt.Errorf("source of rollup %s is kept only %s", rule.Resolution, sourceRetention)
+		}
+
+		previous = rule.Resolution
+		sourceRetention = rule.Retention
+	}
+
+	return nil
+}
+
+func (p RetentionPolicy) Enabled() bool {
+	return p.Raw > 0
+}
+
+// RawCutoff Время, раньше которого исходные значения удаляются.
+// Не удаляются значения, еще не попавшие в агрегаты первого уровня
+func (p RetentionPolicy) RawCutoff(now time.Time, watermarks map[time.Duration]time.Time) time.Time {
+
+	cutoff := now.Add(-p.Raw)
+	if len(p.Rollups) != 0 {
+		cutoff = earliest(cutoff, watermarks[p.Rollups[0].Resolution])
+	}
+	return cutoff
+}
+
+// RollupCutoff Время, раньше которого удаляются агрегаты уровня level
+func (p RetentionPolicy) RollupCutoff(level int, now time.Time, watermarks map[time.Duration]time.Time) time.Time {
+
+	cutoff := now.Add(-p.Rollups[level].Retention)
+	if level+1 < len(p.Rollups) {
+		cutoff = earliest(cutoff, watermarks[p.Rollups[level+1].Resolution])
+	}
+	return cutoff
+}
+
+func (p RetentionPolicy) String() string {
+
+	if !p.Enabled() {
+		return ""
+	}
+
+	parts := make([]string, 0, len(p.Rollups)+1)
+	parts = append(parts, rawRetentionName+":"+p.Raw.String())
+	for _, rule := range p.Rollups {
+		parts = append(parts, rule.Resolution.String()+":"+rule.Retention.String())
+	}
+
+	return strings.Join(parts, ",")
+}
+
+func (p RetentionPolicy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *RetentionPolicy) UnmarshalText(text []byte) error {
+
+	policy, err := ParseRetentionPolicy(string(text))
+	if err != nil {
+		return err
+	}
+
+	*p = policy
+	return nil
+}
+
+// BucketStart Начало интервала длиной resolution, в который попадает t. Интервалы отсчитываются от unix-эпохи
+func BucketStart(t time.Time, resolution time.Duration) time.Time {
+
+	nanos := t.UnixNano()
+	return time.Unix(0, nanos-nanos%int64(resolution))
+}
+
+// parseDuration Как time.ParseDuration, дополнительно понимает дни: 30d
+func parseDuration(s string) (time.Duration, error) {
+
+	s = strings.TrimSpace(s)
+	if days, found := strings.CutSuffix(s, "d"); found {
+		value, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("incorrect duration %q: %w", s, err)
+		}
+		return time.Duration(value) * 24 * time.Hour, nil
+	}
+
+	value, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("incorrect duration %q: %w", s, err)
+	}
+
+	return value, nil
+}
+
+func earliest(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
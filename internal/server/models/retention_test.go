@@ -0,0 +1,96 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRetentionPolicy(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		value   string
+		want    RetentionPolicy
+		wantErr bool
+	}{
+		{
+			name:  "Исходные значения и два уровня агрегатов. Успешно",
+			value: "raw:24h,1m:30d,1h:365d",
+			want: RetentionPolicy{
+				Raw: 24 * time.Hour,
+				Rollups: []RollupRule{
+					{Resolution: time.Minute, Retention: 30 * 24 * time.Hour},
+					{Resolution: time.Hour, Retention: 365 * 24 * time.Hour},
+				},
+			},
+		},
+		{
+			name:  "Только исходные значения. Успешно",
+			value: "raw:1h",
+			want:  RetentionPolicy{Raw: time.Hour},
+		},
+		{
+			name:  "Пустая строка отключает очистку. Успешно",
+			value: "",
+			want:  RetentionPolicy{},
+		},
+		{
+			name:    "Первое правило не raw. Ошибка",
+			value:   "1m:30d",
+			wantErr: true,
+		},
+		{
+			name:    "Интервал не кратен предыдущему. Ошибка",
+			value:   "raw:24h,1m:30d,90s:365d",
+			wantErr: true,
+		},
+		{
+			name:    "Интервалы не по возрастанию. Ошибка",
+			value:   "raw:24h,1h:30d,1m:365d",
+			wantErr: true,
+		},
+		{
+			name:    "Исходные значения хранятся меньше интервала. Ошибка",
+			value:   "raw:30s,1m:30d",
+			wantErr: true,
+		},
+		{
+			name:    "Неверный формат срока. Ошибка",
+			value:   "raw:day",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRetentionPolicy(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRetentionPolicy_Cutoff(t *testing.T) {
+
+	policy, err := ParseRetentionPolicy("raw:1h,1m:1d,1h:30d")
+	assert.NoError(t, err)
+
+	now := time.Date(2025, 10, 6, 15, 0, 0, 0, time.UTC)
+
+	// агрегаты первого уровня построены только до 14:30, более поздние значения не удаляются
+	watermarks := map[time.Duration]time.Time{time.Minute: now.Add(-90 * time.Minute)}
+	assert.Equal(t, now.Add(-90*time.Minute), policy.RawCutoff(now, watermarks))
+	assert.Equal(t, time.Time{}, policy.RollupCutoff(0, now, watermarks))
+	assert.Equal(t, now.Add(-30*24*time.Hour), policy.RollupCutoff(1, now, watermarks))
+
+	watermarks = map[time.Duration]time.Time{time.Minute: now, time.Hour: now}
+	assert.Equal(t, now.Add(-time.Hour), policy.RawCutoff(now, watermarks))
+	assert.Equal(t, now.Add(-24*time.Hour), policy.RollupCutoff(0, now, watermarks))
+
+	assert.Equal(t, "raw:1h0m0s,1m0s:24h0m0s,1h0m0s:720h0m0s", policy.String())
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Действия с устаревшими gauge
+const (
+	StaleMark   = "mark"
+	StaleRemove = "remove"
+)
+
+// StalePolicy Gauge, не обновлявшийся дольше TTL, считается устаревшим.
+// При Remove устаревшие gauge удаляются, иначе только помечаются в ответах. TTL = 0 отключает проверку
+type StalePolicy struct {
+	TTL    time.Duration
+	Remove bool
+}
+
+func NewStalePolicy(ttlSeconds int, action string) (StalePolicy, error) {
+
+	if ttlSeconds < 0 {
+		return StalePolicy{}, fmt.Errorf("incorrect gauge ttl %d", ttlSeconds)
+	}
+
+	policy := StalePolicy{TTL: time.Duration(ttlSeconds) * time.Second}
+
+	switch action {
+	case StaleMark, "":
+	case StaleRemove:
+		policy.Remove = true
+	default:
+		return StalePolicy{}, fmt.Errorf("incorrect stale gauges action %q, need %s or %s", action, StaleMark, StaleRemove)
+	}
+
+	return policy, nil
+}
+
+func (p StalePolicy) Enabled() bool {
+	return p.TTL > 0
+}
+
+// IsStale Устарело ли значение, обновленное в updated, на момент now
+func (p StalePolicy) IsStale(updated, now time.Time) bool {
+	return p.Enabled() && now.Sub(updated) > p.TTL
+}
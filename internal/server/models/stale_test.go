@@ -0,0 +1,30 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewStalePolicy(t *testing.T) {
+
+	policy, err := NewStalePolicy(60, StaleRemove)
+	require.NoError(t, err)
+	assert.Equal(t, StalePolicy{TTL: time.Minute, Remove: true}, policy)
+
+	now := time.Now()
+	assert.True(t, policy.IsStale(now.Add(-2*time.Minute), now))
+	assert.False(t, policy.IsStale(now.Add(-30*time.Second), now))
+
+	policy, err = NewStalePolicy(0, StaleMark)
+	require.NoError(t, err)
+	assert.False(t, policy.IsStale(now.Add(-time.Hour), now), "TTL = 0 отключает проверку")
+
+	_, err = NewStalePolicy(60, "drop")
+	assert.Error(t, err)
+
+	_, err = NewStalePolicy(-1, StaleMark)
+	assert.Error(t, err)
+}
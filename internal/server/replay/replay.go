@@ -0,0 +1,125 @@
+// Package replay Защита от повтора подписанных запросов агентов по метке времени и nonce
+package replay
+
+import (
+	"container/list"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrMissing   = errors.New("missing request timestamp or nonce")
+	ErrTimestamp = errors.New("invalid request timestamp")
+	ErrSkew      = errors.New("request timestamp is outside the allowed clock skew")
+	ErrReplay    = errors.New("request nonce has already been used")
+	ErrFull      = errors.New("too many signed requests within the replay window")
+)
+
+type seenNonce struct {
+	nonce   string
+	expires time.Time
+}
+
+// Guard Проверка метки времени в пределах окна и однократности nonce.
+// Nonce хранятся, пока метка времени запроса с ним проходит проверку окна, но не больше capacity.
+// Вытеснить nonce до истечения окна нельзя - его повтор прошел бы проверку, поэтому при переполнении
+// новые запросы отклоняются с ErrFull. capacity должна быть не меньше числа запросов за 2*window
+type Guard struct {
+	window   time.Duration
+	capacity int
+	now      func() time.Time
+
+	mutex  sync.Mutex
+	nonces map[string]*list.Element
+	order  *list.List
+}
+
+// New Создание Guard, window - допустимое расхождение часов агента и сервера.
+// При window <= 0 возвращается nil, проверка отключена
+func New(window time.Duration, capacity int) *Guard {
+
+	if window <= 0 {
+		return nil
+	}
+
+	return &Guard{
+		window:   window,
+		capacity: capacity,
+		now:      time.Now,
+		nonces:   make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Check Проверка заголовков запроса, прошедшего проверку подписи. Принятый nonce запоминается
+func (g *Guard) Check(timestamp, nonce string) error {
+
+	if timestamp == "" || nonce == "" {
+		return ErrMissing
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrTimestamp
+	}
+
+	now := g.now()
+	sent := time.Unix(seconds, 0)
+	if sent.Before(now.Add(-g.window)) || sent.After(now.Add(g.window)) {
+		return ErrSkew
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.removeExpired(now)
+
+	if _, exist := g.nonces[nonce]; exist {
+		return ErrReplay
+	}
+
+	if g.capacity > 0 && g.order.Len() >= g.capacity {
+		g.removeAllExpired(now)
+		if g.order.Len() >= g.capacity {
+			return ErrFull
+		}
+	}
+
+	// позже sent+window запрос с этой меткой времени не пройдет проверку окна
+	element := g.order.PushBack(seenNonce{nonce: nonce, expires: sent.Add(g.window)})
+	g.nonces[nonce] = element
+
+	return nil
+}
+
+// removeExpired Nonce лежат в порядке поступления, сроки хранения отличаются не больше чем на 2*window,
+// поэтому проверяется только начало списка
+func (g *Guard) removeExpired(now time.Time) {
+
+	for element := g.order.Front(); element != nil; element = g.order.Front() {
+		if element.Value.(seenNonce).expires.After(now) {
+			return
+		}
+		g.remove(element)
+	}
+}
+
+// removeAllExpired Удаление истекших nonce по всему списку, вызывается только при переполнении
+func (g *Guard) removeAllExpired(now time.Time) {
+
+	for element := g.order.Front(); element != nil; {
+		next := element.Next()
+		if !element.Value.(seenNonce).expires.After(now) {
+			g.remove(element)
+		}
+		element = next
+	}
+}
+
+func (g *Guard) remove(element *list.Element) {
+
+	delete(g.nonces, element.Value.(seenNonce).nonce)
+	g.order.Remove(element)
+}
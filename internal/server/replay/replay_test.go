@@ -0,0 +1,84 @@
+package replay
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGuard_Check(t *testing.T) {
+
+	now := time.Unix(1700000000, 0)
+	unix := func(d time.Duration) string {
+		return strconv.FormatInt(now.Add(d).Unix(), 10)
+	}
+
+	g := New(time.Minute, 10)
+	g.now = func() time.Time { return now }
+
+	tests := []struct {
+		name      string
+		timestamp string
+		nonce     string
+		want      error
+	}{
+		{name: "новый nonce", timestamp: unix(0), nonce: "a", want: nil},
+		{name: "повтор nonce", timestamp: unix(0), nonce: "a", want: ErrReplay},
+		{name: "часы агента отстают в пределах окна", timestamp: unix(-50 * time.Second), nonce: "b", want: nil},
+		{name: "часы агента спешат в пределах окна", timestamp: unix(50 * time.Second), nonce: "c", want: nil},
+		{name: "старый запрос", timestamp: unix(-2 * time.Minute), nonce: "d", want: ErrSkew},
+		{name: "запрос из будущего", timestamp: unix(2 * time.Minute), nonce: "e", want: ErrSkew},
+		{name: "без nonce", timestamp: unix(0), nonce: "", want: ErrMissing},
+		{name: "без метки времени", timestamp: "", nonce: "f", want: ErrMissing},
+		{name: "метка времени не число", timestamp: "now", nonce: "g", want: ErrTimestamp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, g.Check(tt.timestamp, tt.nonce), tt.want)
+		})
+	}
+}
+
+func TestGuard_Bounded(t *testing.T) {
+
+	now := time.Unix(1700000000, 0)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	g := New(time.Minute, 2)
+	g.now = func() time.Time { return now }
+
+	assert.NoError(t, g.Check(timestamp, "a"))
+	assert.NoError(t, g.Check(timestamp, "b"))
+	assert.ErrorIs(t, g.Check(timestamp, "c"), ErrFull, "при переполнении новые запросы отклоняются")
+	assert.Equal(t, 2, g.order.Len(), "размер ограничен capacity")
+	assert.ErrorIs(t, g.Check(timestamp, "a"), ErrReplay, "nonce в окне не вытесняется")
+
+	now = now.Add(2 * time.Minute)
+	assert.NoError(t, g.Check(strconv.FormatInt(now.Unix(), 10), "c"))
+	assert.Equal(t, 1, g.order.Len(), "nonce с истекшим окном удалены")
+}
+
+func TestGuard_FullRemovesExpiredOutOfOrder(t *testing.T) {
+
+	now := time.Unix(1700000000, 0)
+	unix := func(d time.Duration) string {
+		return strconv.FormatInt(now.Add(d).Unix(), 10)
+	}
+
+	g := New(time.Minute, 2)
+	g.now = func() time.Time { return now }
+
+	assert.NoError(t, g.Check(unix(50*time.Second), "a"))
+	assert.NoError(t, g.Check(unix(-50*time.Second), "b"))
+
+	now = now.Add(20 * time.Second)
+	assert.NoError(t, g.Check(unix(0), "c"), "истекший nonce за еще действующим освобождает место")
+	assert.ErrorIs(t, g.Check(unix(0), "a"), ErrReplay)
+}
+
+func TestNew_Disabled(t *testing.T) {
+	assert.Nil(t, New(0, 10))
+}
@@ -1,2 +1,800 @@
 // Package file Хранение данных в файлах
 package file
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/s-turchinskiy/metrics/internal/server/middleware/logger"
+	"github.com/s-turchinskiy/metrics/internal/server/models"
+	"github.com/s-turchinskiy/metrics/internal/server/repository/memcashed"
+	"github.com/s-turchinskiy/metrics/internal/server/tenant"
+	"github.com/s-turchinskiy/metrics/internal/utils/errutil"
+)
+
+const (
+	opGauge     = "gauge"
+	opCounter   = "counter"
+	opHistogram = "histogram"
+	opSummary   = "summary"
+	opDelete    = "delete"
+
+	// DefaultCompactionThreshold Количество записей в журнале, после которого журнал сжимается
+	DefaultCompactionThreshold = 10000
+)
+
+// record Одна запись журнала. gauge и summary - установка значения, counter и histogram - приращение,
+// delete - удаление серии типа Type
+type record struct {
+	Op        string            `json:"op"`
+	Name      string            `json:"name"`
+	Type      string            `json:"type,omitempty"`
+	Value     *float64          `json:"value,omitempty"`
+	Delta     *int64            `json:"delta,omitempty"`
+	Histogram *models.Histogram `json:"histogram,omitempty"`
+	Summary   *models.Summary   `json:"summary,omitempty"`
+	Time      *time.Time        `json:"time,omitempty"`
+	Tenant    string            `json:"tenant,omitempty"`
+}
+
+// legacySnapshot Снимок метрик прежнего формата, как service.MetricsFileStorage:
+// один JSON-объект, записанный SaveMetricsToFile вместо журнала
+type legacySnapshot struct {
+	Gauge   map[string]float64
+	Counter map[string]int64
+	Date    string
+}
+
+// Repository Хранение метрик в журнале только для дозаписи.
+// Каждое изменение дописывается в файл до изменения данных в памяти,
+// при сжатии журнал заменяется текущим состоянием.
+type Repository struct {
+	state               *memcashed.MemCashed
+	path                string
+	file                *os.File
+	writer              *bufio.Writer
+	offset              int64 // размер журнала после последней успешной записи
+	syncWrites          bool
+	records             int
+	compactionThreshold int
+	mutex               sync.Mutex
+}
+
+// New Открытие журнала. При restore = true состояние восстанавливается из журнала,
+// иначе журнал очищается. Снимок прежнего формата загружается и переписывается журналом.
+// При syncWrites = true каждая запись сбрасывается на диск.
+func New(ctx context.Context, path string, restore, syncWrites bool) (*Repository, error) {
+
+	r := &Repository{
+		state: &memcashed.MemCashed{
+			Gauge:     make(map[string]float64),
+			Counter:   make(map[string]int64),
+			Histogram: make(map[string]models.Histogram),
+			Summary:   make(map[string]models.Summary),
+			Updated:   memcashed.NewUpdated(),
+		},
+		path:                path,
+		syncWrites:          syncWrites,
+		compactionThreshold: DefaultCompactionThreshold,
+	}
+
+	if restore {
+		if err := r.replay(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	// история ведется с момента запуска, восстановленные из журнала значения в нее не попадают
+	r.state.History = memcashed.NewHistory()
+
+	if err := r.compact(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *Repository) replay(ctx context.Context) error {
+
+	f, err := os.Open(r.path)
+	if errors.Is(err, os.ErrNotExist) {
+		logger.Log.Debugw("file repository, journal not exist", "path", r.path)
+		return nil
+	}
+	if err != nil {
+		return errutil.WrapError(err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var brokenLine int
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		if line == 1 && isLegacySnapshot(scanner.Bytes()) {
+			return r.loadLegacySnapshot(ctx)
+		}
+
+		if brokenLine != 0 {
+			return errutil.WrapError(fmt.Errorf("journal %s is corrupted at line %d", r.path, brokenLine))
+		}
+
+		var rec record
+		if err = json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// последняя строка могла быть записана не полностью при падении процесса
+			brokenLine = line
+			continue
+		}
+
+		if err = r.apply(tenant.WithTenant(ctx, rec.Tenant), rec); err != nil {
+			return errutil.WrapError(fmt.Errorf("journal %s, line %d: %w", r.path, line, err))
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return errutil.WrapError(err)
+	}
+
+	if brokenLine != 0 {
+		logger.Log.Infow("file repository, incomplete last record skipped", "path", r.path, "line", brokenLine)
+	}
+
+	logger.Log.Debugw("file repository restored", "path", r.path, "records", line)
+
+	return nil
+}
+
+// isLegacySnapshot Первая строка файла - начало снимка прежнего формата: "{" объекта с отступами
+// или объект в одну строку с полями Gauge и Counter вместо записи журнала
+func isLegacySnapshot(firstLine []byte) bool {
+
+	firstLine = bytes.TrimSpace(firstLine)
+	if string(firstLine) == "{" {
+		return true
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(firstLine, &fields); err != nil {
+		return false
+	}
+
+	_, op := fields["op"]
+	_, gauge := fields["Gauge"]
+	_, counter := fields["Counter"]
+	return !op && (gauge || counter)
+}
+
+// loadLegacySnapshot Загрузка снимка прежнего формата, как service.LoadMetricsFromData, в состояние арендатора по умолчанию.
+// Журналом снимок переписывает сжатие после восстановления
+func (r *Repository) loadLegacySnapshot(ctx context.Context) error {
+
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return errutil.WrapError(err)
+	}
+
+	var snapshot legacySnapshot
+	if err = json.Unmarshal(data, &snapshot); err != nil {
+		return errutil.WrapError(fmt.Errorf("snapshot %s: %w", r.path, err))
+	}
+
+	// в снимке без метрик типа поле null, состоянию нужна непустая карта
+	if snapshot.Gauge == nil {
+		snapshot.Gauge = make(map[string]float64)
+	}
+	if snapshot.Counter == nil {
+		snapshot.Counter = make(map[string]int64)
+	}
+
+	ctx = tenant.WithTenant(ctx, "")
+	if err = r.state.ReloadAllGauges(ctx, snapshot.Gauge); err != nil {
+		return errutil.WrapError(err)
+	}
+	if err = r.state.ReloadAllCounters(ctx, snapshot.Counter); err != nil {
+		return errutil.WrapError(err)
+	}
+
+	logger.Log.Infow("file repository, legacy snapshot converted to journal", "path", r.path,
+		"gauges", len(snapshot.Gauge), "counters", len(snapshot.Counter), "date", snapshot.Date)
+
+	return nil
+}
+
+// apply Применение записи к состоянию. Время обновления серии берется из записи,
+// чтобы после восстановления устаревшие серии оставались устаревшими
+func (r *Repository) apply(ctx context.Context, rec record) error {
+
+	if err := r.applyValue(ctx, rec); err != nil {
+		return err
+	}
+
+	if rec.Time != nil && rec.Op != opDelete {
+		r.state.SetUpdated(ctx, rec.Op, rec.Name, *rec.Time)
+	}
+
+	return nil
+}
+
+func (r *Repository) applyValue(ctx context.Context, rec record) error {
+
+	switch rec.Op {
+	case opGauge:
+		if rec.Value == nil {
+			return fmt.Errorf("value is not defined for gauge %s", rec.Name)
+		}
+		return r.state.UpdateGauge(ctx, rec.Name, *rec.Value)
+	case opCounter:
+		if rec.Delta == nil {
+			return fmt.Errorf("delta is not defined for counter %s", rec.Name)
+		}
+		return r.state.UpdateCounter(ctx, rec.Name, *rec.Delta)
+	case opHistogram:
+		if rec.Histogram == nil {
+			return fmt.Errorf("histogram is not defined for %s", rec.Name)
+		}
+		return r.state.UpdateHistogram(ctx, rec.Name, *rec.Histogram)
+	case opSummary:
+		if rec.Summary == nil {
+			return fmt.Errorf("summary is not defined for %s", rec.Name)
+		}
+		return r.state.UpdateSummary(ctx, rec.Name, *rec.Summary)
+	case opDelete:
+		_, err := r.state.DeleteMetrics(ctx, rec.Type, []string{rec.Name})
+		return err
+	default:
+		return fmt.Errorf("unclown op %s", rec.Op)
+	}
+}
+
+// append Дозапись записи в журнал. При ошибке записи журнал обрезается до последней успешной записи,
+// чтобы в нем не осталось части строки, а буфер сбрасывается: состояние в памяти не меняется, запись не повторяется
+func (r *Repository) append(rec record) error {
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return errutil.WrapError(err)
+	}
+	data = append(data, '\n')
+
+	if err = r.write(data); err != nil {
+		return r.rollback(err)
+	}
+
+	r.offset += int64(len(data))
+	r.records++
+
+	return nil
+}
+
+func (r *Repository) write(data []byte) error {
+
+	if _, err := r.writer.Write(data); err != nil {
+		return err
+	}
+
+	if err := r.writer.Flush(); err != nil {
+		return err
+	}
+
+	if r.syncWrites {
+		return r.file.Sync()
+	}
+
+	return nil
+}
+
+// rollback Обрезка журнала до последней успешной записи и сброс буфера после ошибки записи err
+func (r *Repository) rollback(err error) error {
+
+	r.writer.Reset(r.file)
+
+	if errTruncate := r.file.Truncate(r.offset); errTruncate != nil {
+		logger.Log.Infow("file repository, journal truncate error", "path", r.path, "error", errTruncate.Error())
+		return errutil.WrapError(errors.Join(err, errTruncate))
+	}
+
+	return errutil.WrapError(err)
+}
+
+// compactIfNeeded Сжатие журнала после применения записи к состоянию, если журнал разросся
+func (r *Repository) compactIfNeeded() error {
+
+	if r.records < r.compactionThreshold {
+		return nil
+	}
+
+	return r.compact()
+}
+
+// compact Запись текущего состояния во временный файл и замена им журнала
+func (r *Repository) compact() error {
+
+	tmpPath := r.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
+	if err != nil {
+		return errutil.WrapError(err)
+	}
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+
+	records := 0
+	for id, ns := range r.state.Namespaces() {
+		n, err := writeNamespace(enc, id, ns)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		records += n
+	}
+
+	if err = w.Flush(); err != nil {
+		tmp.Close()
+		return errutil.WrapError(err)
+	}
+
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return errutil.WrapError(err)
+	}
+
+	if err = tmp.Close(); err != nil {
+		return errutil.WrapError(err)
+	}
+
+	if r.file != nil {
+		if err = r.file.Close(); err != nil {
+			return errutil.WrapError(err)
+		}
+		r.file = nil
+	}
+
+	if err = os.Rename(tmpPath, r.path); err != nil {
+		return errutil.WrapError(err)
+	}
+
+	r.file, err = os.OpenFile(r.path, os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		return errutil.WrapError(err)
+	}
+	r.writer = bufio.NewWriter(r.file)
+	r.records = records
+
+	info, err := r.file.Stat()
+	if err != nil {
+		return errutil.WrapError(err)
+	}
+	r.offset = info.Size()
+
+	logger.Log.Debugw("file repository compacted", "path", r.path, "records", records)
+
+	return nil
+}
+
+// writeNamespace Запись состояния арендатора id при сжатии журнала, результат - количество записей
+func writeNamespace(enc *json.Encoder, id string, ns *memcashed.MemCashed) (int, error) {
+
+	updated := func(metricsType, metricsName string) *time.Time {
+		t, exist := ns.Updated[metricsType][metricsName]
+		if !exist {
+			return nil
+		}
+		return &t
+	}
+
+	records := 0
+	for name, value := range ns.Gauge {
+		if err := enc.Encode(record{Op: opGauge, Name: name, Value: &value, Time: updated(opGauge, name), Tenant: id}); err != nil {
+			return records, errutil.WrapError(err)
+		}
+		records++
+	}
+
+	for name, delta := range ns.Counter {
+		if err := enc.Encode(record{Op: opCounter, Name: name, Delta: &delta, Time: updated(opCounter, name), Tenant: id}); err != nil {
+			return records, errutil.WrapError(err)
+		}
+		records++
+	}
+
+	for name, h := range ns.Histogram {
+		if err := enc.Encode(record{Op: opHistogram, Name: name, Histogram: &h, Time: updated(opHistogram, name), Tenant: id}); err != nil {
+			return records, errutil.WrapError(err)
+		}
+		records++
+	}
+
+	for name, summary := range ns.Summary {
+		if err := enc.Encode(record{Op: opSummary, Name: name, Summary: &summary, Time: updated(opSummary, name), Tenant: id}); err != nil {
+			return records, errutil.WrapError(err)
+		}
+		records++
+	}
+
+	return records, nil
+}
+
+// Compact Сжатие журнала до текущего состояния
+func (r *Repository) Compact(ctx context.Context) error {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.compact()
+}
+
+func (r *Repository) UpdateGauge(ctx context.Context, metricsName string, newValue float64) error {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	if err := r.append(record{Op: opGauge, Name: metricsName, Value: &newValue, Time: &now, Tenant: tenant.FromContext(ctx)}); err != nil {
+		return err
+	}
+
+	if err := r.state.UpdateGauge(ctx, metricsName, newValue); err != nil {
+		return err
+	}
+	r.state.SetUpdated(ctx, opGauge, metricsName, now)
+
+	return r.compactIfNeeded()
+}
+
+func (r *Repository) UpdateCounter(ctx context.Context, metricsName string, delta int64) error {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	if err := r.append(record{Op: opCounter, Name: metricsName, Delta: &delta, Time: &now, Tenant: tenant.FromContext(ctx)}); err != nil {
+		return err
+	}
+
+	if err := r.state.UpdateCounter(ctx, metricsName, delta); err != nil {
+		return err
+	}
+	r.state.SetUpdated(ctx, opCounter, metricsName, now)
+
+	return r.compactIfNeeded()
+}
+
+func (r *Repository) UpdateHistogram(ctx context.Context, metricsName string, delta models.Histogram) error {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// несовместимая гистограмма не должна попасть в журнал, иначе журнал не восстановится
+	if current, exist, _ := r.state.GetHistogram(ctx, metricsName); exist {
+		if err := current.Merge(delta); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	if err := r.append(record{Op: opHistogram, Name: metricsName, Histogram: &delta, Time: &now, Tenant: tenant.FromContext(ctx)}); err != nil {
+		return err
+	}
+
+	if err := r.state.UpdateHistogram(ctx, metricsName, delta); err != nil {
+		return err
+	}
+	r.state.SetUpdated(ctx, opHistogram, metricsName, now)
+
+	return r.compactIfNeeded()
+}
+
+func (r *Repository) UpdateSummary(ctx context.Context, metricsName string, summary models.Summary) error {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	if err := r.append(record{Op: opSummary, Name: metricsName, Summary: &summary, Time: &now, Tenant: tenant.FromContext(ctx)}); err != nil {
+		return err
+	}
+
+	if err := r.state.UpdateSummary(ctx, metricsName, summary); err != nil {
+		return err
+	}
+	r.state.SetUpdated(ctx, opSummary, metricsName, now)
+
+	return r.compactIfNeeded()
+}
+
+func (r *Repository) DeleteMetrics(ctx context.Context, metricsType string, metricsNames []string) (int64, error) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var deleted int64
+	for _, metricsName := range metricsNames {
+
+		exist, err := r.exist(ctx, metricsType, metricsName)
+		if err != nil {
+			return deleted, err
+		}
+		if !exist {
+			continue
+		}
+
+		if err = r.append(record{Op: opDelete, Name: metricsName, Type: metricsType, Tenant: tenant.FromContext(ctx)}); err != nil {
+			return deleted, err
+		}
+
+		count, err := r.state.DeleteMetrics(ctx, metricsType, []string{metricsName})
+		if err != nil {
+			return deleted, err
+		}
+		deleted += count
+	}
+
+	return deleted, r.compactIfNeeded()
+}
+
+// exist Наличие серии в состоянии, для записи в журнал только реально удаляемых серий
+func (r *Repository) exist(ctx context.Context, metricsType, metricsName string) (bool, error) {
+
+	var exist bool
+	var err error
+	switch metricsType {
+	case opGauge:
+		_, exist, err = r.state.GetGauge(ctx, metricsName)
+	case opCounter:
+		_, exist, err = r.state.GetCounter(ctx, metricsName)
+	case opHistogram:
+		_, exist, err = r.state.GetHistogram(ctx, metricsName)
+	case opSummary:
+		_, exist, err = r.state.GetSummary(ctx, metricsName)
+	default:
+		return false, fmt.Errorf("unclown MType %s", metricsType)
+	}
+
+	return exist, err
+}
+
+func (r *Repository) GetUpdated(ctx context.Context, metricsType, metricsName string) (time.Time, bool, error) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.state.GetUpdated(ctx, metricsType, metricsName)
+}
+
+func (r *Repository) GetAllUpdated(ctx context.Context, metricsType string) (map[string]time.Time, error) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.state.GetAllUpdated(ctx, metricsType)
+}
+
+func (r *Repository) TenantIDs(ctx context.Context) ([]string, error) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.state.TenantIDs(ctx)
+}
+
+func (r *Repository) GetHistogram(ctx context.Context, metricsName string) (models.Histogram, bool, error) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.state.GetHistogram(ctx, metricsName)
+}
+
+func (r *Repository) GetAllHistograms(ctx context.Context) (map[string]models.Histogram, error) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.state.GetAllHistograms(ctx)
+}
+
+func (r *Repository) GetSummary(ctx context.Context, metricsName string) (models.Summary, bool, error) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.state.GetSummary(ctx, metricsName)
+}
+
+func (r *Repository) GetAllSummaries(ctx context.Context) (map[string]models.Summary, error) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.state.GetAllSummaries(ctx)
+}
+
+func (r *Repository) CountGauges(ctx context.Context) int {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.state.CountGauges(ctx)
+}
+
+func (r *Repository) CountCounters(ctx context.Context) int {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.state.CountCounters(ctx)
+}
+
+func (r *Repository) GetGauge(ctx context.Context, metricsName string) (float64, bool, error) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.state.GetGauge(ctx, metricsName)
+}
+
+func (r *Repository) GetCounter(ctx context.Context, metricsName string) (int64, bool, error) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.state.GetCounter(ctx, metricsName)
+}
+
+func (r *Repository) GetAllGauges(ctx context.Context) (map[string]float64, error) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	gauges, err := r.state.GetAllGauges(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]float64, len(gauges))
+	for name, value := range gauges {
+		result[name] = value
+	}
+
+	return result, nil
+}
+
+func (r *Repository) GetAllCounters(ctx context.Context) (map[string]int64, error) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	counters, err := r.state.GetAllCounters(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]int64, len(counters))
+	for name, value := range counters {
+		result[name] = value
+	}
+
+	return result, nil
+}
+
+func (r *Repository) ReloadAllGauges(ctx context.Context, newValue map[string]float64) error {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if newValue == nil {
+		newValue = make(map[string]float64)
+	}
+
+	if err := r.state.ReloadAllGauges(ctx, newValue); err != nil {
+		return err
+	}
+
+	return r.compact()
+}
+
+func (r *Repository) ReloadAllCounters(ctx context.Context, newValue map[string]int64) error {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if newValue == nil {
+		newValue = make(map[string]int64)
+	}
+
+	if err := r.state.ReloadAllCounters(ctx, newValue); err != nil {
+		return err
+	}
+
+	return r.compact()
+}
+
+func (r *Repository) ReloadAllMetrics(ctx context.Context, metrics []models.StorageMetrics) (int64, error) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	count, err := r.state.ReloadAllMetrics(ctx, metrics)
+
+	// состояние в памяти уже заменено, даже если часть метрик не загрузилась
+	if errCompact := r.compact(); errCompact != nil {
+		return count, errors.Join(err, errCompact)
+	}
+
+	return count, err
+}
+
+func (r *Repository) GetHistory(ctx context.Context, metricsType, metricsName string, from, to time.Time) ([]models.HistorySample, error) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.state.GetHistory(ctx, metricsType, metricsName, from, to)
+}
+
+func (r *Repository) GetHistoryRollups(ctx context.Context, metricsType, metricsName string, resolution time.Duration, from, to time.Time) ([]models.RollupSample, error) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.state.GetHistoryRollups(ctx, metricsType, metricsName, resolution, from, to)
+}
+
+func (r *Repository) CompactHistory(ctx context.Context, policy models.RetentionPolicy, now time.Time) error {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.state.CompactHistory(ctx, policy, now)
+}
+
+func (r *Repository) Ping(ctx context.Context) ([]byte, error) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.file == nil {
+		return nil, fmt.Errorf("journal %s is closed", r.path)
+	}
+
+	if _, err := r.file.Stat(); err != nil {
+		return nil, errutil.WrapError(err)
+	}
+
+	return nil, nil
+}
+
+// Close Сжатие журнала и закрытие файла
+func (r *Repository) Close(ctx context.Context) error {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+
+	err := r.compact()
+	if err == nil {
+		err = r.file.Close()
+	}
+	r.file = nil
+
+	if err != nil {
+		logger.Log.Infow("file repository stopped with error", "error", err.Error())
+	} else {
+		logger.Log.Infow("file repository stopped")
+	}
+
+	return err
+}
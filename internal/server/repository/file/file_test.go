@@ -0,0 +1,431 @@
+package file
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/s-turchinskiy/metrics/internal/server/models"
+	"github.com/s-turchinskiy/metrics/internal/server/tenant"
+)
+
+func TestRepository_Restore(t *testing.T) {
+
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		journal     string
+		restore     bool
+		wantGauge   map[string]float64
+		wantCounter map[string]int64
+		wantErr     bool
+	}{
+		{
+			name: "Восстановление из журнала",
+			journal: `{"op":"gauge","name":"Alloc","value":1.5}
+{"op":"counter","name":"PollCount","delta":2}
+{"op":"gauge","name":"Alloc","value":2.5}
+{"op":"counter","name":"PollCount","delta":3}
+`,
+			restore:     true,
+			wantGauge:   map[string]float64{"Alloc": 2.5},
+			wantCounter: map[string]int64{"PollCount": 5},
+		},
+		{
+			name: "Недописанная последняя запись пропускается",
+			journal: `{"op":"counter","name":"PollCount","delta":2}
+{"op":"counter","name":"PollCo`,
+			restore:     true,
+			wantGauge:   map[string]float64{},
+			wantCounter: map[string]int64{"PollCount": 2},
+		},
+		{
+			name: "Испорченная запись в середине журнала",
+			journal: `{"op":"counter","name":"PollCount","delta":2}
+{"op":"counter","name":"PollCo
+{"op":"counter","name":"PollCount","delta":2}
+`,
+			restore: true,
+			wantErr: true,
+		},
+		{
+			name: "Снимок прежнего формата",
+			journal: `{
+   "Gauge": {
+      "Alloc": 1.5
+   },
+   "Counter": {
+      "PollCount": 7
+   },
+   "Date": "2024-05-01 10:00:00"
+}`,
+			restore:     true,
+			wantGauge:   map[string]float64{"Alloc": 1.5},
+			wantCounter: map[string]int64{"PollCount": 7},
+		},
+		{
+			name:        "Снимок прежнего формата в одну строку без gauge",
+			journal:     `{"Gauge":null,"Counter":{"PollCount":7},"Date":"2024-05-01 10:00:00"}`,
+			restore:     true,
+			wantGauge:   map[string]float64{},
+			wantCounter: map[string]int64{"PollCount": 7},
+		},
+		{
+			name:        "Без восстановления журнал очищается",
+			journal:     `{"op":"counter","name":"PollCount","delta":2}`,
+			restore:     false,
+			wantGauge:   map[string]float64{},
+			wantCounter: map[string]int64{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			path := filepath.Join(t.TempDir(), "store.txt")
+			require.NoError(t, os.WriteFile(path, []byte(tt.journal), 0666))
+
+			r, err := New(ctx, path, tt.restore, false)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			defer r.Close(ctx)
+
+			gauges, err := r.GetAllGauges(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantGauge, gauges)
+
+			counters, err := r.GetAllCounters(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCounter, counters)
+		})
+	}
+}
+
+func TestRepository_LegacySnapshotMigration(t *testing.T) {
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "store.txt")
+
+	data, err := json.MarshalIndent(legacySnapshot{
+		Gauge:   map[string]float64{"Alloc": 1.5},
+		Counter: map[string]int64{"PollCount": 7},
+		Date:    "2024-05-01 10:00:00",
+	}, "", "   ")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0666))
+
+	r, err := New(ctx, path, true, false)
+	require.NoError(t, err)
+	require.NoError(t, r.UpdateCounter(ctx, "PollCount", 3))
+	require.NoError(t, r.Close(ctx))
+
+	journal, err := os.ReadFile(path)
+	require.NoError(t, err)
+	for _, line := range strings.Split(strings.TrimSpace(string(journal)), "\n") {
+		var rec record
+		require.NoError(t, json.Unmarshal([]byte(line), &rec))
+		assert.NotEmpty(t, rec.Op, "снимок переписан журналом")
+	}
+
+	reopened, err := New(ctx, path, true, false)
+	require.NoError(t, err)
+	defer reopened.Close(ctx)
+
+	delta, _, err := reopened.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), delta, "изменения после переноса дописываются в журнал")
+
+	value, _, err := reopened.GetGauge(ctx, "Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, value)
+}
+
+// partialWriter Записывает первые n байт и возвращает ошибку, как при нехватке места на диске
+type partialWriter struct {
+	file *os.File
+	n    int
+}
+
+func (w *partialWriter) Write(p []byte) (int, error) {
+
+	n, err := w.file.Write(p[:min(w.n, len(p))])
+	if err != nil {
+		return n, err
+	}
+
+	return n, errors.New("no space left on device")
+}
+
+func TestRepository_AppendError(t *testing.T) {
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "store.txt")
+
+	r, err := New(ctx, path, true, false)
+	require.NoError(t, err)
+	require.NoError(t, r.UpdateGauge(ctx, "Alloc", 1.5))
+
+	r.writer = bufio.NewWriter(&partialWriter{file: r.file, n: 10})
+	assert.Error(t, r.UpdateCounter(ctx, "PollCount", 5))
+
+	_, exist, err := r.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.False(t, exist, "при ошибке записи состояние не меняется")
+
+	require.NoError(t, r.UpdateCounter(ctx, "PollCount", 2), "после ошибки запись продолжается")
+
+	// без Close, как при падении процесса
+	reopened, err := New(ctx, path, true, false)
+	require.NoError(t, err, "в журнале нет части строки")
+	defer reopened.Close(ctx)
+
+	delta, _, err := reopened.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), delta)
+
+	value, _, err := reopened.GetGauge(ctx, "Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, value)
+}
+
+func TestRepository_UpdateAndReopen(t *testing.T) {
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "store.txt")
+
+	r, err := New(ctx, path, true, true)
+	require.NoError(t, err)
+
+	require.NoError(t, r.UpdateGauge(ctx, "Alloc", 1.23))
+	require.NoError(t, r.UpdateCounter(ctx, "PollCount", 2))
+	require.NoError(t, r.UpdateCounter(ctx, "PollCount", 3))
+
+	// без Close, как при падении процесса
+	reopened, err := New(ctx, path, true, false)
+	require.NoError(t, err)
+
+	value, exist, err := reopened.GetGauge(ctx, "Alloc")
+	require.NoError(t, err)
+	assert.True(t, exist)
+	assert.Equal(t, 1.23, value)
+
+	delta, exist, err := reopened.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.True(t, exist)
+	assert.Equal(t, int64(5), delta)
+
+	require.NoError(t, reopened.Close(ctx))
+}
+
+func TestRepository_CompactionKeepsTriggeringUpdate(t *testing.T) {
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "store.txt")
+
+	r, err := New(ctx, path, true, false)
+	require.NoError(t, err)
+	r.compactionThreshold = 5
+
+	for i := 0; i < r.compactionThreshold; i++ {
+		require.NoError(t, r.UpdateCounter(ctx, "PollCount", 1))
+	}
+	defer r.Close(ctx)
+
+	// журнал читается без Close, как после падения процесса: Close сам записал бы состояние
+	reopened, err := New(ctx, path, true, false)
+	require.NoError(t, err)
+	defer reopened.Close(ctx)
+
+	value, _, err := reopened.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), value, "обновление, вызвавшее сжатие, попадает в снимок")
+}
+
+func TestRepository_Compaction(t *testing.T) {
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "store.txt")
+
+	r, err := New(ctx, path, true, false)
+	require.NoError(t, err)
+	r.compactionThreshold = 5
+
+	for i := 0; i < 12; i++ {
+		require.NoError(t, r.UpdateCounter(ctx, "PollCount", 1))
+	}
+
+	assert.Less(t, r.records, r.compactionThreshold)
+
+	var value = 1.5
+	var delta int64 = 4
+	count, err := r.ReloadAllMetrics(ctx, []models.StorageMetrics{
+		{MType: "gauge", Name: "Alloc", Value: &value},
+		{MType: "counter", Name: "someMetric", Delta: &delta},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+	assert.Equal(t, 2, r.records)
+
+	defer r.Close(ctx)
+
+	// журнал читается без Close, как после падения процесса: Close сам записал бы состояние
+	reopened, err := New(ctx, path, true, false)
+	require.NoError(t, err)
+	defer reopened.Close(ctx)
+
+	counters, err := reopened.GetAllCounters(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"someMetric": 4}, counters)
+}
+
+func TestRepository_DistributionsReopen(t *testing.T) {
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "store.txt")
+
+	r, err := New(ctx, path, true, false)
+	require.NoError(t, err)
+
+	for _, value := range []float64{0.5, 1.5} {
+		h := models.NewHistogram([]float64{1, 2})
+		h.Observe(value)
+		require.NoError(t, r.UpdateHistogram(ctx, "Latency", h))
+	}
+	assert.Error(t, r.UpdateHistogram(ctx, "Latency", models.NewHistogram([]float64{5})))
+
+	summary := models.Summary{Count: 1, Sum: 2, Quantiles: []models.Quantile{{Quantile: 0.5, Value: 2}}}
+	require.NoError(t, r.UpdateSummary(ctx, "Latency", summary))
+
+	// без Close, как при падении процесса
+	reopened, err := New(ctx, path, true, false)
+	require.NoError(t, err)
+
+	h, exist, err := reopened.GetHistogram(ctx, "Latency")
+	require.NoError(t, err)
+	assert.True(t, exist)
+	assert.Equal(t, []uint64{1, 1, 0}, h.Counts)
+	assert.Equal(t, 2.0, h.Sum)
+
+	got, exist, err := reopened.GetSummary(ctx, "Latency")
+	require.NoError(t, err)
+	assert.True(t, exist)
+	assert.Equal(t, summary, got)
+
+	require.NoError(t, reopened.Close(ctx))
+	require.NoError(t, r.Close(ctx))
+}
+
+func TestRepository_DeleteAndReopen(t *testing.T) {
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "store.txt")
+
+	r, err := New(ctx, path, true, false)
+	require.NoError(t, err)
+
+	require.NoError(t, r.UpdateGauge(ctx, "CPUutilization1", 1))
+	require.NoError(t, r.UpdateGauge(ctx, "CPUutilization2", 2))
+
+	deleted, err := r.DeleteMetrics(ctx, "gauge", []string{"CPUutilization1", "Unknown"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	_, err = r.DeleteMetrics(ctx, "unknown", []string{"CPUutilization2"})
+	assert.Error(t, err)
+
+	// без Close, как при падении процесса
+	reopened, err := New(ctx, path, true, false)
+	require.NoError(t, err)
+
+	gauges, err := reopened.GetAllGauges(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"CPUutilization2": 2}, gauges)
+
+	require.NoError(t, reopened.Close(ctx))
+	require.NoError(t, r.Close(ctx))
+}
+
+func TestRepository_UpdatedReopen(t *testing.T) {
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "store.txt")
+
+	r, err := New(ctx, path, true, false)
+	require.NoError(t, err)
+
+	require.NoError(t, r.UpdateGauge(ctx, "FreeMemory", 1))
+	updated, exist, err := r.GetUpdated(ctx, "gauge", "FreeMemory")
+	require.NoError(t, err)
+	require.True(t, exist)
+
+	// время обновления восстанавливается из журнала, а не становится временем запуска
+	time.Sleep(10 * time.Millisecond)
+	reopened, err := New(ctx, path, true, false)
+	require.NoError(t, err)
+
+	got, exist, err := reopened.GetUpdated(ctx, "gauge", "FreeMemory")
+	require.NoError(t, err)
+	assert.True(t, exist)
+	assert.True(t, updated.Equal(got))
+
+	_, err = reopened.DeleteMetrics(ctx, "gauge", []string{"FreeMemory"})
+	require.NoError(t, err)
+
+	all, err := reopened.GetAllUpdated(ctx, "gauge")
+	require.NoError(t, err)
+	assert.Empty(t, all)
+
+	require.NoError(t, reopened.Close(ctx))
+	require.NoError(t, r.Close(ctx))
+}
+
+func TestRepository_TenantsReopen(t *testing.T) {
+
+	ctx := context.Background()
+	ctxA := tenant.WithTenant(ctx, "team-a")
+	path := filepath.Join(t.TempDir(), "store.txt")
+
+	r, err := New(ctx, path, true, false)
+	require.NoError(t, err)
+	r.compactionThreshold = 4
+
+	require.NoError(t, r.UpdateGauge(ctx, "Alloc", 1))
+	require.NoError(t, r.UpdateGauge(ctxA, "Alloc", 2))
+	require.NoError(t, r.UpdateCounter(ctxA, "PollCount", 3))
+
+	_, err = r.DeleteMetrics(ctx, "counter", []string{"PollCount"})
+	require.NoError(t, err)
+
+	// после сжатия журнала арендатор записей сохраняется
+	require.NoError(t, r.UpdateGauge(ctxA, "FreeMemory", 4))
+	defer r.Close(ctx)
+
+	// журнал читается без Close, как после падения процесса: Close сам записал бы состояние
+	reopened, err := New(ctx, path, true, false)
+	require.NoError(t, err)
+	defer reopened.Close(ctx)
+
+	gauges, err := reopened.GetAllGauges(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"Alloc": 1}, gauges)
+
+	gauges, err = reopened.GetAllGauges(ctxA)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"Alloc": 2, "FreeMemory": 4}, gauges)
+
+	counters, err := reopened.GetAllCounters(ctxA)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"PollCount": 3}, counters, "удаление у арендатора по умолчанию не затрагивает других")
+}
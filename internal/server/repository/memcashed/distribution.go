@@ -0,0 +1,84 @@
+package memcashed
+
+import (
+	"context"
+	"time"
+
+	"github.com/s-turchinskiy/metrics/internal/server/models"
+)
+
+func (m *MemCashed) UpdateHistogram(ctx context.Context, metricsName string, delta models.Histogram) error {
+	m = m.namespace(ctx)
+
+	if m.Histogram == nil {
+		m.Histogram = make(map[string]models.Histogram)
+	}
+
+	current, exist := m.Histogram[metricsName]
+	if !exist {
+		m.Histogram[metricsName] = delta.Clone()
+		m.Updated.set("histogram", metricsName, time.Now())
+		return nil
+	}
+
+	// гистограмма изменяется только при успешном сложении
+	current = current.Clone()
+	if err := current.Merge(delta); err != nil {
+		return err
+	}
+	m.Histogram[metricsName] = current
+	m.Updated.set("histogram", metricsName, time.Now())
+
+	return nil
+}
+
+func (m *MemCashed) GetHistogram(ctx context.Context, metricsName string) (models.Histogram, bool, error) {
+	m = m.view(ctx)
+
+	h, exist := m.Histogram[metricsName]
+	if !exist {
+		return models.Histogram{}, false, nil
+	}
+	return h.Clone(), true, nil
+}
+
+func (m *MemCashed) GetAllHistograms(ctx context.Context) (map[string]models.Histogram, error) {
+	m = m.view(ctx)
+
+	result := make(map[string]models.Histogram, len(m.Histogram))
+	for name, h := range m.Histogram {
+		result[name] = h.Clone()
+	}
+	return result, nil
+}
+
+func (m *MemCashed) UpdateSummary(ctx context.Context, metricsName string, summary models.Summary) error {
+	m = m.namespace(ctx)
+
+	if m.Summary == nil {
+		m.Summary = make(map[string]models.Summary)
+	}
+
+	summary.Quantiles = append([]models.Quantile(nil), summary.Quantiles...)
+	m.Summary[metricsName] = summary
+	m.Updated.set("summary", metricsName, time.Now())
+
+	return nil
+}
+
+func (m *MemCashed) GetSummary(ctx context.Context, metricsName string) (models.Summary, bool, error) {
+	m = m.view(ctx)
+
+	s, exist := m.Summary[metricsName]
+	return s, exist, nil
+}
+
+func (m *MemCashed) GetAllSummaries(ctx context.Context) (map[string]models.Summary, error) {
+	m = m.view(ctx)
+
+	result := make(map[string]models.Summary, len(m.Summary))
+	for name, s := range m.Summary {
+		result[name] = s
+	}
+	return result, nil
+}
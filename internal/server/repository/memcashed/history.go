@@ -0,0 +1,263 @@
+package memcashed
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/s-turchinskiy/metrics/internal/server/models"
+)
+
+// History История значений метрик в порядке поступления и агрегаты по интервалам
+type History struct {
+	Gauge      map[string][]models.HistorySample
+	Counter    map[string][]models.HistorySample
+	Rollups    map[RollupKey][]models.RollupSample
+	Watermarks map[time.Duration]time.Time // до какого момента значения уже агрегированы в интервалы
+}
+
+type RollupKey struct {
+	MType      string
+	Name       string
+	Resolution time.Duration
+}
+
+func NewHistory() *History {
+	return &History{
+		Gauge:      make(map[string][]models.HistorySample),
+		Counter:    make(map[string][]models.HistorySample),
+		Rollups:    make(map[RollupKey][]models.RollupSample),
+		Watermarks: make(map[time.Duration]time.Time),
+	}
+}
+
+func (h *History) series(metricsType string) (map[string][]models.HistorySample, error) {
+
+	switch metricsType {
+	case "gauge":
+		return h.Gauge, nil
+	case "counter":
+		return h.Counter, nil
+	default:
+		return nil, fmt.Errorf("unclown MType %s", metricsType)
+	}
+}
+
+// add Добавление значения, при h == nil история не ведется
+func (h *History) add(metricsType, metricsName string, sample models.HistorySample) {
+
+	if h == nil {
+		return
+	}
+
+	series, err := h.series(metricsType)
+	if err != nil {
+		return
+	}
+
+	series[metricsName] = append(series[metricsName], sample)
+}
+
+// get Значения метрики в интервале [from, to]
+func (h *History) get(metricsType, metricsName string, from, to time.Time) ([]models.HistorySample, error) {
+
+	if h == nil {
+		return []models.HistorySample{}, nil
+	}
+
+	series, err := h.series(metricsType)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := series[metricsName]
+
+	start := sort.Search(len(samples), func(i int) bool {
+		return !samples[i].Timestamp.Before(from)
+	})
+	end := sort.Search(len(samples), func(i int) bool {
+		return samples[i].Timestamp.After(to)
+	})
+
+	if start >= end {
+		return []models.HistorySample{}, nil
+	}
+
+	result := make([]models.HistorySample, end-start)
+	copy(result, samples[start:end])
+
+	return result, nil
+}
+
+// getRollups Агрегаты метрики с интервалом resolution, начинающиеся в [from, to]
+func (h *History) getRollups(metricsType, metricsName string, resolution time.Duration, from, to time.Time) ([]models.RollupSample, error) {
+
+	if h == nil {
+		return []models.RollupSample{}, nil
+	}
+
+	if _, err := h.series(metricsType); err != nil {
+		return nil, err
+	}
+
+	rollups := h.Rollups[RollupKey{MType: metricsType, Name: metricsName, Resolution: resolution}]
+
+	start := sort.Search(len(rollups), func(i int) bool {
+		return !rollups[i].Timestamp.Before(from)
+	})
+	end := sort.Search(len(rollups), func(i int) bool {
+		return rollups[i].Timestamp.After(to)
+	})
+
+	if start >= end {
+		return []models.RollupSample{}, nil
+	}
+
+	result := make([]models.RollupSample, end-start)
+	copy(result, rollups[start:end])
+
+	return result, nil
+}
+
+// compact Агрегация значений в интервалы по правилам policy и удаление устаревших значений
+func (h *History) compact(policy models.RetentionPolicy, now time.Time) {
+
+	if h == nil || !policy.Enabled() {
+		return
+	}
+
+	for level, rule := range policy.Rollups {
+
+		since := h.Watermarks[rule.Resolution]
+		until := models.BucketStart(now, rule.Resolution)
+		if !since.Before(until) {
+			continue
+		}
+
+		if level == 0 {
+			h.rollupSamples("gauge", h.Gauge, rule.Resolution, since, until)
+			h.rollupSamples("counter", h.Counter, rule.Resolution, since, until)
+		} else {
+			h.rollupRollups(policy.Rollups[level-1].Resolution, rule.Resolution, since, until)
+		}
+
+		h.Watermarks[rule.Resolution] = until
+	}
+
+	rawCutoff := policy.RawCutoff(now, h.Watermarks)
+	trimSamples(h.Gauge, rawCutoff)
+	trimSamples(h.Counter, rawCutoff)
+
+	for level, rule := range policy.Rollups {
+		cutoff := policy.RollupCutoff(level, now, h.Watermarks)
+		for key, rollups := range h.Rollups {
+			if key.Resolution != rule.Resolution {
+				continue
+			}
+
+			start := sort.Search(len(rollups), func(i int) bool {
+				return !rollups[i].Timestamp.Before(cutoff)
+			})
+			if start == len(rollups) {
+				delete(h.Rollups, key)
+			} else if start > 0 {
+				h.Rollups[key] = append([]models.RollupSample(nil), rollups[start:]...)
+			}
+		}
+	}
+}
+
+func (h *History) rollupSamples(metricsType string, series map[string][]models.HistorySample, resolution time.Duration, since, until time.Time) {
+
+	for name, samples := range series {
+
+		key := RollupKey{MType: metricsType, Name: name, Resolution: resolution}
+
+		var current *models.RollupSample
+		for _, sample := range samples {
+			if sample.Timestamp.Before(since) {
+				continue
+			}
+			if !sample.Timestamp.Before(until) {
+				break
+			}
+
+			value := sample.Value
+			if sample.Delta != nil {
+				value = float64(*sample.Delta)
+			}
+
+			bucket := models.BucketStart(sample.Timestamp, resolution)
+			if current == nil || !current.Timestamp.Equal(bucket) {
+				h.appendRollup(key, current)
+				current = &models.RollupSample{Timestamp: bucket, Min: value, Max: value}
+			}
+
+			current.Min = min(current.Min, value)
+			current.Max = max(current.Max, value)
+			current.Sum += value
+			current.Last = sample.Value
+			current.Count++
+		}
+		h.appendRollup(key, current)
+	}
+}
+
+func (h *History) rollupRollups(sourceResolution, resolution time.Duration, since, until time.Time) {
+
+	for sourceKey, rollups := range h.Rollups {
+
+		if sourceKey.Resolution != sourceResolution {
+			continue
+		}
+
+		key := RollupKey{MType: sourceKey.MType, Name: sourceKey.Name, Resolution: resolution}
+
+		var current *models.RollupSample
+		for _, rollup := range rollups {
+			if rollup.Timestamp.Before(since) {
+				continue
+			}
+			if !rollup.Timestamp.Before(until) {
+				break
+			}
+
+			bucket := models.BucketStart(rollup.Timestamp, resolution)
+			if current == nil || !current.Timestamp.Equal(bucket) {
+				h.appendRollup(key, current)
+				current = &models.RollupSample{Timestamp: bucket, Min: rollup.Min, Max: rollup.Max}
+			}
+
+			current.Min = min(current.Min, rollup.Min)
+			current.Max = max(current.Max, rollup.Max)
+			current.Sum += rollup.Sum
+			current.Last = rollup.Last
+			current.Count += rollup.Count
+		}
+		h.appendRollup(key, current)
+	}
+}
+
+func (h *History) appendRollup(key RollupKey, rollup *models.RollupSample) {
+
+	if rollup == nil {
+		return
+	}
+
+	rollup.Avg = rollup.Sum / float64(rollup.Count)
+	h.Rollups[key] = append(h.Rollups[key], *rollup)
+}
+
+func trimSamples(series map[string][]models.HistorySample, cutoff time.Time) {
+
+	for name, samples := range series {
+		start := sort.Search(len(samples), func(i int) bool {
+			return !samples[i].Timestamp.Before(cutoff)
+		})
+		if start == len(samples) {
+			delete(series, name)
+		} else if start > 0 {
+			series[name] = append([]models.HistorySample(nil), samples[start:]...)
+		}
+	}
+}
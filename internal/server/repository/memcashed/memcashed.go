@@ -5,19 +5,43 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/s-turchinskiy/metrics/internal/server/models"
 )
 
 type MemCashed struct {
-	Gauge   map[string]float64
-	Counter map[string]int64
+	Gauge     map[string]float64
+	Counter   map[string]int64
+	Histogram map[string]models.Histogram
+	Summary   map[string]models.Summary
+	History   *History
+	Updated   Updated
+	// Tenants Хранилища арендаторов, данные арендатора по умолчанию хранятся в самом MemCashed
+	Tenants map[string]*MemCashed
+
+	namespaced bool
+}
+
+// New Хранилище с пустыми метриками, включенной историей значений и временем обновления серий
+func New() *MemCashed {
+	return &MemCashed{
+		Gauge:     make(map[string]float64),
+		Counter:   make(map[string]int64),
+		Histogram: make(map[string]models.Histogram),
+		Summary:   make(map[string]models.Summary),
+		History:   NewHistory(),
+		Updated:   NewUpdated(),
+	}
 }
 
 func (m *MemCashed) ReloadAllMetrics(ctx context.Context, metrics []models.StorageMetrics) (int64, error) {
+	m = m.namespace(ctx)
 
 	m.Gauge = make(map[string]float64)
 	m.Counter = make(map[string]int64)
+	m.Updated.reset("gauge")
+	m.Updated.reset("counter")
 
 	var errs []error
 
@@ -43,50 +67,110 @@ func (m *MemCashed) ReloadAllMetrics(ctx context.Context, metrics []models.Stora
 	return int64(len(m.Gauge) + len(m.Counter)), errors.Join(errs...)
 }
 
+func (m *MemCashed) DeleteMetrics(ctx context.Context, metricsType string, metricsNames []string) (int64, error) {
+	m = m.namespace(ctx)
+
+	var deleted int64
+	for _, metricsName := range metricsNames {
+
+		var exist bool
+		switch metricsType {
+		case "gauge":
+			_, exist = m.Gauge[metricsName]
+			delete(m.Gauge, metricsName)
+		case "counter":
+			_, exist = m.Counter[metricsName]
+			delete(m.Counter, metricsName)
+		case "histogram":
+			_, exist = m.Histogram[metricsName]
+			delete(m.Histogram, metricsName)
+		case "summary":
+			_, exist = m.Summary[metricsName]
+			delete(m.Summary, metricsName)
+		default:
+			return deleted, fmt.Errorf("unclown MType %s", metricsType)
+		}
+
+		if exist {
+			m.Updated.remove(metricsType, metricsName)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
 func (m *MemCashed) Ping(ctx context.Context) ([]byte, error) {
 	return nil, nil
 }
 
 func (m *MemCashed) ReloadAllGauges(ctx context.Context, newValue map[string]float64) error {
+	m = m.namespace(ctx)
+
 	m.Gauge = newValue
+
+	now := time.Now()
+	m.Updated.reset("gauge")
+	for name := range newValue {
+		m.Updated.set("gauge", name, now)
+	}
 	return nil
 }
 
 func (m *MemCashed) ReloadAllCounters(ctx context.Context, newValue map[string]int64) error {
+	m = m.namespace(ctx)
+
 	m.Counter = newValue
+
+	now := time.Now()
+	m.Updated.reset("counter")
+	for name := range newValue {
+		m.Updated.set("counter", name, now)
+	}
 	return nil
 }
 
 func (m *MemCashed) GetAllGauges(ctx context.Context) (map[string]float64, error) {
+	m = m.view(ctx)
 
 	return m.Gauge, nil
 }
 
 func (m *MemCashed) GetAllCounters(ctx context.Context) (map[string]int64, error) {
+	m = m.view(ctx)
 
 	return m.Counter, nil
 
 }
 
 func (m *MemCashed) GetGauge(ctx context.Context, metricsName string) (float64, bool, error) {
+	m = m.view(ctx)
+
 	v, exist := m.Gauge[metricsName]
 	return v, exist, nil
 }
 
 func (m *MemCashed) GetCounter(ctx context.Context, metricsName string) (int64, bool, error) {
+	m = m.view(ctx)
+
 	v, exist := m.Counter[metricsName]
 	return v, exist, nil
 }
 
 func (m *MemCashed) CountGauges(ctx context.Context) int {
+	m = m.view(ctx)
+
 	return len(m.Gauge)
 }
 
 func (m *MemCashed) CountCounters(ctx context.Context) int {
+	m = m.view(ctx)
+
 	return len(m.Counter)
 }
 
 func (m *MemCashed) UpdateCounter(ctx context.Context, metricsName string, delta int64) error {
+	m = m.namespace(ctx)
 
 	_, exist, err := m.GetCounter(ctx, metricsName)
 	if err != nil {
@@ -99,17 +183,48 @@ func (m *MemCashed) UpdateCounter(ctx context.Context, metricsName string, delta
 		m.Counter[metricsName] += delta
 	}
 
+	now := time.Now()
+	m.Updated.set("counter", metricsName, now)
+	m.History.add("counter", metricsName, models.HistorySample{
+		Timestamp: now,
+		Value:     float64(m.Counter[metricsName]),
+		Delta:     &delta,
+	})
+
 	return nil
 
 }
 
 func (m *MemCashed) UpdateGauge(ctx context.Context, metricsName string, newValue float64) error {
+	m = m.namespace(ctx)
 
+	now := time.Now()
 	m.Gauge[metricsName] = newValue
+	m.Updated.set("gauge", metricsName, now)
+	m.History.add("gauge", metricsName, models.HistorySample{Timestamp: now, Value: newValue})
 	return nil
 
 }
 
+func (m *MemCashed) GetHistory(ctx context.Context, metricsType, metricsName string, from, to time.Time) ([]models.HistorySample, error) {
+	m = m.view(ctx)
+
+	return m.History.get(metricsType, metricsName, from, to)
+}
+
+func (m *MemCashed) GetHistoryRollups(ctx context.Context, metricsType, metricsName string, resolution time.Duration, from, to time.Time) ([]models.RollupSample, error) {
+	m = m.view(ctx)
+
+	return m.History.getRollups(metricsType, metricsName, resolution, from, to)
+}
+
+func (m *MemCashed) CompactHistory(ctx context.Context, policy models.RetentionPolicy, now time.Time) error {
+	for _, ns := range m.Namespaces() {
+		ns.History.compact(policy, now)
+	}
+	return nil
+}
+
 func (m *MemCashed) Close(ctx context.Context) error {
 	return nil
 }
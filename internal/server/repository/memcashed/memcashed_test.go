@@ -3,9 +3,12 @@ package memcashed
 import (
 	"context"
 	"github.com/s-turchinskiy/metrics/internal/server/models"
+	"github.com/s-turchinskiy/metrics/internal/server/tenant"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestMemCashed_CountCounters(t *testing.T) {
@@ -455,3 +458,270 @@ func TestMemCashed_UpdateGauge(t *testing.T) {
 		})
 	}
 }
+
+func TestMemCashed_GetHistory(t *testing.T) {
+
+	ctx := context.Background()
+
+	m := New()
+	start := time.Now()
+	assert.NoError(t, m.UpdateGauge(ctx, "name", 1))
+	assert.NoError(t, m.UpdateGauge(ctx, "name", 2))
+	assert.NoError(t, m.UpdateCounter(ctx, "name", 3))
+	assert.NoError(t, m.UpdateCounter(ctx, "name", 4))
+	middle := time.Now()
+	assert.NoError(t, m.UpdateGauge(ctx, "name", 5))
+
+	type args struct {
+		metricsType string
+		from        time.Time
+		to          time.Time
+	}
+	tests := []struct {
+		name       string
+		args       args
+		wantValues []float64
+		wantDeltas []int64
+		wantErr    bool
+	}{
+		{
+			name:       "Вся история Gauge",
+			args:       args{metricsType: "gauge", from: start, to: time.Now()},
+			wantValues: []float64{1, 2, 5},
+		},
+		{
+			name:       "История Gauge за интервал",
+			args:       args{metricsType: "gauge", from: start, to: middle},
+			wantValues: []float64{1, 2},
+		},
+		{
+			name:       "История Counter с накопленным значением",
+			args:       args{metricsType: "counter", from: start, to: time.Now()},
+			wantValues: []float64{3, 7},
+			wantDeltas: []int64{3, 4},
+		},
+		{
+			name:       "Пустой интервал",
+			args:       args{metricsType: "gauge", from: time.Now().Add(time.Hour), to: time.Now().Add(2 * time.Hour)},
+			wantValues: []float64{},
+		},
+		{
+			name:    "Неизвестный тип",
+			args:    args{metricsType: "error_type", from: start, to: time.Now()},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := m.GetHistory(ctx, tt.args.metricsType, "name", tt.args.from, tt.args.to)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			values := make([]float64, 0, len(got))
+			for i, sample := range got {
+				values = append(values, sample.Value)
+				if tt.wantDeltas != nil {
+					assert.Equal(t, tt.wantDeltas[i], *sample.Delta)
+				}
+			}
+			assert.Equal(t, tt.wantValues, values)
+		})
+	}
+}
+
+func TestMemCashed_HistoryDisabled(t *testing.T) {
+
+	ctx := context.Background()
+	m := &MemCashed{
+		Gauge:   make(map[string]float64),
+		Counter: make(map[string]int64),
+	}
+
+	assert.NoError(t, m.UpdateGauge(ctx, "name", 1))
+
+	got, err := m.GetHistory(ctx, "gauge", "name", time.Time{}, time.Now())
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func TestMemCashed_CompactHistory(t *testing.T) {
+
+	ctx := context.Background()
+	base := time.Date(2025, 10, 6, 15, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) time.Time { return base.Add(d) }
+	delta := func(d int64) *int64 { return &d }
+
+	m := New()
+	m.History.Gauge["name"] = []models.HistorySample{
+		{Timestamp: at(10 * time.Second), Value: 1},
+		{Timestamp: at(20 * time.Second), Value: 5},
+		{Timestamp: at(30 * time.Second), Value: 3},
+		{Timestamp: at(70 * time.Second), Value: 7},
+		{Timestamp: at(130 * time.Second), Value: 9},
+	}
+	m.History.Counter["name"] = []models.HistorySample{
+		{Timestamp: at(10 * time.Second), Value: 2, Delta: delta(2)},
+		{Timestamp: at(20 * time.Second), Value: 6, Delta: delta(4)},
+	}
+
+	policy, err := models.ParseRetentionPolicy("raw:1m,1m:5m,2m:1h")
+	require.NoError(t, err)
+
+	now := at(150 * time.Second)
+	require.NoError(t, m.CompactHistory(ctx, policy, now))
+
+	gauges, err := m.GetHistoryRollups(ctx, "gauge", "name", time.Minute, time.Time{}, now)
+	require.NoError(t, err)
+	assert.Equal(t, []models.RollupSample{
+		{Timestamp: at(0).Local(), Min: 1, Max: 5, Avg: 3, Sum: 9, Last: 3, Count: 3},
+		{Timestamp: at(time.Minute).Local(), Min: 7, Max: 7, Avg: 7, Sum: 7, Last: 7, Count: 1},
+	}, gauges)
+
+	counters, err := m.GetHistoryRollups(ctx, "counter", "name", time.Minute, time.Time{}, now)
+	require.NoError(t, err)
+	assert.Equal(t, []models.RollupSample{
+		{Timestamp: at(0).Local(), Min: 2, Max: 4, Avg: 3, Sum: 6, Last: 6, Count: 2},
+	}, counters)
+
+	gauges, err = m.GetHistoryRollups(ctx, "gauge", "name", 2*time.Minute, time.Time{}, now)
+	require.NoError(t, err)
+	assert.Equal(t, []models.RollupSample{
+		{Timestamp: at(0).Local(), Min: 1, Max: 7, Avg: 4, Sum: 16, Last: 7, Count: 4},
+	}, gauges)
+
+	// исходные значения старше минуты удалены, последнее еще не попало в агрегаты
+	raw, err := m.GetHistory(ctx, "gauge", "name", time.Time{}, now)
+	require.NoError(t, err)
+	assert.Len(t, raw, 1)
+	assert.Equal(t, 9.0, raw[0].Value)
+
+	// агрегаты по минутам хранятся 5 минут
+	require.NoError(t, m.CompactHistory(ctx, policy, at(10*time.Minute)))
+	gauges, err = m.GetHistoryRollups(ctx, "gauge", "name", time.Minute, time.Time{}, at(10*time.Minute))
+	require.NoError(t, err)
+	assert.Len(t, gauges, 0)
+
+	gauges, err = m.GetHistoryRollups(ctx, "gauge", "name", 2*time.Minute, time.Time{}, at(10*time.Minute))
+	require.NoError(t, err)
+	assert.Len(t, gauges, 2)
+}
+
+func TestMemCashed_UpdateHistogram(t *testing.T) {
+
+	ctx := context.Background()
+	m := New()
+
+	for _, value := range []float64{0.5, 3} {
+		h := models.NewHistogram([]float64{1, 2})
+		h.Observe(value)
+		require.NoError(t, m.UpdateHistogram(ctx, "Latency", h))
+	}
+
+	got, exist, err := m.GetHistogram(ctx, "Latency")
+	require.NoError(t, err)
+	assert.True(t, exist)
+	assert.Equal(t, []uint64{1, 0, 1}, got.Counts)
+	assert.Equal(t, uint64(2), got.Count)
+
+	got.Counts[0] = 100
+	stored, _, err := m.GetHistogram(ctx, "Latency")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), stored.Counts[0], "возвращается копия")
+
+	err = m.UpdateHistogram(ctx, "Latency", models.NewHistogram([]float64{1, 5}))
+	assert.Error(t, err, "другие границы корзин")
+
+	summary := models.Summary{Count: 2, Sum: 3, Quantiles: []models.Quantile{{Quantile: 0.5, Value: 1}}}
+	require.NoError(t, m.UpdateSummary(ctx, "Latency", summary))
+
+	summaries, err := m.GetAllSummaries(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]models.Summary{"Latency": summary}, summaries)
+}
+
+func TestMemCashed_DeleteMetrics(t *testing.T) {
+
+	ctx := context.Background()
+	m := New()
+	require.NoError(t, m.UpdateGauge(ctx, "CPUutilization1", 1))
+	require.NoError(t, m.UpdateGauge(ctx, "CPUutilization2", 2))
+	require.NoError(t, m.UpdateCounter(ctx, "CPUutilization1", 1))
+
+	deleted, err := m.DeleteMetrics(ctx, "gauge", []string{"CPUutilization1", "CPUutilization3"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	assert.Equal(t, map[string]float64{"CPUutilization2": 2}, m.Gauge)
+	assert.Equal(t, map[string]int64{"CPUutilization1": 1}, m.Counter, "другие типы не затрагиваются")
+
+	_, err = m.DeleteMetrics(ctx, "unknown", []string{"CPUutilization2"})
+	assert.Error(t, err)
+}
+
+func TestMemCashed_Updated(t *testing.T) {
+
+	ctx := context.Background()
+	m := New()
+
+	before := time.Now()
+	require.NoError(t, m.UpdateGauge(ctx, "FreeMemory", 1))
+	require.NoError(t, m.UpdateCounter(ctx, "PollCount", 1))
+
+	updated, exist, err := m.GetUpdated(ctx, "gauge", "FreeMemory")
+	require.NoError(t, err)
+	assert.True(t, exist)
+	assert.False(t, updated.Before(before))
+
+	_, exist, err = m.GetUpdated(ctx, "gauge", "PollCount")
+	require.NoError(t, err)
+	assert.False(t, exist, "время ведется отдельно по типам")
+
+	_, err = m.ReloadAllMetrics(ctx, nil)
+	require.NoError(t, err)
+
+	all, err := m.GetAllUpdated(ctx, "gauge")
+	require.NoError(t, err)
+	assert.Empty(t, all)
+
+	// без Updated время обновления не отслеживается
+	m = &MemCashed{Gauge: make(map[string]float64)}
+	require.NoError(t, m.UpdateGauge(ctx, "FreeMemory", 1))
+	_, exist, err = m.GetUpdated(ctx, "gauge", "FreeMemory")
+	require.NoError(t, err)
+	assert.False(t, exist)
+}
+
+func TestMemCashed_Tenants(t *testing.T) {
+
+	ctx := context.Background()
+	ctxA := tenant.WithTenant(ctx, "team-a")
+	ctxB := tenant.WithTenant(ctx, "team-b")
+	m := New()
+
+	require.NoError(t, m.UpdateGauge(ctx, "Alloc", 1))
+	require.NoError(t, m.UpdateGauge(ctxA, "Alloc", 2))
+	require.NoError(t, m.UpdateCounter(ctxA, "PollCount", 3))
+
+	value, exist, err := m.GetGauge(ctxA, "Alloc")
+	require.NoError(t, err)
+	assert.True(t, exist)
+	assert.Equal(t, 2.0, value)
+
+	assert.Equal(t, map[string]float64{"Alloc": 1}, m.Gauge, "арендатор по умолчанию хранится в корне")
+	assert.Equal(t, 1, m.CountCounters(ctxA))
+	assert.Equal(t, 0, m.CountCounters(ctx))
+
+	gauges, err := m.GetAllGauges(ctxB)
+	require.NoError(t, err)
+	assert.Empty(t, gauges)
+	assert.NotContains(t, m.Tenants, "team-b", "чтение не создает пространство арендатора")
+
+	_, err = m.ReloadAllMetrics(ctxA, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, m.CountGauges(ctxA))
+	assert.Equal(t, 1, m.CountGauges(ctx), "перезагрузка не затрагивает других арендаторов")
+
+	assert.Len(t, m.Namespaces(), 2)
+}
@@ -0,0 +1,83 @@
+package memcashed
+
+import (
+	"context"
+	"sort"
+
+	"github.com/s-turchinskiy/metrics/internal/server/models"
+	"github.com/s-turchinskiy/metrics/internal/server/tenant"
+)
+
+// namespace Хранилище арендатора из ctx, создается при первой записи.
+// Данные арендатора по умолчанию хранятся в самом MemCashed
+func (m *MemCashed) namespace(ctx context.Context) *MemCashed {
+
+	id := tenant.FromContext(ctx)
+	if m.namespaced || id == tenant.Default {
+		return m
+	}
+
+	ns, exist := m.Tenants[id]
+	if exist {
+		return ns
+	}
+
+	ns = &MemCashed{
+		Gauge:      make(map[string]float64),
+		Counter:    make(map[string]int64),
+		Histogram:  make(map[string]models.Histogram),
+		Summary:    make(map[string]models.Summary),
+		namespaced: true,
+	}
+	if m.History != nil {
+		ns.History = NewHistory()
+	}
+	if m.Updated != nil {
+		ns.Updated = NewUpdated()
+	}
+
+	if m.Tenants == nil {
+		m.Tenants = make(map[string]*MemCashed)
+	}
+	m.Tenants[id] = ns
+
+	return ns
+}
+
+// view Хранилище арендатора из ctx для чтения, для арендатора без данных - пустое
+func (m *MemCashed) view(ctx context.Context) *MemCashed {
+
+	id := tenant.FromContext(ctx)
+	if m.namespaced || id == tenant.Default {
+		return m
+	}
+
+	if ns, exist := m.Tenants[id]; exist {
+		return ns
+	}
+
+	return &MemCashed{namespaced: true}
+}
+
+// Namespaces Хранилища всех арендаторов, включая арендатора по умолчанию
+func (m *MemCashed) Namespaces() map[string]*MemCashed {
+
+	result := make(map[string]*MemCashed, len(m.Tenants)+1)
+	result[tenant.Default] = m
+	for id, ns := range m.Tenants {
+		result[id] = ns
+	}
+
+	return result
+}
+
+func (m *MemCashed) TenantIDs(ctx context.Context) ([]string, error) {
+
+	result := make([]string, 0, len(m.Tenants)+1)
+	for id := range m.Namespaces() {
+		result = append(result, id)
+	}
+	sort.Strings(result)
+
+	return result, nil
+}
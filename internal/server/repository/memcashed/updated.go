@@ -0,0 +1,60 @@
+package memcashed
+
+import (
+	"context"
+	"time"
+)
+
+// Updated Время последнего обновления серий по типам метрик
+type Updated map[string]map[string]time.Time
+
+func NewUpdated() Updated {
+	return make(Updated)
+}
+
+// set Запись времени обновления, при u == nil время не отслеживается
+func (u Updated) set(metricsType, metricsName string, t time.Time) {
+
+	if u == nil {
+		return
+	}
+
+	series, exist := u[metricsType]
+	if !exist {
+		series = make(map[string]time.Time)
+		u[metricsType] = series
+	}
+	series[metricsName] = t
+}
+
+func (u Updated) remove(metricsType, metricsName string) {
+	delete(u[metricsType], metricsName)
+}
+
+func (u Updated) reset(metricsType string) {
+	delete(u, metricsType)
+}
+
+// SetUpdated Установка времени обновления серии, например при восстановлении из журнала
+func (m *MemCashed) SetUpdated(ctx context.Context, metricsType, metricsName string, t time.Time) {
+	m = m.namespace(ctx)
+
+	m.Updated.set(metricsType, metricsName, t)
+}
+
+func (m *MemCashed) GetUpdated(ctx context.Context, metricsType, metricsName string) (time.Time, bool, error) {
+	m = m.view(ctx)
+
+	t, exist := m.Updated[metricsType][metricsName]
+	return t, exist, nil
+}
+
+func (m *MemCashed) GetAllUpdated(ctx context.Context, metricsType string) (map[string]time.Time, error) {
+	m = m.view(ctx)
+
+	result := make(map[string]time.Time, len(m.Updated[metricsType]))
+	for name, t := range m.Updated[metricsType] {
+		result[name] = t
+	}
+	return result, nil
+}
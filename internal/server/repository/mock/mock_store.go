@@ -7,6 +7,7 @@ package mocksrepository
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	models "github.com/s-turchinskiy/metrics/internal/server/models"
@@ -49,6 +50,20 @@ func (mr *MockRepositoryMockRecorder) Close(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockRepository)(nil).Close), arg0)
 }
 
+// CompactHistory mocks base method.
+func (m *MockRepository) CompactHistory(arg0 context.Context, arg1 models.RetentionPolicy, arg2 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompactHistory", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CompactHistory indicates an expected call of CompactHistory.
+func (mr *MockRepositoryMockRecorder) CompactHistory(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompactHistory", reflect.TypeOf((*MockRepository)(nil).CompactHistory), arg0, arg1, arg2)
+}
+
 // CountCounters mocks base method.
 func (m *MockRepository) CountCounters(arg0 context.Context) int {
 	m.ctrl.T.Helper()
@@ -77,6 +92,21 @@ func (mr *MockRepositoryMockRecorder) CountGauges(arg0 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountGauges", reflect.TypeOf((*MockRepository)(nil).CountGauges), arg0)
 }
 
+// DeleteMetrics mocks base method.
+func (m *MockRepository) DeleteMetrics(arg0 context.Context, arg1 string, arg2 []string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteMetrics", arg0, arg1, arg2)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteMetrics indicates an expected call of DeleteMetrics.
+func (mr *MockRepositoryMockRecorder) DeleteMetrics(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteMetrics", reflect.TypeOf((*MockRepository)(nil).DeleteMetrics), arg0, arg1, arg2)
+}
+
 // GetAllCounters mocks base method.
 func (m *MockRepository) GetAllCounters(arg0 context.Context) (map[string]int64, error) {
 	m.ctrl.T.Helper()
@@ -107,6 +137,51 @@ func (mr *MockRepositoryMockRecorder) GetAllGauges(arg0 interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllGauges", reflect.TypeOf((*MockRepository)(nil).GetAllGauges), arg0)
 }
 
+// GetAllHistograms mocks base method.
+func (m *MockRepository) GetAllHistograms(arg0 context.Context) (map[string]models.Histogram, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAllHistograms", arg0)
+	ret0, _ := ret[0].(map[string]models.Histogram)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAllHistograms indicates an expected call of GetAllHistograms.
+func (mr *MockRepositoryMockRecorder) GetAllHistograms(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllHistograms", reflect.TypeOf((*MockRepository)(nil).GetAllHistograms), arg0)
+}
+
+// GetAllSummaries mocks base method.
+func (m *MockRepository) GetAllSummaries(arg0 context.Context) (map[string]models.Summary, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAllSummaries", arg0)
+	ret0, _ := ret[0].(map[string]models.Summary)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAllSummaries indicates an expected call of GetAllSummaries.
+func (mr *MockRepositoryMockRecorder) GetAllSummaries(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllSummaries", reflect.TypeOf((*MockRepository)(nil).GetAllSummaries), arg0)
+}
+
+// GetAllUpdated mocks base method.
+func (m *MockRepository) GetAllUpdated(arg0 context.Context, arg1 string) (map[string]time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAllUpdated", arg0, arg1)
+	ret0, _ := ret[0].(map[string]time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAllUpdated indicates an expected call of GetAllUpdated.
+func (mr *MockRepositoryMockRecorder) GetAllUpdated(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllUpdated", reflect.TypeOf((*MockRepository)(nil).GetAllUpdated), arg0, arg1)
+}
+
 // GetCounter mocks base method.
 func (m *MockRepository) GetCounter(arg0 context.Context, arg1 string) (int64, bool, error) {
 	m.ctrl.T.Helper()
@@ -139,6 +214,84 @@ func (mr *MockRepositoryMockRecorder) GetGauge(arg0, arg1 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGauge", reflect.TypeOf((*MockRepository)(nil).GetGauge), arg0, arg1)
 }
 
+// GetHistogram mocks base method.
+func (m *MockRepository) GetHistogram(arg0 context.Context, arg1 string) (models.Histogram, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetHistogram", arg0, arg1)
+	ret0, _ := ret[0].(models.Histogram)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetHistogram indicates an expected call of GetHistogram.
+func (mr *MockRepositoryMockRecorder) GetHistogram(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetHistogram", reflect.TypeOf((*MockRepository)(nil).GetHistogram), arg0, arg1)
+}
+
+// GetHistory mocks base method.
+func (m *MockRepository) GetHistory(arg0 context.Context, arg1, arg2 string, arg3, arg4 time.Time) ([]models.HistorySample, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetHistory", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].([]models.HistorySample)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetHistory indicates an expected call of GetHistory.
+func (mr *MockRepositoryMockRecorder) GetHistory(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetHistory", reflect.TypeOf((*MockRepository)(nil).GetHistory), arg0, arg1, arg2, arg3, arg4)
+}
+
+// GetHistoryRollups mocks base method.
+func (m *MockRepository) GetHistoryRollups(arg0 context.Context, arg1, arg2 string, arg3 time.Duration, arg4, arg5 time.Time) ([]models.RollupSample, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetHistoryRollups", arg0, arg1, arg2, arg3, arg4, arg5)
+	ret0, _ := ret[0].([]models.RollupSample)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetHistoryRollups indicates an expected call of GetHistoryRollups.
+func (mr *MockRepositoryMockRecorder) GetHistoryRollups(arg0, arg1, arg2, arg3, arg4, arg5 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetHistoryRollups", reflect.TypeOf((*MockRepository)(nil).GetHistoryRollups), arg0, arg1, arg2, arg3, arg4, arg5)
+}
+
+// GetSummary mocks base method.
+func (m *MockRepository) GetSummary(arg0 context.Context, arg1 string) (models.Summary, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSummary", arg0, arg1)
+	ret0, _ := ret[0].(models.Summary)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetSummary indicates an expected call of GetSummary.
+func (mr *MockRepositoryMockRecorder) GetSummary(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSummary", reflect.TypeOf((*MockRepository)(nil).GetSummary), arg0, arg1)
+}
+
+// GetUpdated mocks base method.
+func (m *MockRepository) GetUpdated(arg0 context.Context, arg1, arg2 string) (time.Time, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUpdated", arg0, arg1, arg2)
+	ret0, _ := ret[0].(time.Time)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetUpdated indicates an expected call of GetUpdated.
+func (mr *MockRepositoryMockRecorder) GetUpdated(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUpdated", reflect.TypeOf((*MockRepository)(nil).GetUpdated), arg0, arg1, arg2)
+}
+
 // Ping mocks base method.
 func (m *MockRepository) Ping(arg0 context.Context) ([]byte, error) {
 	m.ctrl.T.Helper()
@@ -197,6 +350,21 @@ func (mr *MockRepositoryMockRecorder) ReloadAllMetrics(arg0, arg1 interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReloadAllMetrics", reflect.TypeOf((*MockRepository)(nil).ReloadAllMetrics), arg0, arg1)
 }
 
+// TenantIDs mocks base method.
+func (m *MockRepository) TenantIDs(arg0 context.Context) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TenantIDs", arg0)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TenantIDs indicates an expected call of TenantIDs.
+func (mr *MockRepositoryMockRecorder) TenantIDs(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TenantIDs", reflect.TypeOf((*MockRepository)(nil).TenantIDs), arg0)
+}
+
 // UpdateCounter mocks base method.
 func (m *MockRepository) UpdateCounter(arg0 context.Context, arg1 string, arg2 int64) error {
 	m.ctrl.T.Helper()
@@ -224,3 +392,31 @@ func (mr *MockRepositoryMockRecorder) UpdateGauge(arg0, arg1, arg2 interface{})
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateGauge", reflect.TypeOf((*MockRepository)(nil).UpdateGauge), arg0, arg1, arg2)
 }
+
+// UpdateHistogram mocks base method.
+func (m *MockRepository) UpdateHistogram(arg0 context.Context, arg1 string, arg2 models.Histogram) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateHistogram", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateHistogram indicates an expected call of UpdateHistogram.
+func (mr *MockRepositoryMockRecorder) UpdateHistogram(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateHistogram", reflect.TypeOf((*MockRepository)(nil).UpdateHistogram), arg0, arg1, arg2)
+}
+
+// UpdateSummary mocks base method.
+func (m *MockRepository) UpdateSummary(arg0 context.Context, arg1 string, arg2 models.Summary) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSummary", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateSummary indicates an expected call of UpdateSummary.
+func (mr *MockRepositoryMockRecorder) UpdateSummary(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSummary", reflect.TypeOf((*MockRepository)(nil).UpdateSummary), arg0, arg1, arg2)
+}
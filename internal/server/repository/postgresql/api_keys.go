@@ -0,0 +1,51 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/s-turchinskiy/metrics/internal/server/auth"
+	"github.com/s-turchinskiy/metrics/internal/utils/errutil"
+)
+
+const QueryUpsertAPIKey = `
+	INSERT INTO postgres.api_keys (key_hash, roles, description) VALUES ($1, $2, $3)
+	ON CONFLICT (key_hash) DO UPDATE SET roles = EXCLUDED.roles, description = EXCLUDED.description`
+
+// LookupAPIKey Поиск ключа по хэшу SHA256, роли хранятся строкой вида read|write
+func (p *PostgreSQL) LookupAPIKey(ctx context.Context, key string) (auth.Roles, bool, error) {
+
+	var roles string
+	err := p.db.QueryRowContext(ctx, "SELECT roles FROM postgres.api_keys WHERE key_hash = $1", auth.HashKey(key)).Scan(&roles)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, errutil.WrapError(err)
+	}
+
+	result, err := auth.ParseRoles(roles)
+	if err != nil {
+		return nil, false, errutil.WrapError(err)
+	}
+
+	return result, true, nil
+}
+
+// SaveAPIKey Добавление ключа или замена его ролей. Сам ключ не сохраняется
+func (p *PostgreSQL) SaveAPIKey(ctx context.Context, key string, roles auth.Roles, description string) error {
+
+	names := make([]string, 0, len(roles))
+	for _, role := range roles {
+		names = append(names, string(role))
+	}
+
+	_, err := p.db.ExecContext(ctx, QueryUpsertAPIKey, auth.HashKey(key), strings.Join(names, "|"), description)
+	if err != nil {
+		return errutil.WrapError(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package postgresql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/s-turchinskiy/metrics/internal/server/tenant"
+	"github.com/s-turchinskiy/metrics/internal/utils/errutil"
+)
+
+// metricsTables Таблицы с текущими значениями по типам метрик
+var metricsTables = map[string]string{
+	"gauge":     "postgres.gauges",
+	"counter":   "postgres.counters",
+	"histogram": "postgres.histograms",
+	"summary":   "postgres.summaries",
+}
+
+// DeleteMetrics Удаление выполняется в одной транзакции: либо удаляются все серии, либо ни одной
+func (p *PostgreSQL) DeleteMetrics(ctx context.Context, metricsType string, metricsNames []string) (int64, error) {
+
+	table, exist := metricsTables[metricsType]
+	if !exist {
+		return 0, fmt.Errorf("unclown MType %s", metricsType)
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, errutil.WrapError(err)
+	}
+
+	defer tx.Rollback()
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE tenant = $3 AND metrics_name = $1 AND labels = $2::JSONB", table)
+
+	var deleted int64
+	for _, metricsName := range metricsNames {
+
+		name, labels, err := splitSeriesKey(metricsName)
+		if err != nil {
+			return 0, errutil.WrapError(err)
+		}
+
+		result, err := tx.ExecContext(ctx, query, name, labels, tenant.FromContext(ctx))
+		if err != nil {
+			return 0, errutil.WrapError(err)
+		}
+
+		count, err := result.RowsAffected()
+		if err != nil {
+			return 0, errutil.WrapError(err)
+		}
+		deleted += count
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, errutil.WrapError(err)
+	}
+
+	return deleted, nil
+}
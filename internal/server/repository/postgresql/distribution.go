@@ -0,0 +1,218 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/s-turchinskiy/metrics/internal/server/models"
+	"github.com/s-turchinskiy/metrics/internal/server/tenant"
+	"github.com/s-turchinskiy/metrics/internal/utils/errutil"
+)
+
+const (
+	QueryInsertHistogram = `
+	INSERT INTO postgres.histograms (metrics_name, labels, value, updated, tenant)
+	VALUES ($1, $2::JSONB, $3::JSONB, $4, $5)
+	ON CONFLICT (tenant, metrics_name, labels) DO NOTHING`
+
+	QuerySelectHistogramForUpdate = `
+	SELECT value FROM postgres.histograms WHERE tenant = $3 AND metrics_name = $1 AND labels = $2::JSONB FOR UPDATE`
+
+	QueryUpdateHistogram = `
+	UPDATE postgres.histograms SET value = $3::JSONB, updated = $4
+	WHERE tenant = $5 AND metrics_name = $1 AND labels = $2::JSONB`
+
+	QueryUpsertSummary = `
+	INSERT INTO postgres.summaries (metrics_name, labels, value, updated, tenant)
+	VALUES ($1, $2::JSONB, $3::JSONB, $4, $5)
+	ON CONFLICT (tenant, metrics_name, labels) DO UPDATE SET
+		value = EXCLUDED.value,
+		updated = EXCLUDED.updated`
+)
+
+// UpdateHistogram Сложение выполняется в транзакции с блокировкой строки,
+// чтобы гистограммы от нескольких агентов не терялись при одновременной записи
+func (p *PostgreSQL) UpdateHistogram(ctx context.Context, metricsName string, delta models.Histogram) error {
+
+	name, labels, err := splitSeriesKey(metricsName)
+	if err != nil {
+		return errutil.WrapError(err)
+	}
+
+	id := tenant.FromContext(ctx)
+
+	data, err := json.Marshal(delta)
+	if err != nil {
+		return errutil.WrapError(err)
+	}
+
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errutil.WrapError(err)
+	}
+	defer tx.Rollback()
+
+	// новая серия вставляется сразу, существующая блокируется до конца транзакции
+	result, err := tx.ExecContext(ctx, QueryInsertHistogram, name, labels, string(data), time.Now(), id)
+	if err != nil {
+		return errutil.WrapError(err)
+	}
+
+	if inserted, err := result.RowsAffected(); err != nil {
+		return errutil.WrapError(err)
+	} else if inserted == 0 {
+
+		var h models.Histogram
+		if err = tx.QueryRowContext(ctx, QuerySelectHistogramForUpdate, name, labels, id).Scan(&data); err != nil {
+			return errutil.WrapError(err)
+		}
+		if err = json.Unmarshal(data, &h); err != nil {
+			return errutil.WrapError(err)
+		}
+		if err = h.Merge(delta); err != nil {
+			return err
+		}
+
+		if data, err = json.Marshal(h); err != nil {
+			return errutil.WrapError(err)
+		}
+		if _, err = tx.ExecContext(ctx, QueryUpdateHistogram, name, labels, string(data), time.Now(), id); err != nil {
+			return errutil.WrapError(err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errutil.WrapError(err)
+	}
+
+	return nil
+}
+
+func (p *PostgreSQL) GetHistogram(ctx context.Context, metricsName string) (models.Histogram, bool, error) {
+
+	var h models.Histogram
+	exist, err := p.getDistribution(ctx, "SELECT value FROM postgres.histograms WHERE tenant = $3 AND metrics_name = $1 AND labels = $2::JSONB", metricsName, &h)
+	return h, exist, err
+}
+
+func (p *PostgreSQL) GetAllHistograms(ctx context.Context) (map[string]models.Histogram, error) {
+
+	result := make(map[string]models.Histogram)
+	err := p.getAllDistributions(ctx, "SELECT metrics_name, labels::TEXT, value FROM postgres.histograms WHERE tenant = $1",
+		func(key string, data []byte) error {
+			var h models.Histogram
+			if err := json.Unmarshal(data, &h); err != nil {
+				return err
+			}
+			result[key] = h
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (p *PostgreSQL) UpdateSummary(ctx context.Context, metricsName string, summary models.Summary) error {
+
+	name, labels, err := splitSeriesKey(metricsName)
+	if err != nil {
+		return errutil.WrapError(err)
+	}
+
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return errutil.WrapError(err)
+	}
+
+	if _, err = p.db.ExecContext(ctx, QueryUpsertSummary, name, labels, string(data), time.Now(), tenant.FromContext(ctx)); err != nil {
+		return errutil.WrapError(err)
+	}
+
+	return nil
+}
+
+func (p *PostgreSQL) GetSummary(ctx context.Context, metricsName string) (models.Summary, bool, error) {
+
+	var s models.Summary
+	exist, err := p.getDistribution(ctx, "SELECT value FROM postgres.summaries WHERE tenant = $3 AND metrics_name = $1 AND labels = $2::JSONB", metricsName, &s)
+	return s, exist, err
+}
+
+func (p *PostgreSQL) GetAllSummaries(ctx context.Context) (map[string]models.Summary, error) {
+
+	result := make(map[string]models.Summary)
+	err := p.getAllDistributions(ctx, "SELECT metrics_name, labels::TEXT, value FROM postgres.summaries WHERE tenant = $1",
+		func(key string, data []byte) error {
+			var s models.Summary
+			if err := json.Unmarshal(data, &s); err != nil {
+				return err
+			}
+			result[key] = s
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (p *PostgreSQL) getDistribution(ctx context.Context, query, metricsName string, value any) (bool, error) {
+
+	name, labels, err := splitSeriesKey(metricsName)
+	if err != nil {
+		return false, errutil.WrapError(err)
+	}
+
+	var data []byte
+	err = p.db.QueryRowContext(ctx, query, name, labels, tenant.FromContext(ctx)).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errutil.WrapError(err)
+	}
+
+	if err = json.Unmarshal(data, value); err != nil {
+		return false, errutil.WrapError(err)
+	}
+
+	return true, nil
+}
+
+func (p *PostgreSQL) getAllDistributions(ctx context.Context, query string, add func(key string, data []byte) error) error {
+
+	rows, err := p.db.QueryContext(ctx, query, tenant.FromContext(ctx))
+	if err != nil {
+		return errutil.WrapError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, labels string
+		var data []byte
+		if err = rows.Scan(&name, &labels, &data); err != nil {
+			return errutil.WrapError(err)
+		}
+
+		key, err := joinSeriesKey(name, labels)
+		if err != nil {
+			return errutil.WrapError(err)
+		}
+
+		if err = add(key, data); err != nil {
+			return errutil.WrapError(err)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return errutil.WrapError(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package postgresql
+
+import (
+	"encoding/json"
+
+	"github.com/s-turchinskiy/metrics/internal/server/models"
+)
+
+// splitSeriesKey Имя метрики и метки в JSON для колонок metrics_name и labels.
+// Ключ серии приходит из сервиса в виде name{label="value"}
+func splitSeriesKey(key string) (string, string, error) {
+
+	name, labels, err := models.ParseSeriesKey(key)
+	if err != nil {
+		return "", "", err
+	}
+
+	if len(labels) == 0 {
+		return name, "{}", nil
+	}
+
+	data, err := json.Marshal(labels)
+	if err != nil {
+		return "", "", err
+	}
+
+	return name, string(data), nil
+}
+
+// joinSeriesKey Ключ серии из колонок metrics_name и labels
+func joinSeriesKey(name, labelsJSON string) (string, error) {
+
+	var labels models.Labels
+	if err := json.Unmarshal([]byte(labelsJSON), &labels); err != nil {
+		return "", err
+	}
+
+	return models.SeriesKey(name, labels), nil
+}
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS postgres.gauges_history (
+    id BIGSERIAL PRIMARY KEY,
+    metrics_name TEXT NOT NULL,
+    value DOUBLE PRECISION NOT NULL,
+    created TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS gauges_history_name_created_idx ON postgres.gauges_history (metrics_name, created);
+
+CREATE TABLE IF NOT EXISTS postgres.counters_history (
+    id BIGSERIAL PRIMARY KEY,
+    metrics_name TEXT NOT NULL,
+    delta BIGINT NOT NULL,
+    value BIGINT NOT NULL,
+    created TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS counters_history_name_created_idx ON postgres.counters_history (metrics_name, created);
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS postgres.history_rollups (
+    metrics_type TEXT NOT NULL,
+    metrics_name TEXT NOT NULL,
+    resolution BIGINT NOT NULL,
+    bucket TIMESTAMPTZ NOT NULL,
+    min DOUBLE PRECISION NOT NULL,
+    max DOUBLE PRECISION NOT NULL,
+    sum DOUBLE PRECISION NOT NULL,
+    last DOUBLE PRECISION NOT NULL,
+    count BIGINT NOT NULL,
+    PRIMARY KEY (metrics_type, metrics_name, resolution, bucket)
+);
+
+CREATE INDEX IF NOT EXISTS history_rollups_resolution_bucket_idx ON postgres.history_rollups (resolution, bucket);
+
+CREATE TABLE IF NOT EXISTS postgres.rollup_watermarks (
+    resolution BIGINT PRIMARY KEY,
+    compacted_until TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS gauges_history_created_idx ON postgres.gauges_history (created);
+CREATE INDEX IF NOT EXISTS counters_history_created_idx ON postgres.counters_history (created);
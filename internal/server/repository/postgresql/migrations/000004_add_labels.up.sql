@@ -0,0 +1,17 @@
+ALTER TABLE postgres.gauges ADD COLUMN IF NOT EXISTS labels JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE postgres.gauges DROP CONSTRAINT IF EXISTS gauges_metrics_name_key;
+ALTER TABLE postgres.gauges ADD CONSTRAINT gauges_metrics_name_labels_key UNIQUE (metrics_name, labels);
+
+ALTER TABLE postgres.counters ADD COLUMN IF NOT EXISTS labels JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE postgres.counters DROP CONSTRAINT IF EXISTS counters_metrics_name_key;
+ALTER TABLE postgres.counters ADD CONSTRAINT counters_metrics_name_labels_key UNIQUE (metrics_name, labels);
+
+ALTER TABLE postgres.gauges_history ADD COLUMN IF NOT EXISTS labels JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE postgres.counters_history ADD COLUMN IF NOT EXISTS labels JSONB NOT NULL DEFAULT '{}';
+
+ALTER TABLE postgres.history_rollups ADD COLUMN IF NOT EXISTS labels JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE postgres.history_rollups DROP CONSTRAINT IF EXISTS history_rollups_pkey;
+ALTER TABLE postgres.history_rollups ADD PRIMARY KEY (metrics_type, metrics_name, labels, resolution, bucket);
+
+CREATE INDEX IF NOT EXISTS gauges_labels_idx ON postgres.gauges USING GIN (labels);
+CREATE INDEX IF NOT EXISTS counters_labels_idx ON postgres.counters USING GIN (labels);
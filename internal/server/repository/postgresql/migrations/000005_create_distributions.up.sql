@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS postgres.histograms (
+    id SERIAL PRIMARY KEY,
+    metrics_name TEXT NOT NULL,
+    labels JSONB NOT NULL DEFAULT '{}',
+    value JSONB NOT NULL,
+    updated TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (metrics_name, labels)
+);
+
+CREATE TABLE IF NOT EXISTS postgres.summaries (
+    id SERIAL PRIMARY KEY,
+    metrics_name TEXT NOT NULL,
+    labels JSONB NOT NULL DEFAULT '{}',
+    value JSONB NOT NULL,
+    updated TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (metrics_name, labels)
+);
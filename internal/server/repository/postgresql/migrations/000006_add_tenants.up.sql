@@ -0,0 +1,22 @@
+ALTER TABLE postgres.gauges ADD COLUMN IF NOT EXISTS tenant TEXT NOT NULL DEFAULT '';
+ALTER TABLE postgres.gauges DROP CONSTRAINT IF EXISTS gauges_metrics_name_labels_key;
+ALTER TABLE postgres.gauges ADD CONSTRAINT gauges_tenant_metrics_name_labels_key UNIQUE (tenant, metrics_name, labels);
+
+ALTER TABLE postgres.counters ADD COLUMN IF NOT EXISTS tenant TEXT NOT NULL DEFAULT '';
+ALTER TABLE postgres.counters DROP CONSTRAINT IF EXISTS counters_metrics_name_labels_key;
+ALTER TABLE postgres.counters ADD CONSTRAINT counters_tenant_metrics_name_labels_key UNIQUE (tenant, metrics_name, labels);
+
+ALTER TABLE postgres.histograms ADD COLUMN IF NOT EXISTS tenant TEXT NOT NULL DEFAULT '';
+ALTER TABLE postgres.histograms DROP CONSTRAINT IF EXISTS histograms_metrics_name_labels_key;
+ALTER TABLE postgres.histograms ADD CONSTRAINT histograms_tenant_metrics_name_labels_key UNIQUE (tenant, metrics_name, labels);
+
+ALTER TABLE postgres.summaries ADD COLUMN IF NOT EXISTS tenant TEXT NOT NULL DEFAULT '';
+ALTER TABLE postgres.summaries DROP CONSTRAINT IF EXISTS summaries_metrics_name_labels_key;
+ALTER TABLE postgres.summaries ADD CONSTRAINT summaries_tenant_metrics_name_labels_key UNIQUE (tenant, metrics_name, labels);
+
+ALTER TABLE postgres.gauges_history ADD COLUMN IF NOT EXISTS tenant TEXT NOT NULL DEFAULT '';
+ALTER TABLE postgres.counters_history ADD COLUMN IF NOT EXISTS tenant TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE postgres.history_rollups ADD COLUMN IF NOT EXISTS tenant TEXT NOT NULL DEFAULT '';
+ALTER TABLE postgres.history_rollups DROP CONSTRAINT IF EXISTS history_rollups_pkey;
+ALTER TABLE postgres.history_rollups ADD PRIMARY KEY (tenant, metrics_type, metrics_name, labels, resolution, bucket);
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS postgres.api_keys (
+    key_hash TEXT PRIMARY KEY,
+    roles TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -16,22 +16,41 @@ import (
 
 	"github.com/s-turchinskiy/metrics/internal/server/middleware/logger"
 	"github.com/s-turchinskiy/metrics/internal/server/models"
+	"github.com/s-turchinskiy/metrics/internal/server/tenant"
 )
 
 const (
 	QueryInsertUpdateCounter = `
-	INSERT INTO postgres.counters (metrics_name, value, updated) 
-	VALUES ($1, $2, $3)
-	ON CONFLICT (metrics_name) DO UPDATE SET
+	WITH updated_counter AS (
+		INSERT INTO postgres.counters (metrics_name, value, updated, labels, tenant) 
+		VALUES ($1, $2, $3, $4::JSONB, $5)
+		ON CONFLICT (tenant, metrics_name, labels) DO UPDATE SET
 			value = EXCLUDED.value + counters.value,
-			updated = EXCLUDED.updated`
+			updated = EXCLUDED.updated
+		RETURNING value, updated)
+	INSERT INTO postgres.counters_history (metrics_name, labels, delta, value, created, tenant)
+	SELECT $1, $4::JSONB, $2, value, updated, $5 FROM updated_counter`
 
 	QueryInsertUpdateGauge = `
-		INSERT INTO postgres.gauges (metrics_name, value, updated) 
-		VALUES ($1, $2, $3)
-		ON CONFLICT (metrics_name) DO UPDATE SET
+	WITH updated_gauge AS (
+		INSERT INTO postgres.gauges (metrics_name, value, updated, labels, tenant) 
+		VALUES ($1, $2, $3, $4::JSONB, $5)
+		ON CONFLICT (tenant, metrics_name, labels) DO UPDATE SET
 			value = EXCLUDED.value,
-			updated = EXCLUDED.updated`
+			updated = EXCLUDED.updated
+		RETURNING value, updated)
+	INSERT INTO postgres.gauges_history (metrics_name, labels, value, created, tenant)
+	SELECT $1, $4::JSONB, value, updated, $5 FROM updated_gauge`
+
+	QuerySelectGaugeHistory = `
+	SELECT value, NULL::BIGINT AS delta, created FROM postgres.gauges_history
+	WHERE tenant = $5 AND metrics_name = $1 AND labels = $4::JSONB AND created BETWEEN $2 AND $3
+	ORDER BY created, id`
+
+	QuerySelectCounterHistory = `
+	SELECT value, delta, created FROM postgres.counters_history
+	WHERE tenant = $5 AND metrics_name = $1 AND labels = $4::JSONB AND created BETWEEN $2 AND $3
+	ORDER BY created, id`
 )
 
 type keyTx string
@@ -49,7 +68,12 @@ func (p *PostgreSQL) Ping(ctx context.Context) ([]byte, error) {
 
 func (p *PostgreSQL) UpdateGauge(ctx context.Context, metricsName string, newValue float64) error {
 
-	_, err := p.db.ExecContext(ctx, QueryInsertUpdateGauge, metricsName, newValue, time.Now())
+	name, labels, err := splitSeriesKey(metricsName)
+	if err != nil {
+		return errutil.WrapError(err)
+	}
+
+	_, err = p.db.ExecContext(ctx, QueryInsertUpdateGauge, name, newValue, time.Now(), labels, tenant.FromContext(ctx))
 	if err != nil {
 		err = fmt.Errorf("PostgreSQL.UpdateGauge error in p.DB.Exec, %w", err)
 	}
@@ -64,7 +88,12 @@ func (p *PostgreSQL) UpdateCounter(ctx context.Context, metricsName string, newV
 		"value", newValue,
 	)
 
-	_, err := p.db.ExecContext(ctx, QueryInsertUpdateCounter, metricsName, newValue, time.Now())
+	name, labels, err := splitSeriesKey(metricsName)
+	if err != nil {
+		return errutil.WrapError(err)
+	}
+
+	_, err = p.db.ExecContext(ctx, QueryInsertUpdateCounter, name, newValue, time.Now(), labels, tenant.FromContext(ctx))
 	if err != nil {
 		var pgErr *pgconn.PgError
 		errors.As(err, &pgErr)
@@ -80,7 +109,7 @@ func (p *PostgreSQL) UpdateCounter(ctx context.Context, metricsName string, newV
 
 func (p *PostgreSQL) CountGauges(ctx context.Context) int {
 
-	row := p.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM postgres.gauges")
+	row := p.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM postgres.gauges WHERE tenant = $1", tenant.FromContext(ctx))
 	var count int
 	_ = row.Scan(&count)
 
@@ -90,7 +119,7 @@ func (p *PostgreSQL) CountGauges(ctx context.Context) int {
 
 func (p *PostgreSQL) CountCounters(ctx context.Context) int {
 
-	row := p.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM postgres.counters")
+	row := p.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM postgres.counters WHERE tenant = $1", tenant.FromContext(ctx))
 	var count int
 	_ = row.Scan(&count)
 
@@ -100,7 +129,12 @@ func (p *PostgreSQL) CountCounters(ctx context.Context) int {
 
 func (p *PostgreSQL) GetGauge(ctx context.Context, metricsName string) (value float64, isExist bool, err error) {
 
-	row := p.db.QueryRowContext(ctx, "SELECT value FROM postgres.gauges WHERE metrics_name = $1", metricsName)
+	name, labels, err := splitSeriesKey(metricsName)
+	if err != nil {
+		return 0, false, errutil.WrapError(err)
+	}
+
+	row := p.db.QueryRowContext(ctx, "SELECT value FROM postgres.gauges WHERE tenant = $3 AND metrics_name = $1 AND labels = $2::JSONB", name, labels, tenant.FromContext(ctx))
 	err = row.Scan(&value)
 
 	isExist = true
@@ -126,16 +160,21 @@ func (p *PostgreSQL) GetGauge(ctx context.Context, metricsName string) (value fl
 
 func (p *PostgreSQL) GetCounter(ctx context.Context, metricsName string) (value int64, isExist bool, err error) {
 
-	query := "SELECT value FROM postgres.counters WHERE metrics_name = $1"
+	query := "SELECT value FROM postgres.counters WHERE tenant = $3 AND metrics_name = $1 AND labels = $2::JSONB"
+
+	name, labels, err := splitSeriesKey(metricsName)
+	if err != nil {
+		return 0, false, errutil.WrapError(err)
+	}
 
 	var row *sql.Row
 
 	tx := ctx.Value(keyTx("tx"))
 
 	if tx != nil {
-		row = tx.(*sql.Tx).QueryRowContext(ctx, query, metricsName)
+		row = tx.(*sql.Tx).QueryRowContext(ctx, query, name, labels, tenant.FromContext(ctx))
 	} else {
-		row = p.db.QueryRowContext(ctx, query, metricsName)
+		row = p.db.QueryRowContext(ctx, query, name, labels, tenant.FromContext(ctx))
 	}
 	err = row.Scan(&value)
 
@@ -165,13 +204,17 @@ func (p *PostgreSQL) GetAllGauges(ctx context.Context) (map[string]float64, erro
 	result := make(map[string]float64)
 
 	var metrics []models.DatabaseTableGauges
-	err := p.db.SelectContext(ctx, &metrics, "SELECT metrics_name, value from postgres.gauges")
+	err := p.db.SelectContext(ctx, &metrics, "SELECT metrics_name, labels::TEXT AS labels, value from postgres.gauges WHERE tenant = $1", tenant.FromContext(ctx))
 
 	if err != nil {
 		return nil, errutil.WrapError(err)
 	}
 	for _, data := range metrics {
-		result[data.MetricsName] = data.Value
+		key, err := joinSeriesKey(data.MetricsName, data.Labels)
+		if err != nil {
+			return nil, errutil.WrapError(err)
+		}
+		result[key] = data.Value
 	}
 
 	return result, nil
@@ -182,7 +225,7 @@ func (p *PostgreSQL) GetAllCounters(ctx context.Context) (map[string]int64, erro
 
 	result := make(map[string]int64)
 
-	rows, err := p.db.QueryContext(ctx, "SELECT metrics_name, value from postgres.counters")
+	rows, err := p.db.QueryContext(ctx, "SELECT metrics_name, labels::TEXT, value from postgres.counters WHERE tenant = $1", tenant.FromContext(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -190,14 +233,18 @@ func (p *PostgreSQL) GetAllCounters(ctx context.Context) (map[string]int64, erro
 	defer rows.Close()
 
 	for rows.Next() {
-		var metricsName string
+		var metricsName, labels string
 		var value int64
-		err = rows.Scan(&metricsName, &value)
+		err = rows.Scan(&metricsName, &labels, &value)
 		if err != nil {
 			return nil, err
 		}
 
-		result[metricsName] = value
+		key, err := joinSeriesKey(metricsName, labels)
+		if err != nil {
+			return nil, errutil.WrapError(err)
+		}
+		result[key] = value
 	}
 
 	err = rows.Err()
@@ -217,7 +264,7 @@ func (p *PostgreSQL) ReloadAllGauges(ctx context.Context, data map[string]float6
 
 	defer tx.Rollback()
 
-	_, err = tx.ExecContext(ctx, "TRUNCATE postgres.gauges")
+	_, err = tx.ExecContext(ctx, "DELETE FROM postgres.gauges WHERE tenant = $1", tenant.FromContext(ctx))
 	if err != nil {
 		return errutil.WrapError(err)
 	}
@@ -257,7 +304,12 @@ func (p *PostgreSQL) ReloadAllGauges(ctx context.Context, data map[string]float6
 func insertUpdatePortionGauges(ctx context.Context, portionData map[string]float64, tx *sql.Tx) error {
 
 	for metricsName, newValue := range portionData {
-		_, err := tx.ExecContext(ctx, QueryInsertUpdateGauge, metricsName, newValue, time.Now())
+		name, labels, err := splitSeriesKey(metricsName)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, QueryInsertUpdateGauge, name, newValue, time.Now(), labels, tenant.FromContext(ctx))
 		if err != nil {
 			return err
 
@@ -275,7 +327,7 @@ func (p *PostgreSQL) ReloadAllCounters(ctx context.Context, data map[string]int6
 
 	defer tx.Rollback()
 
-	_, err = tx.ExecContext(ctx, "TRUNCATE postgres.counters")
+	_, err = tx.ExecContext(ctx, "DELETE FROM postgres.counters WHERE tenant = $1", tenant.FromContext(ctx))
 	if err != nil {
 		return errutil.WrapError(err)
 	}
@@ -314,7 +366,12 @@ func (p *PostgreSQL) ReloadAllCounters(ctx context.Context, data map[string]int6
 func insertUpdatePortionCounters(ctx context.Context, portionData map[string]int64, tx *sql.Tx) error {
 
 	for metricsName, newValue := range portionData {
-		_, err := tx.ExecContext(ctx, QueryInsertUpdateCounter, metricsName, newValue, time.Now())
+		name, labels, err := splitSeriesKey(metricsName)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, QueryInsertUpdateCounter, name, newValue, time.Now(), labels, tenant.FromContext(ctx))
 		if err != nil {
 			return err
 
@@ -333,16 +390,24 @@ func (p *PostgreSQL) ReloadAllMetrics(ctx context.Context, metrics []models.Stor
 
 	batch := new(pgx.Batch)
 
-	batch.Queue("TRUNCATE postgres.counters")
-	batch.Queue("TRUNCATE postgres.gauges")
+	id := tenant.FromContext(ctx)
+
+	// перезагружаются только серии арендатора из контекста
+	batch.Queue("DELETE FROM postgres.counters WHERE tenant = $1", id)
+	batch.Queue("DELETE FROM postgres.gauges WHERE tenant = $1", id)
 
 	for _, metric := range metrics {
 
+		name, labels, err := splitSeriesKey(metric.Name)
+		if err != nil {
+			return 0, errutil.WrapError(err)
+		}
+
 		switch metric.MType {
 		case "gauge":
-			batch.Queue(QueryInsertUpdateGauge, metric.Name, &metric.Value, time.Now())
+			batch.Queue(QueryInsertUpdateGauge, name, &metric.Value, time.Now(), labels, id)
 		case "counter":
-			batch.Queue(QueryInsertUpdateCounter, metric.Name, &metric.Delta, time.Now())
+			batch.Queue(QueryInsertUpdateCounter, name, &metric.Delta, time.Now(), labels, id)
 		default:
 			return 0, fmt.Errorf("unclown MType %s", metric.MType)
 		}
@@ -374,3 +439,40 @@ func (p *PostgreSQL) ReloadAllMetrics(ctx context.Context, metrics []models.Stor
 	return tag.RowsAffected(), nil
 
 }
+
+func (p *PostgreSQL) GetHistory(ctx context.Context, metricsType, metricsName string, from, to time.Time) ([]models.HistorySample, error) {
+
+	var query string
+	switch metricsType {
+	case "gauge":
+		query = QuerySelectGaugeHistory
+	case "counter":
+		query = QuerySelectCounterHistory
+	default:
+		return nil, fmt.Errorf("unclown MType %s", metricsType)
+	}
+
+	name, labels, err := splitSeriesKey(metricsName)
+	if err != nil {
+		return nil, errutil.WrapError(err)
+	}
+
+	var rows []models.DatabaseTableHistory
+	err = p.db.SelectContext(ctx, &rows, query, name, from, to, labels, tenant.FromContext(ctx))
+	if err != nil {
+		return nil, errutil.WrapError(err)
+	}
+
+	result := make([]models.HistorySample, 0, len(rows))
+	for _, row := range rows {
+		sample := models.HistorySample{Timestamp: row.Created, Value: row.Value}
+		if row.Delta.Valid {
+			delta := row.Delta.Int64
+			sample.Delta = &delta
+		}
+		result = append(result, sample)
+	}
+
+	return result, nil
+
+}
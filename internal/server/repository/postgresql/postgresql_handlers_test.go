@@ -3,10 +3,12 @@ package postgresql
 import (
 	"context"
 	"fmt"
+	"github.com/s-turchinskiy/metrics/internal/server/auth"
 	"github.com/s-turchinskiy/metrics/internal/server/models"
 	"github.com/stretchr/testify/require"
 	"strconv"
 	"testing"
+	"time"
 )
 
 func TestIntegration(t *testing.T) {
@@ -42,6 +44,15 @@ func TestIntegration(t *testing.T) {
 		require.Equal(t, false, isExist)
 		require.NoError(t, err)
 
+		err = db.UpdateCounter(ctx, "counterName", 3)
+		require.NoError(t, err)
+
+		historyCounter, err := db.GetHistory(ctx, "counter", "counterName", time.Time{}, time.Now())
+		require.NoError(t, err)
+		require.Equal(t, 2, len(historyCounter))
+		require.Equal(t, int64(3), *historyCounter[1].Delta)
+		require.Equal(t, float64(5), historyCounter[1].Value)
+
 		resultCounters, err := db.GetAllCounters(ctx)
 		require.Equal(t, 1, len(resultCounters))
 		require.NoError(t, err)
@@ -74,10 +85,51 @@ func TestIntegration(t *testing.T) {
 		require.Equal(t, false, isExist)
 		require.NoError(t, err)
 
+		err = db.UpdateGauge(ctx, "gaugeName", 2.34)
+		require.NoError(t, err)
+
+		historyGauge, err := db.GetHistory(ctx, "gauge", "gaugeName", time.Time{}, time.Now())
+		require.NoError(t, err)
+		require.Equal(t, 2, len(historyGauge))
+		require.Equal(t, 1.23, historyGauge[0].Value)
+		require.Equal(t, 2.34, historyGauge[1].Value)
+
+		policy, err := models.ParseRetentionPolicy("raw:1h,1s:1h")
+		require.NoError(t, err)
+		compactedAt := time.Now().Add(2 * time.Second)
+		err = db.CompactHistory(ctx, policy, compactedAt)
+		require.NoError(t, err)
+
+		rollups, err := db.GetHistoryRollups(ctx, "gauge", "gaugeName", time.Second, time.Time{}, compactedAt)
+		require.NoError(t, err)
+		require.NotEmpty(t, rollups)
+		require.Equal(t, 2.34, rollups[len(rollups)-1].Last)
+
 		resultGauges, err := db.GetAllGauges(ctx)
 		require.Equal(t, 1, len(resultGauges))
 		require.NoError(t, err)
 
+		//labels
+		err = db.UpdateGauge(ctx, `gaugeName{host="server01"}`, 3.45)
+		require.NoError(t, err)
+
+		valueGauge, isExist, err = db.GetGauge(ctx, `gaugeName{host="server01"}`)
+		require.NoError(t, err)
+		require.Equal(t, true, isExist)
+		require.Equal(t, 3.45, valueGauge)
+
+		valueGauge, _, err = db.GetGauge(ctx, "gaugeName")
+		require.NoError(t, err)
+		require.Equal(t, 2.34, valueGauge)
+
+		resultGauges, err = db.GetAllGauges(ctx)
+		require.NoError(t, err)
+		require.Equal(t, map[string]float64{"gaugeName": 2.34, `gaugeName{host="server01"}`: 3.45}, resultGauges)
+
+		historyGauge, err = db.GetHistory(ctx, "gauge", `gaugeName{host="server01"}`, time.Time{}, time.Now())
+		require.NoError(t, err)
+		require.Equal(t, 1, len(historyGauge))
+
 		gauges := make(map[string]float64, 10)
 		for i := 1; i <= 10; i++ {
 			gauges["gauge"+strconv.Itoa(i)] = 1.23
@@ -126,6 +178,20 @@ func TestIntegration(t *testing.T) {
 		}
 		_, err = db.ReloadAllMetrics(ctx, data)
 		require.Error(t, err)
+
+		//api keys
+		keys := db.(*PostgreSQL)
+		err = keys.SaveAPIKey(ctx, "secret", auth.Roles{auth.RoleRead, auth.RoleWrite}, "agent")
+		require.NoError(t, err)
+
+		roles, isExist, err := keys.LookupAPIKey(ctx, "secret")
+		require.NoError(t, err)
+		require.Equal(t, true, isExist)
+		require.Equal(t, auth.Roles{auth.RoleRead, auth.RoleWrite}, roles)
+
+		_, isExist, err = keys.LookupAPIKey(ctx, "unknown")
+		require.NoError(t, err)
+		require.Equal(t, false, isExist)
 	})
 
 }
@@ -0,0 +1,204 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/s-turchinskiy/metrics/internal/server/middleware/logger"
+	"github.com/s-turchinskiy/metrics/internal/server/models"
+	"github.com/s-turchinskiy/metrics/internal/server/tenant"
+	"github.com/s-turchinskiy/metrics/internal/utils/errutil"
+)
+
+const (
+	QueryRollupGauges = `
+	INSERT INTO postgres.history_rollups (tenant, metrics_type, metrics_name, labels, resolution, bucket, min, max, sum, last, count)
+	SELECT tenant, 'gauge', metrics_name, labels, $1::BIGINT,
+		to_timestamp(floor(extract(epoch FROM created)::DOUBLE PRECISION / $1::BIGINT) * $1::BIGINT) AS bucket,
+		MIN(value), MAX(value), SUM(value), (ARRAY_AGG(value ORDER BY created DESC, id DESC))[1], COUNT(*)
+	FROM postgres.gauges_history
+	WHERE created >= $2 AND created < $3
+	GROUP BY tenant, metrics_name, labels, bucket
+	ON CONFLICT (tenant, metrics_type, metrics_name, labels, resolution, bucket) DO UPDATE SET
+		min = EXCLUDED.min, max = EXCLUDED.max, sum = EXCLUDED.sum, last = EXCLUDED.last, count = EXCLUDED.count`
+
+	QueryRollupCounters = `
+	INSERT INTO postgres.history_rollups (tenant, metrics_type, metrics_name, labels, resolution, bucket, min, max, sum, last, count)
+	SELECT tenant, 'counter', metrics_name, labels, $1::BIGINT,
+		to_timestamp(floor(extract(epoch FROM created)::DOUBLE PRECISION / $1::BIGINT) * $1::BIGINT) AS bucket,
+		MIN(delta), MAX(delta), SUM(delta), (ARRAY_AGG(value ORDER BY created DESC, id DESC))[1], COUNT(*)
+	FROM postgres.counters_history
+	WHERE created >= $2 AND created < $3
+	GROUP BY tenant, metrics_name, labels, bucket
+	ON CONFLICT (tenant, metrics_type, metrics_name, labels, resolution, bucket) DO UPDATE SET
+		min = EXCLUDED.min, max = EXCLUDED.max, sum = EXCLUDED.sum, last = EXCLUDED.last, count = EXCLUDED.count`
+
+	QueryRollupRollups = `
+	INSERT INTO postgres.history_rollups (tenant, metrics_type, metrics_name, labels, resolution, bucket, min, max, sum, last, count)
+	SELECT tenant, metrics_type, metrics_name, labels, $1::BIGINT,
+		to_timestamp(floor(extract(epoch FROM bucket)::DOUBLE PRECISION / $1::BIGINT) * $1::BIGINT) AS target_bucket,
+		MIN(min), MAX(max), SUM(sum), (ARRAY_AGG(last ORDER BY bucket DESC))[1], SUM(count)
+	FROM postgres.history_rollups
+	WHERE resolution = $2 AND bucket >= $3 AND bucket < $4
+	GROUP BY tenant, metrics_type, metrics_name, labels, target_bucket
+	ON CONFLICT (tenant, metrics_type, metrics_name, labels, resolution, bucket) DO UPDATE SET
+		min = EXCLUDED.min, max = EXCLUDED.max, sum = EXCLUDED.sum, last = EXCLUDED.last, count = EXCLUDED.count`
+
+	QuerySelectRollups = `
+	SELECT bucket, min, max, sum, last, count FROM postgres.history_rollups
+	WHERE tenant = $7 AND metrics_type = $1 AND metrics_name = $2 AND labels = $6::JSONB AND resolution = $3 AND bucket BETWEEN $4 AND $5
+	ORDER BY bucket`
+
+	QueryUpsertWatermark = `
+	INSERT INTO postgres.rollup_watermarks (resolution, compacted_until) VALUES ($1, $2)
+	ON CONFLICT (resolution) DO UPDATE SET compacted_until = EXCLUDED.compacted_until`
+)
+
+type databaseTableRollup struct {
+	Bucket time.Time `db:"bucket"`
+	Min    float64   `db:"min"`
+	Max    float64   `db:"max"`
+	Sum    float64   `db:"sum"`
+	Last   float64   `db:"last"`
+	Count  int64     `db:"count"`
+}
+
+func (p *PostgreSQL) GetHistoryRollups(ctx context.Context, metricsType, metricsName string, resolution time.Duration, from, to time.Time) ([]models.RollupSample, error) {
+
+	name, labels, err := splitSeriesKey(metricsName)
+	if err != nil {
+		return nil, errutil.WrapError(err)
+	}
+
+	var rows []databaseTableRollup
+	err = p.db.SelectContext(ctx, &rows, QuerySelectRollups, metricsType, name, int64(resolution.Seconds()), from, to, labels, tenant.FromContext(ctx))
+	if err != nil {
+		return nil, errutil.WrapError(err)
+	}
+
+	result := make([]models.RollupSample, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, models.RollupSample{
+			Timestamp: row.Bucket,
+			Min:       row.Min,
+			Max:       row.Max,
+			Avg:       row.Sum / float64(row.Count),
+			Sum:       row.Sum,
+			Last:      row.Last,
+			Count:     row.Count,
+		})
+	}
+
+	return result, nil
+}
+
+// CompactHistory Агрегация истории в интервалы по правилам policy и удаление устаревших строк.
+// Интервалы в базе хранятся в секундах, поэтому правила с долями секунды не поддерживаются
+func (p *PostgreSQL) CompactHistory(ctx context.Context, policy models.RetentionPolicy, now time.Time) error {
+
+	if !policy.Enabled() {
+		return nil
+	}
+
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errutil.WrapError(err)
+	}
+	defer tx.Rollback()
+
+	watermarks, err := selectWatermarks(ctx, tx)
+	if err != nil {
+		return errutil.WrapError(err)
+	}
+
+	for level, rule := range policy.Rollups {
+
+		if rule.Resolution%time.Second != 0 {
+			return errutil.WrapError(fmt.Errorf("rollup resolution %s is not a whole number of seconds", rule.Resolution))
+		}
+		resolution := int64(rule.Resolution.Seconds())
+
+		since := watermarks[rule.Resolution]
+		until := models.BucketStart(now, rule.Resolution)
+		if !since.Before(until) {
+			continue
+		}
+
+		if level == 0 {
+			if _, err = tx.ExecContext(ctx, QueryRollupGauges, resolution, since, until); err != nil {
+				return errutil.WrapError(err)
+			}
+			if _, err = tx.ExecContext(ctx, QueryRollupCounters, resolution, since, until); err != nil {
+				return errutil.WrapError(err)
+			}
+		} else {
+			sourceResolution := int64(policy.Rollups[level-1].Resolution.Seconds())
+			if _, err = tx.ExecContext(ctx, QueryRollupRollups, resolution, sourceResolution, since, until); err != nil {
+				return errutil.WrapError(err)
+			}
+		}
+
+		if _, err = tx.ExecContext(ctx, QueryUpsertWatermark, resolution, until); err != nil {
+			return errutil.WrapError(err)
+		}
+		watermarks[rule.Resolution] = until
+	}
+
+	rawCutoff := policy.RawCutoff(now, watermarks)
+	resultGauges, err := tx.ExecContext(ctx, "DELETE FROM postgres.gauges_history WHERE created < $1", rawCutoff)
+	if err != nil {
+		return errutil.WrapError(err)
+	}
+	resultCounters, err := tx.ExecContext(ctx, "DELETE FROM postgres.counters_history WHERE created < $1", rawCutoff)
+	if err != nil {
+		return errutil.WrapError(err)
+	}
+
+	for level, rule := range policy.Rollups {
+		cutoff := policy.RollupCutoff(level, now, watermarks)
+		_, err = tx.ExecContext(ctx, "DELETE FROM postgres.history_rollups WHERE resolution = $1 AND bucket < $2",
+			int64(rule.Resolution.Seconds()), cutoff)
+		if err != nil {
+			return errutil.WrapError(err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errutil.WrapError(err)
+	}
+
+	deletedGauges, _ := resultGauges.RowsAffected()
+	deletedCounters, _ := resultCounters.RowsAffected()
+	logger.Log.Debugw("PostgreSQL.CompactHistory",
+		"deletedGauges", deletedGauges,
+		"deletedCounters", deletedCounters,
+	)
+
+	return nil
+}
+
+func selectWatermarks(ctx context.Context, tx *sqlx.Tx) (map[time.Duration]time.Time, error) {
+
+	rows, err := tx.QueryContext(ctx, "SELECT resolution, compacted_until FROM postgres.rollup_watermarks")
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[time.Duration]time.Time)
+	for rows.Next() {
+		var resolution int64
+		var compactedUntil time.Time
+		if err = rows.Scan(&resolution, &compactedUntil); err != nil {
+			return nil, err
+		}
+		result[time.Duration(resolution)*time.Second] = compactedUntil
+	}
+
+	return result, rows.Err()
+}
@@ -0,0 +1,83 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/s-turchinskiy/metrics/internal/server/tenant"
+	"github.com/s-turchinskiy/metrics/internal/utils/errutil"
+)
+
+func (p *PostgreSQL) GetUpdated(ctx context.Context, metricsType, metricsName string) (time.Time, bool, error) {
+
+	table, exist := metricsTables[metricsType]
+	if !exist {
+		return time.Time{}, false, fmt.Errorf("unclown MType %s", metricsType)
+	}
+
+	name, labels, err := splitSeriesKey(metricsName)
+	if err != nil {
+		return time.Time{}, false, errutil.WrapError(err)
+	}
+
+	query := fmt.Sprintf("SELECT updated FROM %s WHERE tenant = $3 AND metrics_name = $1 AND labels = $2::JSONB", table)
+
+	var updated time.Time
+	err = p.db.QueryRowContext(ctx, query, name, labels, tenant.FromContext(ctx)).Scan(&updated)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, errutil.WrapError(err)
+	}
+
+	return updated, true, nil
+}
+
+func (p *PostgreSQL) GetAllUpdated(ctx context.Context, metricsType string) (map[string]time.Time, error) {
+
+	table, exist := metricsTables[metricsType]
+	if !exist {
+		return nil, fmt.Errorf("unclown MType %s", metricsType)
+	}
+
+	rows, err := p.db.QueryContext(ctx, fmt.Sprintf("SELECT metrics_name, labels, updated FROM %s WHERE tenant = $1", table), tenant.FromContext(ctx))
+	if err != nil {
+		return nil, errutil.WrapError(err)
+	}
+	defer rows.Close()
+
+	result := make(map[string]time.Time)
+	for rows.Next() {
+		var name, labels string
+		var updated time.Time
+		if err = rows.Scan(&name, &labels, &updated); err != nil {
+			return nil, errutil.WrapError(err)
+		}
+
+		key, err := joinSeriesKey(name, labels)
+		if err != nil {
+			return nil, errutil.WrapError(err)
+		}
+		result[key] = updated
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, errutil.WrapError(err)
+	}
+
+	return result, nil
+}
+
+func (p *PostgreSQL) TenantIDs(ctx context.Context) ([]string, error) {
+
+	var result []string
+	if err := p.db.SelectContext(ctx, &result, "SELECT DISTINCT tenant FROM postgres.gauges ORDER BY tenant"); err != nil {
+		return nil, errutil.WrapError(err)
+	}
+
+	return result, nil
+}
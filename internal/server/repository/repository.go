@@ -3,10 +3,12 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/s-turchinskiy/metrics/internal/server/models"
 )
 
+// Repository Хранилище метрик. metricsName - ключ серии models.SeriesKey: имя метрики и метки
 type Repository interface {
 	UpdateGauge(ctx context.Context, metricsName string, newValue float64) error
 	UpdateCounter(ctx context.Context, metricsName string, newValue int64) error
@@ -19,6 +21,24 @@ type Repository interface {
 	ReloadAllGauges(context.Context, map[string]float64) error
 	ReloadAllCounters(context.Context, map[string]int64) error
 	ReloadAllMetrics(context.Context, []models.StorageMetrics) (int64, error)
+	GetHistory(ctx context.Context, metricsType, metricsName string, from, to time.Time) ([]models.HistorySample, error)
+	GetHistoryRollups(ctx context.Context, metricsType, metricsName string, resolution time.Duration, from, to time.Time) ([]models.RollupSample, error)
+	CompactHistory(ctx context.Context, policy models.RetentionPolicy, now time.Time) error
+	// UpdateHistogram Сложение гистограммы с сохраненной, границы корзин должны совпадать
+	UpdateHistogram(ctx context.Context, metricsName string, delta models.Histogram) error
+	GetHistogram(ctx context.Context, metricsName string) (models.Histogram, bool, error)
+	GetAllHistograms(ctx context.Context) (map[string]models.Histogram, error)
+	// UpdateSummary Замена сохраненных квантилей
+	UpdateSummary(ctx context.Context, metricsName string, summary models.Summary) error
+	GetSummary(ctx context.Context, metricsName string) (models.Summary, bool, error)
+	GetAllSummaries(ctx context.Context) (map[string]models.Summary, error)
+	// GetUpdated Время последнего обновления серии metricsType
+	GetUpdated(ctx context.Context, metricsType, metricsName string) (time.Time, bool, error)
+	GetAllUpdated(ctx context.Context, metricsType string) (map[string]time.Time, error)
+	// DeleteMetrics Удаление серий metricsType, результат - количество удаленных серий. История значений не удаляется
+	DeleteMetrics(ctx context.Context, metricsType string, metricsNames []string) (int64, error)
+	// TenantIDs Арендаторы с сохраненными gauge, включая арендатора по умолчанию
+	TenantIDs(ctx context.Context) ([]string, error)
 
 	Close(ctx context.Context) error
 	Ping(ctx context.Context) ([]byte, error)
@@ -0,0 +1,101 @@
+// Package retention Фоновое прореживание и очистка истории метрик
+package retention
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/s-turchinskiy/metrics/internal/server/middleware/logger"
+	"github.com/s-turchinskiy/metrics/internal/server/models"
+	"github.com/s-turchinskiy/metrics/internal/server/repository"
+)
+
+// Compactor Периодически агрегирует историю в интервалы и удаляет устаревшие значения
+type Compactor struct {
+	rep      repository.Repository
+	policy   models.RetentionPolicy
+	interval time.Duration
+	now      func() time.Time
+
+	mutex   sync.Mutex
+	stopped bool
+	done    chan struct{}
+	cancel  context.CancelFunc
+}
+
+func New(rep repository.Repository, policy models.RetentionPolicy, interval time.Duration) *Compactor {
+	return &Compactor{
+		rep:      rep,
+		policy:   policy,
+		interval: interval,
+		now:      time.Now,
+		done:     make(chan struct{}),
+	}
+}
+
+// Run Запуск сжатия по таймеру до отмены ctx или вызова Stop.
+// Ошибка сжатия не останавливает сервер: история будет сжата при следующем срабатывании
+func (c *Compactor) Run(ctx context.Context) {
+
+	defer close(c.done)
+
+	c.mutex.Lock()
+	if c.stopped || !c.policy.Enabled() || c.interval <= 0 {
+		c.mutex.Unlock()
+		return
+	}
+	ctx, c.cancel = context.WithCancel(ctx)
+	c.mutex.Unlock()
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.Compact(ctx); err != nil && ctx.Err() == nil {
+				logger.Log.Infow("history compaction error", "error", err.Error())
+			}
+		}
+	}
+}
+
+// Compact Однократное сжатие истории
+func (c *Compactor) Compact(ctx context.Context) error {
+
+	start := time.Now()
+	err := c.rep.CompactHistory(ctx, c.policy, c.now())
+	if err != nil {
+		return err
+	}
+
+	logger.Log.Debugw("history compacted", "duration", time.Since(start).String())
+	return nil
+}
+
+// Stop Остановка таймера с ожиданием завершения текущего сжатия
+func (c *Compactor) Stop(ctx context.Context) error {
+
+	c.mutex.Lock()
+	c.stopped = true
+	if c.cancel != nil {
+		c.cancel()
+	}
+	started := c.cancel != nil
+	c.mutex.Unlock()
+
+	if !started {
+		return nil
+	}
+
+	select {
+	case <-c.done:
+		logger.Log.Infow("history compactor stopped")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,56 @@
+package retention
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/s-turchinskiy/metrics/internal/server/models"
+	mocksrepository "github.com/s-turchinskiy/metrics/internal/server/repository/mock"
+)
+
+func TestCompactor_RunStop(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	policy, err := models.ParseRetentionPolicy("raw:1h,1m:1d")
+	require.NoError(t, err)
+
+	called := make(chan struct{}, 10)
+	mock := mocksrepository.NewMockRepository(ctrl)
+	mock.EXPECT().CompactHistory(gomock.Any(), policy, gomock.Any()).
+		DoAndReturn(func(ctx context.Context, policy models.RetentionPolicy, now time.Time) error {
+			called <- struct{}{}
+			return nil
+		}).MinTimes(1)
+
+	c := New(mock, policy, 10*time.Millisecond)
+	go c.Run(context.Background())
+
+	select {
+	case <-called:
+	case <-time.After(time.Second):
+		t.Fatal("history was not compacted")
+	}
+
+	assert.NoError(t, c.Stop(context.Background()))
+}
+
+func TestCompactor_Disabled(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// при выключенной политике репозиторий не вызывается
+	mock := mocksrepository.NewMockRepository(ctrl)
+
+	c := New(mock, models.RetentionPolicy{}, 10*time.Millisecond)
+	c.Run(context.Background())
+
+	assert.NoError(t, c.Stop(context.Background()))
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"slices"
+
+	"github.com/s-turchinskiy/metrics/internal/server/models"
+)
+
+// DeleteMetric Удаление одной серии метрики
+func (s *Service) DeleteMetric(ctx context.Context, metric models.UntypedMetric) error {
+
+	if !slices.Contains(models.MetricsTypes, metric.MetricsType) {
+		return errMetricsTypeNotFound
+	}
+
+	if err := metric.Labels.Validate(); err != nil {
+		return err
+	}
+
+	key := models.SeriesKey(metric.MetricsName, metric.Labels)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var deleted int64
+	err := s.withRetry(func() (err error) {
+		deleted, err = s.Repository.DeleteMetrics(ctx, metric.MetricsType, []string{key})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return ErrMetricNotFound
+	}
+
+	return nil
+}
+
+// PurgeMetrics Массовое удаление серий по фильтру, результат - количество удаленных серий
+func (s *Service) PurgeMetrics(ctx context.Context, filter models.PurgeFilter) (int64, error) {
+
+	if err := filter.Validate(); err != nil {
+		return 0, err
+	}
+
+	types := filter.Types
+	if len(types) == 0 {
+		types = models.MetricsTypes
+	}
+
+	for _, metricsType := range types {
+		if !slices.Contains(models.MetricsTypes, metricsType) {
+			return 0, errMetricsTypeNotFound
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var result int64
+	for _, metricsType := range types {
+
+		keys, err := s.seriesKeys(ctx, metricsType)
+		if err != nil {
+			return result, err
+		}
+
+		keys = slices.DeleteFunc(keys, func(key string) bool { return !filter.Matches(key) })
+		if len(keys) == 0 {
+			continue
+		}
+
+		var deleted int64
+		err = s.withRetry(func() (err error) {
+			deleted, err = s.Repository.DeleteMetrics(ctx, metricsType, keys)
+			return err
+		})
+		if err != nil {
+			return result, err
+		}
+		result += deleted
+	}
+
+	return result, nil
+}
+
+// seriesKeys Ключи всех серий типа metricsType
+func (s *Service) seriesKeys(ctx context.Context, metricsType string) ([]string, error) {
+
+	var keys []string
+	err := s.withRetry(func() error {
+
+		keys = keys[:0]
+		switch metricsType {
+		case "gauge":
+			gauges, err := s.Repository.GetAllGauges(ctx)
+			for key := range gauges {
+				keys = append(keys, key)
+			}
+			return err
+		case "counter":
+			counters, err := s.Repository.GetAllCounters(ctx)
+			for key := range counters {
+				keys = append(keys, key)
+			}
+			return err
+		case "histogram":
+			histograms, err := s.Repository.GetAllHistograms(ctx)
+			for key := range histograms {
+				keys = append(keys, key)
+			}
+			return err
+		case "summary":
+			summaries, err := s.Repository.GetAllSummaries(ctx)
+			for key := range summaries {
+				keys = append(keys, key)
+			}
+			return err
+		default:
+			return errMetricsTypeNotFound
+		}
+	})
+
+	return keys, err
+}
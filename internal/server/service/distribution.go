@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/s-turchinskiy/metrics/internal/server/models"
+)
+
+var (
+	errHistogramNotDefined = errors.New("histogram is not defined")
+	errSummaryNotDefined   = errors.New("summary is not defined")
+	errQuantileNotDefined  = errors.New("quantile is not defined")
+	errUntypedSummary      = errors.New("summary can be updated only with JSON")
+	errNoObservations      = errors.New("no observations")
+)
+
+// withRetry Вызов fn с повтором при ошибках подключения по retryStrategy
+func (s *Service) withRetry(fn func() error) error {
+
+	var err error
+	for _, delay := range s.retryStrategy {
+		time.Sleep(delay)
+		err = fn()
+		if err == nil || !isConnectionError(err) {
+			return err
+		}
+	}
+
+	return err
+}
+
+// updateHistogram Сложение гистограммы с сохраненной, результат - гистограмма после сложения
+func (s *Service) updateHistogram(ctx context.Context, key string, h *models.Histogram) (*models.Histogram, error) {
+
+	if h == nil {
+		return nil, errHistogramNotDefined
+	}
+
+	if err := h.Validate(); err != nil {
+		return nil, err
+	}
+
+	err := s.withRetry(func() error {
+		return s.Repository.UpdateHistogram(ctx, key, *h)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result models.Histogram
+	err = s.withRetry(func() (err error) {
+		result, _, err = s.Repository.GetHistogram(ctx, key)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (s *Service) updateSummary(ctx context.Context, key string, summary *models.Summary) (*models.Summary, error) {
+
+	if summary == nil {
+		return nil, errSummaryNotDefined
+	}
+
+	if err := summary.Validate(); err != nil {
+		return nil, err
+	}
+
+	err := s.withRetry(func() error {
+		return s.Repository.UpdateSummary(ctx, key, *summary)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+// observeHistogram Добавление одного значения в гистограмму. Для новой серии используются границы из настроек
+func (s *Service) observeHistogram(ctx context.Context, key string, value float64) error {
+
+	var current models.Histogram
+	var exist bool
+	err := s.withRetry(func() (err error) {
+		current, exist, err = s.Repository.GetHistogram(ctx, key)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	bounds := s.histogramBuckets
+	if exist {
+		bounds = current.Bounds
+	}
+
+	h := models.NewHistogram(bounds)
+	h.Observe(value)
+
+	return s.withRetry(func() error {
+		return s.Repository.UpdateHistogram(ctx, key, h)
+	})
+}
+
+// getDistribution Получение histogram или summary и значений запрошенных квантилей.
+// Для несуществующей серии возвращается пустое значение
+func (s *Service) getDistribution(ctx context.Context, key string, metric models.StorageMetrics, result *models.StorageMetrics) error {
+
+	switch metric.MType {
+	case "histogram":
+
+		var h models.Histogram
+		var exist bool
+		err := s.withRetry(func() (err error) {
+			h, exist, err = s.Repository.GetHistogram(ctx, key)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if !exist {
+			h = models.NewHistogram(nil)
+		}
+		result.Histogram = &h
+
+	case "summary":
+
+		var summary models.Summary
+		err := s.withRetry(func() (err error) {
+			summary, _, err = s.Repository.GetSummary(ctx, key)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		result.Summary = &summary
+
+	default:
+		return errMetricsTypeNotFound
+	}
+
+	if len(metric.Quantiles) == 0 {
+		return nil
+	}
+
+	result.Quantiles = make([]models.Quantile, 0, len(metric.Quantiles))
+	for _, q := range metric.Quantiles {
+		value, err := quantile(result, q.Quantile)
+		if err != nil {
+			return err
+		}
+		result.Quantiles = append(result.Quantiles, models.Quantile{Quantile: q.Quantile, Value: value})
+	}
+
+	return nil
+}
+
+// quantile Значение квантиля histogram или summary. Для пустой гистограммы значения нет
+func quantile(metric *models.StorageMetrics, q float64) (float64, error) {
+
+	var value float64
+	var err error
+	switch {
+	case metric.Histogram != nil:
+		value, err = metric.Histogram.Quantile(q)
+	case metric.Summary != nil:
+		value, err = metric.Summary.Quantile(q)
+	default:
+		return 0, errMetricsTypeNotFound
+	}
+
+	if err == nil && math.IsNaN(value) {
+		return 0, errNoObservations
+	}
+
+	return value, err
+}
+
+// formatDistribution Краткое представление histogram и summary для списка метрик
+func formatDistribution(count uint64, sum float64) string {
+	return fmt.Sprintf("count=%d sum=%s", count, strconv.FormatFloat(sum, 'f', -1, 64))
+}
@@ -3,6 +3,7 @@ package service
 
 import (
 	"context"
+	"time"
 
 	"github.com/s-turchinskiy/metrics/internal/server/models"
 )
@@ -11,9 +12,15 @@ type MetricsUpdater interface {
 	UpdateMetric(ctx context.Context, metric models.UntypedMetric) error
 	UpdateTypedMetric(ctx context.Context, metric models.StorageMetrics) (*models.StorageMetrics, error)
 	UpdateTypedMetrics(ctx context.Context, metric []models.StorageMetrics) (int64, error)
+	AddTypedMetrics(ctx context.Context, metrics []models.StorageMetrics) (int64, error)
 	GetMetric(ctx context.Context, metric models.UntypedMetric) (string, error)
 	GetTypedMetric(ctx context.Context, metric models.StorageMetrics) (*models.StorageMetrics, error)
-	GetAllMetrics(ctx context.Context) (map[string]map[string]string, error)
+	GetAllMetrics(ctx context.Context, selector models.Labels) (map[string]map[string]string, error)
+	DeleteMetric(ctx context.Context, metric models.UntypedMetric) error
+	PurgeMetrics(ctx context.Context, filter models.PurgeFilter) (int64, error)
+	ExpireStaleGauges(ctx context.Context, now time.Time) (int64, error)
+	GetMetricHistory(ctx context.Context, metricsType, metricsName string, from, to time.Time) ([]models.HistorySample, error)
+	GetMetricHistoryRollups(ctx context.Context, metricsType, metricsName string, resolution time.Duration, from, to time.Time) ([]models.RollupSample, error)
 	SaveMetricsToFile(ctx context.Context) error
 	LoadMetricsFromFile(ctx context.Context) error
 	Ping(ctx context.Context) ([]byte, error)
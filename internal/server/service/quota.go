@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/s-turchinskiy/metrics/internal/server/models"
+	"github.com/s-turchinskiy/metrics/internal/server/tenant"
+)
+
+// ErrQuotaExceeded Превышена квота арендатора на число серий
+var ErrQuotaExceeded = errors.New("tenant quota exceeded")
+
+// checkQuota Проверка квоты арендатора из контекста перед созданием новой серии key.
+// Обновление существующей серии квотой не ограничивается
+func (s *Service) checkQuota(ctx context.Context, metricsType, key string) error {
+
+	limit := s.quotas.Limit(tenant.FromContext(ctx))
+	if limit == 0 {
+		return nil
+	}
+
+	exist, err := s.seriesExists(ctx, metricsType, key)
+	if err != nil || exist {
+		return err
+	}
+
+	count, err := s.countSeries(ctx)
+	if err != nil {
+		return err
+	}
+
+	if count >= limit {
+		return fmt.Errorf("%w: %d series", ErrQuotaExceeded, limit)
+	}
+
+	return nil
+}
+
+// checkBatchQuota Проверка квоты перед загрузкой пакета: gauge и counter пакета заменяют сохраненные,
+// histogram и summary добавляются к сохраненным
+func (s *Service) checkBatchQuota(ctx context.Context, stored, distributions []models.StorageMetrics) error {
+
+	limit := s.quotas.Limit(tenant.FromContext(ctx))
+	if limit == 0 {
+		return nil
+	}
+
+	series := make(map[string]struct{}, len(stored)+len(distributions))
+	for _, metric := range stored {
+		series[metric.MType+" "+metric.Name] = struct{}{}
+	}
+
+	if len(distributions) != 0 {
+
+		var histograms map[string]models.Histogram
+		var summaries map[string]models.Summary
+		err := s.withRetry(func() (err error) {
+			if histograms, err = s.Repository.GetAllHistograms(ctx); err != nil {
+				return err
+			}
+			summaries, err = s.Repository.GetAllSummaries(ctx)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		for key := range histograms {
+			series["histogram "+key] = struct{}{}
+		}
+		for key := range summaries {
+			series["summary "+key] = struct{}{}
+		}
+		for _, metric := range distributions {
+			series[metric.MType+" "+metric.Name] = struct{}{}
+		}
+	}
+
+	if len(series) > limit {
+		return fmt.Errorf("%w: %d series", ErrQuotaExceeded, limit)
+	}
+
+	return nil
+}
+
+// checkAddQuota Проверка квоты перед сложением пакета с сохраненными метриками: новыми считаются серии keys,
+// которых еще нет в хранилище
+func (s *Service) checkAddQuota(ctx context.Context, metrics []models.StorageMetrics, keys []string) error {
+
+	limit := s.quotas.Limit(tenant.FromContext(ctx))
+	if limit == 0 {
+		return nil
+	}
+
+	// серия -> есть ли она в хранилище
+	series := make(map[string]bool, len(metrics))
+	added := 0
+	for i, metric := range metrics {
+
+		name := metric.MType + " " + keys[i]
+		if _, checked := series[name]; checked {
+			continue
+		}
+
+		exist, err := s.seriesExists(ctx, metric.MType, keys[i])
+		if err != nil {
+			return err
+		}
+		series[name] = exist
+		if !exist {
+			added++
+		}
+	}
+
+	if added == 0 {
+		return nil
+	}
+
+	count, err := s.countSeries(ctx)
+	if err != nil {
+		return err
+	}
+
+	if count+added > limit {
+		return fmt.Errorf("%w: %d series", ErrQuotaExceeded, limit)
+	}
+
+	return nil
+}
+
+// seriesExists Есть ли серия key типа metricsType у арендатора из контекста
+func (s *Service) seriesExists(ctx context.Context, metricsType, key string) (bool, error) {
+
+	var exist bool
+	err := s.withRetry(func() (err error) {
+		switch metricsType {
+		case "gauge":
+			_, exist, err = s.Repository.GetGauge(ctx, key)
+		case "counter":
+			_, exist, err = s.Repository.GetCounter(ctx, key)
+		case "histogram":
+			_, exist, err = s.Repository.GetHistogram(ctx, key)
+		case "summary":
+			_, exist, err = s.Repository.GetSummary(ctx, key)
+		default:
+			err = errMetricsTypeNotFound
+		}
+		return err
+	})
+
+	return exist, err
+}
+
+// countSeries Количество серий арендатора из контекста по всем типам метрик
+func (s *Service) countSeries(ctx context.Context) (int, error) {
+
+	var count int
+	err := s.withRetry(func() error {
+
+		histograms, err := s.Repository.GetAllHistograms(ctx)
+		if err != nil {
+			return err
+		}
+
+		summaries, err := s.Repository.GetAllSummaries(ctx)
+		if err != nil {
+			return err
+		}
+
+		count = s.Repository.CountGauges(ctx) + s.Repository.CountCounters(ctx) + len(histograms) + len(summaries)
+		return nil
+	})
+
+	return count, err
+}
@@ -18,13 +18,17 @@ import (
 	"github.com/s-turchinskiy/metrics/internal/server/models"
 	"github.com/s-turchinskiy/metrics/internal/server/repository"
 	"github.com/s-turchinskiy/metrics/internal/server/settings"
+	"github.com/s-turchinskiy/metrics/internal/server/tenant"
 )
 
 type Service struct {
-	Repository      repository.Repository
-	retryStrategy   []time.Duration
-	fileStoragePath string
-	mutex           sync.Mutex
+	Repository       repository.Repository
+	retryStrategy    []time.Duration
+	fileStoragePath  string
+	histogramBuckets []float64
+	stalePolicy      models.StalePolicy
+	quotas           tenant.Quotas
+	mutex            sync.Mutex
 }
 
 // New Создание нового сервиса
@@ -33,10 +37,19 @@ func New(rep repository.Repository, retryStrategy []time.Duration, fileStoragePa
 	if len(retryStrategy) == 0 {
 		retryStrategy = []time.Duration{0}
 	}
+
+	histogramBuckets := settings.Settings.HistogramBounds
+	if len(histogramBuckets) == 0 {
+		histogramBuckets, _ = models.ParseHistogramBuckets(models.DefaultHistogramBuckets)
+	}
+
 	return &Service{
-		Repository:      rep,
-		retryStrategy:   retryStrategy,
-		fileStoragePath: fileStoragePath,
+		Repository:       rep,
+		retryStrategy:    retryStrategy,
+		fileStoragePath:  fileStoragePath,
+		histogramBuckets: histogramBuckets,
+		stalePolicy:      settings.Settings.StalePolicy,
+		quotas:           settings.Settings.TenantQuotaMap,
 	}
 }
 
@@ -49,23 +62,75 @@ type MetricsFileStorage struct {
 // UpdateTypedMetrics Массовое обновление метрик
 func (s *Service) UpdateTypedMetrics(ctx context.Context, metrics []models.StorageMetrics) (int64, error) {
 
+	// histogram и summary не участвуют в замене всех метрик, а обновляются после нее
+	stored := make([]models.StorageMetrics, 0, len(metrics))
+	var distributions []models.StorageMetrics
+	for _, metric := range metrics {
+		if err := metric.Labels.Validate(); err != nil {
+			return 0, err
+		}
+		metric.Name = models.SeriesKey(metric.Name, metric.Labels)
+
+		switch metric.MType {
+		case "histogram":
+			if metric.Histogram == nil {
+				return 0, errHistogramNotDefined
+			}
+			if err := metric.Histogram.Validate(); err != nil {
+				return 0, err
+			}
+			distributions = append(distributions, metric)
+		case "summary":
+			if metric.Summary == nil {
+				return 0, errSummaryNotDefined
+			}
+			if err := metric.Summary.Validate(); err != nil {
+				return 0, err
+			}
+			distributions = append(distributions, metric)
+		default:
+			stored = append(stored, metric)
+		}
+	}
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	for _, delay := range s.retryStrategy {
-		time.Sleep(delay)
-		result, err := s.Repository.ReloadAllMetrics(ctx, metrics)
-		if !isConnectionError(err) {
+	if err := s.checkBatchQuota(ctx, stored, distributions); err != nil {
+		return 0, err
+	}
+
+	var result int64
+	var err error
+	if len(stored) != 0 || len(distributions) == 0 {
+		err = s.withRetry(func() (err error) {
+			result, err = s.Repository.ReloadAllMetrics(ctx, stored)
+			return err
+		})
+		if err != nil {
+			return result, err
+		}
+	}
+
+	for _, metric := range distributions {
+		if metric.MType == "histogram" {
+			_, err = s.updateHistogram(ctx, metric.Name, metric.Histogram)
+		} else {
+			_, err = s.updateSummary(ctx, metric.Name, metric.Summary)
+		}
+		if err != nil {
 			return result, err
 		}
+		result++
 	}
 
-	return 0, errRetryStrategyIsNotDefined
+	return result, nil
 
 }
 
-// GetAllMetrics Получение всех метрик
-func (s *Service) GetAllMetrics(ctx context.Context) (map[string]map[string]string, error) {
+// GetAllMetrics Получение всех метрик, метки которых содержат selector.
+// Ключи результата - ключи серий: имя и метки, например CPUutilization{cpu="3"}
+func (s *Service) GetAllMetrics(ctx context.Context, selector models.Labels) (map[string]map[string]string, error) {
 
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -87,6 +152,9 @@ func (s *Service) GetAllMetrics(ctx context.Context) (map[string]map[string]stri
 	resultGauges := make(map[string]string, len(gauges))
 
 	for name, value := range gauges {
+		if !seriesMatches(name, selector) {
+			continue
+		}
 		resultGauges[name] = strconv.FormatFloat(value, 'f', -1, 64)
 	}
 	result["Gauge"] = resultGauges
@@ -104,20 +172,136 @@ func (s *Service) GetAllMetrics(ctx context.Context) (map[string]map[string]stri
 
 	resultCounters := make(map[string]string, len(counters))
 	for name, value := range counters {
+		if !seriesMatches(name, selector) {
+			continue
+		}
 		resultCounters[name] = strconv.FormatInt(value, 10)
 	}
 	result["Counter"] = resultCounters
 
+	var histograms map[string]models.Histogram
+	err = s.withRetry(func() (err error) {
+		histograms, err = s.Repository.GetAllHistograms(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resultHistograms := make(map[string]string, len(histograms))
+	for name, h := range histograms {
+		if !seriesMatches(name, selector) {
+			continue
+		}
+		resultHistograms[name] = formatDistribution(h.Count, h.Sum)
+	}
+	result["Histogram"] = resultHistograms
+
+	var summaries map[string]models.Summary
+	err = s.withRetry(func() (err error) {
+		summaries, err = s.Repository.GetAllSummaries(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resultSummaries := make(map[string]string, len(summaries))
+	for name, summary := range summaries {
+		if !seriesMatches(name, selector) {
+			continue
+		}
+		resultSummaries[name] = formatDistribution(summary.Count, summary.Sum)
+	}
+	result["Summary"] = resultSummaries
+
 	return result, nil
 }
 
 // UpdateTypedMetric Обновление типизированной метрики
 func (s *Service) UpdateTypedMetric(ctx context.Context, metric models.StorageMetrics) (*models.StorageMetrics, error) {
 
+	if err := metric.Labels.Validate(); err != nil {
+		return nil, err
+	}
+	key := models.SeriesKey(metric.Name, metric.Labels)
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	result := models.StorageMetrics{Name: metric.Name, MType: metric.MType}
+	if err := s.checkQuota(ctx, metric.MType, key); err != nil {
+		return nil, err
+	}
+
+	return s.updateTypedMetric(ctx, key, metric)
+}
+
+// AddTypedMetrics Массовое обновление метрик с семантикой UpdateTypedMetric: counter складывается с сохраненным,
+// а не заменяет его, как в UpdateTypedMetrics. Поэтому пакет можно делить на части и отправлять частями.
+// Пакет проверяется целиком до записи, чтобы ошибка в одной метрике не применяла пакет частично
+func (s *Service) AddTypedMetrics(ctx context.Context, metrics []models.StorageMetrics) (int64, error) {
+
+	keys := make([]string, 0, len(metrics))
+	for _, metric := range metrics {
+		if err := validateTypedMetric(metric); err != nil {
+			return 0, err
+		}
+		keys = append(keys, models.SeriesKey(metric.Name, metric.Labels))
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.checkAddQuota(ctx, metrics, keys); err != nil {
+		return 0, err
+	}
+
+	for i, metric := range metrics {
+		if _, err := s.updateTypedMetric(ctx, keys[i], metric); err != nil {
+			return int64(i), err
+		}
+	}
+
+	return int64(len(metrics)), nil
+}
+
+// validateTypedMetric Проверка метрики до записи: метки, тип и значение по типу
+func validateTypedMetric(metric models.StorageMetrics) error {
+
+	if err := metric.Labels.Validate(); err != nil {
+		return err
+	}
+
+	switch metric.MType {
+	case "gauge":
+		if metric.Value == nil {
+			return fmt.Errorf("value is not defined")
+		}
+	case "counter":
+		if metric.Delta == nil {
+			return fmt.Errorf("delta is not defined")
+		}
+	case "histogram":
+		if metric.Histogram == nil {
+			return errHistogramNotDefined
+		}
+		return metric.Histogram.Validate()
+	case "summary":
+		if metric.Summary == nil {
+			return errSummaryNotDefined
+		}
+		return metric.Summary.Validate()
+	default:
+		return errMetricsTypeNotFound
+	}
+
+	return nil
+}
+
+// updateTypedMetric Запись метрики в серию key, вызывается под s.mutex
+func (s *Service) updateTypedMetric(ctx context.Context, key string, metric models.StorageMetrics) (*models.StorageMetrics, error) {
+
+	result := models.StorageMetrics{Name: metric.Name, MType: metric.MType, Labels: metric.Labels}
 	switch metricsType := metric.MType; metricsType {
 	case "gauge":
 
@@ -129,7 +313,7 @@ func (s *Service) UpdateTypedMetric(ctx context.Context, metric models.StorageMe
 
 		for _, delay := range s.retryStrategy {
 			time.Sleep(delay)
-			err := s.Repository.UpdateGauge(ctx, metric.Name, newValue)
+			err := s.Repository.UpdateGauge(ctx, key, newValue)
 			if err == nil {
 				break
 			} else if !isConnectionError(err) {
@@ -146,7 +330,7 @@ func (s *Service) UpdateTypedMetric(ctx context.Context, metric models.StorageMe
 
 		for _, delay := range s.retryStrategy {
 			time.Sleep(delay)
-			err := s.Repository.UpdateCounter(ctx, metric.Name, *metric.Delta)
+			err := s.Repository.UpdateCounter(ctx, key, *metric.Delta)
 			if err == nil {
 				break
 			} else if !isConnectionError(err) {
@@ -158,7 +342,7 @@ func (s *Service) UpdateTypedMetric(ctx context.Context, metric models.StorageMe
 		var err error
 		for _, delay := range s.retryStrategy {
 			time.Sleep(delay)
-			value, _, err = s.Repository.GetCounter(ctx, metric.Name)
+			value, _, err = s.Repository.GetCounter(ctx, key)
 			if err == nil {
 				break
 			} else if !isConnectionError(err) {
@@ -168,6 +352,22 @@ func (s *Service) UpdateTypedMetric(ctx context.Context, metric models.StorageMe
 
 		result.Delta = &value
 
+	case "histogram":
+
+		h, err := s.updateHistogram(ctx, key, metric.Histogram)
+		if err != nil {
+			return nil, err
+		}
+		result.Histogram = h
+
+	case "summary":
+
+		summary, err := s.updateSummary(ctx, key, metric.Summary)
+		if err != nil {
+			return nil, err
+		}
+		result.Summary = summary
+
 	default:
 		return nil, errMetricsTypeNotFound
 	}
@@ -179,9 +379,18 @@ func (s *Service) UpdateTypedMetric(ctx context.Context, metric models.StorageMe
 // UpdateMetric Обновление нетипизированной метрики
 func (s *Service) UpdateMetric(ctx context.Context, metric models.UntypedMetric) error {
 
+	if err := metric.Labels.Validate(); err != nil {
+		return err
+	}
+	key := models.SeriesKey(metric.MetricsName, metric.Labels)
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	if err := s.checkQuota(ctx, metric.MetricsType, key); err != nil {
+		return err
+	}
+
 	switch metricsType := metric.MetricsType; metricsType {
 	case "gauge":
 
@@ -190,7 +399,7 @@ func (s *Service) UpdateMetric(ctx context.Context, metric models.UntypedMetric)
 			return fmt.Errorf("MetricsValue = %s, error: "+err.Error(), metric.MetricsValue)
 		}
 
-		err = s.Repository.UpdateGauge(ctx, metric.MetricsName, value)
+		err = s.Repository.UpdateGauge(ctx, key, value)
 		if err != nil {
 			return err
 		}
@@ -202,13 +411,25 @@ func (s *Service) UpdateMetric(ctx context.Context, metric models.UntypedMetric)
 			return err
 		}
 
-		err = s.Repository.UpdateCounter(ctx, metric.MetricsName, delta)
+		err = s.Repository.UpdateCounter(ctx, key, delta)
 		if err != nil {
 			return err
 		}
 
 		return err
 
+	case "histogram":
+
+		value, err := strconv.ParseFloat(metric.MetricsValue, 64)
+		if err != nil {
+			return fmt.Errorf("MetricsValue = %s, error: "+err.Error(), metric.MetricsValue)
+		}
+
+		return s.observeHistogram(ctx, key, value)
+
+	case "summary":
+		return errUntypedSummary
+
 	default:
 		return errMetricsTypeNotFound
 	}
@@ -219,10 +440,12 @@ func (s *Service) UpdateMetric(ctx context.Context, metric models.UntypedMetric)
 // GetTypedMetric Получение типизированной метрики
 func (s *Service) GetTypedMetric(ctx context.Context, metric models.StorageMetrics) (*models.StorageMetrics, error) {
 
+	key := models.SeriesKey(metric.Name, metric.Labels)
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	result := models.StorageMetrics{Name: metric.Name, MType: metric.MType}
+	result := models.StorageMetrics{Name: metric.Name, MType: metric.MType, Labels: metric.Labels}
 
 	switch metricsType := metric.MType; metricsType {
 	case "gauge":
@@ -233,7 +456,7 @@ func (s *Service) GetTypedMetric(ctx context.Context, metric models.StorageMetri
 
 		for _, delay := range s.retryStrategy {
 			time.Sleep(delay)
-			value, exist, err = s.Repository.GetGauge(ctx, metric.Name)
+			value, exist, err = s.Repository.GetGauge(ctx, key)
 			if err == nil {
 				break
 			} else if !isConnectionError(err) {
@@ -248,6 +471,9 @@ func (s *Service) GetTypedMetric(ctx context.Context, metric models.StorageMetri
 		}
 
 		result.Value = &value
+		if err = s.markUpdated(ctx, key, &result); err != nil {
+			return nil, err
+		}
 		return &result, nil
 
 	case "counter":
@@ -258,7 +484,7 @@ func (s *Service) GetTypedMetric(ctx context.Context, metric models.StorageMetri
 
 		for _, delay := range s.retryStrategy {
 			time.Sleep(delay)
-			value, exist, err = s.Repository.GetCounter(ctx, metric.Name)
+			value, exist, err = s.Repository.GetCounter(ctx, key)
 			if err == nil {
 				break
 			} else if !isConnectionError(err) {
@@ -273,6 +499,19 @@ func (s *Service) GetTypedMetric(ctx context.Context, metric models.StorageMetri
 		}
 
 		result.Delta = &value
+		if err = s.markUpdated(ctx, key, &result); err != nil {
+			return nil, err
+		}
+		return &result, nil
+
+	case "histogram", "summary":
+
+		if err := s.getDistribution(ctx, key, metric, &result); err != nil {
+			return nil, err
+		}
+		if err := s.markUpdated(ctx, key, &result); err != nil {
+			return nil, err
+		}
 		return &result, nil
 
 	default:
@@ -283,40 +522,123 @@ func (s *Service) GetTypedMetric(ctx context.Context, metric models.StorageMetri
 // GetMetric Получение нетипизированной метрики
 func (s *Service) GetMetric(ctx context.Context, metric models.UntypedMetric) (string, error) {
 
+	key := models.SeriesKey(metric.MetricsName, metric.Labels)
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
 	switch metricsType := metric.MetricsType; metricsType {
 	case "gauge":
 
-		value, exist, err := s.Repository.GetGauge(ctx, metric.MetricsName)
+		value, exist, err := s.Repository.GetGauge(ctx, key)
 		if err != nil {
 			return "", err
 		}
 
 		if !exist {
-			return "", fmt.Errorf("not found")
+			return "", ErrMetricNotFound
 		}
 
 		return strconv.FormatFloat(value, 'f', -1, 64), nil
 	case "counter":
 
-		value, exist, err := s.Repository.GetCounter(ctx, metric.MetricsName)
+		value, exist, err := s.Repository.GetCounter(ctx, key)
 		if err != nil {
 			return "", err
 		}
 
 		if !exist {
-			return "", fmt.Errorf("not found")
+			return "", ErrMetricNotFound
 		}
 
 		return strconv.FormatInt(value, 10), nil
 
+	case "histogram", "summary":
+
+		if metric.Quantile == nil {
+			return "", errQuantileNotDefined
+		}
+
+		var result models.StorageMetrics
+		err := s.getDistribution(ctx, key, models.StorageMetrics{MType: metricsType}, &result)
+		if err != nil {
+			return "", err
+		}
+
+		if (result.Histogram != nil && result.Histogram.Count == 0) || (result.Summary != nil && result.Summary.Quantiles == nil) {
+			return "", ErrMetricNotFound
+		}
+
+		value, err := quantile(&result, *metric.Quantile)
+		if err != nil {
+			return "", err
+		}
+
+		return strconv.FormatFloat(value, 'f', -1, 64), nil
+
 	default:
 		return "", errMetricsTypeNotFound
 	}
 }
 
+// GetMetricHistory Получение истории значений метрики за интервал времени
+func (s *Service) GetMetricHistory(ctx context.Context, metricsType, metricsName string, from, to time.Time) ([]models.HistorySample, error) {
+
+	if metricsType != "gauge" && metricsType != "counter" {
+		return nil, errMetricsTypeNotFound
+	}
+
+	if to.Before(from) {
+		return nil, errIncorrectInterval
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var result []models.HistorySample
+	var err error
+	for _, delay := range s.retryStrategy {
+		time.Sleep(delay)
+		result, err = s.Repository.GetHistory(ctx, metricsType, metricsName, from, to)
+		if err == nil {
+			break
+		} else if !isConnectionError(err) {
+			return nil, err
+		}
+	}
+
+	return result, err
+}
+
+// GetMetricHistoryRollups Получение агрегатов истории метрики с интервалом resolution
+func (s *Service) GetMetricHistoryRollups(ctx context.Context, metricsType, metricsName string, resolution time.Duration, from, to time.Time) ([]models.RollupSample, error) {
+
+	if metricsType != "gauge" && metricsType != "counter" {
+		return nil, errMetricsTypeNotFound
+	}
+
+	if to.Before(from) || resolution <= 0 {
+		return nil, errIncorrectInterval
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var result []models.RollupSample
+	var err error
+	for _, delay := range s.retryStrategy {
+		time.Sleep(delay)
+		result, err = s.Repository.GetHistoryRollups(ctx, metricsType, metricsName, resolution, from, to)
+		if err == nil {
+			break
+		} else if !isConnectionError(err) {
+			return nil, err
+		}
+	}
+
+	return result, err
+}
+
 // GetMetricsFromRepository Получение всех метрик для сохранения в файл
 func (s *Service) GetMetricsFromRepository(ctx context.Context) (data []byte, err error) {
 
@@ -423,11 +745,30 @@ func (s *Service) Ping(ctx context.Context) ([]byte, error) {
 
 }
 
+// ErrMetricNotFound Метрика не найдена
+var ErrMetricNotFound = errors.New("not found")
+
 var (
 	errMetricsTypeNotFound       = errors.New("metrics type not found")
 	errRetryStrategyIsNotDefined = errors.New("retry strategy is not defined")
+	errIncorrectInterval         = errors.New("the end of the interval is earlier than the beginning")
 )
 
+// seriesMatches Метки серии с ключом key содержат selector
+func seriesMatches(key string, selector models.Labels) bool {
+
+	if len(selector) == 0 {
+		return true
+	}
+
+	_, labels, err := models.ParseSeriesKey(key)
+	if err != nil {
+		return false
+	}
+
+	return labels.Matches(selector)
+}
+
 func isConnectionError(err error) bool {
 
 	if err == nil {
@@ -6,9 +6,16 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/s-turchinskiy/metrics/internal/server/models"
 	"github.com/s-turchinskiy/metrics/internal/server/repository"
+	"github.com/s-turchinskiy/metrics/internal/server/repository/memcashed"
 	mocksrepository "github.com/s-turchinskiy/metrics/internal/server/repository/mock"
+	"github.com/s-turchinskiy/metrics/internal/server/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strconv"
 	"testing"
+	"time"
 )
 
 func Test_isConnectionError(t *testing.T) {
@@ -233,3 +240,301 @@ func TestService_GetMetricsFromRepository(t *testing.T) {
 		})
 	}
 }
+
+func TestService_Labels(t *testing.T) {
+
+	ctx := context.Background()
+	rep := &memcashed.MemCashed{
+		Gauge:   make(map[string]float64),
+		Counter: make(map[string]int64),
+	}
+	s := New(rep, nil, "")
+
+	for cpu, value := range []float64{1.5, 2.5} {
+		_, err := s.UpdateTypedMetric(ctx, models.StorageMetrics{
+			Name: "CPUutilization", MType: "gauge", Value: &value,
+			Labels: models.Labels{"cpu": strconv.Itoa(cpu), "host": "server01"},
+		})
+		require.NoError(t, err)
+	}
+
+	value := 3.5
+	_, err := s.UpdateTypedMetric(ctx, models.StorageMetrics{Name: "CPUutilization", MType: "gauge", Value: &value})
+	require.NoError(t, err)
+
+	_, err = s.UpdateTypedMetric(ctx, models.StorageMetrics{
+		Name: "CPUutilization", MType: "gauge", Value: &value, Labels: models.Labels{"cpu-id": "1"},
+	})
+	assert.Error(t, err, "некорректное имя метки")
+
+	result, err := s.GetTypedMetric(ctx, models.StorageMetrics{
+		Name: "CPUutilization", MType: "gauge", Labels: models.Labels{"host": "server01", "cpu": "1"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2.5, *result.Value)
+	assert.Equal(t, models.Labels{"host": "server01", "cpu": "1"}, result.Labels)
+
+	result, err = s.GetTypedMetric(ctx, models.StorageMetrics{Name: "CPUutilization", MType: "gauge"})
+	require.NoError(t, err)
+	assert.Equal(t, 3.5, *result.Value)
+
+	all, err := s.GetAllMetrics(ctx, models.Labels{"cpu": "0"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{`CPUutilization{cpu="0",host="server01"}`: "1.5"}, all["Gauge"])
+
+	all, err = s.GetAllMetrics(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, len(all["Gauge"]))
+}
+
+func TestService_Histogram(t *testing.T) {
+
+	ctx := context.Background()
+	rep := &memcashed.MemCashed{
+		Gauge:   make(map[string]float64),
+		Counter: make(map[string]int64),
+	}
+	s := New(rep, nil, "")
+
+	// гистограммы от двух агентов складываются
+	for _, values := range [][]float64{{0.5, 1.5}, {1.5, 3}} {
+		h := models.NewHistogram([]float64{1, 2})
+		for _, value := range values {
+			h.Observe(value)
+		}
+		result, err := s.UpdateTypedMetric(ctx, models.StorageMetrics{Name: "Latency", MType: "histogram", Histogram: &h})
+		require.NoError(t, err)
+		require.NotNil(t, result.Histogram)
+	}
+
+	result, err := s.GetTypedMetric(ctx, models.StorageMetrics{
+		Name: "Latency", MType: "histogram", Quantiles: []models.Quantile{{Quantile: 0.5}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 2, 1}, result.Histogram.Counts)
+	assert.Equal(t, uint64(4), result.Histogram.Count)
+	assert.Equal(t, []models.Quantile{{Quantile: 0.5, Value: 1.5}}, result.Quantiles)
+
+	h := models.NewHistogram([]float64{1, 5})
+	_, err = s.UpdateTypedMetric(ctx, models.StorageMetrics{Name: "Latency", MType: "histogram", Histogram: &h})
+	assert.Error(t, err, "другие границы корзин")
+
+	err = s.UpdateMetric(ctx, models.UntypedMetric{MetricsType: "histogram", MetricsName: "Latency", MetricsValue: "0.2"})
+	require.NoError(t, err)
+
+	q := 0.2
+	value, err := s.GetMetric(ctx, models.UntypedMetric{MetricsType: "histogram", MetricsName: "Latency", Quantile: &q})
+	require.NoError(t, err)
+	assert.Equal(t, "0.5", value)
+
+	_, err = s.GetMetric(ctx, models.UntypedMetric{MetricsType: "histogram", MetricsName: "Latency"})
+	assert.Error(t, err, "квантиль не задан")
+
+	_, err = s.GetMetric(ctx, models.UntypedMetric{MetricsType: "histogram", MetricsName: "Unknown", Quantile: &q})
+	assert.Error(t, err, "гистограмма не найдена")
+
+	all, err := s.GetAllMetrics(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"Latency": "count=5 sum=6.7"}, all["Histogram"])
+}
+
+func TestService_Summary(t *testing.T) {
+
+	ctx := context.Background()
+	s := New(memcashed.New(), nil, "")
+
+	summary := models.Summary{Count: 10, Sum: 12, Quantiles: []models.Quantile{{Quantile: 0.5, Value: 1}, {Quantile: 0.99, Value: 3}}}
+	_, err := s.UpdateTypedMetric(ctx, models.StorageMetrics{Name: "Latency", MType: "summary", Summary: &summary})
+	require.NoError(t, err)
+
+	q := 0.99
+	value, err := s.GetMetric(ctx, models.UntypedMetric{MetricsType: "summary", MetricsName: "Latency", Quantile: &q})
+	require.NoError(t, err)
+	assert.Equal(t, "3", value)
+
+	q = 0.9
+	_, err = s.GetMetric(ctx, models.UntypedMetric{MetricsType: "summary", MetricsName: "Latency", Quantile: &q})
+	assert.Error(t, err, "квантиль не передавался агентом")
+
+	err = s.UpdateMetric(ctx, models.UntypedMetric{MetricsType: "summary", MetricsName: "Latency", MetricsValue: "1"})
+	assert.Error(t, err)
+}
+
+func TestService_PurgeMetrics(t *testing.T) {
+
+	ctx := context.Background()
+	s := New(memcashed.New(), nil, "")
+
+	for _, name := range []string{"CPUutilization1", "CPUutilization2", "Alloc"} {
+		value := 1.0
+		_, err := s.UpdateTypedMetric(ctx, models.StorageMetrics{Name: name, MType: "gauge", Value: &value, Labels: models.Labels{"host": "server01"}})
+		require.NoError(t, err)
+	}
+	delta := int64(1)
+	_, err := s.UpdateTypedMetric(ctx, models.StorageMetrics{Name: "CPUcount", MType: "counter", Delta: &delta})
+	require.NoError(t, err)
+
+	err = s.DeleteMetric(ctx, models.UntypedMetric{MetricsType: "gauge", MetricsName: "Alloc", Labels: models.Labels{"host": "server01"}})
+	require.NoError(t, err)
+
+	err = s.DeleteMetric(ctx, models.UntypedMetric{MetricsType: "gauge", MetricsName: "Alloc", Labels: models.Labels{"host": "server01"}})
+	assert.Error(t, err, "серия уже удалена")
+
+	_, err = s.PurgeMetrics(ctx, models.PurgeFilter{})
+	assert.Error(t, err, "пустой фильтр")
+
+	_, err = s.PurgeMetrics(ctx, models.PurgeFilter{Types: []string{"unknown"}, Prefix: "CPU"})
+	assert.Error(t, err, "неизвестный тип")
+
+	deleted, err := s.PurgeMetrics(ctx, models.PurgeFilter{Prefix: "CPU", Labels: models.Labels{"host": "server01"}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+
+	all, err := s.GetAllMetrics(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, all["Gauge"])
+	assert.Equal(t, map[string]string{"CPUcount": "1"}, all["Counter"], "серия без метки host не удаляется")
+
+	deleted, err = s.PurgeMetrics(ctx, models.PurgeFilter{Pattern: "*"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+}
+
+func TestService_ExpireStaleGauges(t *testing.T) {
+
+	ctx := context.Background()
+
+	for _, remove := range []bool{false, true} {
+
+		rep := memcashed.New()
+		s := New(rep, nil, "")
+		s.stalePolicy = models.StalePolicy{TTL: time.Minute, Remove: remove}
+
+		value := 1.5
+		for _, name := range []string{"FreeMemory", "Alloc"} {
+			_, err := s.UpdateTypedMetric(ctx, models.StorageMetrics{Name: name, MType: "gauge", Value: &value})
+			require.NoError(t, err)
+		}
+		rep.SetUpdated(ctx, "gauge", "FreeMemory", time.Now().Add(-time.Hour))
+
+		result, err := s.GetTypedMetric(ctx, models.StorageMetrics{Name: "FreeMemory", MType: "gauge"})
+		require.NoError(t, err)
+		require.NotNil(t, result.Stale)
+		assert.True(t, *result.Stale)
+		require.NotNil(t, result.Updated)
+
+		result, err = s.GetTypedMetric(ctx, models.StorageMetrics{Name: "Alloc", MType: "gauge"})
+		require.NoError(t, err)
+		require.NotNil(t, result.Stale)
+		assert.False(t, *result.Stale)
+
+		deleted, err := s.ExpireStaleGauges(ctx, time.Now())
+		require.NoError(t, err)
+
+		if remove {
+			assert.Equal(t, int64(1), deleted)
+			assert.Equal(t, map[string]float64{"Alloc": 1.5}, rep.Gauge)
+		} else {
+			assert.Equal(t, int64(0), deleted, "устаревшие gauge только помечаются")
+			assert.Len(t, rep.Gauge, 2)
+		}
+	}
+}
+
+func TestService_ExpireStaleGaugesAllTenants(t *testing.T) {
+
+	rep := memcashed.New()
+	s := New(rep, nil, "")
+	s.stalePolicy = models.StalePolicy{TTL: time.Minute, Remove: true}
+
+	value := 1.5
+	for _, id := range []string{tenant.Default, "team-a"} {
+		ctx := tenant.WithTenant(context.Background(), id)
+		_, err := s.UpdateTypedMetric(ctx, models.StorageMetrics{Name: "FreeMemory", MType: "gauge", Value: &value})
+		require.NoError(t, err)
+		rep.SetUpdated(ctx, "gauge", "FreeMemory", time.Now().Add(-time.Hour))
+	}
+
+	deleted, err := s.ExpireStaleGauges(context.Background(), time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted, "устаревшие gauge удаляются у всех арендаторов")
+	assert.Empty(t, rep.Gauge)
+	assert.Empty(t, rep.Tenants["team-a"].Gauge)
+}
+
+func TestService_TenantQuota(t *testing.T) {
+
+	ctx := tenant.WithTenant(context.Background(), "team-a")
+	s := New(memcashed.New(), nil, "")
+	s.quotas = tenant.Quotas{"team-a": 2}
+
+	value := 1.0
+	for _, name := range []string{"Alloc", "FreeMemory"} {
+		_, err := s.UpdateTypedMetric(ctx, models.StorageMetrics{Name: name, MType: "gauge", Value: &value})
+		require.NoError(t, err)
+	}
+
+	_, err := s.UpdateTypedMetric(ctx, models.StorageMetrics{Name: "Alloc", MType: "gauge", Value: &value})
+	assert.NoError(t, err, "существующая серия обновляется сверх квоты")
+
+	err = s.UpdateMetric(ctx, models.UntypedMetric{MetricsType: "counter", MetricsName: "PollCount", MetricsValue: "1"})
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+
+	_, err = s.UpdateTypedMetrics(ctx, []models.StorageMetrics{
+		{Name: "Alloc", MType: "gauge", Value: &value},
+		{Name: "HeapAlloc", MType: "gauge", Value: &value},
+		{Name: "Latency", MType: "histogram", Histogram: &models.Histogram{Bounds: []float64{1}, Counts: []uint64{0, 0}}},
+	})
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+
+	err = s.UpdateMetric(context.Background(), models.UntypedMetric{MetricsType: "counter", MetricsName: "PollCount", MetricsValue: "1"})
+	assert.NoError(t, err, "арендатор по умолчанию не ограничивается")
+}
+
+func TestService_AddTypedMetrics(t *testing.T) {
+
+	ctx := tenant.WithTenant(context.Background(), "team-a")
+	s := New(memcashed.New(), nil, "")
+	s.quotas = tenant.Quotas{"team-a": 3}
+
+	value := 1.5
+	delta := int64(2)
+	batch := []models.StorageMetrics{
+		{Name: "Alloc", MType: "gauge", Value: &value},
+		{Name: "PollCount", MType: "counter", Delta: &delta},
+		{Name: "PollCount", MType: "counter", Delta: &delta},
+	}
+
+	count, err := s.AddTypedMetrics(ctx, batch)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	_, err = s.AddTypedMetrics(ctx, batch[1:2])
+	require.NoError(t, err)
+
+	counter, _, err := s.Repository.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), counter, "counter пакета складывается с сохраненным")
+
+	_, err = s.AddTypedMetrics(ctx, []models.StorageMetrics{
+		{Name: "PollCount", MType: "counter", Delta: &delta},
+		{Name: "HeapAlloc", MType: "gauge"},
+	})
+	assert.Error(t, err)
+
+	counter, _, err = s.Repository.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), counter, "пакет с ошибкой не применяется частично")
+
+	_, err = s.AddTypedMetrics(ctx, []models.StorageMetrics{
+		{Name: "HeapAlloc", MType: "gauge", Value: &value},
+		{Name: "HeapAlloc", MType: "gauge", Value: &value},
+	})
+	require.NoError(t, err, "повтор серии в пакете считается одной новой серией")
+
+	_, err = s.AddTypedMetrics(ctx, []models.StorageMetrics{
+		{Name: "Alloc", MType: "gauge", Value: &value},
+		{Name: "FreeMemory", MType: "gauge", Value: &value},
+	})
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+}
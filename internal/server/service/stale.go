@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/s-turchinskiy/metrics/internal/server/models"
+	"github.com/s-turchinskiy/metrics/internal/server/tenant"
+)
+
+// markUpdated Время последнего обновления серии и, для gauge, признак устаревания
+func (s *Service) markUpdated(ctx context.Context, key string, result *models.StorageMetrics) error {
+
+	var updated time.Time
+	var exist bool
+	err := s.withRetry(func() (err error) {
+		updated, exist, err = s.Repository.GetUpdated(ctx, result.MType, key)
+		return err
+	})
+	if err != nil || !exist {
+		return err
+	}
+
+	result.Updated = &updated
+
+	if result.MType == "gauge" && s.stalePolicy.Enabled() {
+		stale := s.stalePolicy.IsStale(updated, time.Now())
+		result.Stale = &stale
+	}
+
+	return nil
+}
+
+// ExpireStaleGauges Удаление gauge, не обновлявшихся дольше TTL, если политика требует удаления.
+// Результат - количество удаленных серий. Проверяются серии всех арендаторов
+func (s *Service) ExpireStaleGauges(ctx context.Context, now time.Time) (int64, error) {
+
+	if !s.stalePolicy.Enabled() || !s.stalePolicy.Remove {
+		return 0, nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var tenants []string
+	err := s.withRetry(func() (err error) {
+		tenants, err = s.Repository.TenantIDs(ctx)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	var errs []error
+	for _, id := range tenants {
+		deleted, err := s.expireStaleGauges(tenant.WithTenant(ctx, id), now)
+		total += deleted
+		if err != nil {
+			errs = append(errs, fmt.Errorf("tenant %s: %w", id, err))
+		}
+	}
+
+	return total, errors.Join(errs...)
+}
+
+// expireStaleGauges Удаление устаревших gauge арендатора из ctx
+func (s *Service) expireStaleGauges(ctx context.Context, now time.Time) (int64, error) {
+
+	var updated map[string]time.Time
+	err := s.withRetry(func() (err error) {
+		updated, err = s.Repository.GetAllUpdated(ctx, "gauge")
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var keys []string
+	for key, t := range updated {
+		if s.stalePolicy.IsStale(t, now) {
+			keys = append(keys, key)
+		}
+	}
+
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	var deleted int64
+	err = s.withRetry(func() (err error) {
+		deleted, err = s.Repository.DeleteMetrics(ctx, "gauge", keys)
+		return err
+	})
+
+	return deleted, err
+}
@@ -6,12 +6,40 @@ import (
 )
 
 type JSONConfig struct {
-	Address       string `json:"address,omitempty"`
-	Restore       bool   `json:"restore,omitempty"`
-	StoreInterval string `json:"store_interval,omitempty"`
-	StoreFile     string `json:"store_file,omitempty"`
-	DatabaseDSN   string `json:"database_dsn,omitempty"`
-	CryptoKey     string `json:"crypto_key,omitempty"`
+	Address                  string `json:"address,omitempty"`
+	GRPCAddress              string `json:"grpc_address,omitempty"`
+	StatsdUDPAddress         string `json:"statsd_udp_address,omitempty"`
+	StatsdTCPAddress         string `json:"statsd_tcp_address,omitempty"`
+	Restore                  bool   `json:"restore,omitempty"`
+	StoreInterval            string `json:"store_interval,omitempty"`
+	StoreFile                string `json:"store_file,omitempty"`
+	DatabaseDSN              string `json:"database_dsn,omitempty"`
+	CryptoKey                string `json:"crypto_key,omitempty"`
+	HashKeysFile             string `json:"hash_keys_file,omitempty"`
+	CryptoKeysDir            string `json:"crypto_keys_dir,omitempty"`
+	CryptoRequired           *bool  `json:"crypto_required,omitempty"`
+	KeysReloadInterval       string `json:"keys_reload_interval,omitempty"`
+	ReplayWindow             string `json:"replay_window,omitempty"`
+	ReplayNonceCache         int    `json:"replay_nonce_cache,omitempty"`
+	Retention                string `json:"retention,omitempty"`
+	RetentionInterval        string `json:"retention_interval,omitempty"`
+	HistogramBuckets         string `json:"histogram_buckets,omitempty"`
+	GaugeTTL                 string `json:"gauge_ttl,omitempty"`
+	StaleGauges              string `json:"stale_gauges,omitempty"`
+	TLSCACert                string `json:"tls_ca_cert,omitempty"`
+	TLSCert                  string `json:"tls_cert,omitempty"`
+	TLSKey                   string `json:"tls_key,omitempty"`
+	TLSClientSubjects        string `json:"tls_client_subjects,omitempty"`
+	TrustedSubnet            string `json:"trusted_subnet,omitempty"`
+	APIKeys                  string `json:"api_keys,omitempty"`
+	APIKeysDatabase          *bool  `json:"api_keys_database,omitempty"`
+	TenantKeys               string `json:"tenant_keys,omitempty"`
+	TenantHeader             string `json:"tenant_header,omitempty"`
+	TenantQuotas             string `json:"tenant_quotas,omitempty"`
+	InfluxNameSeparator      string `json:"influx_name_separator,omitempty"`
+	InfluxTags               string `json:"influx_tags,omitempty"`
+	InfluxCounterSuffixes    string `json:"influx_counter_suffixes,omitempty"`
+	InfluxCumulativeCounters *bool  `json:"influx_cumulative_counters,omitempty"`
 }
 
 func loadConfigFromJSON(config *ProgramSettings, filePath string) error {
@@ -27,6 +55,18 @@ func loadConfigFromJSON(config *ProgramSettings, filePath string) error {
 		}
 	}
 
+	if jsonConfig.GRPCAddress != "" {
+		config.GRPCAddress = jsonConfig.GRPCAddress
+	}
+
+	if jsonConfig.StatsdUDPAddress != "" {
+		config.StatsdUDPAddress = jsonConfig.StatsdUDPAddress
+	}
+
+	if jsonConfig.StatsdTCPAddress != "" {
+		config.StatsdTCPAddress = jsonConfig.StatsdTCPAddress
+	}
+
 	if jsonConfig.Restore {
 		config.Restore = jsonConfig.Restore
 	}
@@ -46,6 +86,38 @@ func loadConfigFromJSON(config *ProgramSettings, filePath string) error {
 		config.RSAPrivateKeyPath = jsonConfig.CryptoKey
 	}
 
+	if jsonConfig.HashKeysFile != "" {
+		config.HashKeysFile = jsonConfig.HashKeysFile
+	}
+
+	if jsonConfig.CryptoKeysDir != "" {
+		config.CryptoKeysDir = jsonConfig.CryptoKeysDir
+	}
+
+	if jsonConfig.CryptoRequired != nil {
+		config.CryptoRequired = *jsonConfig.CryptoRequired
+	}
+
+	if jsonConfig.KeysReloadInterval != "" {
+		seconds, err := timeutils.ParseDurationFromString(jsonConfig.KeysReloadInterval)
+		if err != nil {
+			return err
+		}
+		config.KeysReloadInterval = seconds
+	}
+
+	if jsonConfig.ReplayWindow != "" {
+		seconds, err := timeutils.ParseDurationFromString(jsonConfig.ReplayWindow)
+		if err != nil {
+			return err
+		}
+		config.ReplayWindow = seconds
+	}
+
+	if jsonConfig.ReplayNonceCache != 0 {
+		config.ReplayNonceCache = jsonConfig.ReplayNonceCache
+	}
+
 	if jsonConfig.StoreInterval != "" {
 		seconds, err := timeutils.ParseDurationFromString(jsonConfig.StoreInterval)
 		if err != nil {
@@ -54,5 +126,89 @@ func loadConfigFromJSON(config *ProgramSettings, filePath string) error {
 		config.StoreInterval = seconds
 	}
 
+	if jsonConfig.HistogramBuckets != "" {
+		config.HistogramBuckets = jsonConfig.HistogramBuckets
+	}
+
+	if jsonConfig.InfluxNameSeparator != "" {
+		config.InfluxNameSeparator = jsonConfig.InfluxNameSeparator
+	}
+
+	if jsonConfig.InfluxTags != "" {
+		config.InfluxTags = jsonConfig.InfluxTags
+	}
+
+	if jsonConfig.InfluxCounterSuffixes != "" {
+		config.InfluxCounterSuffixes = jsonConfig.InfluxCounterSuffixes
+	}
+
+	if jsonConfig.InfluxCumulativeCounters != nil {
+		config.InfluxCumulativeCounters = *jsonConfig.InfluxCumulativeCounters
+	}
+
+	if jsonConfig.Retention != "" {
+		config.Retention = jsonConfig.Retention
+	}
+
+	if jsonConfig.RetentionInterval != "" {
+		seconds, err := timeutils.ParseDurationFromString(jsonConfig.RetentionInterval)
+		if err != nil {
+			return err
+		}
+		config.RetentionInterval = seconds
+	}
+
+	if jsonConfig.GaugeTTL != "" {
+		seconds, err := timeutils.ParseDurationFromString(jsonConfig.GaugeTTL)
+		if err != nil {
+			return err
+		}
+		config.GaugeTTL = seconds
+	}
+
+	if jsonConfig.StaleGauges != "" {
+		config.StaleGauges = jsonConfig.StaleGauges
+	}
+
+	if jsonConfig.TLSCACert != "" {
+		config.TLSCACert = jsonConfig.TLSCACert
+	}
+
+	if jsonConfig.TLSCert != "" {
+		config.TLSCert = jsonConfig.TLSCert
+	}
+
+	if jsonConfig.TLSKey != "" {
+		config.TLSKey = jsonConfig.TLSKey
+	}
+
+	if jsonConfig.TLSClientSubjects != "" {
+		config.TLSClientSubjects = jsonConfig.TLSClientSubjects
+	}
+
+	if jsonConfig.TrustedSubnet != "" {
+		config.TrustedSubnet = jsonConfig.TrustedSubnet
+	}
+
+	if jsonConfig.APIKeys != "" {
+		config.APIKeys = jsonConfig.APIKeys
+	}
+
+	if jsonConfig.APIKeysDatabase != nil {
+		config.APIKeysDatabase = *jsonConfig.APIKeysDatabase
+	}
+
+	if jsonConfig.TenantKeys != "" {
+		config.TenantKeys = jsonConfig.TenantKeys
+	}
+
+	if jsonConfig.TenantHeader != "" {
+		config.TenantHeader = jsonConfig.TenantHeader
+	}
+
+	if jsonConfig.TenantQuotas != "" {
+		config.TenantQuotas = jsonConfig.TenantQuotas
+	}
+
 	return nil
 }
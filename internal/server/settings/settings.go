@@ -3,10 +3,12 @@ package settings
 
 import (
 	"crypto/rsa"
+	"crypto/tls"
 	"errors"
 	"flag"
 	"fmt"
 	configutils "github.com/s-turchinskiy/metrics/internal/utils/configutil"
+	"net"
 	"os"
 	"reflect"
 	"strconv"
@@ -16,9 +18,16 @@ import (
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 
+	"github.com/s-turchinskiy/metrics/internal/server/auth"
+	"github.com/s-turchinskiy/metrics/internal/server/influx"
+	"github.com/s-turchinskiy/metrics/internal/server/keyring"
 	"github.com/s-turchinskiy/metrics/internal/server/middleware/logger"
+	"github.com/s-turchinskiy/metrics/internal/server/models"
+	"github.com/s-turchinskiy/metrics/internal/server/tenant"
 	"github.com/s-turchinskiy/metrics/internal/utils/fileutil"
+	"github.com/s-turchinskiy/metrics/internal/utils/netutil"
 	rsautil "github.com/s-turchinskiy/metrics/internal/utils/rsautil"
+	"github.com/s-turchinskiy/metrics/internal/utils/tlsutil"
 )
 
 const (
@@ -35,15 +44,54 @@ const (
 )
 
 type ProgramSettings struct {
-	Address                       netAddress `yaml:"ADDRESS" lc:"net address host:port to run server"`
-	StoreInterval                 int        `env:"STORE_INTERVAL" yaml:"STORE_INTERVAL" lc:"интервал времени в секундах, по истечении которого текущие показания сервера сохраняются на диск (по умолчанию 300 секунд, значение 0 делает запись синхронной)"`
-	FileStoragePath               string     `env:"FILE_STORAGE_PATH" yaml:"FILE_STORAGE_PATH" lc:"путь до файла, куда сохраняются текущие значения"`
-	Restore                       bool       `env:"RESTORE" yaml:"RESTORE" lc:"определяет загружать или нет ранее сохранённые значения из указанного файла при старте сервера"`
-	Database                      database   `env:"DATABASE_DSN" yaml:"DATABASE_DSN" lc:"данные для подключения к базе данных"`
-	HashKey                       string     `env:"KEY" yaml:"HASH_KEY" lc:"HashSHA256 ключ для обмена между агентом и сервером"`
-	RSAPrivateKeyPath             string     `env:"CRYPTO_KEY" yaml:"CRYPTO_KEY" lc:"Путь к приватному ключу RSA"`
-	EnableHTTPS                   bool       `env:"ENABLE_HTTPS" yaml:"ENABLE_HTTPS" lc:"Включить HTTPS"`
+	Address                       netAddress             `yaml:"ADDRESS" lc:"net address host:port to run server"`
+	GRPCAddress                   string                 `env:"GRPC_ADDRESS" yaml:"GRPC_ADDRESS" lc:"адрес host:port gRPC-сервера, пустая строка отключает gRPC"`
+	StatsdUDPAddress              string                 `env:"STATSD_UDP_ADDRESS" yaml:"STATSD_UDP_ADDRESS" lc:"адрес host:port для приема метрик StatsD по UDP, пустая строка отключает прием"`
+	StatsdTCPAddress              string                 `env:"STATSD_TCP_ADDRESS" yaml:"STATSD_TCP_ADDRESS" lc:"адрес host:port для приема метрик StatsD по TCP, пустая строка отключает прием"`
+	StoreInterval                 int                    `env:"STORE_INTERVAL" yaml:"STORE_INTERVAL" lc:"интервал времени в секундах, по истечении которого текущие показания сервера сохраняются на диск (по умолчанию 300 секунд, значение 0 делает запись синхронной)"`
+	FileStoragePath               string                 `env:"FILE_STORAGE_PATH" yaml:"FILE_STORAGE_PATH" lc:"путь до файла, куда сохраняются текущие значения"`
+	Restore                       bool                   `env:"RESTORE" yaml:"RESTORE" lc:"определяет загружать или нет ранее сохранённые значения из указанного файла при старте сервера"`
+	Database                      database               `env:"DATABASE_DSN" yaml:"DATABASE_DSN" lc:"данные для подключения к базе данных"`
+	HashKey                       string                 `env:"KEY" yaml:"HASH_KEY" lc:"HashSHA256 ключ для обмена между агентом и сервером"`
+	RSAPrivateKeyPath             string                 `env:"CRYPTO_KEY" yaml:"CRYPTO_KEY" lc:"Путь к приватному ключу RSA"`
+	HashKeysFile                  string                 `env:"HASH_KEYS_FILE" yaml:"HASH_KEYS_FILE" lc:"файл с действующими ключами HashSHA256 для ротации, строки <идентификатор>:<ключ> (идентификатор передается в заголовке HashSHA256-Key-ID)"`
+	CryptoKeysDir                 string                 `env:"CRYPTO_KEYS_DIR" yaml:"CRYPTO_KEYS_DIR" lc:"каталог с действующими приватными ключами RSA <идентификатор>.pem для ротации (идентификатор передается в заголовке X-Crypto-Key-ID)"`
+	CryptoRequired                bool                   `env:"CRYPTO_REQUIRED" yaml:"CRYPTO_REQUIRED" lc:"отклонять незашифрованные тела запросов обновления метрик, если задан ключ RSA (по умолчанию открытые тела принимаются)"`
+	KeysReloadInterval            int                    `env:"KEYS_RELOAD_INTERVAL" yaml:"KEYS_RELOAD_INTERVAL" lc:"интервал времени в секундах проверки изменения файлов ключей (0 - перечитывать только по SIGHUP)"`
+	ReplayWindow                  int                    `env:"REPLAY_WINDOW" yaml:"REPLAY_WINDOW" lc:"допустимое расхождение в секундах метки времени подписанного запроса с часами сервера, включает обязательную защиту от повтора (0 - отключена)"`
+	ReplayNonceCache              int                    `env:"REPLAY_NONCE_CACHE" yaml:"REPLAY_NONCE_CACHE" lc:"максимальное количество запоминаемых nonce подписанных запросов, не меньше числа запросов за два окна REPLAY_WINDOW; при переполнении запросы отклоняются с кодом 503"`
+	EnableHTTPS                   bool                   `env:"ENABLE_HTTPS" yaml:"ENABLE_HTTPS" lc:"Включить HTTPS"`
+	Retention                     string                 `env:"RETENTION" yaml:"RETENTION" lc:"сроки хранения истории метрик: raw:<срок>,<интервал>:<срок>,... (пустая строка отключает очистку истории)"`
+	RetentionInterval             int                    `env:"RETENTION_INTERVAL" yaml:"RETENTION_INTERVAL" lc:"интервал времени в секундах, через который история прореживается и очищается"`
+	RetentionPolicy               models.RetentionPolicy `yaml:"-"`
+	InfluxNameSeparator           string                 `env:"INFLUX_NAME_SEPARATOR" yaml:"INFLUX_NAME_SEPARATOR" lc:"разделитель частей имени метрики, полученной из line protocol"`
+	InfluxTags                    string                 `env:"INFLUX_TAGS" yaml:"INFLUX_TAGS" lc:"ключи тегов line protocol через запятую, значения которых включаются в имя метрики (* - все теги)"`
+	InfluxCounterSuffixes         string                 `env:"INFLUX_COUNTER_SUFFIXES" yaml:"INFLUX_COUNTER_SUFFIXES" lc:"суффиксы полей line protocol через запятую, которые сохраняются как counter"`
+	InfluxCumulativeCounters      bool                   `env:"INFLUX_CUMULATIVE_COUNTERS" yaml:"INFLUX_CUMULATIVE_COUNTERS" lc:"значения counter-полей line protocol накопленные, сохраняется разница с предыдущим значением"`
+	HistogramBuckets              string                 `env:"HISTOGRAM_BUCKETS" yaml:"HISTOGRAM_BUCKETS" lc:"границы корзин через запятую для новых гистограмм, обновляемых по одному значению через /update/histogram"`
+	HistogramBounds               []float64              `yaml:"-"`
+	GaugeTTL                      int                    `env:"GAUGE_TTL" yaml:"GAUGE_TTL" lc:"время в секундах, после которого не обновлявшийся gauge считается устаревшим (0 - не проверять)"`
+	StaleGauges                   string                 `env:"STALE_GAUGES" yaml:"STALE_GAUGES" lc:"действие с устаревшими gauge: mark - помечать в ответах, remove - удалять"`
+	StalePolicy                   models.StalePolicy     `yaml:"-"`
+	TenantKeys                    string                 `env:"TENANT_KEYS" yaml:"TENANT_KEYS" lc:"ключи API арендаторов: <ключ>:<арендатор>,... (ключ передается в заголовке Authorization или X-API-Key, при заданных ключах TENANT_HEADER не используется)"`
+	TenantHeader                  string                 `env:"TENANT_HEADER" yaml:"TENANT_HEADER" lc:"заголовок с арендатором для запросов без ключа API, по умолчанию отключен: заголовок может подставить любой клиент"`
+	TenantQuotas                  string                 `env:"TENANT_QUOTAS" yaml:"TENANT_QUOTAS" lc:"максимальное количество серий арендатора: <арендатор>:<квота>,... (* - квота остальных арендаторов, в том числе арендатора по умолчанию)"`
+	TLSCACert                     string                 `env:"TLS_CA_CERT" yaml:"TLS_CA_CERT" lc:"путь к сертификатам CA для проверки сертификатов агентов, включает взаимную аутентификацию TLS (mTLS)"`
+	TLSCert                       string                 `env:"TLS_CERT" yaml:"TLS_CERT" lc:"путь к сертификату сервера для mTLS"`
+	TLSKey                        string                 `env:"TLS_KEY" yaml:"TLS_KEY" lc:"путь к приватному ключу сертификата сервера для mTLS"`
+	TLSClientSubjects             string                 `env:"TLS_CLIENT_SUBJECTS" yaml:"TLS_CLIENT_SUBJECTS" lc:"CommonName сертификатов допустимых агентов через запятую, пустая строка - любой сертификат, подписанный CA"`
+	TLSConfig                     *tls.Config            `yaml:"-"`
+	TLSClients                    []string               `yaml:"-"`
+	TrustedSubnet                 string                 `env:"TRUSTED_SUBNET" yaml:"TRUSTED_SUBNET" lc:"доверенная подсеть в нотации CIDR для запросов обновления метрик (адрес агента из заголовка X-Real-IP или метаданных gRPC x-real-ip), пустая строка отключает проверку"`
+	TrustedNet                    *net.IPNet             `yaml:"-"`
+	APIKeys                       string                 `env:"API_KEYS" yaml:"API_KEYS" lc:"ключи API с ролями: <ключ>:<роль>|<роль>,... (роли read, write, admin), пустая строка без API_KEYS_DATABASE отключает проверку"`
+	APIKeysDatabase               bool                   `env:"API_KEYS_DATABASE" yaml:"API_KEYS_DATABASE" lc:"искать ключи API также в таблице postgres.api_keys"`
+	APIKeyMap                     auth.Keys              `yaml:"-"`
+	TenantKeyMap                  map[string]string      `yaml:"-"`
+	TenantQuotaMap                tenant.Quotas          `yaml:"-"`
+	InfluxMapping                 influx.Mapping         `yaml:"-"`
 	RSAPrivateKey                 *rsa.PrivateKey
+	Keyring                       *keyring.Keyring `yaml:"-"`
 	AsynchronousWritingDataToFile bool
 	Store                         Store
 }
@@ -73,6 +121,9 @@ func (s ProgramSettings) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
 	if err != nil {
 		return err
 	}
+	encoder.AddString("GRPCAddress", s.GRPCAddress)
+	encoder.AddString("StatsdUDPAddress", s.StatsdUDPAddress)
+	encoder.AddString("StatsdTCPAddress", s.StatsdTCPAddress)
 	encoder.AddInt("StoreInterval", s.StoreInterval)
 	encoder.AddString("FileStoragePath", s.FileStoragePath)
 	encoder.AddBool("Restore", s.Restore)
@@ -81,6 +132,30 @@ func (s ProgramSettings) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
 		return err
 	}
 
+	encoder.AddString("HashKeysFile", s.HashKeysFile)
+	encoder.AddString("CryptoKeysDir", s.CryptoKeysDir)
+	encoder.AddBool("CryptoRequired", s.CryptoRequired)
+	encoder.AddInt("KeysReloadInterval", s.KeysReloadInterval)
+	encoder.AddInt("ReplayWindow", s.ReplayWindow)
+	encoder.AddInt("ReplayNonceCache", s.ReplayNonceCache)
+	encoder.AddString("Retention", s.RetentionPolicy.String())
+	encoder.AddInt("RetentionInterval", s.RetentionInterval)
+	encoder.AddString("HistogramBuckets", s.HistogramBuckets)
+	encoder.AddInt("GaugeTTL", s.GaugeTTL)
+	encoder.AddString("StaleGauges", s.StaleGauges)
+	encoder.AddString("TLSCACert", s.TLSCACert)
+	encoder.AddString("TLSCert", s.TLSCert)
+	encoder.AddString("TLSClientSubjects", s.TLSClientSubjects)
+	encoder.AddString("TrustedSubnet", s.TrustedSubnet)
+	encoder.AddInt("APIKeys", len(s.APIKeyMap))
+	encoder.AddBool("APIKeysDatabase", s.APIKeysDatabase)
+	encoder.AddInt("TenantKeys", len(s.TenantKeyMap))
+	encoder.AddString("TenantHeader", s.TenantHeader)
+	encoder.AddString("TenantQuotas", s.TenantQuotas)
+	encoder.AddString("InfluxNameSeparator", s.InfluxNameSeparator)
+	encoder.AddString("InfluxTags", s.InfluxTags)
+	encoder.AddString("InfluxCounterSuffixes", s.InfluxCounterSuffixes)
+	encoder.AddBool("InfluxCumulativeCounters", s.InfluxCumulativeCounters)
 	encoder.AddBool("AsynchronousWritingDataToFile", s.AsynchronousWritingDataToFile)
 
 	switch s.Store {
@@ -125,10 +200,20 @@ func GetSettings() error {
 	Settings = ProgramSettings{
 		Address: netAddress{
 			Host: "localhost", Port: 8080},
-		StoreInterval:   300,
-		FileStoragePath: "store.txt",
-		Restore:         true,
-		Database:        database{Host: "localhost", DBName: "metrics", Login: "metrics"},
+		StoreInterval:            300,
+		FileStoragePath:          "store.txt",
+		Restore:                  true,
+		Database:                 database{Host: "localhost", DBName: "metrics", Login: "metrics"},
+		Retention:                "raw:24h,1m:30d,1h:365d",
+		RetentionInterval:        60,
+		HistogramBuckets:         models.DefaultHistogramBuckets,
+		StaleGauges:              models.StaleMark,
+		TenantHeader:             "",
+		InfluxNameSeparator:      influx.DefaultSeparator,
+		InfluxCounterSuffixes:    influx.DefaultCounterSuffixes,
+		InfluxCumulativeCounters: true,
+		KeysReloadInterval:       10,
+		ReplayNonceCache:         100000,
 	}
 
 	configFilePath := configutils.GetConfigFilePath()
@@ -191,6 +276,57 @@ func GetSettings() error {
 		Settings.Store = Database
 	}
 
+	Settings.RetentionPolicy, err = models.ParseRetentionPolicy(Settings.Retention)
+	if err != nil {
+		return fmt.Errorf("retention: %w", err)
+	}
+
+	Settings.HistogramBounds, err = models.ParseHistogramBuckets(Settings.HistogramBuckets)
+	if err != nil {
+		return fmt.Errorf("histogram buckets: %w", err)
+	}
+
+	Settings.StalePolicy, err = models.NewStalePolicy(Settings.GaugeTTL, Settings.StaleGauges)
+	if err != nil {
+		return fmt.Errorf("stale gauges: %w", err)
+	}
+
+	if Settings.TLSCACert != "" {
+		Settings.TLSConfig, err = tlsutil.ServerConfig(Settings.TLSCACert, Settings.TLSCert, Settings.TLSKey)
+		if err != nil {
+			return fmt.Errorf("mTLS: %w", err)
+		}
+	}
+
+	for _, subject := range strings.Split(Settings.TLSClientSubjects, ",") {
+		if subject = strings.TrimSpace(subject); subject != "" {
+			Settings.TLSClients = append(Settings.TLSClients, subject)
+		}
+	}
+
+	Settings.TrustedNet, err = netutil.ParseSubnet(Settings.TrustedSubnet)
+	if err != nil {
+		return fmt.Errorf("trusted subnet: %w", err)
+	}
+
+	Settings.APIKeyMap, err = auth.ParseKeys(Settings.APIKeys)
+	if err != nil {
+		return fmt.Errorf("api keys: %w", err)
+	}
+
+	Settings.TenantKeyMap, err = tenant.ParseKeys(Settings.TenantKeys)
+	if err != nil {
+		return fmt.Errorf("tenant keys: %w", err)
+	}
+
+	Settings.TenantQuotaMap, err = tenant.ParseQuotas(Settings.TenantQuotas)
+	if err != nil {
+		return fmt.Errorf("tenant quotas: %w", err)
+	}
+
+	Settings.InfluxMapping = influx.NewMapping(Settings.InfluxNameSeparator, Settings.InfluxTags,
+		Settings.InfluxCounterSuffixes, Settings.InfluxCumulativeCounters)
+
 	if Settings.RSAPrivateKeyPath != "" {
 		Settings.RSAPrivateKey, err = rsautil.ReadPrivateKey(Settings.RSAPrivateKeyPath)
 		if err != nil {
@@ -199,6 +335,11 @@ func GetSettings() error {
 		}
 	}
 
+	Settings.Keyring, err = keyring.New(Settings.HashKey, Settings.RSAPrivateKey, Settings.HashKeysFile, Settings.CryptoKeysDir)
+	if err != nil {
+		return fmt.Errorf("keys: %w", err)
+	}
+
 	logger.LogNoSugar.Info("Settings", zap.Inline(Settings)) //если Sugar, то выводит без имен
 	return nil
 }
@@ -207,13 +348,41 @@ func parseFlags() {
 
 	flag.Var(&Settings.Address, "a", "Net address host:port")
 	//flag.StringVar(&flagRunAddr, "a", "localhost:8080", "address and port to run server")
+	flag.StringVar(&Settings.GRPCAddress, "grpc-address", Settings.GRPCAddress, "Адрес host:port gRPC-сервера, пустая строка отключает gRPC")
+	flag.StringVar(&Settings.StatsdUDPAddress, "statsd-udp", Settings.StatsdUDPAddress, "Адрес host:port для приема метрик StatsD по UDP")
+	flag.StringVar(&Settings.StatsdTCPAddress, "statsd-tcp", Settings.StatsdTCPAddress, "Адрес host:port для приема метрик StatsD по TCP")
 	flag.IntVar(&Settings.StoreInterval, "i", Settings.StoreInterval, "Интервал времени в секундах, по истечении которого текущие показания сервера сохраняются на диск (по умолчанию 300 секунд, значение 0 делает запись синхронной)")
 	flag.StringVar(&Settings.FileStoragePath, "f", Settings.FileStoragePath, "Путь до файла, куда сохраняются текущие значения")
 	flag.BoolVar(&Settings.Restore, "r", Settings.Restore, "Определяет загружать или нет ранее сохранённые значения из указанного файла при старте сервера")
 	flag.Var(&Settings.Database, "d", "path to database")
 	flag.StringVar(&Settings.HashKey, "k", "", "HashSHA256 key")
 	flag.StringVar(&Settings.RSAPrivateKeyPath, "crypto-key", "", "Путь до файла с приватным ключом")
+	flag.StringVar(&Settings.HashKeysFile, "hash-keys-file", Settings.HashKeysFile, "Файл с действующими ключами HashSHA256, строки <идентификатор>:<ключ>")
+	flag.StringVar(&Settings.CryptoKeysDir, "crypto-keys-dir", Settings.CryptoKeysDir, "Каталог с действующими приватными ключами RSA <идентификатор>.pem")
+	flag.BoolVar(&Settings.CryptoRequired, "crypto-required", Settings.CryptoRequired, "Отклонять незашифрованные тела запросов обновления метрик, если задан ключ RSA")
+	flag.IntVar(&Settings.KeysReloadInterval, "keys-reload-interval", Settings.KeysReloadInterval, "Интервал времени в секундах проверки изменения файлов ключей (0 - только по SIGHUP)")
+	flag.IntVar(&Settings.ReplayWindow, "replay-window", Settings.ReplayWindow, "Допустимое расхождение в секундах метки времени подписанного запроса, включает защиту от повтора (0 - отключена)")
+	flag.IntVar(&Settings.ReplayNonceCache, "replay-nonce-cache", Settings.ReplayNonceCache, "Максимальное количество запоминаемых nonce подписанных запросов")
 	flag.BoolVar(&Settings.EnableHTTPS, "s", Settings.EnableHTTPS, "Определяет включен ли HTTPS")
+	flag.StringVar(&Settings.Retention, "retention", Settings.Retention, "Сроки хранения истории метрик, например raw:24h,1m:30d,1h:365d")
+	flag.IntVar(&Settings.RetentionInterval, "retention-interval", Settings.RetentionInterval, "Интервал времени в секундах, через который история прореживается и очищается")
+	flag.StringVar(&Settings.HistogramBuckets, "histogram-buckets", Settings.HistogramBuckets, "Границы корзин через запятую для новых гистограмм, обновляемых по одному значению")
+	flag.IntVar(&Settings.GaugeTTL, "gauge-ttl", Settings.GaugeTTL, "Время в секундах, после которого не обновлявшийся gauge считается устаревшим (0 - не проверять)")
+	flag.StringVar(&Settings.StaleGauges, "stale-gauges", Settings.StaleGauges, "Действие с устаревшими gauge: mark - помечать в ответах, remove - удалять")
+	flag.StringVar(&Settings.TLSCACert, "tls-ca", Settings.TLSCACert, "Путь к сертификатам CA для проверки сертификатов агентов (mTLS)")
+	flag.StringVar(&Settings.TLSCert, "tls-cert", Settings.TLSCert, "Путь к сертификату сервера для mTLS")
+	flag.StringVar(&Settings.TLSKey, "tls-key", Settings.TLSKey, "Путь к приватному ключу сертификата сервера для mTLS")
+	flag.StringVar(&Settings.TLSClientSubjects, "tls-client-subjects", Settings.TLSClientSubjects, "CommonName сертификатов допустимых агентов через запятую")
+	flag.StringVar(&Settings.TrustedSubnet, "t", Settings.TrustedSubnet, "Доверенная подсеть в нотации CIDR для запросов обновления метрик")
+	flag.StringVar(&Settings.APIKeys, "api-keys", Settings.APIKeys, "Ключи API с ролями: <ключ>:<роль>|<роль>,... (роли read, write, admin)")
+	flag.BoolVar(&Settings.APIKeysDatabase, "api-keys-database", Settings.APIKeysDatabase, "Искать ключи API также в таблице postgres.api_keys")
+	flag.StringVar(&Settings.TenantKeys, "tenant-keys", Settings.TenantKeys, "Ключи API арендаторов: <ключ>:<арендатор>,...")
+	flag.StringVar(&Settings.TenantHeader, "tenant-header", Settings.TenantHeader, "Заголовок с арендатором для запросов без ключа API")
+	flag.StringVar(&Settings.TenantQuotas, "tenant-quotas", Settings.TenantQuotas, "Максимальное количество серий арендатора: <арендатор>:<квота>,...")
+	flag.StringVar(&Settings.InfluxNameSeparator, "influx-separator", Settings.InfluxNameSeparator, "Разделитель частей имени метрики, полученной из line protocol")
+	flag.StringVar(&Settings.InfluxTags, "influx-tags", Settings.InfluxTags, "Ключи тегов line protocol через запятую, значения которых включаются в имя метрики (* - все теги)")
+	flag.StringVar(&Settings.InfluxCounterSuffixes, "influx-counter-suffixes", Settings.InfluxCounterSuffixes, "Суффиксы полей line protocol через запятую, которые сохраняются как counter")
+	flag.BoolVar(&Settings.InfluxCumulativeCounters, "influx-cumulative-counters", Settings.InfluxCumulativeCounters, "Значения counter-полей line protocol накопленные")
 	flag.Parse()
 
 }
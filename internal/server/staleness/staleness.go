@@ -0,0 +1,98 @@
+// Package staleness Фоновое удаление устаревших gauge от агентов, переставших присылать метрики
+package staleness
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/s-turchinskiy/metrics/internal/server/middleware/logger"
+	"github.com/s-turchinskiy/metrics/internal/server/models"
+)
+
+// Expirer Удаление gauge, не обновлявшихся дольше TTL
+type Expirer interface {
+	ExpireStaleGauges(ctx context.Context, now time.Time) (int64, error)
+}
+
+// Remover Периодически удаляет устаревшие gauge по политике StalePolicy
+type Remover struct {
+	expirer  Expirer
+	policy   models.StalePolicy
+	interval time.Duration
+	now      func() time.Time
+
+	mutex   sync.Mutex
+	stopped bool
+	done    chan struct{}
+	cancel  context.CancelFunc
+}
+
+// New Создание Remover. Устаревшие gauge удаляются с задержкой не больше interval после истечения TTL
+func New(expirer Expirer, policy models.StalePolicy, interval time.Duration) *Remover {
+	return &Remover{
+		expirer:  expirer,
+		policy:   policy,
+		interval: interval,
+		now:      time.Now,
+		done:     make(chan struct{}),
+	}
+}
+
+// Run Запуск удаления по таймеру до отмены ctx или вызова Stop.
+// Работает только при политике с удалением устаревших gauge
+func (r *Remover) Run(ctx context.Context) {
+
+	defer close(r.done)
+
+	r.mutex.Lock()
+	if r.stopped || !r.policy.Enabled() || !r.policy.Remove || r.interval <= 0 {
+		r.mutex.Unlock()
+		return
+	}
+	ctx, r.cancel = context.WithCancel(ctx)
+	r.mutex.Unlock()
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := r.expirer.ExpireStaleGauges(ctx, r.now())
+			if err != nil && ctx.Err() == nil {
+				logger.Log.Infow("stale gauges removal error", "error", err.Error())
+				continue
+			}
+			if deleted != 0 {
+				logger.Log.Infow("stale gauges removed", "count", deleted)
+			}
+		}
+	}
+}
+
+// Stop Остановка таймера с ожиданием завершения текущего удаления
+func (r *Remover) Stop(ctx context.Context) error {
+
+	r.mutex.Lock()
+	r.stopped = true
+	if r.cancel != nil {
+		r.cancel()
+	}
+	started := r.cancel != nil
+	r.mutex.Unlock()
+
+	if !started {
+		return nil
+	}
+
+	select {
+	case <-r.done:
+		logger.Log.Infow("stale gauges remover stopped")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,51 @@
+package staleness
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/s-turchinskiy/metrics/internal/server/models"
+)
+
+type expirerFunc func(ctx context.Context, now time.Time) (int64, error)
+
+func (f expirerFunc) ExpireStaleGauges(ctx context.Context, now time.Time) (int64, error) {
+	return f(ctx, now)
+}
+
+func TestRemover_RunStop(t *testing.T) {
+
+	called := make(chan struct{}, 10)
+	expirer := expirerFunc(func(ctx context.Context, now time.Time) (int64, error) {
+		called <- struct{}{}
+		return 1, nil
+	})
+
+	r := New(expirer, models.StalePolicy{TTL: time.Minute, Remove: true}, 10*time.Millisecond)
+	go r.Run(context.Background())
+
+	select {
+	case <-called:
+	case <-time.After(time.Second):
+		t.Fatal("stale gauges were not removed")
+	}
+
+	assert.NoError(t, r.Stop(context.Background()))
+}
+
+func TestRemover_MarkOnly(t *testing.T) {
+
+	// без удаления устаревших gauge Expirer не вызывается
+	expirer := expirerFunc(func(ctx context.Context, now time.Time) (int64, error) {
+		t.Error("unexpected call")
+		return 0, nil
+	})
+
+	r := New(expirer, models.StalePolicy{TTL: time.Minute}, 10*time.Millisecond)
+	r.Run(context.Background())
+
+	assert.NoError(t, r.Stop(context.Background()))
+}
@@ -0,0 +1,110 @@
+package statsd
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Типы StatsD. Поддерживаются только counter и gauge, остальные учитываются в StatsdUnsupported
+const (
+	TypeCounter = "c"
+	TypeGauge   = "g"
+	TypeTimer   = "ms"
+	TypeHisto   = "h"
+	TypeSet     = "s"
+	TypeDist    = "d"
+)
+
+var (
+	errEmptyName      = errors.New("metric name is empty")
+	errIncorrectLine  = errors.New("incorrect statsd line, need name:value|type")
+	errIncorrectValue = errors.New("incorrect statsd value")
+	errIncorrectRate  = errors.New("incorrect statsd sample rate")
+)
+
+// Metric Разобранная строка StatsD вида name:value|type|@rate|#tags
+type Metric struct {
+	Name       string
+	Type       string
+	Value      float64
+	SampleRate float64
+	// Relative для gauge со знаком (+5, -3): значение прибавляется к текущему
+	Relative bool
+}
+
+// ParseLine Разбор одной строки StatsD. Теги (#tag:value) пропускаются
+func ParseLine(line string) (Metric, error) {
+
+	name, rest, found := strings.Cut(line, ":")
+	if !found {
+		return Metric{}, errIncorrectLine
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Metric{}, errEmptyName
+	}
+
+	parts := strings.Split(rest, "|")
+	if len(parts) < 2 {
+		return Metric{}, errIncorrectLine
+	}
+
+	metric := Metric{Name: name, Type: strings.TrimSpace(parts[1]), SampleRate: 1}
+
+	// значения set - произвольные строки, тип не поддерживается, поэтому значение не разбирается
+	rawValue := strings.TrimSpace(parts[0])
+	if metric.Type != TypeSet {
+		value, err := strconv.ParseFloat(rawValue, 64)
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			return Metric{}, fmt.Errorf("%w %q", errIncorrectValue, rawValue)
+		}
+		metric.Value = value
+		metric.Relative = metric.Type == TypeGauge && (rawValue[0] == '+' || rawValue[0] == '-')
+	}
+
+	for _, part := range parts[2:] {
+
+		part = strings.TrimSpace(part)
+		if !strings.HasPrefix(part, "@") {
+			continue
+		}
+
+		rate, err := strconv.ParseFloat(part[1:], 64)
+		if err != nil || rate <= 0 || rate > 1 {
+			return Metric{}, fmt.Errorf("%w %q", errIncorrectRate, part)
+		}
+		metric.SampleRate = rate
+	}
+
+	return metric, nil
+}
+
+// ParsePacket Разбор пакета из нескольких строк, разделенных переводом строки.
+// Ошибочные строки не прерывают разбор остальных
+func ParsePacket(packet []byte) ([]Metric, []error) {
+
+	var metrics []Metric
+	var errs []error
+
+	for _, line := range strings.Split(string(packet), "\n") {
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		metric, err := ParseLine(line)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %q: %w", line, err))
+			continue
+		}
+
+		metrics = append(metrics, metric)
+	}
+
+	return metrics, errs
+}
@@ -0,0 +1,85 @@
+package statsd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLine(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		line    string
+		want    Metric
+		wantErr bool
+	}{
+		{
+			name: "Counter. Успешно",
+			line: "requests:1|c",
+			want: Metric{Name: "requests", Type: TypeCounter, Value: 1, SampleRate: 1},
+		},
+		{
+			name: "Counter с частотой выборки и тегами. Успешно",
+			line: "requests:2|c|@0.5|#env:prod",
+			want: Metric{Name: "requests", Type: TypeCounter, Value: 2, SampleRate: 0.5},
+		},
+		{
+			name: "Gauge. Успешно",
+			line: "queue.size:42|g",
+			want: Metric{Name: "queue.size", Type: TypeGauge, Value: 42, SampleRate: 1},
+		},
+		{
+			name: "Gauge с изменением на величину. Успешно",
+			line: "queue.size:-3|g",
+			want: Metric{Name: "queue.size", Type: TypeGauge, Value: -3, SampleRate: 1, Relative: true},
+		},
+		{
+			name: "Timer разбирается, но не поддерживается сервером. Успешно",
+			line: "latency:3|ms",
+			want: Metric{Name: "latency", Type: TypeTimer, Value: 3, SampleRate: 1},
+		},
+		{
+			name:    "Нет типа. Ошибка",
+			line:    "requests:1",
+			wantErr: true,
+		},
+		{
+			name:    "Нет имени. Ошибка",
+			line:    ":1|c",
+			wantErr: true,
+		},
+		{
+			name:    "Нечисловое значение. Ошибка",
+			line:    "requests:one|c",
+			wantErr: true,
+		},
+		{
+			name:    "Частота выборки больше 1. Ошибка",
+			line:    "requests:1|c|@2",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLine(tt.line)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParsePacket(t *testing.T) {
+
+	metrics, errs := ParsePacket([]byte("a:1|c\nbroken\n\nb:2|g\n"))
+
+	assert.Len(t, errs, 1)
+	assert.Equal(t, []Metric{
+		{Name: "a", Type: TypeCounter, Value: 1, SampleRate: 1},
+		{Name: "b", Type: TypeGauge, Value: 2, SampleRate: 1},
+	}, metrics)
+}
@@ -0,0 +1,260 @@
+// Package statsd Прием метрик в формате StatsD по UDP и TCP
+package statsd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"math"
+	"net"
+	"sync"
+
+	"github.com/s-turchinskiy/metrics/internal/server/middleware/logger"
+	"github.com/s-turchinskiy/metrics/internal/server/models"
+	"github.com/s-turchinskiy/metrics/internal/server/service"
+)
+
+const (
+	// MetricUnsupported Счетчик строк неподдерживаемых типов (ms, h, s, d и т.д.)
+	MetricUnsupported = "StatsdUnsupported"
+	// MetricParseErrors Счетчик строк, которые не удалось разобрать
+	MetricParseErrors = "StatsdParseErrors"
+
+	maxUDPPacketSize = 65535
+)
+
+type Server struct {
+	service service.MetricsUpdater
+	udpAddr string
+	tcpAddr string
+
+	// gaugeMutex Изменение gauge на величину - чтение и запись, между которыми gauge не должен меняться
+	gaugeMutex sync.Mutex
+
+	mutex       sync.Mutex
+	udpConn     net.PacketConn
+	tcpListener net.Listener
+	connections map[net.Conn]struct{}
+	wg          sync.WaitGroup
+	cancel      context.CancelFunc
+}
+
+// New Создание сервера. Пустой адрес отключает соответствующий протокол
+func New(svc service.MetricsUpdater, udpAddr, tcpAddr string) *Server {
+	return &Server{
+		service:     svc,
+		udpAddr:     udpAddr,
+		tcpAddr:     tcpAddr,
+		connections: make(map[net.Conn]struct{}),
+	}
+}
+
+// Run Открытие портов и прием метрик в фоне до вызова Shutdown
+func (s *Server) Run(ctx context.Context) error {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	ctx, s.cancel = context.WithCancel(ctx)
+
+	if s.udpAddr != "" {
+		conn, err := net.ListenPacket("udp", s.udpAddr)
+		if err != nil {
+			return err
+		}
+		s.udpConn = conn
+
+		s.wg.Add(1)
+		go s.serveUDP(ctx, conn)
+	}
+
+	if s.tcpAddr != "" {
+		listener, err := net.Listen("tcp", s.tcpAddr)
+		if err != nil {
+			if s.udpConn != nil {
+				s.udpConn.Close()
+			}
+			return err
+		}
+		s.tcpListener = listener
+
+		s.wg.Add(1)
+		go s.serveTCP(ctx, listener)
+	}
+
+	return nil
+}
+
+func (s *Server) serveUDP(ctx context.Context, conn net.PacketConn) {
+
+	defer s.wg.Done()
+
+	buf := make([]byte, maxUDPPacketSize)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				logger.Log.Infow("statsd udp read error", "error", err.Error())
+			}
+			return
+		}
+
+		s.handlePacket(ctx, buf[:n])
+	}
+}
+
+func (s *Server) serveTCP(ctx context.Context, listener net.Listener) {
+
+	defer s.wg.Done()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				logger.Log.Infow("statsd tcp accept error", "error", err.Error())
+			}
+			return
+		}
+
+		s.mutex.Lock()
+		s.connections[conn] = struct{}{}
+		s.mutex.Unlock()
+
+		s.wg.Add(1)
+		go s.serveConn(ctx, conn)
+	}
+}
+
+// serveConn По TCP строки разделяются переводом строки
+func (s *Server) serveConn(ctx context.Context, conn net.Conn) {
+
+	defer s.wg.Done()
+	defer func() {
+		s.mutex.Lock()
+		delete(s.connections, conn)
+		s.mutex.Unlock()
+		conn.Close()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		s.handlePacket(ctx, scanner.Bytes())
+	}
+
+	if err := scanner.Err(); err != nil && !errors.Is(err, net.ErrClosed) {
+		logger.Log.Infow("statsd tcp read error", "error", err.Error(), "remote", conn.RemoteAddr().String())
+	}
+}
+
+func (s *Server) handlePacket(ctx context.Context, packet []byte) {
+
+	metrics, errs := ParsePacket(packet)
+	for _, err := range errs {
+		logger.Log.Debugw("statsd parse error", "error", err.Error())
+	}
+	if len(errs) != 0 {
+		s.countSelfMetric(ctx, MetricParseErrors, int64(len(errs)))
+	}
+
+	var unsupported int64
+	for _, metric := range metrics {
+
+		ok, err := s.update(ctx, metric)
+		if err != nil {
+			logger.Log.Infow("statsd update error", "error", err.Error(), "name", metric.Name)
+		}
+		if !ok {
+			unsupported++
+		}
+	}
+
+	if unsupported != 0 {
+		s.countSelfMetric(ctx, MetricUnsupported, unsupported)
+	}
+}
+
+// update Обновление метрики сервера, ok = false для неподдерживаемого типа
+func (s *Server) update(ctx context.Context, metric Metric) (bool, error) {
+
+	result := models.StorageMetrics{Name: metric.Name}
+
+	switch metric.Type {
+	case TypeCounter:
+
+		delta := int64(math.Round(metric.Value / metric.SampleRate))
+		result.MType = "counter"
+		result.Delta = &delta
+
+	case TypeGauge:
+
+		s.gaugeMutex.Lock()
+		defer s.gaugeMutex.Unlock()
+
+		value := metric.Value
+		if metric.Relative {
+			current, err := s.service.GetTypedMetric(ctx, models.StorageMetrics{Name: metric.Name, MType: "gauge"})
+			if err != nil {
+				return true, err
+			}
+			value += *current.Value
+		}
+		result.MType = "gauge"
+		result.Value = &value
+
+	default:
+		return false, nil
+	}
+
+	_, err := s.service.UpdateTypedMetric(ctx, result)
+	return true, err
+}
+
+func (s *Server) countSelfMetric(ctx context.Context, name string, delta int64) {
+
+	_, err := s.service.UpdateTypedMetric(ctx, models.StorageMetrics{Name: name, MType: "counter", Delta: &delta})
+	if err != nil {
+		logger.Log.Infow("statsd self metric error", "error", err.Error(), "name", name)
+	}
+}
+
+// Shutdown Закрытие портов и соединений с ожиданием обработки полученных пакетов
+func (s *Server) Shutdown(ctx context.Context) error {
+
+	s.mutex.Lock()
+	var errs []error
+	if s.udpConn != nil {
+		errs = append(errs, s.udpConn.Close())
+	}
+	if s.tcpListener != nil {
+		errs = append(errs, s.tcpListener.Close())
+	}
+	for conn := range s.connections {
+		errs = append(errs, conn.Close())
+	}
+	s.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		errs = append(errs, ctx.Err())
+	}
+
+	if s.cancel != nil {
+		s.cancel()
+	}
+
+	err := errors.Join(errs...)
+	if err != nil {
+		logger.Log.Infow("statsd server stopped with error", "error", err.Error())
+	} else {
+		logger.Log.Infow("statsd server stopped")
+	}
+
+	return err
+}
@@ -0,0 +1,59 @@
+package statsd
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/s-turchinskiy/metrics/internal/server/repository/memcashed"
+	"github.com/s-turchinskiy/metrics/internal/server/service"
+)
+
+func TestServer_handlePacket(t *testing.T) {
+
+	ctx := context.Background()
+	storage := memcashed.New()
+	s := New(service.New(storage, []time.Duration{0}, ""), "", "")
+
+	s.handlePacket(ctx, []byte("requests:1|c\nrequests:2|c|@0.5\nqueue:10|g\nqueue:-3|g\nlatency:3|ms\nusers:u1|s\nbroken"))
+
+	assert.Equal(t, map[string]int64{
+		"requests":        5,
+		MetricUnsupported: 2,
+		MetricParseErrors: 1,
+	}, storage.Counter)
+	assert.Equal(t, map[string]float64{"queue": 7}, storage.Gauge)
+}
+
+func TestServer_Run(t *testing.T) {
+
+	ctx := context.Background()
+	storage := memcashed.New()
+	svc := service.New(storage, []time.Duration{0}, "")
+	s := New(svc, "127.0.0.1:0", "127.0.0.1:0")
+	require.NoError(t, s.Run(ctx))
+
+	udp, err := net.Dial("udp", s.udpConn.LocalAddr().String())
+	require.NoError(t, err)
+	defer udp.Close()
+	_, err = udp.Write([]byte("udp:1|c"))
+	require.NoError(t, err)
+
+	tcp, err := net.Dial("tcp", s.tcpListener.Addr().String())
+	require.NoError(t, err)
+	_, err = tcp.Write([]byte("tcp:2|c\ntcp:3|c\n"))
+	require.NoError(t, err)
+	require.NoError(t, tcp.Close())
+
+	assert.Eventually(t, func() bool {
+		counters, err := svc.GetAllMetrics(ctx, nil)
+		require.NoError(t, err)
+		return counters["Counter"]["udp"] == "1" && counters["Counter"]["tcp"] == "5"
+	}, time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, s.Shutdown(ctx))
+}
@@ -0,0 +1,110 @@
+// Package tenant Идентификатор арендатора в контексте запроса, ключи API арендаторов и квоты
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Default Арендатор по умолчанию: запросы без ключа API и заголовка арендатора,
+// а также StatsD и внутренние вызовы сервера
+const Default = ""
+
+// AnyTenant Ключ квоты, действующей для всех арендаторов без отдельной квоты
+const AnyTenant = "*"
+
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+type contextKey struct{}
+
+// WithTenant Контекст с арендатором id
+func WithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext Арендатор из контекста, Default если не задан
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Validate Идентификатор арендатора: латинские буквы, цифры, _ . -, не длиннее 64 символов
+func Validate(id string) error {
+
+	if !idPattern.MatchString(id) {
+		return fmt.Errorf("incorrect tenant %q", id)
+	}
+	return nil
+}
+
+// ParseKeys Соответствие ключей API арендаторам из строки вида key1:tenant1,key2:tenant2
+func ParseKeys(s string) (map[string]string, error) {
+
+	result := make(map[string]string)
+	for key, id := range pairs(s) {
+		if key == "" {
+			return nil, fmt.Errorf("empty api key for tenant %q", id)
+		}
+		if err := Validate(id); err != nil {
+			return nil, err
+		}
+		result[key] = id
+	}
+
+	return result, nil
+}
+
+// Quotas Максимальное количество серий метрик арендатора. AnyTenant - квота по умолчанию
+type Quotas map[string]int
+
+// ParseQuotas Квоты из строки вида tenant1:1000,*:500
+func ParseQuotas(s string) (Quotas, error) {
+
+	result := make(Quotas)
+	for id, value := range pairs(s) {
+		if id != AnyTenant {
+			if err := Validate(id); err != nil {
+				return nil, err
+			}
+		}
+
+		quota, err := strconv.Atoi(value)
+		if err != nil || quota < 0 {
+			return nil, fmt.Errorf("incorrect quota %q for tenant %q", value, id)
+		}
+		result[id] = quota
+	}
+
+	return result, nil
+}
+
+// Limit Квота арендатора id, 0 - без ограничений. На арендатора по умолчанию, куда попадают
+// запросы без ключа API, действует квота AnyTenant
+func (q Quotas) Limit(id string) int {
+
+	if quota, exist := q[id]; exist {
+		return quota
+	}
+
+	return q[AnyTenant]
+}
+
+// pairs Разбор строки вида a:b,c:d, строки без двоеточия дают пустое значение
+func pairs(s string) map[string]string {
+
+	result := make(map[string]string)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		key, value, _ := strings.Cut(part, ":")
+		result[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	return result
+}
@@ -0,0 +1,56 @@
+package tenant
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromContext(t *testing.T) {
+
+	assert.Equal(t, Default, FromContext(context.Background()))
+	assert.Equal(t, "team-a", FromContext(WithTenant(context.Background(), "team-a")))
+}
+
+func TestParseKeys(t *testing.T) {
+
+	keys, err := ParseKeys("secret1:team-a, secret2:team_b")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"secret1": "team-a", "secret2": "team_b"}, keys)
+
+	_, err = ParseKeys("secret1:team a")
+	assert.Error(t, err, "недопустимый символ в арендаторе")
+
+	_, err = ParseKeys("secret1")
+	assert.Error(t, err, "арендатор не задан")
+}
+
+func TestQuotas_Limit(t *testing.T) {
+
+	quotas, err := ParseQuotas("team-a:100,*:10")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		tenant string
+		want   int
+	}{
+		{name: "Отдельная квота", tenant: "team-a", want: 100},
+		{name: "Квота по умолчанию", tenant: "team-b", want: 10},
+		{name: "Арендатор по умолчанию с квотой по умолчанию", tenant: Default, want: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, quotas.Limit(tt.tenant))
+		})
+	}
+
+	_, err = ParseQuotas("team-a:-1")
+	assert.Error(t, err)
+
+	_, err = ParseQuotas("team-a:many")
+	assert.Error(t, err)
+}
@@ -0,0 +1,133 @@
+// Package grpcutil Общие процедуры подписи gRPC-сообщений ключом HashSHA256
+package grpcutil
+
+import (
+	"crypto/hmac"
+	"errors"
+	"net/http"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/s-turchinskiy/metrics/internal/utils/hashutil"
+)
+
+const (
+	// HashMetadataKey Ключ метаданных с хэшем запроса или ответа, аналог заголовка HashSHA256
+	HashMetadataKey = "hashsha256"
+
+	// KeyIDMetadataKey Ключ метаданных с идентификатором ключа подписи, аналог заголовка HashSHA256-Key-ID
+	KeyIDMetadataKey = "hashsha256-key-id"
+
+	// TimestampMetadataKey и NonceMetadataKey Защита от повтора запроса или потока,
+	// аналог заголовков HashSHA256-Timestamp и HashSHA256-Nonce. Значения входят в подписываемые данные
+	TimestampMetadataKey = "hashsha256-timestamp"
+	NonceMetadataKey     = "hashsha256-nonce"
+
+	// AuthorizationMetadataKey Ключ метаданных с ключом API, аналог заголовка Authorization: ключ или Bearer ключ
+	AuthorizationMetadataKey = "authorization"
+
+	// RealIPMetadataKey Ключ метаданных с IP-адресом агента, аналог заголовка X-Real-IP
+	RealIPMetadataKey = "x-real-ip"
+
+	// hashFieldName и payloadFieldName Поля сообщения потока: хэш и подписываемое им вложенное сообщение
+	hashFieldName    = "hash"
+	payloadFieldName = "request"
+)
+
+var (
+	ErrInvalidHash       = errors.New("invalid request hash")
+	ErrHashFieldNotFound = errors.New("message has no hash or request field")
+	ErrMissingHash       = errors.New("missing request hash")
+)
+
+// MessageHash Хэш детерминированно сериализованного сообщения
+func MessageHash(hashKey string, m proto.Message) (string, error) {
+	return RequestHash(hashKey, "", "", "", m)
+}
+
+// RequestHash Хэш сообщения вместе с меткой времени и nonce, как hashutil.SignedData для тела HTTP-запроса.
+// Вызов gRPC подписывается как POST на полное имя метода method
+func RequestHash(hashKey, method, timestamp, nonce string, m proto.Message) (string, error) {
+
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+
+	return hashutil.СomputeHexadecimalSha256Hash(hashKey, hashutil.SignedData(http.MethodPost, method, timestamp, nonce, data)), nil
+}
+
+// VerifyHash Сравнение хэша сообщения с полученным
+func VerifyHash(hashKey string, m proto.Message, hash string) error {
+	return VerifyRequestHash(hashKey, "", "", "", m, hash)
+}
+
+// VerifyRequestHash Сравнение хэша сообщения метода method с меткой времени и nonce с полученным
+func VerifyRequestHash(hashKey, method, timestamp, nonce string, m proto.Message, hash string) error {
+
+	expected, err := RequestHash(hashKey, method, timestamp, nonce, m)
+	if err != nil {
+		return err
+	}
+
+	if !hmac.Equal([]byte(expected), []byte(hash)) {
+		return ErrInvalidHash
+	}
+
+	return nil
+}
+
+// SignMessage Запись хэша поля request сообщения потока в его поле hash.
+// Метод, timestamp и nonce потока из метаданных входят в хэш, чтобы сообщение нельзя было перенести в другой поток
+func SignMessage(hashKey, method, timestamp, nonce string, m proto.Message) error {
+
+	field, payload := streamFields(m)
+	if field == nil {
+		return ErrHashFieldNotFound
+	}
+
+	hash, err := RequestHash(hashKey, method, timestamp, nonce, payload)
+	if err != nil {
+		return err
+	}
+
+	m.ProtoReflect().Set(field, protoreflect.ValueOfString(hash))
+	return nil
+}
+
+// VerifyMessage Проверка хэша из поля hash сообщения потока метода method с меткой времени и nonce потока.
+// Сообщение без хэша - ErrMissingHash
+func VerifyMessage(hashKey, method, timestamp, nonce string, m proto.Message) error {
+
+	field, payload := streamFields(m)
+	if field == nil {
+		return ErrHashFieldNotFound
+	}
+
+	hash := m.ProtoReflect().Get(field).String()
+	if hash == "" {
+		return ErrMissingHash
+	}
+
+	return VerifyRequestHash(hashKey, method, timestamp, nonce, payload, hash)
+}
+
+// streamFields Поле hash сообщения потока и значение поля request, которое оно подписывает.
+// Если полей нет, поле hash - nil
+func streamFields(m proto.Message) (protoreflect.FieldDescriptor, proto.Message) {
+
+	fields := m.ProtoReflect().Descriptor().Fields()
+
+	hash := fields.ByName(hashFieldName)
+	if hash == nil || hash.Kind() != protoreflect.StringKind || hash.IsList() {
+		return nil, nil
+	}
+
+	payload := fields.ByName(payloadFieldName)
+	if payload == nil || payload.Kind() != protoreflect.MessageKind || payload.IsList() || payload.IsMap() {
+		return nil, nil
+	}
+
+	return hash, m.ProtoReflect().Get(payload).Message().Interface()
+}
@@ -3,8 +3,20 @@ package hashutil
 
 import (
 	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// KeyIDHeader Заголовок с идентификатором ключа HashSHA256, которым подписан запрос или ответ
+const KeyIDHeader = "HashSHA256-Key-ID"
+
+// Заголовки защиты от повтора запроса, их значения входят в подписываемые данные
+const (
+	TimestampHeader = "HashSHA256-Timestamp"
+	NonceHeader     = "HashSHA256-Nonce"
 )
 
 type HashFunc func(secretKey string, data []byte) string
@@ -17,5 +29,38 @@ func СomputeHexadecimalSha256Hash(secretKey string, data []byte) string {
 
 func СomputeSha256Hash(secretKey string, data []byte) []byte {
 	h := hmac.New(sha256.New, []byte(secretKey))
-	return h.Sum(data)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// NewReplayValues Метка времени в секундах Unix и случайный nonce для заголовков TimestampHeader и NonceHeader
+func NewReplayValues(now time.Time) (timestamp, nonce string, err error) {
+
+	random := make([]byte, 16)
+	if _, err = rand.Read(random); err != nil {
+		return "", "", err
+	}
+
+	return strconv.FormatInt(now.Unix(), 10), hex.EncodeToString(random), nil
+}
+
+// SignedData Подписываемые данные: без метки времени и nonce - только тело, как раньше.
+// С меткой времени и nonce подписываются также метод и URI запроса (путь с параметрами),
+// чтобы подпись запроса без тела нельзя было перенести на другой запрос
+func SignedData(method, uri, timestamp, nonce string, body []byte) []byte {
+
+	if timestamp == "" && nonce == "" {
+		return body
+	}
+
+	data := make([]byte, 0, len(method)+len(uri)+len(timestamp)+len(nonce)+len(body)+4)
+	data = append(data, method...)
+	data = append(data, '\n')
+	data = append(data, uri...)
+	data = append(data, '\n')
+	data = append(data, timestamp...)
+	data = append(data, '\n')
+	data = append(data, nonce...)
+	data = append(data, '\n')
+	return append(data, body...)
 }
@@ -0,0 +1,51 @@
+// Package netutil Общие процедуры работы с IP-адресами
+package netutil
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+)
+
+// OutboundIP IP-адрес интерфейса, через который уходят запросы на rawURL.
+// UDP-соединение только выбирает маршрут, пакеты не отправляются
+func OutboundIP(rawURL string) (net.IP, error) {
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "80"
+	}
+
+	conn, err := net.Dial("udp", net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	addr, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return nil, fmt.Errorf("unexpected local address %s", conn.LocalAddr())
+	}
+
+	return addr.IP, nil
+}
+
+// ParseSubnet Подсеть в нотации CIDR, пустая строка - nil
+func ParseSubnet(cidr string) (*net.IPNet, error) {
+
+	if cidr == "" {
+		return nil, nil
+	}
+
+	_, subnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("incorrect subnet %q: %w", cidr, err)
+	}
+
+	return subnet, nil
+}
@@ -3,11 +3,14 @@ package rsautil
 
 import (
 	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/base64"
 	"encoding/pem"
 	"fmt"
 	"github.com/s-turchinskiy/metrics/internal/utils/errutil"
@@ -19,6 +22,12 @@ import (
 
 var label = []byte("OAEP Encrypted")
 
+// EncryptedKeyHeader Заголовок с ключом AES, зашифрованным публичным ключом RSA, в base64
+const EncryptedKeyHeader = "X-Encrypted-Key"
+
+// KeyIDHeader Заголовок с идентификатором ключа RSA, которым зашифрован запрос
+const KeyIDHeader = "X-Crypto-Key-ID"
+
 func ReadPublicKey(publicKeyPath string) (*rsa.PublicKey, error) {
 
 	pub, err := os.ReadFile(publicKeyPath)
@@ -64,6 +73,79 @@ func Encrypt(publicKey *rsa.PublicKey, message []byte) ([]byte, error) {
 	return ciphertext, nil
 }
 
+// EncryptHybrid Шифрование сообщения любого размера: сообщение шифруется AES-256-GCM случайным ключом,
+// ключ шифруется RSA-OAEP. Результат - nonce и шифротекст, зашифрованный ключ в base64 для EncryptedKeyHeader
+func EncryptHybrid(publicKey *rsa.PublicKey, message []byte) ([]byte, string, error) {
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, "", errutil.WrapError(err)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, "", errutil.WrapError(err)
+	}
+
+	wrappedKey, err := Encrypt(publicKey, key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return gcm.Seal(nonce, nonce, message, nil), base64.StdEncoding.EncodeToString(wrappedKey), nil
+}
+
+// DecryptHybrid Расшифровка сообщения EncryptHybrid
+func DecryptHybrid(privateKey *rsa.PrivateKey, wrappedKey string, message []byte) ([]byte, error) {
+
+	encryptedKey, err := base64.StdEncoding.DecodeString(wrappedKey)
+	if err != nil {
+		return nil, errutil.WrapError(fmt.Errorf("decode encrypted key: %w", err))
+	}
+
+	key, err := Decrypt(privateKey, encryptedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(message) < gcm.NonceSize() {
+		return nil, errutil.WrapError(fmt.Errorf("message is shorter than nonce"))
+	}
+
+	nonce, ciphertext := message[:gcm.NonceSize()], message[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, errutil.WrapError(err)
+	}
+
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errutil.WrapError(err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errutil.WrapError(err)
+	}
+
+	return gcm, nil
+}
+
 func ReadPrivateKey(privateKeyFile string) (*rsa.PrivateKey, error) {
 
 	priv, err := os.ReadFile(privateKeyFile)
@@ -73,6 +155,10 @@ func ReadPrivateKey(privateKeyFile string) (*rsa.PrivateKey, error) {
 	}
 
 	privPem, _ := pem.Decode(priv)
+	if privPem == nil {
+		err = fmt.Errorf("path: %s, error: no PEM data", privateKeyFile)
+		return nil, errutil.WrapError(err)
+	}
 	var privPemBytes []byte
 	if privPem.Type != "RSA PRIVATE KEY" {
 		err = fmt.Errorf("RSA private key is of the wrong type :%s", privPem.Type)
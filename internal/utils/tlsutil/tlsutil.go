@@ -0,0 +1,73 @@
+// Package tlsutil Настройки TLS для взаимной аутентификации агента и сервера
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/s-turchinskiy/metrics/internal/utils/errutil"
+)
+
+// ServerConfig Сервер предъявляет certPath/keyPath и требует сертификат клиента, подписанный CA из caPath
+func ServerConfig(caPath, certPath, keyPath string) (*tls.Config, error) {
+
+	pool, err := loadPool(caPath)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, errutil.WrapError(fmt.Errorf("cert: %s, key: %s, error: %w", certPath, keyPath, err))
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// ClientConfig Сертификат сервера проверяется только по CA из caPath, системные CA не используются.
+// Без certPath клиент не предъявляет сертификат
+func ClientConfig(caPath, certPath, keyPath string) (*tls.Config, error) {
+
+	pool, err := loadPool(caPath)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		RootCAs:    pool,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if certPath != "" {
+		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			return nil, errutil.WrapError(fmt.Errorf("cert: %s, key: %s, error: %w", certPath, keyPath, err))
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// loadPool Сертификаты CA из файла PEM, в файле может быть несколько сертификатов
+func loadPool(caPath string) (*x509.CertPool, error) {
+
+	data, err := os.ReadFile(caPath)
+	if err != nil {
+		return nil, errutil.WrapError(fmt.Errorf("path: %s, error: %w", caPath, err))
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errutil.WrapError(fmt.Errorf("path: %s, no certificates found", caPath))
+	}
+
+	return pool, nil
+}